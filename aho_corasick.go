@@ -0,0 +1,139 @@
+package onig
+
+import (
+	"sort"
+)
+
+// ahoCorasick is an Aho-Corasick automaton that finds which of a set of literal strings occur in a text
+// with a single pass over the text.
+type ahoCorasick struct {
+	// root holds the transitions of the root node, which are looked up for most bytes of a text.
+	root  [256]int32
+	nodes []acNode
+}
+
+// acNode is a node of the trie of the literals, with the links of the automaton.
+type acNode struct {
+	// keys and next are the transitions to the children of the node, sorted by byte.
+	keys []byte
+	next []int32
+	// fail is the node of the longest proper suffix of the node's string that is in the trie.
+	fail int32
+	// literals are the indices of the literals that end at the node.
+	literals []int32
+	// output is the nearest node on the chain of failure links that has literals, or -1.
+	output int32
+}
+
+// newAhoCorasick builds the automaton of literals. Empty literals are ignored.
+func newAhoCorasick(literals []string) *ahoCorasick {
+	automaton := &ahoCorasick{nodes: []acNode{{output: -1}}}
+	for i, literal := range literals {
+		if literal == "" {
+			continue
+		}
+		node := int32(0)
+		for j := 0; j < len(literal); j++ {
+			child := automaton.child(node, literal[j])
+			if child < 0 {
+				child = automaton.addChild(node, literal[j])
+			}
+			node = child
+		}
+		automaton.nodes[node].literals = append(automaton.nodes[node].literals, int32(i))
+	}
+	automaton.link()
+	for c := range automaton.root {
+		automaton.root[c] = max(automaton.child(0, byte(c)), 0)
+	}
+	return automaton
+}
+
+// child returns the child of node for the byte c, or -1 if there is none.
+func (a *ahoCorasick) child(node int32, c byte) int32 {
+	keys := a.nodes[node].keys
+	i := sort.Search(len(keys), func(i int) bool { return keys[i] >= c })
+	if i < len(keys) && keys[i] == c {
+		return a.nodes[node].next[i]
+	}
+	return -1
+}
+
+// addChild adds a child to node for the byte c, keeping the transitions sorted, and returns it.
+func (a *ahoCorasick) addChild(node int32, c byte) int32 {
+	child := int32(len(a.nodes))
+	a.nodes = append(a.nodes, acNode{output: -1})
+	parent := &a.nodes[node]
+	i := sort.Search(len(parent.keys), func(i int) bool { return parent.keys[i] >= c })
+	parent.keys = append(parent.keys, 0)
+	copy(parent.keys[i+1:], parent.keys[i:])
+	parent.keys[i] = c
+	parent.next = append(parent.next, 0)
+	copy(parent.next[i+1:], parent.next[i:])
+	parent.next[i] = child
+	return child
+}
+
+// link sets the failure and output links of the nodes, in breadth-first order so that the links of
+// the shorter strings are set first.
+func (a *ahoCorasick) link() {
+	queue := make([]int32, 0, len(a.nodes))
+	for _, child := range a.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for i, c := range a.nodes[node].keys {
+			child := a.nodes[node].next[i]
+			fail := a.nodes[node].fail
+			for {
+				if next := a.child(fail, c); next >= 0 {
+					a.nodes[child].fail = next
+					break
+				}
+				if fail == 0 {
+					break
+				}
+				fail = a.nodes[fail].fail
+			}
+			failNode := &a.nodes[a.nodes[child].fail]
+			if len(failNode.literals) > 0 {
+				a.nodes[child].output = a.nodes[child].fail
+			} else {
+				a.nodes[child].output = failNode.output
+			}
+			queue = append(queue, child)
+		}
+	}
+}
+
+// step returns the node the automaton moves to from node when reading the byte c.
+func (a *ahoCorasick) step(node int32, c byte) int32 {
+	for node != 0 {
+		if next := a.child(node, c); next >= 0 {
+			return next
+		}
+		node = a.nodes[node].fail
+	}
+	return a.root[c]
+}
+
+// find calls found once with the index of each literal that occurs in text.
+func (a *ahoCorasick) find(text string, found func(literal int)) {
+	// reported marks the nodes whose literals were passed to found, which is also true of their output chain.
+	reported := make(map[int32]struct{})
+	node := int32(0)
+	for i := 0; i < len(text); i++ {
+		node = a.step(node, text[i])
+		for output := node; output > 0; output = a.nodes[output].output {
+			if _, ok := reported[output]; ok {
+				break
+			}
+			reported[output] = struct{}{}
+			for _, literal := range a.nodes[output].literals {
+				found(int(literal))
+			}
+		}
+	}
+}
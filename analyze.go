@@ -0,0 +1,921 @@
+package onig
+
+/*
+#include "regex.h"
+*/
+import "C"
+import (
+	"fmt"
+	"github.com/tmikus/onig-go/v2/syntax/ast"
+	"runtime"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// AttackRetryLimit is the retry limit in match that searching the attack string of a verified Finding exceeds.
+// Searching a text of a few hundred characters with a pattern that doesn't backtrack catastrophically
+// stays far below it.
+const AttackRetryLimit = 100000
+
+// errRetryLimitInMatch is the message of the error of a search that exceeds its retry limit in match.
+var errRetryLimitInMatch = errorFromCode(C.ONIGERR_RETRY_LIMIT_IN_MATCH_OVER).Error()
+
+// Severity is how the time to search an attack string grows with its length.
+type Severity int
+
+const (
+	// SeverityPolynomial is a time that grows polynomially, as with a bounded repetition such as (a+){2,5}.
+	SeverityPolynomial Severity = iota
+	// SeverityExponential is a time that grows exponentially, as with an unbounded repetition such as (a+)+.
+	SeverityExponential
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityPolynomial:
+		return "polynomial"
+	case SeverityExponential:
+		return "exponential"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// FindingKind is the kind of construct that makes a pattern backtrack catastrophically.
+type FindingKind int
+
+const (
+	// FindingNestedQuantifier is a repetition of an expression with a quantifier over overlapping languages,
+	// such as (a+)+ or (\w+\d*)*.
+	FindingNestedQuantifier FindingKind = iota
+	// FindingAmbiguousAlternation is a repetition of an alternation whose alternatives can match the same text,
+	// such as (a|a)* or (\w|\d)+.
+	FindingAmbiguousAlternation
+	// FindingExponentialBackref is a repetition of a back reference to a group captured in each repetition,
+	// such as (?:(a+)\1)+.
+	FindingExponentialBackref
+)
+
+// String returns a description of the kind of finding.
+func (k FindingKind) String() string {
+	switch k {
+	case FindingNestedQuantifier:
+		return "nested quantifier"
+	case FindingAmbiguousAlternation:
+		return "ambiguous alternation"
+	case FindingExponentialBackref:
+		return "exponential backreference"
+	}
+	return fmt.Sprintf("FindingKind(%d)", int(k))
+}
+
+// Finding is a repetition of a pattern that can make a search backtrack catastrophically.
+type Finding struct {
+	Kind     FindingKind
+	Severity Severity
+	// Span is the repetition, in byte indices of the pattern.
+	Span Range
+	// Attack is a text on which a search for the pattern backtracks catastrophically.
+	Attack string
+	// Verified is set when searching Attack with the compiled pattern exceeded AttackRetryLimit, as checked by
+	// VerifyAttack.
+	Verified bool
+}
+
+// Report is the result of Analyze.
+type Report struct {
+	// Findings are the repetitions that can backtrack catastrophically, in the order of the pattern.
+	Findings []Finding
+	// Err is set when the pattern couldn't be analyzed.
+	Err error
+}
+
+// Safe reports whether the pattern was analyzed without findings.
+func (r Report) Safe() bool {
+	return r.Err == nil && len(r.Findings) == 0
+}
+
+// Analyze looks for the repetitions of a pattern that make Oniguruma backtrack catastrophically: nested quantifiers
+// over overlapping languages such as (a+)+, alternations under a repetition whose alternatives match the same text
+// such as (a|a)*, and repetitions of back references to groups captured in each repetition such as (?:(a+)\1)+.
+// A nil syntax means the default syntax.
+//
+// A repetition is reported when a text, its pump, can be matched by repeating its expression in two different ways,
+// since the pump repeated n times can then be matched in 2^n ways, or polynomially many for a bounded repetition.
+// Pumps are looked for among short texts made of the characters of the pattern and of a few common characters.
+// The attack string of a finding is the pump repeated between a prefix that reaches the repetition and a suffix that
+// makes the match fail, and it is verified by searching it with the compiled pattern and AttackRetryLimit.
+// Findings whose attack can't be verified, because Oniguruma optimizes the search or the suffix doesn't make the
+// match fail, are reported unverified.
+//
+// Report.Err is set when the pattern doesn't compile, or when its syntax isn't supported by the syntax/ast package.
+func Analyze(pattern string, syntax *Syntax) Report {
+	if syntax == nil {
+		syntax = DefaultSyntax()
+	}
+	regex, err := CompileWithSyntax(pattern, syntax)
+	if err != nil {
+		return Report{Err: err}
+	}
+	flavor := astFlavor(syntax)
+	if flavor == nil {
+		return Report{Err: fmt.Errorf("%w: %s", ast.ErrUnsupportedFlavor, syntax.Name())}
+	}
+	tree, err := ast.Parse(pattern, flavor)
+	if err != nil {
+		return Report{Err: err}
+	}
+	return Report{Findings: newAnalyzer(pattern, tree, regex).findings()}
+}
+
+// VerifyAttack reports whether searching attack with regex exceeds AttackRetryLimit retries in a match.
+func VerifyAttack(regex *Regex, attack string) bool {
+	_, err := regex.SearchFirstWithParam(attack, 0, uint(len(attack)), REGEX_OPTION_NONE, 0, AttackRetryLimit)
+	return err != nil && err.Error() == errRetryLimitInMatch
+}
+
+// astFlavor returns the flavor of the syntax/ast package for a syntax, or nil if the package doesn't parse it.
+func astFlavor(syntax *Syntax) *ast.Flavor {
+	switch syntax.raw {
+	case SyntaxRuby.raw:
+		return ast.FlavorRuby
+	case SyntaxOniguruma.raw:
+		return ast.FlavorOniguruma
+	case SyntaxPerl.raw:
+		return ast.FlavorPerl
+	case SyntaxPerlNG.raw:
+		return ast.FlavorPerlNG
+	case SyntaxPython.raw:
+		return ast.FlavorPython
+	case SyntaxJava.raw:
+		return ast.FlavorJava
+	case SyntaxPosixExtended.raw:
+		return ast.FlavorPosixExtended
+	case SyntaxGnuRegex.raw:
+		return ast.FlavorGnuRegex
+	}
+	return nil
+}
+
+// commonChars are the characters that pumps are made of besides the literal characters of the pattern, and that
+// attack strings end with to make a match fail. Pumps are made of the first characters that match when possible.
+const commonChars = "aA0_ -.,/Zé!\t\n"
+
+// pumpLength is the length of the texts that a repetition is matched against when looking for a pump.
+const pumpLength = 6
+
+// maxPairChars is the number of characters whose pairs are tried as pumps.
+const maxPairChars = 8
+
+// maxPumps is the largest number of times a pump is repeated in an attack string.
+const maxPumps = 1024
+
+// maxPumpedLength is the largest length of the repeated pumps of an attack string. Matching longer texts may
+// take long without exceeding the retry limit, as possessive repetitions and look-arounds don't count as retries.
+const maxPumpedLength = 1024
+
+// analyzer looks for the repetitions of a parsed pattern that backtrack catastrophically.
+type analyzer struct {
+	pattern string
+	regex   *Regex
+	root    ast.Node
+	// groups are the capturing groups by number.
+	groups map[int]*ast.Group
+	// alphabet are the characters that pumps are made of.
+	alphabet []rune
+	// classes are the regexes that match a character class or a property of the pattern alone, or nil for those
+	// that don't compile alone.
+	classes map[ast.Node]*Regex
+	// chars caches whether the nodes that match a single character match a character.
+	chars map[charMatch]bool
+	// visiting are the groups being expanded for a back reference or a call, which aren't expanded again inside.
+	visiting map[*ast.Group]bool
+}
+
+// charMatch is a node that matches a single character, and a character.
+type charMatch struct {
+	node ast.Node
+	ch   rune
+}
+
+func newAnalyzer(pattern string, tree *ast.Tree, regex *Regex) *analyzer {
+	a := &analyzer{
+		pattern:  pattern,
+		regex:    regex,
+		root:     tree.Root,
+		groups:   map[int]*ast.Group{},
+		classes:  map[ast.Node]*Regex{},
+		chars:    map[charMatch]bool{},
+		visiting: map[*ast.Group]bool{},
+	}
+	seen := map[rune]bool{}
+	addChar := func(ch rune) {
+		if !seen[ch] && utf8.ValidRune(ch) {
+			seen[ch] = true
+			a.alphabet = append(a.alphabet, ch)
+		}
+	}
+	for _, ch := range commonChars {
+		addChar(ch)
+	}
+	ast.Walk(tree.Root, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Group:
+			if n.Index > 0 {
+				a.groups[n.Index] = n
+			}
+		case *ast.Literal:
+			if !n.Byte || n.Char < utf8.RuneSelf {
+				addChar(n.Char)
+				for r := unicode.SimpleFold(n.Char); n.IgnoreCase && r != n.Char; r = unicode.SimpleFold(r) {
+					addChar(r)
+				}
+			}
+		}
+		return true
+	})
+	return a
+}
+
+// findings returns the repetitions that backtrack catastrophically. Only the innermost of nested repetitions that
+// do is reported.
+func (a *analyzer) findings() []Finding {
+	quantifiers := repetitions(a.root, true, nil)
+	var findings []Finding
+	// The quantifiers are visited in reverse, so that the ones inside a repetition come before it.
+	for i := len(quantifiers) - 1; i >= 0; i-- {
+		q := quantifiers[i]
+		span := q.Pos()
+		if slices.ContainsFunc(findings, func(f Finding) bool { return span.Start <= f.Span.From && f.Span.To <= span.End }) {
+			continue
+		}
+		if finding, ok := a.analyzeRepetition(q); ok {
+			findings = append(findings, finding)
+		}
+	}
+	slices.SortStableFunc(findings, func(x, y Finding) int { return x.Span.From - y.Span.From })
+	return findings
+}
+
+// repetitions appends the repetitions of node to quantifiers, in the order of the pattern, except for those after
+// which nothing can make a match fail, so that the match never backtracks into them. tail is set when nothing after
+// node can, up to the end of the pattern or of an atomic group or a look-around, which are never backtracked into
+// once they have matched.
+func repetitions(node ast.Node, tail bool, quantifiers []*ast.Quantifier) []*ast.Quantifier {
+	switch n := node.(type) {
+	case *ast.Concat:
+		for i, item := range n.Items {
+			quantifiers = repetitions(item, tail && !slices.ContainsFunc(n.Items[i+1:], mayFail), quantifiers)
+		}
+	case *ast.Alternation:
+		for _, alternative := range n.Alternatives {
+			quantifiers = repetitions(alternative, tail, quantifiers)
+		}
+	case *ast.Group:
+		switch n.Kind {
+		case ast.GroupCapture, ast.GroupNonCapture, ast.GroupOptions:
+			quantifiers = repetitions(n.Sub, tail, quantifiers)
+		default:
+			quantifiers = repetitions(n.Sub, true, quantifiers)
+		}
+	case *ast.Flags:
+		quantifiers = repetitions(n.Sub, tail, quantifiers)
+	case *ast.Quantifier:
+		if !tail {
+			quantifiers = append(quantifiers, n)
+		}
+		quantifiers = repetitions(n.Sub, tail || n.Kind == ast.Possessive, quantifiers)
+	case *ast.Conditional:
+		quantifiers = repetitions(n.Cond, true, quantifiers)
+		quantifiers = repetitions(n.Yes, tail, quantifiers)
+		quantifiers = repetitions(n.No, tail, quantifiers)
+	case *ast.Absent:
+		quantifiers = repetitions(n.Absent, false, quantifiers)
+		quantifiers = repetitions(n.Expr, false, quantifiers)
+	}
+	return quantifiers
+}
+
+// mayFail reports whether a node can fail to match at some position.
+func mayFail(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.Concat:
+		return slices.ContainsFunc(n.Items, mayFail)
+	case *ast.Alternation:
+		return !slices.ContainsFunc(n.Alternatives, func(alternative ast.Node) bool { return !mayFail(alternative) })
+	case *ast.Group:
+		switch n.Kind {
+		case ast.GroupNegativeLookahead, ast.GroupNegativeLookbehind:
+			return true
+		}
+		return mayFail(n.Sub)
+	case *ast.Flags:
+		return mayFail(n.Sub)
+	case *ast.Quantifier:
+		return n.Min > 0 && mayFail(n.Sub)
+	case *ast.Keep:
+		return false
+	case *ast.Callout:
+		// Callouts of contents never fail in this package, unlike callouts by name such as (*FAIL) or (*MAX{2}).
+		return n.Name != ""
+	case *ast.Absent:
+		return n.Kind != ast.AbsentStopper && n.Kind != ast.AbsentClear
+	}
+	return true
+}
+
+// analyzeRepetition looks for a pump of a repetition, and returns the finding it makes.
+func (a *analyzer) analyzeRepetition(q *ast.Quantifier) (Finding, bool) {
+	upper, ok := compiledUpper(q)
+	if !ok || q.Kind == ast.Possessive || !mayBeAmbiguous(q.Sub) {
+		return Finding{}, false
+	}
+	severity := SeverityExponential
+	if upper >= 0 {
+		// A bounded repetition of an expression of bounded length can only match a text in a bounded number of ways.
+		if upper < 2 || !hasUnboundedQuantifier(q.Sub) {
+			return Finding{}, false
+		}
+		severity = SeverityPolynomial
+	}
+	body := q.Sub.Pos()
+	pump, ok := a.pump(q.Sub, ambiguityMode{body: body})
+	if !ok {
+		return Finding{}, false
+	}
+	kind := FindingNestedQuantifier
+	switch {
+	case !a.ambiguous(q.Sub, pump, ambiguityMode{body: body, failBackrefs: true}):
+		kind = FindingExponentialBackref
+	case !hasVariableQuantifier(q.Sub) || !a.ambiguous(q.Sub, pump, ambiguityMode{body: body, unionAlternatives: true}):
+		kind = FindingAmbiguousAlternation
+	}
+	attack, verified := a.attack(q, pump, upper)
+	return Finding{
+		Kind:     kind,
+		Severity: severity,
+		Span:     Range{From: q.Pos().Start, To: q.Pos().End},
+		Attack:   attack,
+		Verified: verified,
+	}, true
+}
+
+// compiledUpper returns the upper bound that Oniguruma compiles a repetition with, and false when Oniguruma merges it
+// with the repetition it repeats. A repetition of ?, * or + that directly repeats another one, such as (?:a+)*,
+// becomes a single repetition, and a bounded greedy repetition of * or + is bounded by its lower bound instead,
+// or by 1 when it's 0, as set_quantifier does.
+func compiledUpper(q *ast.Quantifier) (int, bool) {
+	sub := q.Sub
+	for {
+		group, ok := sub.(*ast.Group)
+		if !ok || group.Kind != ast.GroupNonCapture {
+			break
+		}
+		sub = group.Sub
+	}
+	inner, ok := sub.(*ast.Quantifier)
+	switch {
+	case !ok || !isSimpleQuantifier(inner):
+		return q.Max, true
+	case isSimpleQuantifier(q):
+		return 0, false
+	case inner.Kind == ast.Greedy && inner.Max < 0 && q.Kind == ast.Greedy && q.Max > 1:
+		return max(q.Min, 1), true
+	}
+	return q.Max, true
+}
+
+// isSimpleQuantifier reports whether a quantifier is ?, * or +, greedy or lazy, or an interval with the same bounds.
+func isSimpleQuantifier(q *ast.Quantifier) bool {
+	return q.Kind != ast.Possessive && (q.Min == 0 && (q.Max == 1 || q.Max < 0) || q.Min == 1 && q.Max < 0)
+}
+
+// mayBeAmbiguous reports whether a repetition of node can match a text in several ways: node then has an
+// alternation, a repetition of variable length or a back reference. Otherwise it matches texts of a fixed
+// length only, which can be repeated in one way.
+func mayBeAmbiguous(node ast.Node) bool {
+	found := false
+	ast.Walk(node, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Alternation, *ast.Backref, *ast.Conditional:
+			found = true
+		case *ast.Quantifier:
+			found = found || n.Min != n.Max
+		}
+		return !found
+	})
+	return found
+}
+
+// hasUnboundedQuantifier reports whether node has a repetition without upper bound.
+func hasUnboundedQuantifier(node ast.Node) bool {
+	found := false
+	ast.Walk(node, func(node ast.Node) bool {
+		if q, ok := node.(*ast.Quantifier); ok && q.Max < 0 {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// hasVariableQuantifier reports whether node has a repetition that can backtrack over a variable number of
+// repetitions.
+func hasVariableQuantifier(node ast.Node) bool {
+	found := false
+	ast.Walk(node, func(node ast.Node) bool {
+		if q, ok := node.(*ast.Quantifier); ok && q.Min != q.Max && q.Kind != ast.Possessive {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// pump returns the shortest text, made of a character of the alphabet or of a pair of them repeated, that a
+// repetition of node matches in several ways.
+func (a *analyzer) pump(node ast.Node, mode ambiguityMode) (string, bool) {
+	var chars []rune
+	for _, ch := range a.alphabet {
+		if a.mayMatchChar(node, ch) {
+			chars = append(chars, ch)
+		}
+	}
+	words := make([]string, 0, len(chars)*len(chars))
+	for _, ch := range chars {
+		words = append(words, string(ch))
+	}
+	for i, x := range chars[:min(len(chars), maxPairChars)] {
+		for j, y := range chars[:min(len(chars), maxPairChars)] {
+			if i != j {
+				words = append(words, string([]rune{x, y}))
+			}
+		}
+	}
+	for _, word := range words {
+		text := []rune(strings.Repeat(word, pumpLength/utf8.RuneCountInString(word)))
+		repeated := a.ways(node, text, mode).repeat(1, -1)
+		for end := utf8.RuneCountInString(word); end < len(repeated); end += utf8.RuneCountInString(word) {
+			if repeated[0][end] >= manyWays {
+				return string(text[:end]), true
+			}
+		}
+	}
+	return "", false
+}
+
+// ambiguous reports whether a repetition of node matches pump in several ways.
+func (a *analyzer) ambiguous(node ast.Node, pump string, mode ambiguityMode) bool {
+	text := []rune(pump)
+	return a.ways(node, text, mode).repeat(1, -1)[0][len(text)] >= manyWays
+}
+
+// mayMatchChar reports whether a node has a part that matches ch.
+func (a *analyzer) mayMatchChar(node ast.Node, ch rune) bool {
+	found := false
+	ast.Walk(node, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Literal, *ast.AnyChar, *ast.CharType, *ast.Class, *ast.Property, *ast.TextSegment, *ast.GeneralNewline:
+			found = found || a.matchesChar(node, ch)
+			return false
+		case *ast.Backref:
+			for _, index := range n.Groups {
+				if group := a.groups[index]; group != nil && !a.visiting[group] {
+					a.visiting[group] = true
+					found = found || a.mayMatchChar(group.Sub, ch)
+					delete(a.visiting, group)
+				}
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// matchesChar reports whether a node that matches a single character matches ch.
+func (a *analyzer) matchesChar(node ast.Node, ch rune) bool {
+	key := charMatch{node, ch}
+	if matches, ok := a.chars[key]; ok {
+		return matches
+	}
+	var matches bool
+	switch n := node.(type) {
+	case *ast.Literal:
+		if n.Byte && n.Char >= utf8.RuneSelf {
+			break
+		}
+		matches = n.Char == ch
+		for r := unicode.SimpleFold(n.Char); n.IgnoreCase && !matches && r != n.Char; r = unicode.SimpleFold(r) {
+			matches = r == ch
+		}
+	case *ast.AnyChar:
+		matches = ch != '\n' || n.Kind == ast.AnyCharTrue || n.Kind == ast.AnyCharDot && n.Multiline
+	case *ast.CharType:
+		matches = charTypeMatches(n, ch) != n.Negated
+	case *ast.TextSegment:
+		matches = true
+	case *ast.GeneralNewline:
+		matches = strings.ContainsRune("\n\v\f\r\u0085\u2028\u2029", ch)
+	case *ast.Class, *ast.Property:
+		matches = a.classMatches(node, ch)
+	}
+	a.chars[key] = matches
+	return matches
+}
+
+// charTypeMatches reports whether a character type such as \w matches ch, ignoring its negation.
+func charTypeMatches(n *ast.CharType, ch rune) bool {
+	if n.ASCII && ch >= utf8.RuneSelf {
+		return false
+	}
+	switch n.Kind {
+	case ast.CharTypeWord:
+		return ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch) || unicode.IsMark(ch) ||
+			unicode.Is(unicode.Pc, ch)
+	case ast.CharTypeDigit:
+		return unicode.IsDigit(ch)
+	case ast.CharTypeSpace:
+		return unicode.IsSpace(ch)
+	case ast.CharTypeHexDigit:
+		return '0' <= ch && ch <= '9' || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+	}
+	return false
+}
+
+// classMatches reports whether a character class or a property matches ch, by searching ch with the class or the
+// property compiled alone.
+func (a *analyzer) classMatches(node ast.Node, ch rune) bool {
+	regex, ok := a.classes[node]
+	if !ok {
+		span := node.Pos()
+		pattern := a.pattern[span.Start:span.End]
+		if class, ok := node.(*ast.Class); ok && class.IgnoreCase {
+			pattern = "(?i:" + pattern + ")"
+		}
+		regex, _ = CompileWithSyntax(pattern, a.regex.syntax)
+		a.classes[node] = regex
+	}
+	if regex == nil {
+		return false
+	}
+	text := string(ch)
+	match, err := regex.FindMatch(text)
+	return err == nil && match != nil && match.From == 0 && match.To == len(text)
+}
+
+// ambiguityMode changes how the ways a node matches a text are counted.
+type ambiguityMode struct {
+	// body is the expression of the repetition being analyzed. The back references to the groups inside it match
+	// like their group, since the group is captured again in each repetition, and the others match a fixed text.
+	body ast.Span
+	// failBackrefs makes the back references to the groups inside body fail.
+	failBackrefs bool
+	// unionAlternatives counts a match of several alternatives of an alternation as a single way.
+	unionAlternatives bool
+}
+
+// manyWays is the number of ways from which a node is ambiguous. Counts of ways saturate at it.
+const manyWays = 2
+
+// ways counts, for each start and end position in a text, the ways in which a node matches the text between them,
+// up to manyWays.
+type ways [][]uint8
+
+// noWays returns the ways of a node that doesn't match a text of length n.
+func noWays(n int) ways {
+	w := make(ways, n+1)
+	for i := range w {
+		w[i] = make([]uint8, n+1)
+	}
+	return w
+}
+
+// emptyWays returns the ways of a node that matches the empty string only, for a text of length n.
+func emptyWays(n int) ways {
+	w := noWays(n)
+	for i := range w {
+		w[i][i] = 1
+	}
+	return w
+}
+
+// add returns the ways of matching either w or other.
+func (w ways) add(other ways) ways {
+	sum := noWays(len(w) - 1)
+	for i := range w {
+		for j := range w[i] {
+			sum[i][j] = min(w[i][j]+other[i][j], manyWays)
+		}
+	}
+	return sum
+}
+
+// union returns the ways of matching w or other, counting the texts matched by both once.
+func (w ways) union(other ways) ways {
+	union := noWays(len(w) - 1)
+	for i := range w {
+		for j := range w[i] {
+			union[i][j] = max(w[i][j], other[i][j])
+		}
+	}
+	return union
+}
+
+// then returns the ways of matching w followed by other.
+func (w ways) then(other ways) ways {
+	product := noWays(len(w) - 1)
+	for i := range w {
+		for k := range w {
+			if w[i][k] == 0 {
+				continue
+			}
+			for j := range w {
+				product[i][j] = min(product[i][j]+w[i][k]*other[k][j], manyWays)
+			}
+		}
+	}
+	return product
+}
+
+// repeat returns the ways of repeating w between lower and upper times, or at least lower times if upper is
+// negative.
+// As in Oniguruma, a repetition stops when it matches the empty string, which fills the remaining required
+// repetitions.
+func (w ways) repeat(lower, upper int) ways {
+	step := noWays(len(w) - 1)
+	nullable := false
+	for i := range w {
+		copy(step[i], w[i])
+		nullable = nullable || step[i][i] > 0
+		step[i][i] = 0
+	}
+	result := noWays(len(w) - 1)
+	power := emptyWays(len(w) - 1)
+	for count := 0; count < len(w) && (upper < 0 || count <= upper); count++ {
+		if count >= lower || nullable {
+			result = result.add(power)
+		}
+		power = power.then(step)
+	}
+	return result
+}
+
+// longest returns the ways of an atomic match of w, which keeps the longest match from each position.
+func (w ways) longest() ways {
+	atomic := noWays(len(w) - 1)
+	for i := range w {
+		for j := len(w) - 1; j >= i; j-- {
+			if w[i][j] > 0 {
+				atomic[i][j] = 1
+				break
+			}
+		}
+	}
+	return atomic
+}
+
+// ways counts the ways in which a node matches each part of a text. Look-arounds and anchors are assumed to
+// succeed, and calls and absent expressions not to match.
+func (a *analyzer) ways(node ast.Node, text []rune, mode ambiguityMode) ways {
+	switch n := node.(type) {
+	case nil:
+		return emptyWays(len(text))
+	case *ast.Literal, *ast.AnyChar, *ast.CharType, *ast.Class, *ast.Property, *ast.TextSegment, *ast.GeneralNewline:
+		w := noWays(len(text))
+		for i, ch := range text {
+			if a.matchesChar(node, ch) {
+				w[i][i+1] = 1
+			}
+		}
+		if _, ok := node.(*ast.GeneralNewline); ok {
+			for i := 0; i+1 < len(text); i++ {
+				if text[i] == '\r' && text[i+1] == '\n' {
+					w[i][i+1], w[i][i+2] = 0, 1
+				}
+			}
+		}
+		return w
+	case *ast.Concat:
+		w := emptyWays(len(text))
+		for _, item := range n.Items {
+			w = w.then(a.ways(item, text, mode))
+		}
+		return w
+	case *ast.Alternation:
+		w := noWays(len(text))
+		for _, alternative := range n.Alternatives {
+			if mode.unionAlternatives {
+				w = w.union(a.ways(alternative, text, mode))
+			} else {
+				w = w.add(a.ways(alternative, text, mode))
+			}
+		}
+		return w
+	case *ast.Group:
+		switch n.Kind {
+		case ast.GroupLookahead, ast.GroupNegativeLookahead, ast.GroupLookbehind, ast.GroupNegativeLookbehind:
+			return emptyWays(len(text))
+		case ast.GroupAtomic:
+			return a.ways(n.Sub, text, mode).longest()
+		}
+		return a.ways(n.Sub, text, mode)
+	case *ast.Flags:
+		return a.ways(n.Sub, text, mode)
+	case *ast.Quantifier:
+		w := a.ways(n.Sub, text, mode).repeat(n.Min, n.Max)
+		if n.Kind == ast.Possessive {
+			return w.longest()
+		}
+		return w
+	case *ast.Backref:
+		w := noWays(len(text))
+		for _, index := range n.Groups {
+			group := a.groups[index]
+			if group == nil || a.visiting[group] {
+				continue
+			}
+			a.visiting[group] = true
+			groupWays := a.ways(group.Sub, text, mode)
+			delete(a.visiting, group)
+			switch span := group.Pos(); {
+			case span.Start < mode.body.Start || span.End > mode.body.End:
+				w = w.add(groupWays.longest())
+			case !mode.failBackrefs:
+				w = w.add(groupWays)
+			}
+		}
+		return w
+	case *ast.Conditional:
+		return a.ways(n.Yes, text, mode).add(a.ways(n.No, text, mode))
+	case *ast.Callout:
+		if n.Name == "FAIL" {
+			return noWays(len(text))
+		}
+	case *ast.Call:
+		return noWays(len(text))
+	case *ast.Absent:
+		if n.Kind == ast.AbsentRepeater || n.Kind == ast.AbsentExpression {
+			return noWays(len(text))
+		}
+	}
+	return emptyWays(len(text))
+}
+
+// attack returns a text on which a repetition with the given pump backtracks catastrophically, and whether
+// searching it exceeded AttackRetryLimit. The suffixes that end the text are tried in turn until one makes the
+// search fail, along with a sample match of the whole pattern for searches that look for a required literal first.
+func (a *analyzer) attack(q *ast.Quantifier, pump string, upper int) (string, bool) {
+	prefix, _ := a.prefix(a.root, q)
+	pumped := prefix + strings.Repeat(pump, max(min(pumpCount(upper), maxPumpedLength/len(pump)), 1))
+	sample := a.sample(a.root)
+	suffixes := []string{""}
+	for _, ch := range a.alphabet {
+		suffixes = append(suffixes, string(ch), string(ch)+sample)
+	}
+	for _, suffix := range suffixes {
+		// Only the match at the start of the text is tried before the whole search, as the searches of the
+		// suffixes that don't make the match fail may take long without exceeding the limit at any position.
+		if a.exceedsAtStart(pumped+suffix) && VerifyAttack(a.regex, pumped+suffix) {
+			return pumped + suffix, true
+		}
+	}
+	return pumped + "!", false
+}
+
+// exceedsAtStart reports whether matching text at its start exceeds AttackRetryLimit retries.
+func (a *analyzer) exceedsAtStart(text string) bool {
+	cText := C.CString(text)
+	defer C.free(unsafe.Pointer(cText))
+	result := C.matchAtWithParam(a.regex.raw, cText, C.uint(len(text)), 0, C.uint(REGEX_OPTION_NONE), AttackRetryLimit)
+	runtime.KeepAlive(a.regex)
+	return result == C.ONIGERR_RETRY_LIMIT_IN_MATCH_OVER
+}
+
+// pumpCount returns the number of times a pump is repeated in an attack string of a repetition of at most upper
+// iterations, or without bound if upper is negative. The number of retries of a failing match grows with the
+// number of ways of splitting the pumps into iterations, with one more iteration for the one that fails.
+func pumpCount(upper int) int {
+	for n := 1; n < maxPumps; n++ {
+		// The ways of splitting n pumps into at most upper+1 iterations, as the sum of the binomials C(n-1, k).
+		splits, binomial := 0.0, 1.0
+		for k := 0; k < n && (upper < 0 || k <= upper); k++ {
+			splits += binomial
+			binomial = binomial * float64(n-1-k) / float64(k+1)
+		}
+		if splits >= 4*AttackRetryLimit {
+			return n
+		}
+	}
+	return maxPumps
+}
+
+// prefix returns a text that a match of node goes through before reaching target, and whether target is in node.
+func (a *analyzer) prefix(node, target ast.Node) (string, bool) {
+	if node == target {
+		return "", true
+	}
+	switch n := node.(type) {
+	case *ast.Concat:
+		var sb strings.Builder
+		for _, item := range n.Items {
+			if prefix, ok := a.prefix(item, target); ok {
+				return sb.String() + prefix, true
+			}
+			sb.WriteString(a.sample(item))
+		}
+	case *ast.Alternation:
+		for _, alternative := range n.Alternatives {
+			if prefix, ok := a.prefix(alternative, target); ok {
+				return prefix, true
+			}
+		}
+	case *ast.Group:
+		return a.prefix(n.Sub, target)
+	case *ast.Flags:
+		return a.prefix(n.Sub, target)
+	case *ast.Quantifier:
+		return a.prefix(n.Sub, target)
+	case *ast.Conditional:
+		for _, branch := range []ast.Node{n.Yes, n.No} {
+			if prefix, ok := a.prefix(branch, target); ok {
+				return prefix, true
+			}
+		}
+	case *ast.Absent:
+		return a.prefix(n.Expr, target)
+	}
+	return "", false
+}
+
+// sample returns a short text that a node matches.
+func (a *analyzer) sample(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.Literal:
+		if n.Byte && n.Char >= utf8.RuneSelf {
+			return string([]byte{byte(n.Char)})
+		}
+		return string(n.Char)
+	case *ast.AnyChar, *ast.CharType, *ast.Class, *ast.Property, *ast.TextSegment, *ast.GeneralNewline:
+		for _, ch := range a.alphabet {
+			if a.matchesChar(node, ch) {
+				return string(ch)
+			}
+		}
+	case *ast.Concat:
+		var sb strings.Builder
+		for _, item := range n.Items {
+			sb.WriteString(a.sample(item))
+		}
+		return sb.String()
+	case *ast.Alternation:
+		var shortest string
+		for i, alternative := range n.Alternatives {
+			if sample := a.sample(alternative); i == 0 || len(sample) < len(shortest) {
+				shortest = sample
+			}
+		}
+		return shortest
+	case *ast.Group:
+		switch n.Kind {
+		case ast.GroupLookahead, ast.GroupNegativeLookahead, ast.GroupLookbehind, ast.GroupNegativeLookbehind:
+			return ""
+		}
+		return a.sample(n.Sub)
+	case *ast.Flags:
+		return a.sample(n.Sub)
+	case *ast.Quantifier:
+		return strings.Repeat(a.sample(n.Sub), min(n.Min, maxPumps))
+	case *ast.Backref:
+		if len(n.Groups) > 0 {
+			return a.groupSample(n.Groups[len(n.Groups)-1])
+		}
+	case *ast.Call:
+		return a.groupSample(n.Group)
+	case *ast.Conditional:
+		if n.Yes != nil {
+			return a.sample(n.Yes)
+		}
+	case *ast.Absent:
+		if n.Kind == ast.AbsentExpression {
+			return a.sample(n.Expr)
+		}
+	}
+	return ""
+}
+
+// groupSample returns a short text that a group matches, or the whole pattern for group 0.
+func (a *analyzer) groupSample(index int) string {
+	if index == 0 {
+		return ""
+	}
+	group := a.groups[index]
+	if group == nil || a.visiting[group] {
+		return ""
+	}
+	a.visiting[group] = true
+	defer delete(a.visiting, group)
+	return a.sample(group.Sub)
+}
@@ -0,0 +1,121 @@
+package onig
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tmikus/onig-go/v2/syntax/ast"
+	"testing"
+)
+
+func TestAnalyze_Findings(t *testing.T) {
+	for _, test := range []struct {
+		pattern  string
+		syntax   *Syntax
+		kind     FindingKind
+		severity Severity
+		span     Range
+	}{
+		{`(a+)+b`, SyntaxRuby, FindingNestedQuantifier, SeverityExponential, Range{0, 5}},
+		{`^(a+)+$`, SyntaxRuby, FindingNestedQuantifier, SeverityExponential, Range{1, 6}},
+		{`(a*)*b`, SyntaxPerlNG, FindingNestedQuantifier, SeverityExponential, Range{0, 5}},
+		{`(\w+\d*)+$`, SyntaxJava, FindingNestedQuantifier, SeverityExponential, Range{0, 9}},
+		{`(?i:a+)+b`, SyntaxRuby, FindingNestedQuantifier, SeverityExponential, Range{0, 8}},
+		{`(?:a?a)*b`, SyntaxPython, FindingNestedQuantifier, SeverityExponential, Range{0, 8}},
+		{`(\s*,\s*)*$`, SyntaxRuby, FindingNestedQuantifier, SeverityExponential, Range{0, 10}},
+		{`(a+){1,5}b`, SyntaxRuby, FindingNestedQuantifier, SeverityPolynomial, Range{0, 9}},
+		{`(?:a+){2,5}b`, SyntaxRuby, FindingNestedQuantifier, SeverityPolynomial, Range{0, 11}},
+		{`(a|a)*b`, SyntaxRuby, FindingAmbiguousAlternation, SeverityExponential, Range{0, 6}},
+		{`x(a|ab|b)*c`, SyntaxRuby, FindingAmbiguousAlternation, SeverityExponential, Range{1, 10}},
+		{`(?:\w|\d)+!`, SyntaxRuby, FindingAmbiguousAlternation, SeverityExponential, Range{0, 10}},
+		{`(?:(a+)\1)+b`, SyntaxRuby, FindingExponentialBackref, SeverityExponential, Range{0, 11}},
+	} {
+		report := Analyze(test.pattern, test.syntax)
+		if !assert.NoError(t, report.Err, test.pattern) || !assert.Len(t, report.Findings, 1, test.pattern) {
+			continue
+		}
+		finding := report.Findings[0]
+		assert.Equal(t, test.kind, finding.Kind, test.pattern)
+		assert.Equal(t, test.severity, finding.Severity, test.pattern)
+		assert.Equal(t, test.span, finding.Span, test.pattern)
+		assert.True(t, finding.Verified, test.pattern)
+		assert.True(t, VerifyAttack(MustCompileWithSyntax(test.pattern, test.syntax), finding.Attack), test.pattern)
+		assert.False(t, report.Safe(), test.pattern)
+	}
+}
+
+func TestAnalyze_Safe(t *testing.T) {
+	for _, pattern := range []string{
+		`abc`,
+		`a+b`,
+		`[a-z]+@[a-z]+\.com`,
+		`(a+)+`,
+		`(?:a+)+b`,
+		`(a+)++b`,
+		`(?>(a+)+)b`,
+		`(.*a){12}`,
+		`(a+)(?:\1)+b`,
+		`(?:ab){2,}+c`,
+		`(?:a|\d)+\z`,
+	} {
+		report := Analyze(pattern, SyntaxRuby)
+		assert.True(t, report.Safe(), pattern)
+		assert.Empty(t, report.Findings, pattern)
+	}
+}
+
+func TestAnalyze_Order(t *testing.T) {
+	report := Analyze(`(a|a)*b(c+)+d`, SyntaxRuby)
+	assert.NoError(t, report.Err)
+	var spans []Range
+	var kinds []FindingKind
+	for _, finding := range report.Findings {
+		spans = append(spans, finding.Span)
+		kinds = append(kinds, finding.Kind)
+	}
+	assert.Equal(t, []Range{{0, 6}, {7, 12}}, spans)
+	assert.Equal(t, []FindingKind{FindingAmbiguousAlternation, FindingNestedQuantifier}, kinds)
+}
+
+func TestAnalyze_Innermost(t *testing.T) {
+	report := Analyze(`((a+)+)*b`, SyntaxRuby)
+	assert.NoError(t, report.Err)
+	if assert.Len(t, report.Findings, 1) {
+		assert.Equal(t, Range{1, 6}, report.Findings[0].Span)
+	}
+}
+
+func TestAnalyze_DefaultSyntax(t *testing.T) {
+	assert.Equal(t, Analyze(`(a+)+b`, DefaultSyntax()), Analyze(`(a+)+b`, nil))
+}
+
+func TestAnalyze_Errors(t *testing.T) {
+	report := Analyze(`(a+`, SyntaxRuby)
+	assert.Error(t, report.Err)
+	assert.False(t, report.Safe())
+
+	for _, syntax := range []*Syntax{SyntaxPosixBasic, SyntaxEmacs} {
+		report = Analyze(`a`, syntax)
+		assert.True(t, errors.Is(report.Err, ast.ErrUnsupportedFlavor), syntax.Name())
+		assert.Empty(t, report.Findings, syntax.Name())
+	}
+}
+
+func TestVerifyAttack(t *testing.T) {
+	regex := MustCompileWithSyntax(`^(a+)+$`, SyntaxRuby)
+	assert.True(t, VerifyAttack(regex, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa!"))
+	assert.False(t, VerifyAttack(regex, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"))
+	assert.False(t, VerifyAttack(regex, "aaaa!"))
+}
+
+func TestSeverity_String(t *testing.T) {
+	assert.Equal(t, "polynomial", SeverityPolynomial.String())
+	assert.Equal(t, "exponential", SeverityExponential.String())
+	assert.Equal(t, "Severity(7)", Severity(7).String())
+}
+
+func TestFindingKind_String(t *testing.T) {
+	assert.Equal(t, "nested quantifier", FindingNestedQuantifier.String())
+	assert.Equal(t, "ambiguous alternation", FindingAmbiguousAlternation.String())
+	assert.Equal(t, "exponential backreference", FindingExponentialBackref.String())
+	assert.Equal(t, "FindingKind(7)", FindingKind(7).String())
+}
@@ -0,0 +1,178 @@
+package onig
+
+/*
+#include "regex.h"
+*/
+import "C"
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// parallelBatchSize is the number of texts each worker of ParallelFindMany searches at once.
+const parallelBatchSize = 1024
+
+// textBatch holds texts packed into one buffer, with the offset of each text and the end of the last one,
+// so that a batch of texts is searched with a single call into C.
+type textBatch struct {
+	buffer  []byte
+	offsets []C.uint
+}
+
+func newTextBatch(texts []string) (textBatch, error) {
+	size := 0
+	for _, text := range texts {
+		size += len(text)
+	}
+	if uint64(size) > math.MaxUint32 {
+		return textBatch{}, fmt.Errorf("batch of %d bytes is too large, the maximum is %d", size, uint(math.MaxUint32))
+	}
+	// The buffer is never empty, so that its first byte can be passed to C.
+	batch := textBatch{
+		buffer:  make([]byte, 0, max(size, 1)),
+		offsets: make([]C.uint, 0, len(texts)+1),
+	}
+	for _, text := range texts {
+		batch.offsets = append(batch.offsets, C.uint(len(batch.buffer)))
+		batch.buffer = append(batch.buffer, text...)
+	}
+	batch.offsets = append(batch.offsets, C.uint(len(batch.buffer)))
+	return batch, nil
+}
+
+// bufferPtr returns the buffer as a C pointer. The buffer is Go memory, which C only reads during the call.
+func (b *textBatch) bufferPtr() *C.char {
+	return (*C.char)(unsafe.Pointer(unsafe.SliceData(b.buffer)))
+}
+
+// MatchMany reports, for each of texts, whether it contains a match of the regex.
+// The texts are searched with a single call into Oniguruma rather than one call per text.
+func (r *Regex) MatchMany(texts []string) ([]bool, error) {
+	if len(texts) == 0 {
+		return []bool{}, nil
+	}
+	batch, err := newTextBatch(texts)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]byte, len(texts))
+	code := C.matchMany(
+		r.raw,
+		batch.bufferPtr(),
+		unsafe.SliceData(batch.offsets),
+		C.uint(len(texts)),
+		C.OnigOptionType(REGEX_OPTION_NONE),
+		(*C.char)(unsafe.Pointer(unsafe.SliceData(results))),
+	)
+	runtime.KeepAlive(r)
+	if code < 0 {
+		return nil, errorFromCode(code)
+	}
+	matched := make([]bool, len(texts))
+	for i, result := range results {
+		matched[i] = result != 0
+	}
+	return matched, nil
+}
+
+// FindMany returns, for each of texts, the non-overlapping matches of the regex in it, as FindMatches does.
+// The positions are byte indices with respect to each text, and texts without a match get a nil slice.
+// The texts are searched with a single call into Oniguruma rather than one call per text.
+func (r *Regex) FindMany(texts []string) ([][]*Range, error) {
+	if len(texts) == 0 {
+		return [][]*Range{}, nil
+	}
+	batch, err := newTextBatch(texts)
+	if err != nil {
+		return nil, err
+	}
+	matchCounts := make([]C.uint, len(texts))
+	result := C.findMany(
+		r.raw,
+		batch.bufferPtr(),
+		unsafe.SliceData(batch.offsets),
+		C.uint(len(texts)),
+		C.OnigOptionType(REGEX_OPTION_NONE),
+		unsafe.SliceData(matchCounts),
+	)
+	runtime.KeepAlive(r)
+	if result.result < 0 {
+		return nil, errorFromCode(result.result)
+	}
+	defer C.free(unsafe.Pointer(result.positions))
+	positions := unsafe.Slice(result.positions, int(result.positionsCount))
+	ranges := make([]Range, len(positions)/2)
+	for i := range ranges {
+		ranges[i] = Range{From: int(positions[2*i]), To: int(positions[2*i+1])}
+	}
+	matches := make([][]*Range, len(texts))
+	next := 0
+	for i, count := range matchCounts {
+		if count == 0 {
+			continue
+		}
+		matches[i] = make([]*Range, count)
+		for j := range matches[i] {
+			matches[i][j] = &ranges[next]
+			next++
+		}
+	}
+	return matches, nil
+}
+
+// ParallelFindMany returns the same result as FindMany, spreading the texts over workers goroutines
+// that each search batches of texts. If workers is 0 or less, then runtime.GOMAXPROCS(0) workers are used.
+// The search stops between batches when ctx is done, and returns the error of ctx.
+func (r *Regex) ParallelFindMany(ctx context.Context, texts []string, workers int) ([][]*Range, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	batches := (len(texts) + parallelBatchSize - 1) / parallelBatchSize
+	workers = max(min(workers, batches), 1)
+	matches := make([][]*Range, len(texts))
+	jobs := make(chan int)
+	var wait sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for range workers {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for from := range jobs {
+				to := min(from+parallelBatchSize, len(texts))
+				batchMatches, err := r.FindMany(texts[from:to])
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				copy(matches[from:to], batchMatches)
+			}
+		}()
+	}
+send:
+	for from := 0; from < len(texts); from += parallelBatchSize {
+		select {
+		case jobs <- from:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(jobs)
+	wait.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
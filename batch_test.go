@@ -0,0 +1,79 @@
+package onig
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var batchTexts = []string{
+	"GET /index.html 200",
+	"",
+	"POST /api/users 201",
+	"no status here",
+	"ÜBER /straße 404 500",
+	"x",
+}
+
+func TestRegex_MatchMany(t *testing.T) {
+	for _, pattern := range []string{`\d{3}`, `^$`, `x*`, `straße`, `\A[A-Z]+ `} {
+		r := MustCompile(pattern)
+		matched, err := r.MatchMany(batchTexts)
+		assert.NoError(t, err)
+		for i, text := range batchTexts {
+			assert.Equal(t, r.MustFindMatch(text) != nil, matched[i], "%s in %q", pattern, text)
+		}
+	}
+	matched, err := MustCompile(`a`).MatchMany(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, matched)
+}
+
+func TestRegex_FindMany(t *testing.T) {
+	for _, pattern := range []string{`\d{3}`, `^$`, `x*`, `\b`, `/\w+`, `(?<=\d) `} {
+		r := MustCompile(pattern)
+		matches, err := r.FindMany(batchTexts)
+		assert.NoError(t, err)
+		assert.Len(t, matches, len(batchTexts))
+		for i, text := range batchTexts {
+			expected := r.MustFindMatches(text)
+			if len(expected) == 0 {
+				assert.Nil(t, matches[i], "%s in %q", pattern, text)
+			} else {
+				assert.Equal(t, expected, matches[i], "%s in %q", pattern, text)
+			}
+		}
+	}
+	matches, err := MustCompile(`a`).FindMany([]string{})
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestRegex_ParallelFindMany(t *testing.T) {
+	texts := make([]string, 5*parallelBatchSize+7)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("line %d: value=%d", i, i*i)
+	}
+	r := MustCompile(`\d+`)
+	expected, err := r.FindMany(texts)
+	assert.NoError(t, err)
+	for _, workers := range []int{0, 1, 3, 100} {
+		matches, err := r.ParallelFindMany(context.Background(), texts, workers)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, matches)
+	}
+
+	matches, err := r.ParallelFindMany(context.Background(), nil, 4)
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestRegex_ParallelFindMany_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	texts := make([]string, 3*parallelBatchSize)
+	matches, err := MustCompile(`a`).ParallelFindMany(ctx, texts, 2)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, matches)
+}
@@ -1,6 +1,8 @@
 package bench
 
 import (
+	"context"
+	"fmt"
 	"github.com/tmikus/onig-go/v2"
 	"strings"
 	"testing"
@@ -18,6 +20,45 @@ func BenchmarkOnigLiteral(b *testing.B) {
 	}
 }
 
+func BenchmarkOnigHybridLiteral(b *testing.B) {
+	x := strings.Repeat("x", 50) + "y"
+	re, err := onig.CompileHybrid("y", onig.REGEX_OPTION_NONE, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if re.MustFindMatch(x) == nil {
+			b.Fatalf("no match!")
+		}
+	}
+}
+
+func BenchmarkOnigHybridMatchClass(b *testing.B) {
+	x := strings.Repeat("xxxx", 20) + "w"
+	re, err := onig.CompileHybrid("[abcdw]", onig.REGEX_OPTION_NONE, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if re.MustFindMatch(x) == nil {
+			b.Fatalf("no match!")
+		}
+	}
+}
+
+func BenchmarkOnigRequiredLiteralMiss(b *testing.B) {
+	x := "INFO: request served in 12ms by worker 7"
+	re := onig.MustCompile(`ERROR: (\w+) timeout`)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if re.MustFindMatch(x) != nil {
+			b.Fatalf("match!")
+		}
+	}
+}
+
 func BenchmarkOnigNotLiteral(b *testing.B) {
 	x := strings.Repeat("x", 50) + "y"
 	b.StopTimer()
@@ -173,6 +214,131 @@ func BenchmarkOnigMatchParallelShared(b *testing.B) {
 	})
 }
 
+func BenchmarkOnigCompile(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		onig.MustCompile(`(?<user>[\w.]+)@(?<host>[\w.]+)`)
+	}
+}
+
+func BenchmarkOnigCompileCached(b *testing.B) {
+	cache := onig.NewCache(16)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := cache.Compile(`(?<user>[\w.]+)@(?<host>[\w.]+)`, onig.REGEX_OPTION_NONE, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// detectionRules returns n detection rules that each start with a distinctive literal.
+func detectionRules(n int) []onig.Rule {
+	rules := make([]onig.Rule, n)
+	for i := range rules {
+		rules[i] = onig.Rule{
+			ID:    fmt.Sprintf("rule-%d", i),
+			Regex: onig.MustCompile(fmt.Sprintf(`signature-%d: (\w+) from (\d+\.\d+\.\d+\.\d+)`, i)),
+		}
+	}
+	return rules
+}
+
+const ruleText = "2024-01-01 12:00:00 sensor: signature-4242: probe from 10.0.0.1 blocked"
+
+func BenchmarkOnigRulesLoop(b *testing.B) {
+	rules := detectionRules(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, rule := range rules {
+			rule.Regex.MustFindMatch(ruleText)
+		}
+	}
+}
+
+func BenchmarkOnigRuleMatcher(b *testing.B) {
+	matcher, err := onig.NewRuleMatcher(detectionRules(10000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := matcher.Match(ruleText); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func manyLines(n int) []string {
+	lines := strings.Split(string(makeText(n*40)), "\n")
+	return lines[:len(lines)-1]
+}
+
+func BenchmarkOnigManyLoop(b *testing.B) {
+	re := onig.MustCompile("[XYZ]AB|[0-9]{3}")
+	lines := manyLines(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			re.MustFindMatches(line)
+		}
+	}
+}
+
+func BenchmarkOnigMatchMany(b *testing.B) {
+	re := onig.MustCompile("[XYZ]AB|[0-9]{3}")
+	lines := manyLines(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := re.MatchMany(lines); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkOnigFindMany(b *testing.B) {
+	re := onig.MustCompile("[XYZ]AB|[0-9]{3}")
+	lines := manyLines(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := re.FindMany(lines); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkOnigParallelFindMany(b *testing.B) {
+	re := onig.MustCompile("[XYZ]AB|[0-9]{3}")
+	lines := manyLines(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := re.ParallelFindMany(context.Background(), lines, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkOnigFindAllSequential(b *testing.B) {
+	re := onig.MustCompile("[XYZ]AB|[0-9]{3}")
+	t := string(makeText(8 << 20))
+	b.ResetTimer()
+	b.SetBytes(int64(len(t)))
+	for i := 0; i < b.N; i++ {
+		re.MustFindMatches(t)
+	}
+}
+
+func BenchmarkOnigFindAllParallel(b *testing.B) {
+	re := onig.MustCompile("[XYZ]AB|[0-9]{3}")
+	t := makeText(8 << 20)
+	b.ResetTimer()
+	b.SetBytes(int64(len(t)))
+	for i := 0; i < b.N; i++ {
+		if _, err := re.FindAllParallel(t, onig.ParallelOptions{MaxMatchLength: 3}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func benchmarkOnig(b *testing.B, re string, n int) {
 	r := onig.MustCompile(re)
 	t := makeText(n)
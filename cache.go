@@ -0,0 +1,189 @@
+package onig
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is the number of regexes kept by the cache used by CompileCached.
+const DefaultCacheSize = 256
+
+// DefaultCacheNegativeTTL is how long a Cache remembers that a pattern failed to compile, unless changed by WithNegativeTTL.
+const DefaultCacheNegativeTTL = time.Minute
+
+// CacheStats are the counters of a Cache.
+type CacheStats struct {
+	// Hits is the number of lookups served from the cache, including the cached compile errors.
+	Hits uint64
+	// Misses is the number of lookups that compiled the pattern.
+	Misses uint64
+	// Shared is the number of lookups that waited for another lookup compiling the same pattern.
+	Shared uint64
+	// Evictions is the number of entries removed to make room for new ones.
+	Evictions uint64
+	// Entries is the number of entries in the cache, including the cached compile errors.
+	Entries int
+}
+
+// CacheOption is a functional option that configures a Cache.
+type CacheOption func(cache *Cache)
+
+// WithNegativeTTL sets how long a Cache remembers that a pattern failed to compile.
+// A TTL of 0 or less disables the caching of compile errors.
+func WithNegativeTTL(ttl time.Duration) CacheOption {
+	return func(cache *Cache) {
+		cache.negativeTTL = ttl
+	}
+}
+
+// cacheKey identifies a compiled regex.
+type cacheKey struct {
+	pattern string
+	options RegexOptions
+	syntax  *Syntax
+}
+
+// cacheEntry is a compiled regex, or the error of a pattern that failed to compile, in the LRU list.
+type cacheEntry struct {
+	key     cacheKey
+	regex   *Regex
+	err     error
+	expires time.Time
+}
+
+// cacheCall is a compilation in progress, which other lookups of the same key wait for.
+type cacheCall struct {
+	done  chan struct{}
+	regex *Regex
+	err   error
+}
+
+// Cache is a least recently used cache of compiled regexes, keyed on the pattern, the options and the syntax.
+// It is safe for concurrent use, and concurrent lookups of the same key compile the pattern only once.
+//
+// A Regex returned by the cache is shared by all the callers that look it up, so it must not be modified.
+// Evicted regexes are dropped by the cache, and freed by the garbage collector once no caller refers to them.
+type Cache struct {
+	mutex       sync.Mutex
+	size        int
+	negativeTTL time.Duration
+	entries     map[cacheKey]*list.Element
+	order       *list.List
+	calls       map[cacheKey]*cacheCall
+	stats       CacheStats
+	now         func() time.Time
+}
+
+// NewCache creates a cache that holds at most size entries. A size of 0 or less means no limit.
+func NewCache(size int, options ...CacheOption) *Cache {
+	cache := &Cache{
+		size:        size,
+		negativeTTL: DefaultCacheNegativeTTL,
+		entries:     map[cacheKey]*list.Element{},
+		order:       list.New(),
+		calls:       map[cacheKey]*cacheCall{},
+		now:         time.Now,
+	}
+	for _, option := range options {
+		option(cache)
+	}
+	return cache
+}
+
+// Compile returns the regex compiled from pattern with the given options and syntax, compiling it
+// with CompileWithOptionsAndSyntax if it isn't cached. A nil syntax means the default syntax.
+// Compile errors are cached for the negative TTL of the cache.
+func (c *Cache) Compile(pattern string, options RegexOptions, syntax *Syntax) (*Regex, error) {
+	if syntax == nil {
+		syntax = DefaultSyntax()
+	}
+	key := cacheKey{pattern: pattern, options: options, syntax: syntax}
+	c.mutex.Lock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if entry.err == nil || c.now().Before(entry.expires) {
+			c.order.MoveToFront(element)
+			c.stats.Hits++
+			c.mutex.Unlock()
+			return entry.regex, entry.err
+		}
+		c.removeElement(element)
+	}
+	if call, ok := c.calls[key]; ok {
+		c.stats.Shared++
+		c.mutex.Unlock()
+		<-call.done
+		return call.regex, call.err
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.stats.Misses++
+	c.mutex.Unlock()
+
+	call.regex, call.err = CompileWithOptionsAndSyntax(pattern, options, syntax)
+
+	c.mutex.Lock()
+	delete(c.calls, key)
+	if call.err == nil {
+		c.add(&cacheEntry{key: key, regex: call.regex})
+	} else if c.negativeTTL > 0 {
+		c.add(&cacheEntry{key: key, err: call.err, expires: c.now().Add(c.negativeTTL)})
+	}
+	c.mutex.Unlock()
+	close(call.done)
+	return call.regex, call.err
+}
+
+// Stats returns the counters of the cache.
+func (c *Cache) Stats() CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	return stats
+}
+
+// Len returns the number of entries in the cache, including the cached compile errors.
+func (c *Cache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
+
+// Purge removes all the entries from the cache. The counters are kept.
+func (c *Cache) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = map[cacheKey]*list.Element{}
+	c.order.Init()
+}
+
+// add adds an entry to the front of the LRU list, evicting the least recently used entries beyond the size.
+// The mutex must be held.
+func (c *Cache) add(entry *cacheEntry) {
+	if element, ok := c.entries[entry.key]; ok {
+		c.removeElement(element)
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.size > 0 && c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// removeElement removes an entry from the cache. The mutex must be held.
+func (c *Cache) removeElement(element *list.Element) {
+	entry := c.order.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	entry.regex = nil
+}
+
+var defaultCache = NewCache(DefaultCacheSize)
+
+// CompileCached compiles a regex like CompileWithOptionsAndSyntax, through a package-level Cache
+// of DefaultCacheSize entries. A nil syntax means the default syntax.
+// The returned Regex can be shared with other callers, so it must not be modified.
+func CompileCached(pattern string, options RegexOptions, syntax *Syntax) (*Regex, error) {
+	return defaultCache.Compile(pattern, options, syntax)
+}
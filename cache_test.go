@@ -0,0 +1,106 @@
+package onig
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_Compile(t *testing.T) {
+	cache := NewCache(2)
+	first, err := cache.Compile(`a+`, REGEX_OPTION_NONE, nil)
+	assert.NoError(t, err)
+	second, err := cache.Compile(`a+`, REGEX_OPTION_NONE, SyntaxDefault)
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+
+	other, err := cache.Compile(`a+`, REGEX_OPTION_IGNORECASE, nil)
+	assert.NoError(t, err)
+	assert.NotSame(t, first, other)
+	python, err := cache.Compile(`a+`, REGEX_OPTION_NONE, SyntaxPython)
+	assert.NoError(t, err)
+	assert.Same(t, SyntaxPython, python.Syntax())
+
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 3, Evictions: 1, Entries: 2}, cache.Stats())
+}
+
+func TestCache_LeastRecentlyUsed(t *testing.T) {
+	cache := NewCache(2)
+	a, _ := cache.Compile(`a`, REGEX_OPTION_NONE, nil)
+	_, _ = cache.Compile(`b`, REGEX_OPTION_NONE, nil)
+	again, _ := cache.Compile(`a`, REGEX_OPTION_NONE, nil)
+	assert.Same(t, a, again)
+	_, _ = cache.Compile(`c`, REGEX_OPTION_NONE, nil)
+
+	again, _ = cache.Compile(`a`, REGEX_OPTION_NONE, nil)
+	assert.Same(t, a, again)
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 3, Evictions: 1, Entries: 2}, cache.Stats())
+	_, _ = cache.Compile(`b`, REGEX_OPTION_NONE, nil)
+	assert.Equal(t, uint64(4), cache.Stats().Misses)
+}
+
+func TestCache_NegativeTTL(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := NewCache(10, WithNegativeTTL(time.Second))
+	cache.now = func() time.Time { return now }
+
+	_, err := cache.Compile(`(`, REGEX_OPTION_NONE, nil)
+	assert.Error(t, err)
+	_, cachedErr := cache.Compile(`(`, REGEX_OPTION_NONE, nil)
+	assert.Same(t, err, cachedErr)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Entries: 1}, cache.Stats())
+
+	now = now.Add(time.Second)
+	_, err = cache.Compile(`(`, REGEX_OPTION_NONE, nil)
+	assert.Error(t, err)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Entries: 1}, cache.Stats())
+
+	cache = NewCache(10, WithNegativeTTL(0))
+	_, _ = cache.Compile(`(`, REGEX_OPTION_NONE, nil)
+	_, _ = cache.Compile(`(`, REGEX_OPTION_NONE, nil)
+	assert.Equal(t, CacheStats{Misses: 2}, cache.Stats())
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	cache := NewCache(4)
+	const goroutines = 32
+	var wait sync.WaitGroup
+	results := make([]*Regex, goroutines)
+	for i := range goroutines {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			regex, err := cache.Compile(`(?<word>\w+)\s+\k<word>`, REGEX_OPTION_NONE, nil)
+			assert.NoError(t, err)
+			results[i] = regex
+			assert.Equal(t, NewRange(0, 7), regex.MustFindMatch("foo foo bar"))
+		}()
+	}
+	wait.Wait()
+	for _, regex := range results {
+		assert.Same(t, results[0], regex)
+	}
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(goroutines), stats.Hits+stats.Shared+stats.Misses)
+}
+
+func TestCache_Purge(t *testing.T) {
+	cache := NewCache(0)
+	for _, pattern := range []string{`a`, `b`, `c`} {
+		_, _ = cache.Compile(pattern, REGEX_OPTION_NONE, nil)
+	}
+	assert.Equal(t, 3, cache.Len())
+	cache.Purge()
+	assert.Equal(t, 0, cache.Len())
+	assert.Equal(t, CacheStats{Misses: 3}, cache.Stats())
+}
+
+func TestCompileCached(t *testing.T) {
+	first, err := CompileCached(`cached\d`, REGEX_OPTION_NONE, nil)
+	assert.NoError(t, err)
+	second, err := CompileCached(`cached\d`, REGEX_OPTION_NONE, nil)
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+}
@@ -0,0 +1,163 @@
+package onig
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stressGC makes the garbage collector run as often as possible for the duration of the test,
+// like GOGC=1, to expose finalizers that run while a search still uses the C memory they free.
+func stressGC(t *testing.T) {
+	t.Helper()
+	previous := debug.SetGCPercent(1)
+	t.Cleanup(func() {
+		debug.SetGCPercent(previous)
+	})
+}
+
+// concurrentResult holds the results of every kind of search on the same text, to compare them across goroutines.
+type concurrentResult struct {
+	matches     []*Range
+	captures    []string
+	replaced    string
+	split       []string
+	overlapping []*Range
+	last        *Range
+	lines       []int
+}
+
+func searchEverything(t *testing.T, r *Regex, text string) concurrentResult {
+	var result concurrentResult
+	var err error
+	result.matches, err = r.FindMatches(text)
+	assert.NoError(t, err)
+	captures, err := r.AllCaptures(text)
+	assert.NoError(t, err)
+	for i := range captures {
+		result.captures = append(result.captures, captures[i].AtGroupName("key"), captures[i].At(2))
+	}
+	result.replaced, err = r.ReplaceAll(text, `\k<key>:\k<value>`)
+	assert.NoError(t, err)
+	result.split, err = r.Split(text)
+	assert.NoError(t, err)
+	for match := range r.FindOverlappingWithOptions(text, OverlappingOptions{AllMatches: true}) {
+		result.overlapping = append(result.overlapping, match.Pos(0))
+	}
+	result.last, err = r.FindLastMatch(text)
+	assert.NoError(t, err)
+	for line := range r.SearchLines(strings.NewReader(text), SearchLinesOptions{BlockSize: 64}) {
+		assert.NoError(t, line.Err)
+		result.lines = append(result.lines, line.LineNumber)
+	}
+	return result
+}
+
+func concurrentText(lines int) string {
+	var builder strings.Builder
+	for i := range lines {
+		fmt.Fprintf(&builder, "key%d=value%d; other%d=%d\n", i, i*7, i, i%3)
+	}
+	return builder.String()
+}
+
+func TestConcurrent_SharedRegex(t *testing.T) {
+	stressGC(t)
+	r := MustCompile(`(?<key>\w+)=(?<value>\w*[13579])`)
+	text := concurrentText(100)
+	expected := searchEverything(t, r, text)
+	assert.NotEmpty(t, expected.matches)
+
+	var wait sync.WaitGroup
+	for range 16 {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for i := range 20 {
+				assert.Equal(t, expected, searchEverything(t, r, text))
+				assert.Equal(t, 2, r.NumberOfCaptures())
+				assert.Equal(t, "UTF-8", r.Encoding())
+				if i%5 == 0 {
+					runtime.GC()
+				}
+			}
+		}()
+	}
+	wait.Wait()
+}
+
+func TestConcurrent_UnreferencedRegex(t *testing.T) {
+	stressGC(t)
+	text := concurrentText(200)
+	var wait sync.WaitGroup
+	for worker := range 8 {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for i := range 50 {
+				// The regex is only referenced by the call, so a finalizer that isn't held back
+				// by the search would free it while Oniguruma is still using it.
+				matches, err := MustCompile(fmt.Sprintf(`key%d=\w+`, (worker*50+i)%200)).FindMatches(text)
+				assert.NoError(t, err)
+				assert.Len(t, matches, 1)
+				captures, err := MustCompile(`(\d+);`).AllCaptures(text)
+				assert.NoError(t, err)
+				assert.Len(t, captures, 200)
+				last, err := MustCompile(`other\d+`).FindLastMatch(text)
+				assert.NoError(t, err)
+				assert.NotNil(t, last)
+			}
+		}()
+	}
+	wait.Wait()
+}
+
+func TestConcurrent_Regions(t *testing.T) {
+	stressGC(t)
+	r := MustCompile(`(?<word>[a-z]+)(\d)`)
+	text := strings.Repeat("abc1 def2 ghi3 ", 50)
+	var wait sync.WaitGroup
+	for range 8 {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for range 50 {
+				captures, err := r.AllCaptures(text)
+				assert.NoError(t, err)
+				words := make([]string, 0, len(captures))
+				for i := range captures {
+					words = append(words, captures[i].AtGroupName("word"))
+				}
+				runtime.GC()
+				assert.Equal(t, slices.Repeat([]string{"abc", "def", "ghi"}, 50), words)
+			}
+		}()
+	}
+	wait.Wait()
+}
+
+func TestConcurrent_Cache(t *testing.T) {
+	stressGC(t)
+	cache := NewCache(8)
+	text := concurrentText(50)
+	var wait sync.WaitGroup
+	for worker := range 16 {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for i := range 100 {
+				r, err := cache.Compile(fmt.Sprintf(`key%d=`, (worker+i)%12), REGEX_OPTION_NONE, nil)
+				assert.NoError(t, err)
+				matches, err := r.FindMatches(text)
+				assert.NoError(t, err)
+				assert.Len(t, matches, 1)
+			}
+		}()
+	}
+	wait.Wait()
+}
@@ -0,0 +1,16 @@
+// Package onig provides regular expressions backed by the Oniguruma library.
+//
+// # Concurrency
+//
+// A compiled *Regex is immutable and safe for concurrent use by multiple goroutines:
+// every search allocates its own Oniguruma region and match parameters, and no search
+// changes state shared with other searches. The C memory of a Regex is freed by a finalizer
+// once no Regex refers to it, and it is kept alive until every call into Oniguruma that uses it returns.
+//
+// The values returned by a search, such as Captures and Region, belong to the caller and are
+// safe to read from multiple goroutines. The functions that change package-level settings,
+// SetDefaultSyntax, RegisterSyntax and RegisterSyntaxAlias, are safe to call concurrently with each other,
+// but SetDefaultSyntax should be called before compiling regexes, since it also changes the default
+// syntax of Oniguruma itself. Methods that replace a Regex in place, such as UnmarshalJSON and Set,
+// must not be called while the Regex is in use.
+package onig
@@ -0,0 +1,132 @@
+package onig
+
+import (
+	"sort"
+	"strings"
+)
+
+// Edit describes the replacement of the text in Range by NewText.
+// The positions in Range are byte indices with respect to the original text.
+type Edit struct {
+	Range
+	NewText string
+}
+
+// ReplaceAllEdits returns the edits that ReplaceAll would apply to text, in the order they appear in text.
+// See the documentation for Replace for details on how to access submatches in the replacement string.
+func (r *Regex) ReplaceAllEdits(text string, replacement string) ([]Edit, error) {
+	return r.ReplaceNEdits(text, replacement, 0)
+}
+
+// ReplaceAllFuncEdits returns the edits that ReplaceAllFunc would apply to text, in the order they appear in text.
+func (r *Regex) ReplaceAllFuncEdits(text string, replacement ReplacementFunc) ([]Edit, error) {
+	return r.ReplaceNFuncEdits(text, replacement, 0)
+}
+
+// ReplaceNEdits returns the edits that ReplaceN would apply to text, in the order they appear in text.
+// If limit is 0, then an edit is returned for every non-overlapping match.
+func (r *Regex) ReplaceNEdits(text string, replacement string, limit int) ([]Edit, error) {
+	template, err := r.CompileTemplate(replacement)
+	if err != nil {
+		return nil, err
+	}
+	return r.ReplaceNFuncEdits(text, template.Replace, limit)
+}
+
+// ReplaceNFuncEdits returns the edits that ReplaceNFunc would apply to text, in the order they appear in text.
+// If limit is 0, then an edit is returned for every non-overlapping match.
+func (r *Regex) ReplaceNFuncEdits(text string, replacement ReplacementFunc, limit int) ([]Edit, error) {
+	edits := make([]Edit, 0)
+	err := r.replaceEach(text, limit, wholeTextParams(text), func(pos *Range, captures *Captures) error {
+		replacedText, err := replacement(captures)
+		if err != nil {
+			return err
+		}
+		edits = append(edits, Edit{Range: *pos, NewText: replacedText})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+// MustReplaceAllEdits returns the edits that ReplaceAll would apply to text, in the order they appear in text.
+// Compared to ReplaceAllEdits, this method panics on error.
+func (r *Regex) MustReplaceAllEdits(text string, replacement string) []Edit {
+	edits, err := r.ReplaceAllEdits(text, replacement)
+	if err != nil {
+		panic(err)
+	}
+	return edits
+}
+
+// ApplyEdits returns text with the edits applied.
+// The edits must be sorted by position and must not overlap, as returned by ReplaceAllEdits.
+func ApplyEdits(text string, edits []Edit) string {
+	var builder strings.Builder
+	builder.Grow(len(text))
+	last := 0
+	for _, edit := range edits {
+		builder.WriteString(text[last:edit.From])
+		builder.WriteString(edit.NewText)
+		last = edit.To
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+// OffsetMap translates byte offsets in an original text into byte offsets in the text
+// produced by applying a list of edits to it.
+type OffsetMap struct {
+	edits []Edit
+	// shifts holds, for each edit, how far the text following it has moved.
+	shifts []int
+}
+
+// NewOffsetMap creates an OffsetMap for the edits.
+// The edits must be sorted by position and must not overlap, as returned by ReplaceAllEdits.
+func NewOffsetMap(edits []Edit) *OffsetMap {
+	shifts := make([]int, len(edits))
+	shift := 0
+	for i, edit := range edits {
+		shift += len(edit.NewText) - (edit.To - edit.From)
+		shifts[i] = shift
+	}
+	return &OffsetMap{
+		edits:  edits,
+		shifts: shifts,
+	}
+}
+
+// Map returns the offset in the edited text that corresponds to offset in the original text.
+// Offsets inside a replaced range, including its start, are mapped to the start of the replacement text,
+// and the end of a replaced range is mapped to the end of the replacement text.
+func (m *OffsetMap) Map(offset int) int {
+	// Find the first edit that ends at or after offset.
+	index := sort.Search(len(m.edits), func(i int) bool {
+		return m.edits[i].To >= offset
+	})
+	shift := 0
+	if index > 0 {
+		shift = m.shifts[index-1]
+	}
+	if index < len(m.edits) {
+		edit := m.edits[index]
+		if offset == edit.To && edit.From < edit.To {
+			return offset + m.shifts[index]
+		}
+		if offset > edit.From {
+			return edit.From + shift
+		}
+	}
+	return offset + shift
+}
+
+// MapRange returns the range in the edited text that corresponds to the range in the original text.
+func (m *OffsetMap) MapRange(r Range) Range {
+	return Range{
+		From: m.Map(r.From),
+		To:   m.Map(r.To),
+	}
+}
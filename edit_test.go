@@ -0,0 +1,90 @@
+package onig
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRegex_ReplaceAllEdits(t *testing.T) {
+	r := MustCompile(`(\d+)`)
+	edits, err := r.ReplaceAllEdits("a12b2c", `<\1>`)
+	assert.NoError(t, err)
+	assert.Equal(t, []Edit{
+		{Range: Range{From: 1, To: 3}, NewText: "<12>"},
+		{Range: Range{From: 4, To: 5}, NewText: "<2>"},
+	}, edits)
+	assert.Equal(t, "a<12>b<2>c", ApplyEdits("a12b2c", edits))
+
+	edits, err = r.ReplaceNEdits("a12b2c", `<\1>`, 1)
+	assert.NoError(t, err)
+	assert.Len(t, edits, 1)
+
+	edits, err = r.ReplaceAllEdits("abc", `<\1>`)
+	assert.NoError(t, err)
+	assert.Empty(t, edits)
+
+	_, err = r.ReplaceAllEdits("a12", `\k<missing>`)
+	assert.Error(t, err)
+}
+
+func TestRegex_ReplaceAllFuncEdits(t *testing.T) {
+	r := MustCompile(`[a-z]+`)
+	edits, err := r.ReplaceAllFuncEdits("ab 12 cd", func(captures *Captures) (string, error) {
+		return strings.ToUpper(captures.At(0)), nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "AB 12 CD", ApplyEdits("ab 12 cd", edits))
+
+	_, err = r.ReplaceAllFuncEdits("ab", func(captures *Captures) (string, error) {
+		return "", fmt.Errorf("failed")
+	})
+	assert.Error(t, err)
+}
+
+func TestRegex_ReplaceAllEdits_MatchesReplaceAll(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		text        string
+		replacement string
+	}{
+		{`\d*`, "a1bbb2", "<\\0>"},
+		{`b|(?=,)`, "ba,", "[\\0]"},
+		{`\b`, "test string", "|"},
+		{`x`, strings.Repeat("xy", 1000), "zz"},
+	}
+	for _, test := range tests {
+		r := MustCompile(test.pattern)
+		edits := r.MustReplaceAllEdits(test.text, test.replacement)
+		assert.Equal(t, r.MustReplaceAll(test.text, test.replacement), ApplyEdits(test.text, edits), test.pattern)
+	}
+}
+
+func TestOffsetMap(t *testing.T) {
+	text := "a12b2c"
+	r := MustCompile(`\d+`)
+	edits := r.MustReplaceAllEdits(text, "<num>")
+	output := ApplyEdits(text, edits)
+	assert.Equal(t, "a<num>b<num>c", output)
+	offsets := NewOffsetMap(edits)
+	assert.Equal(t, 0, offsets.Map(0))
+	assert.Equal(t, 1, offsets.Map(1))
+	assert.Equal(t, 1, offsets.Map(2))
+	assert.Equal(t, 6, offsets.Map(3))
+	assert.Equal(t, 7, offsets.Map(4))
+	assert.Equal(t, 12, offsets.Map(5))
+	assert.Equal(t, 13, offsets.Map(6))
+	assert.Equal(t, "b", output[offsets.Map(3):offsets.Map(4)])
+	assert.Equal(t, Range{From: 7, To: 12}, offsets.MapRange(Range{From: 4, To: 5}))
+
+	edits = MustCompile(`\b`).MustReplaceAllEdits("ab cd", "|")
+	offsets = NewOffsetMap(edits)
+	assert.Equal(t, "|ab| |cd|", ApplyEdits("ab cd", edits))
+	assert.Equal(t, 0, offsets.Map(0))
+	assert.Equal(t, 3, offsets.Map(2))
+	assert.Equal(t, 5, offsets.Map(3))
+	assert.Equal(t, 8, offsets.Map(5))
+
+	assert.Equal(t, 4, NewOffsetMap(nil).Map(4))
+}
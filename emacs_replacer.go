@@ -0,0 +1,76 @@
+package onig
+
+import (
+	"fmt"
+)
+
+// EmacsReplacer is a RegexReplacer that replaces capture groups with the corresponding captured text.
+//
+// The replacement pattern follows the rules of Emacs' replace-match:
+//   - `\&` is the whole match.
+//   - `\0` to `\9` refer to the numbered capture group, `\0` being the whole match.
+//   - `\\` is a literal backslash and `\?` is kept literally as `\?`.
+//
+// Any other use of a backslash is an error.
+type EmacsReplacer struct {
+	parts []replacementPart
+	err   error
+}
+
+// NewEmacsRegexReplacer creates a new EmacsReplacer given a replacement pattern.
+// See https://www.gnu.org/software/emacs/manual/html_node/elisp/Replacing-Match.html for more information.
+func NewEmacsRegexReplacer(pattern string) RegexReplacer {
+	parts, err := parseEmacsReplacement(pattern)
+	return &EmacsReplacer{
+		parts: parts,
+		err:   err,
+	}
+}
+
+// Replace applies the replacement pattern to the captures.
+func (r *EmacsReplacer) Replace(captures *Captures) (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+	return string(appendReplacementParts(nil, r.parts, captures)), nil
+}
+
+func (r *EmacsReplacer) compileTemplate(regex *Regex) ([]replacementPart, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if err := validateReplacementParts(r.parts, regex); err != nil {
+		return nil, err
+	}
+	return r.parts, nil
+}
+
+// parseEmacsReplacement splits an Emacs replacement pattern into literal text and group references.
+func parseEmacsReplacement(pattern string) ([]replacementPart, error) {
+	var parser replacementParser
+	for index := 0; index < len(pattern); index++ {
+		ch := pattern[index]
+		if ch != '\\' {
+			parser.addLiteralByte(ch)
+			continue
+		}
+		index++
+		if index >= len(pattern) {
+			return nil, fmt.Errorf("trailing backslash in replacement pattern")
+		}
+		ch = pattern[index]
+		switch {
+		case ch == '&':
+			parser.addPart(replacementPart{kind: replacementGroup, group: 0})
+		case isASCIIDigit(ch):
+			parser.addPart(replacementPart{kind: replacementGroup, group: int(ch - '0')})
+		case ch == '\\':
+			parser.addLiteralByte('\\')
+		case ch == '?':
+			parser.addLiteral(`\?`)
+		default:
+			return nil, fmt.Errorf("invalid use of `\\' in replacement text: \\%c", ch)
+		}
+	}
+	return parser.result(), nil
+}
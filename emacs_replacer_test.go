@@ -0,0 +1,19 @@
+package onig
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEmacsRegexReplacer(t *testing.T) {
+	r := MustCompileWithSyntax(`hello \(.*\)`, SyntaxEmacs)
+	assert.Equal(t, "goodbye hello world", r.MustReplaceAll("hello world", `goodbye \&`))
+	assert.Equal(t, "goodbye hello world", r.MustReplaceAll("hello world", `goodbye \0`))
+	assert.Equal(t, "goodbye world", r.MustReplaceAll("hello world", `goodbye \1`))
+	assert.Equal(t, "goodbye & world", r.MustReplaceAll("hello world", `goodbye & \1`))
+	assert.Equal(t, `goodbye \1`, r.MustReplaceAll("hello world", `goodbye \\1`))
+	assert.Equal(t, `goodbye \? world`, r.MustReplaceAll("hello world", `goodbye \? \1`))
+	assertReplaceError(t, r, "hello world", `goodbye \2`)
+	assertReplaceError(t, r, "hello world", `goodbye \`)
+	assertReplaceError(t, r, "hello world", `goodbye \n`)
+}
@@ -1,15 +1,16 @@
 package onig
 
 import (
-	"fmt"
 	"strconv"
 )
 
 // GenericRegexReplacer is a RegexReplacer that replaces capture groups with the corresponding captured text.
+//
+// `\0` to `\9` refer to the numbered capture group and `\` followed by namedGroupPrefix and `<name>`
+// refers to the named (or numbered) capture group. `\\` is a literal backslash.
+// Any other backslash is kept literally.
 type GenericRegexReplacer struct {
-	namedGroupPrefix byte
-	repl             []byte
-	replLen          int
+	parts []replacementPart
 }
 
 // NewGenericRegexReplacer creates a new JavaReplacer given a replacement pattern.
@@ -18,60 +19,59 @@ func newGenericRegexReplacer(
 	pattern string,
 	namedGroupPrefix byte,
 ) RegexReplacer {
-	repl := []byte(pattern)
 	return &GenericRegexReplacer{
-		namedGroupPrefix: namedGroupPrefix,
-		repl:             repl,
-		replLen:          len(repl),
+		parts: parseGenericReplacement(pattern, namedGroupPrefix),
 	}
 }
 
 // Replace applies the replacement pattern to the captures.
 func (r *GenericRegexReplacer) Replace(captures *Captures) (string, error) {
-	newReplacement := make([]byte, 0, r.replLen*3)
-	inEscapeMode := false
-	inGroupNameMode := false
-	groupName := make([]byte, 0, r.replLen)
-	for index := 0; index < r.replLen; index++ {
-		ch := r.repl[index]
-		if inGroupNameMode && ch == byte('<') {
-		} else if inGroupNameMode && ch == byte('>') {
-			inGroupNameMode = false
-			groupNameStr := string(groupName)
-			groupIndex, err := strconv.ParseInt(groupNameStr, 10, 32)
-			var capture string
-			if err == nil {
-				capture = captures.At(int(groupIndex))
-			} else {
-				capture = captures.AtGroupName(groupNameStr)
+	return string(appendReplacementParts(nil, r.parts, captures)), nil
+}
+
+func (r *GenericRegexReplacer) compileTemplate(regex *Regex) ([]replacementPart, error) {
+	if err := validateReplacementParts(r.parts, regex); err != nil {
+		return nil, err
+	}
+	return r.parts, nil
+}
+
+// parseGenericReplacement splits a replacement pattern into literal text and group references.
+func parseGenericReplacement(pattern string, namedGroupPrefix byte) []replacementPart {
+	var parser replacementParser
+	for index := 0; index < len(pattern); index++ {
+		ch := pattern[index]
+		if ch != '\\' || index+1 >= len(pattern) {
+			parser.addLiteralByte(ch)
+			continue
+		}
+		next := pattern[index+1]
+		switch {
+		case isASCIIDigit(next):
+			parser.addPart(replacementPart{kind: replacementGroup, group: int(next - '0')})
+			index++
+		case next == '\\':
+			parser.addLiteralByte('\\')
+			index++
+		case next == namedGroupPrefix && index+2 < len(pattern) && pattern[index+2] == '<':
+			end := index + 3
+			for end < len(pattern) && pattern[end] != '>' {
+				end++
 			}
-			newReplacement = append(newReplacement, []byte(capture)...)
-			groupName = groupName[:0]
-		} else if inGroupNameMode {
-			groupName = append(groupName, ch)
-		} else if inEscapeMode && ch >= byte('0') && ch <= byte('9') {
-			capNumStr := string(ch)
-			capNum, err := strconv.ParseInt(capNumStr, 10, 32)
-			if err != nil {
-				return "", fmt.Errorf("invalid capture group number: %s", capNumStr)
+			if end >= len(pattern) {
+				parser.addLiteralByte(ch)
+				continue
 			}
-			capBytes := []byte(captures.At(int(capNum)))
-			newReplacement = append(newReplacement, capBytes...)
-		} else if inEscapeMode && ch == r.namedGroupPrefix && (index+1) < r.replLen && r.repl[index+1] == byte('<') {
-			inGroupNameMode = true
-			inEscapeMode = false
-			index++
-		} else if inEscapeMode {
-			newReplacement = append(newReplacement, '\\')
-			if ch != byte('\\') {
-				newReplacement = append(newReplacement, ch)
+			name := pattern[index+3 : end]
+			if group, err := strconv.ParseInt(name, 10, 32); err == nil {
+				parser.addPart(replacementPart{kind: replacementGroup, group: int(group)})
+			} else {
+				parser.addPart(replacementPart{kind: replacementNamedGroup, text: name})
 			}
-		} else if ch != byte('\\') {
-			newReplacement = append(newReplacement, ch)
-		}
-		if ch == byte('\\') || inEscapeMode {
-			inEscapeMode = !inEscapeMode
+			index = end
+		default:
+			parser.addLiteralByte(ch)
 		}
 	}
-	return string(newReplacement), nil
+	return parser.result()
 }
@@ -0,0 +1,383 @@
+package onig
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Engine is the regex engine that runs the searches of a Regex.
+type Engine int
+
+const (
+	// EngineOniguruma runs the searches with Oniguruma.
+	EngineOniguruma Engine = iota
+	// EngineGo runs the searches with Go's regexp package, for regexes compiled with CompileHybrid.
+	EngineGo
+)
+
+// goRegexp is the Go regexp equivalent to a regex compiled with CompileHybrid.
+type goRegexp struct {
+	regexp *regexp.Regexp
+	// matchesEmpty is true when the regex can match an empty string.
+	matchesEmpty bool
+}
+
+// CompileHybrid compiles a regex like CompileWithOptionsAndSyntax, and also compiles it with Go's regexp package
+// if the pattern has the same meaning in both engines. The searches of the whole text without SearchOptions then
+// run on Go's regexp, which avoids the cost of calling into C, and all the other searches run on Oniguruma.
+// Engine reports which engine was chosen. A nil syntax means the default syntax.
+//
+// The translation is conservative: it only accepts the Ruby, Oniguruma, Java, Perl, Perl_NG and Python syntaxes,
+// without compile options, and patterns made of literals, `.`, character classes, \d, \A, \z, groups, alternation,
+// and greedy or lazy quantifiers. Backreferences, lookaround, atomic groups, possessive quantifiers, inline options,
+// `^`, `$`, \b and the Unicode-aware \w and \s, among others, keep the regex on Oniguruma.
+//
+// Texts that aren't valid UTF-8 are searched by Oniguruma. So are the texts with non-ASCII characters when
+// listing all the matches of a regex that can match an empty string, because Oniguruma then steps through
+// the text one byte at a time and reports empty matches inside multi-byte characters.
+func CompileHybrid(pattern string, options RegexOptions, syntax *Syntax) (*Regex, error) {
+	if syntax == nil {
+		syntax = DefaultSyntax()
+	}
+	regex, err := CompileWithOptionsAndSyntax(pattern, options, syntax)
+	if err != nil {
+		return nil, err
+	}
+	translated, ok := re2Pattern(pattern, syntax, regex.Options())
+	if !ok {
+		return regex, nil
+	}
+	compiled, err := regexp.Compile(translated)
+	if err != nil {
+		return regex, nil
+	}
+	regex.goRegexp = &goRegexp{regexp: compiled, matchesEmpty: compiled.MatchString("")}
+	return regex, nil
+}
+
+// Engine returns the engine that runs the searches of the whole text without SearchOptions.
+// It is EngineGo only for regexes compiled with CompileHybrid whose pattern translates to Go's regexp.
+func (r *Regex) Engine() Engine {
+	if r.goRegexp != nil {
+		return EngineGo
+	}
+	return EngineOniguruma
+}
+
+// usesGoRegexp reports whether a search of text with params runs on Go's regexp. If all is true, then the search
+// lists all the matches of the regex.
+func (r *Regex) usesGoRegexp(text string, params searchParams, all bool) bool {
+	if r.goRegexp == nil || params != wholeTextParams(text) {
+		return false
+	}
+	if all && r.goRegexp.matchesEmpty {
+		return isASCII(text)
+	}
+	return utf8.ValidString(text)
+}
+
+// goRegexpFirst returns the region of the first match of the Go regexp in text, or nil if there is none.
+func (r *Regex) goRegexpFirst(text string) *Region {
+	positions := r.goRegexp.regexp.FindStringSubmatchIndex(text)
+	if positions == nil {
+		return nil
+	}
+	return newGoRegion(r, positions)
+}
+
+// goRegexpAll returns the captures of all the non-overlapping matches of the Go regexp in text.
+func (r *Regex) goRegexpAll(text string) []Captures {
+	matches := r.goRegexp.regexp.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	captures := make([]Captures, len(matches))
+	for i, positions := range matches {
+		captures[i] = Captures{
+			Regex:  r,
+			Region: newGoRegion(r, positions),
+			Text:   text,
+		}
+	}
+	return captures
+}
+
+func isASCII(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// re2Pattern translates pattern to the syntax of Go's regexp, and reports whether the translation has the same
+// meaning in both engines. The regex must have compiled with Oniguruma, with the given effective options.
+func re2Pattern(pattern string, syntax *Syntax, options RegexOptions) (string, bool) {
+	supported, flatClasses := prefilterSyntax(syntax)
+	// The Perl, Java and Python syntaxes set REGEX_OPTION_SINGLELINE, which only changes `$`.
+	if !supported || options&^REGEX_OPTION_SINGLELINE != REGEX_OPTION_NONE {
+		return "", false
+	}
+	var builder strings.Builder
+	builder.Grow(len(pattern))
+	named, unnamed := false, false
+	// groupStarts holds the start of the content of the open groups in the translation.
+	var groupStarts []int
+	// quantified is true after a quantifier, which can be followed by `?` to make it lazy.
+	quantified := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		afterQuantifier := quantified
+		quantified = false
+		switch c {
+		case '\\':
+			escape, end, ok := re2Escape(pattern, i, false)
+			if !ok {
+				return "", false
+			}
+			builder.WriteString(escape)
+			i = end
+		case '[':
+			class, end, ok := re2Class(pattern, i, flatClasses)
+			if !ok {
+				return "", false
+			}
+			builder.WriteString(class)
+			i = end
+		case '(':
+			group, end, ok := re2Group(pattern, i)
+			if !ok {
+				return "", false
+			}
+			switch {
+			case strings.HasPrefix(group, "(?P<"):
+				named = true
+			case group == "(":
+				unnamed = true
+			}
+			// Unnamed groups don't capture next to named groups in some syntaxes, so the group numbers would differ.
+			if named && unnamed {
+				return "", false
+			}
+			builder.WriteString(group)
+			groupStarts = append(groupStarts, builder.Len())
+			i = end
+		case ')':
+			if len(groupStarts) == 0 {
+				return "", false
+			}
+			start := groupStarts[len(groupStarts)-1]
+			groupStarts = groupStarts[:len(groupStarts)-1]
+			// The engines treat the iterations of a repeated group that match an empty string differently,
+			// so the matches and the captures of such groups can differ.
+			if isQuantifier(pattern, i+1) && matchesEmpty(builder.String()[start:]) {
+				return "", false
+			}
+			builder.WriteByte(c)
+		case '{':
+			end := skipQuantifierBraces(pattern, i)
+			if end < 0 || pattern[i+1] < '0' || pattern[i+1] > '9' {
+				return "", false
+			}
+			// A fixed repetition followed by `?` is optional in the Ruby syntax rather than lazy.
+			fixed := strings.IndexByte(pattern[i:end], ',') < 0
+			if end+1 < len(pattern) && ((fixed && pattern[end+1] == '?') || pattern[end+1] == '+') {
+				return "", false
+			}
+			builder.WriteString(pattern[i : end+1])
+			i = end
+			quantified = true
+		case '*', '+', '?':
+			if afterQuantifier && c == '+' {
+				// A possessive quantifier.
+				return "", false
+			}
+			builder.WriteByte(c)
+			quantified = !afterQuantifier
+		case '^', '$':
+			// Line anchors in the Ruby syntax, text anchors that also match before a final newline in the others.
+			return "", false
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	return builder.String(), true
+}
+
+// re2Escape translates the escape starting with the backslash at index i of pattern, and returns the index of
+// its last character. \d is translated to the decimal digits it matches in Oniguruma.
+func re2Escape(pattern string, i int, inClass bool) (string, int, bool) {
+	if i+1 >= len(pattern) {
+		return "", 0, false
+	}
+	next := pattern[i+1]
+	switch {
+	case isASCIIPunctuation(next) || strings.IndexByte("tnrfa", next) >= 0:
+		return pattern[i : i+2], i + 1, true
+	case next == 'd' && inClass:
+		return decimalDigitRanges(), i + 1, true
+	case next == 'd':
+		return "[" + decimalDigitRanges() + "]", i + 1, true
+	case next == 'D' && !inClass:
+		return "[^" + decimalDigitRanges() + "]", i + 1, true
+	case (next == 'A' || next == 'z') && !inClass:
+		return pattern[i : i+2], i + 1, true
+	case next == 'x':
+		// \xHH above 7F is a byte in Oniguruma and a code point in Go.
+		if i+3 < len(pattern) && pattern[i+2] >= '0' && pattern[i+2] <= '7' && isHexDigit(pattern[i+3]) {
+			return pattern[i : i+4], i + 3, true
+		}
+	}
+	return "", 0, false
+}
+
+// re2Class translates the character class starting at index start of pattern, and returns the index of
+// the bracket that closes it. Nested classes, POSIX brackets and intersections aren't translated.
+func re2Class(pattern string, start int, flatClasses bool) (string, int, bool) {
+	var builder strings.Builder
+	builder.WriteByte('[')
+	i := start + 1
+	if i < len(pattern) && pattern[i] == '^' {
+		builder.WriteByte('^')
+		i++
+	}
+	if i < len(pattern) && pattern[i] == ']' {
+		return "", 0, false
+	}
+	for ; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case ']':
+			builder.WriteByte(']')
+			return builder.String(), i, true
+		case '\\':
+			escape, end, ok := re2Escape(pattern, i, true)
+			if !ok {
+				return "", 0, false
+			}
+			builder.WriteString(escape)
+			i = end
+		case '[':
+			// A nested class, or a POSIX bracket such as [:alpha:] in the syntaxes with flat classes.
+			return "", 0, false
+		case '&':
+			if i+1 < len(pattern) && pattern[i+1] == '&' && !flatClasses {
+				return "", 0, false
+			}
+			builder.WriteByte(c)
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
+
+// re2Group translates the opening of the group starting at index i of pattern, and returns the index of its last
+// character. Only capturing, non-capturing and named groups are translated, with names in the (?P<name>) form.
+func re2Group(pattern string, i int) (string, int, bool) {
+	text := pattern[i:]
+	switch {
+	case !strings.HasPrefix(text, "(?"):
+		return "(", i, true
+	case strings.HasPrefix(text, "(?:"):
+		return "(?:", i + 2, true
+	}
+	nameStart := 0
+	switch {
+	case strings.HasPrefix(text, "(?<"):
+		nameStart = 3
+	case strings.HasPrefix(text, "(?P<"):
+		nameStart = 4
+	default:
+		return "", 0, false
+	}
+	nameEnd := strings.IndexByte(text[nameStart:], '>')
+	if nameEnd <= 0 {
+		return "", 0, false
+	}
+	name := text[nameStart : nameStart+nameEnd]
+	for j := 0; j < len(name); j++ {
+		c := name[j]
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !isLetter && (j == 0 || c < '0' || c > '9') {
+			return "", 0, false
+		}
+	}
+	return "(?P<" + name + ">", i + nameStart + nameEnd, true
+}
+
+// isQuantifier reports whether a quantifier starts at index i of pattern.
+func isQuantifier(pattern string, i int) bool {
+	if i >= len(pattern) {
+		return false
+	}
+	switch pattern[i] {
+	case '*', '+', '?':
+		return true
+	case '{':
+		return skipQuantifierBraces(pattern, i) >= 0
+	}
+	return false
+}
+
+// matchesEmpty reports whether the translated pattern can match an empty string.
+// It also returns true if the pattern doesn't compile, so that it is rejected.
+func matchesEmpty(translated string) bool {
+	compiled, err := regexp.Compile(`^(?:` + translated + `)$`)
+	return err != nil || compiled.MatchString("")
+}
+
+var (
+	decimalDigitsOnce sync.Once
+	decimalDigits     string
+)
+
+// decimalDigitRanges returns the content of a Go character class that matches the characters \d matches in Oniguruma.
+// The decimal digits of Go's Unicode tables are checked with Oniguruma, whose Unicode version can be older.
+func decimalDigitRanges() string {
+	decimalDigitsOnce.Do(func() {
+		var candidates strings.Builder
+		for _, r16 := range unicode.Nd.R16 {
+			for c := int(r16.Lo); c <= int(r16.Hi); c += int(r16.Stride) {
+				candidates.WriteRune(rune(c))
+			}
+		}
+		for _, r32 := range unicode.Nd.R32 {
+			for c := int(r32.Lo); c <= int(r32.Hi); c += int(r32.Stride) {
+				candidates.WriteRune(rune(c))
+			}
+		}
+		text := candidates.String()
+		var builder strings.Builder
+		writeRange := func(lo rune, hi rune) {
+			if lo < 0 {
+				return
+			}
+			if lo == hi {
+				fmt.Fprintf(&builder, `\x{%x}`, lo)
+			} else {
+				fmt.Fprintf(&builder, `\x{%x}-\x{%x}`, lo, hi)
+			}
+		}
+		lo, hi := rune(-1), rune(-1)
+		for _, match := range MustCompileWithSyntax(`\d`, SyntaxRuby).MustFindMatches(text) {
+			c, _ := utf8.DecodeRuneInString(text[match.From:])
+			if c != hi+1 {
+				writeRange(lo, hi)
+				lo = c
+			}
+			hi = c
+		}
+		writeRange(lo, hi)
+		decimalDigits = builder.String()
+	})
+	return decimalDigits
+}
+
+func isHexDigit(c byte) bool {
+	return strings.IndexByte("0123456789abcdefABCDEF", c) >= 0
+}
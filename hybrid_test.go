@@ -0,0 +1,198 @@
+package onig
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestCompileHybrid_Engine(t *testing.T) {
+	for _, test := range []struct {
+		pattern  string
+		syntax   *Syntax
+		options  RegexOptions
+		expected Engine
+	}{
+		{`abc`, nil, REGEX_OPTION_NONE, EngineGo},
+		{`a.c*?|[^x-z\]]+\.`, SyntaxRuby, REGEX_OPTION_NONE, EngineGo},
+		{`\A(?<year>\d{4})-(?<month>\d\d)\z`, SyntaxRuby, REGEX_OPTION_NONE, EngineGo},
+		{`(?P<word>[a-z]+)`, SyntaxPython, REGEX_OPTION_NONE, EngineGo},
+		{`(a|b)(?:c)\x41\t`, SyntaxJava, REGEX_OPTION_NONE, EngineGo},
+		{`x{2,}y{1,3}?`, SyntaxPerl, REGEX_OPTION_NONE, EngineGo},
+		{`[[]a]`, SyntaxPerl, REGEX_OPTION_NONE, EngineOniguruma},
+		{`(a)\1`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`(?<n>a)\k<n>`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`a(?=b)`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`(?<!a)b`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`(?>a+)b`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`a++b`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`a{2}+b`, SyntaxJava, REGEX_OPTION_NONE, EngineOniguruma},
+		{`a{2}?`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`a{,2}`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`(?i)abc`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`abc`, SyntaxRuby, REGEX_OPTION_IGNORECASE, EngineOniguruma},
+		{`a.c`, SyntaxRuby, REGEX_OPTION_MULTILINE, EngineOniguruma},
+		{`^abc$`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`abc\Z`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`\bword\b`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`\w+\s`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`[[:alpha:]]`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`[a-z&&[^x]]`, SyntaxJava, REGEX_OPTION_NONE, EngineOniguruma},
+		{`\xff`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`\p{L}`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`(?<name>a)(b)`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`a{1001}`, SyntaxRuby, REGEX_OPTION_NONE, EngineOniguruma},
+		{`abc`, SyntaxPosixExtended, REGEX_OPTION_NONE, EngineOniguruma},
+	} {
+		regex, err := CompileHybrid(test.pattern, test.options, test.syntax)
+		if assert.NoError(t, err, test.pattern) {
+			assert.Equal(t, test.expected, regex.Engine(), test.pattern)
+		}
+	}
+
+	_, err := CompileHybrid(`a(b`, REGEX_OPTION_NONE, nil)
+	assert.Error(t, err)
+	assert.Equal(t, EngineOniguruma, MustCompile(`abc`).Engine())
+}
+
+func TestCompileHybrid_Clone(t *testing.T) {
+	regex, err := CompileHybrid(`a+`, REGEX_OPTION_NONE, nil)
+	assert.NoError(t, err)
+	clone, err := regex.Clone()
+	assert.NoError(t, err)
+	assert.Equal(t, EngineGo, clone.Engine())
+	assert.Equal(t, []*Range{NewRange(1, 3)}, clone.MustFindMatches("baa"))
+}
+
+// randomHybridPattern returns a random pattern made of the constructs that CompileHybrid translates.
+func randomHybridPattern(random *rand.Rand, syntax *Syntax, depth int, groups *int) string {
+	atoms := []string{"a", "b", "ü", `\.`, ".", "[a-c]", "[^a]", `\d`, `[\dx]`, `\x61`, "[ü-ÿ]", " ", `\n`}
+	quantifiers := []string{"", "", "", "*", "+", "?", "*?", "+?", "??", "{1,2}", "{2}", "{0,}", "{1,2}?"}
+	var builder strings.Builder
+	for range 1 + random.Intn(3) {
+		if depth > 0 && random.Intn(3) == 0 {
+			inner := randomHybridPattern(random, syntax, depth-1, groups)
+			switch {
+			case random.Intn(3) == 0:
+				builder.WriteString("(?:" + inner + ")")
+			case syntax == SyntaxPerl || syntax == SyntaxJava:
+				*groups++
+				builder.WriteString("(" + inner + ")")
+			case syntax == SyntaxPython:
+				*groups++
+				builder.WriteString(fmt.Sprintf("(?P<g%d>%s)", *groups, inner))
+			default:
+				*groups++
+				builder.WriteString(fmt.Sprintf("(?<g%d>%s)", *groups, inner))
+			}
+		} else {
+			builder.WriteString(atoms[random.Intn(len(atoms))])
+		}
+		builder.WriteString(quantifiers[random.Intn(len(quantifiers))])
+	}
+	if random.Intn(4) == 0 {
+		builder.WriteString("|" + randomHybridPattern(random, syntax, depth-1, groups))
+	}
+	return builder.String()
+}
+
+func TestCompileHybrid_Differential(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	alphabet := []string{"a", "b", "c", "x", "ü", "ÿ", "1", "٣", " ", "\n", "."}
+	texts := []string{"", "a", "ab", "aab", "üü", "a\nb"}
+	for range 100 {
+		var builder strings.Builder
+		for range random.Intn(10) {
+			builder.WriteString(alphabet[random.Intn(len(alphabet))])
+		}
+		texts = append(texts, builder.String())
+	}
+	for _, syntax := range []*Syntax{SyntaxRuby, SyntaxPerl, SyntaxJava, SyntaxPython} {
+		translated := 0
+		for range 150 {
+			groups := 0
+			pattern := randomHybridPattern(random, syntax, 2, &groups)
+			expected, err := CompileWithSyntax(pattern, syntax)
+			if err != nil {
+				continue
+			}
+			hybrid, err := CompileHybrid(pattern, REGEX_OPTION_NONE, syntax)
+			assert.NoError(t, err)
+			if hybrid.Engine() != EngineGo {
+				continue
+			}
+			translated++
+			for _, text := range texts {
+				message := fmt.Sprintf("%s: %s in %q", syntax.Name(), pattern, text)
+				assert.Equal(t, expected.MustFindMatches(text), hybrid.MustFindMatches(text), message)
+				assert.Equal(t, expected.MustFindMatch(text), hybrid.MustFindMatch(text), message)
+				assert.Equal(t, capturePositions(expected.MustAllCaptures(text)), capturePositions(hybrid.MustAllCaptures(text)), message)
+				assert.Equal(t, expected.MustSplit(text), hybrid.MustSplit(text), message)
+				if groups > 0 {
+					expectedCaptures, actualCaptures := expected.MustCaptures(text), hybrid.MustCaptures(text)
+					if expectedCaptures == nil {
+						assert.Nil(t, actualCaptures, message)
+					} else if assert.NotNil(t, actualCaptures, message) {
+						assert.Equal(t, capturePositions([]Captures{*expectedCaptures}), capturePositions([]Captures{*actualCaptures}), message)
+						assert.Equal(t, expectedCaptures.PosByGroupName("g1"), actualCaptures.PosByGroupName("g1"), message)
+					}
+				}
+			}
+		}
+		assert.Greater(t, translated, 100, syntax.Name())
+	}
+}
+
+func TestCompileHybrid_DecimalDigits(t *testing.T) {
+	var builder strings.Builder
+	for r := rune(0); r <= unicode.MaxRune; r++ {
+		if r < 0xD800 || r > 0xDFFF {
+			builder.WriteRune(r)
+		}
+	}
+	text := builder.String()
+	for _, pattern := range []string{`\d+`, `[\dx]+`, `\D+`, `[^\d]+`} {
+		hybrid, err := CompileHybrid(pattern, REGEX_OPTION_NONE, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, EngineGo, hybrid.Engine())
+		assert.Equal(t, MustCompile(pattern).MustFindMatches(text), hybrid.MustFindMatches(text), pattern)
+	}
+}
+
+func TestCompileHybrid_FallsBackToOniguruma(t *testing.T) {
+	hybrid, err := CompileHybrid(`x*`, REGEX_OPTION_NONE, nil)
+	assert.NoError(t, err)
+	expected := MustCompile(`x*`)
+	// Empty matches inside multi-byte characters are only reported by Oniguruma.
+	assert.Equal(t, expected.MustFindMatches("üx"), hybrid.MustFindMatches("üx"))
+	assert.Equal(t, capturePositions(expected.MustAllCaptures("üx")), capturePositions(hybrid.MustAllCaptures("üx")))
+
+	hybrid, err = CompileHybrid(`a.`, REGEX_OPTION_NONE, nil)
+	assert.NoError(t, err)
+	expected = MustCompile(`a.`)
+	for _, text := range []string{"a\xff", "xa\xc3", "a\x80a\xfeb"} {
+		assert.Equal(t, expected.MustFindMatches(text), hybrid.MustFindMatches(text), "%q", text)
+		assert.Equal(t, expected.MustFindMatch(text), hybrid.MustFindMatch(text), "%q", text)
+	}
+
+	text := "ab\nab"
+	for _, options := range [][]SearchOption{
+		{WithRange(1, 5)},
+		{WithSearchTimeOptions(REGEX_OPTION_NOTBOL)},
+		{WithRetryLimitInMatch(10)},
+	} {
+		assert.Equal(t, MustFindMatchesWithSearchOptions(t, expected, text, options), MustFindMatchesWithSearchOptions(t, hybrid, text, options))
+	}
+	region, err := hybrid.SearchFirstWithParam(text, 4, 0, REGEX_OPTION_NONE, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, NewRange(3, 5), region.Pos(0))
+}
+
+func MustFindMatchesWithSearchOptions(t *testing.T, regex *Regex, text string, options []SearchOption) []*Range {
+	matches, err := regex.FindMatchesWithSearchOptions(text, options...)
+	assert.NoError(t, err)
+	return matches
+}
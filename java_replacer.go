@@ -2,88 +2,209 @@ package onig
 
 import (
 	"fmt"
-	"strconv"
+	"strings"
 )
 
 // JavaReplacer is a RegexReplacer that replaces capture groups with the corresponding captured text.
+//
+// The replacement pattern follows the grammar of java.util.regex.Matcher#appendReplacement:
+//   - `$n` refers to the capture group n. Java reads the longest group number that is still
+//     a valid group of the regex, so with 11 groups `$12` is group 1 followed by a literal "2".
+//   - `${name}` refers to the named capture group name.
+//   - `\x` is the literal character x.
+//
+// The pattern is parsed once when the replacer is created.
+// Group references are checked against the regex when Replace or Validate is called.
 type JavaReplacer struct {
-	repl    []byte
-	replLen int
+	parts []javaReplacementPart
+	err   error
+}
+
+// javaReplacementPart is a single pre-parsed piece of a Java replacement pattern.
+// Exactly one of literal, digits or name is set.
+type javaReplacementPart struct {
+	literal string
+	// digits holds every digit following a `$`.
+	// How many of them belong to the group number depends on the number of groups in the regex.
+	digits string
+	name   string
 }
 
 // NewJavaRegexReplacer creates a new JavaReplacer given a replacement pattern.
 // See https://docs.oracle.com/javase/8/docs/api/java/util/regex/Matcher.html#appendReplacement-java.lang.StringBuffer-java.lang.String- for more information.
 func NewJavaRegexReplacer(pattern string) RegexReplacer {
-	repl := []byte(pattern)
+	parts, err := parseJavaReplacement(pattern)
 	return &JavaReplacer{
-		repl:    repl,
-		replLen: len(repl),
+		parts: parts,
+		err:   err,
 	}
 }
 
+// QuoteJavaReplacement returns a replacement pattern that inserts s literally
+// when used with a JavaReplacer.
+// This is the equivalent of java.util.regex.Matcher#quoteReplacement.
+func QuoteJavaReplacement(s string) string {
+	if !strings.ContainsAny(s, `\$`) {
+		return s
+	}
+	var builder strings.Builder
+	builder.Grow(len(s) + 2)
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' || s[i] == '$' {
+			builder.WriteByte('\\')
+		}
+		builder.WriteByte(s[i])
+	}
+	return builder.String()
+}
+
 // Replace applies the replacement pattern to the captures.
 func (r *JavaReplacer) Replace(captures *Captures) (string, error) {
-	newReplacement := make([]byte, 0, r.replLen*3)
-	inEscapeMode := false
-	isGroupMode := false
-	for index := 0; index < r.replLen; index++ {
-		ch := r.repl[index]
-		if isGroupMode {
+	if r.err != nil {
+		return "", r.err
+	}
+	groupCount := captures.Len() - 1
+	var builder strings.Builder
+	for _, part := range r.parts {
+		switch {
+		case part.digits != "":
+			group, rest, err := resolveJavaGroupNumber(part.digits, groupCount)
+			if err != nil {
+				return "", err
+			}
+			builder.WriteString(captures.At(group))
+			builder.WriteString(rest)
+		case part.name != "":
+			if captures.Regex.GetGroupNumbersForGroupName(part.name) == nil {
+				return "", fmt.Errorf("no group with name {%s}", part.name)
+			}
+			builder.WriteString(captures.AtGroupName(part.name))
+		default:
+			builder.WriteString(part.literal)
+		}
+	}
+	return builder.String(), nil
+}
+
+// Validate checks that the replacement pattern is well-formed and that every group it refers to exists in regex.
+// Java reports these problems with IllegalArgumentException and IndexOutOfBoundsException.
+func (r *JavaReplacer) Validate(regex *Regex) error {
+	_, err := r.compileTemplate(regex)
+	return err
+}
+
+func (r *JavaReplacer) compileTemplate(regex *Regex) ([]replacementPart, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	groupCount := regex.NumberOfCaptures()
+	var parser replacementParser
+	for _, part := range r.parts {
+		switch {
+		case part.digits != "":
+			group, rest, err := resolveJavaGroupNumber(part.digits, groupCount)
+			if err != nil {
+				return nil, err
+			}
+			parser.addPart(replacementPart{kind: replacementGroup, group: group})
+			parser.addLiteral(rest)
+		case part.name != "":
+			if regex.GetGroupNumbersForGroupName(part.name) == nil {
+				return nil, fmt.Errorf("no group with name {%s}", part.name)
+			}
+			parser.addPart(replacementPart{kind: replacementNamedGroup, text: part.name})
+		default:
+			parser.addLiteral(part.literal)
+		}
+	}
+	return parser.result(), nil
+}
+
+// parseJavaReplacement splits a Java replacement pattern into literal text and group references.
+func parseJavaReplacement(pattern string) ([]javaReplacementPart, error) {
+	parts := make([]javaReplacementPart, 0)
+	literal := make([]byte, 0, len(pattern))
+	flushLiteral := func() {
+		if len(literal) > 0 {
+			parts = append(parts, javaReplacementPart{literal: string(literal)})
+			literal = literal[:0]
+		}
+	}
+	for index := 0; index < len(pattern); index++ {
+		ch := pattern[index]
+		switch ch {
+		case '\\':
+			index++
+			if index >= len(pattern) {
+				return nil, fmt.Errorf("character to be escaped is missing")
+			}
+			literal = append(literal, pattern[index])
+		case '$':
+			index++
+			if index >= len(pattern) {
+				return nil, fmt.Errorf("illegal group reference: group index is missing")
+			}
+			ch = pattern[index]
 			if ch == '{' {
-				isGroupClosed := false
-				groupName := make([]byte, 0, r.replLen)
-				for index++; index < r.replLen; index++ {
-					ch = r.repl[index]
-					if ch == '}' {
-						isGroupClosed = true
-					} else {
-						groupName = append(groupName, ch)
-					}
+				start := index + 1
+				end := start
+				for end < len(pattern) && isASCIIAlphanumeric(pattern[end]) {
+					end++
 				}
-				if !isGroupClosed {
-					return "", fmt.Errorf("missing closing brace in replacement pattern")
+				if end == start {
+					return nil, fmt.Errorf("named capturing group has 0 length name")
 				}
-				capBytes := []byte(captures.AtGroupName(string(groupName)))
-				newReplacement = append(newReplacement, capBytes...)
-			} else if ch >= '0' && ch <= '9' {
-				groupNumber := make([]byte, 0, r.replLen)
-				groupNumber = append(groupNumber, ch)
-				for index++; index < r.replLen; index++ {
-					ch = r.repl[index]
-					if ch >= '0' && ch <= '9' {
-						groupNumber = append(groupNumber, ch)
-					} else {
-						index--
-						break
-					}
+				if end >= len(pattern) || pattern[end] != '}' {
+					return nil, fmt.Errorf("named capturing group is missing trailing '}'")
 				}
-				capNumStr := string(groupNumber)
-				capNum, err := strconv.ParseInt(capNumStr, 10, 32)
-				if err != nil {
-					return "", fmt.Errorf("invalid capture group number: %s", capNumStr)
+				name := pattern[start:end]
+				if isASCIIDigit(name[0]) {
+					return nil, fmt.Errorf("capturing group name {%s} starts with digit character", name)
 				}
-				capBytes := []byte(captures.At(int(capNum)))
-				newReplacement = append(newReplacement, capBytes...)
+				flushLiteral()
+				parts = append(parts, javaReplacementPart{name: name})
+				index = end
+			} else if isASCIIDigit(ch) {
+				start := index
+				for index+1 < len(pattern) && isASCIIDigit(pattern[index+1]) {
+					index++
+				}
+				flushLiteral()
+				parts = append(parts, javaReplacementPart{digits: pattern[start : index+1]})
 			} else {
-				return "", fmt.Errorf("unexpected token in replacement pattern: %s", string(ch))
+				return nil, fmt.Errorf("illegal group reference")
 			}
-			isGroupMode = false
-		} else if inEscapeMode {
-			newReplacement = append(newReplacement, ch)
-			inEscapeMode = false
-		} else if ch == '\\' {
-			inEscapeMode = true
-		} else if ch == '$' {
-			isGroupMode = true
-		} else {
-			newReplacement = append(newReplacement, ch)
+		default:
+			literal = append(literal, ch)
 		}
 	}
-	if isGroupMode {
-		return "", fmt.Errorf("incomplete replacement pattern")
+	flushLiteral()
+	return parts, nil
+}
+
+// resolveJavaGroupNumber reads the group number from the digits that followed a `$`.
+// Like Java, it keeps consuming digits for as long as the number is still a valid group,
+// and returns the remaining digits as literal text.
+func resolveJavaGroupNumber(digits string, groupCount int) (int, string, error) {
+	group := int(digits[0] - '0')
+	if group > groupCount {
+		return 0, "", fmt.Errorf("no group %d", group)
 	}
-	if inEscapeMode {
-		return "", fmt.Errorf("incomplete escape pattern")
+	index := 1
+	for ; index < len(digits); index++ {
+		next := group*10 + int(digits[index]-'0')
+		if next > groupCount {
+			break
+		}
+		group = next
 	}
-	return string(newReplacement), nil
+	return group, digits[index:], nil
+}
+
+func isASCIIDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isASCIIAlphanumeric(ch byte) bool {
+	return isASCIIDigit(ch) || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
 }
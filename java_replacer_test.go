@@ -26,3 +26,103 @@ func TestJavaRegexReplacer(t *testing.T) {
 	//assertReplaceError(t, r, "hello world", `goodbye $\{name}`)
 	//assertReplaceError(t, r, "hello world", `goodbye ${name\}`)
 }
+
+func TestJavaRegexReplacer_Conformance(t *testing.T) {
+	// Expected values follow java.util.regex.Matcher#replaceAll.
+	r := MustCompileWithSyntax(`(a)(b)(c)(d)(e)(f)(g)(h)(i)(j)(k)(x)?`, SyntaxJava)
+	text := "abcdefghijkx"
+	tests := []struct {
+		replacement string
+		expected    string
+	}{
+		{`$1`, "a"},
+		{`$11`, "k"},
+		{`$12`, "x"},
+		{`$13`, "a3"},
+		{`$111`, "k1"},
+		{`$01`, "a"},
+		{`$0`, "abcdefghijkx"},
+		{`$12$1`, "xa"},
+		{`[$2$3]`, "[bc]"},
+		{`\$1`, "$1"},
+		{`\\$1`, `\a`},
+		{`\a\b`, "ab"},
+		{`$1\2`, "a2"},
+		{`{$5}`, "{e}"},
+	}
+	for _, test := range tests {
+		t.Run(test.replacement, func(t *testing.T) {
+			replaced, err := r.ReplaceAll(text, test.replacement)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, replaced)
+		})
+	}
+}
+
+func TestJavaRegexReplacer_UnmatchedGroup(t *testing.T) {
+	r := MustCompileWithSyntax(`(a)|(b)`, SyntaxJava)
+	assert.Equal(t, "[a][]", r.MustReplace("a", `[$1][$2]`))
+	assert.Equal(t, "[][b]", r.MustReplace("b", `[$1][$2]`))
+}
+
+func TestJavaRegexReplacer_Errors(t *testing.T) {
+	r := MustCompileWithSyntax(`(a)(b)`, SyntaxJava)
+	tests := []struct {
+		replacement string
+		message     string
+	}{
+		{`\`, "character to be escaped is missing"},
+		{`$`, "illegal group reference: group index is missing"},
+		{`$x`, "illegal group reference"},
+		{`$3`, "no group 3"},
+		{`${`, "named capturing group has 0 length name"},
+		{`${}`, "named capturing group has 0 length name"},
+		{`${name`, "named capturing group is missing trailing '}'"},
+		{`${na-me}`, "named capturing group is missing trailing '}'"},
+		{`${1name}`, "capturing group name {1name} starts with digit character"},
+		{`${name}`, "no group with name {name}"},
+	}
+	for _, test := range tests {
+		t.Run(test.replacement, func(t *testing.T) {
+			_, err := r.ReplaceAll("ab", test.replacement)
+			assert.ErrorContains(t, err, test.message)
+			replacer := NewJavaRegexReplacer(test.replacement).(*JavaReplacer)
+			assert.EqualError(t, replacer.Validate(r), test.message)
+		})
+	}
+}
+
+func TestJavaRegexReplacer_NamedGroups(t *testing.T) {
+	// Oniguruma's Java syntax has no named groups, so the replacer is used with a Ruby regex.
+	r := MustCompile(`hello (?<name>.*)`)
+	captures := r.MustCaptures("hello world")
+	replace := func(replacement string) string {
+		replaced, err := NewJavaRegexReplacer(replacement).Replace(captures)
+		assert.NoError(t, err)
+		return replaced
+	}
+	assert.Equal(t, "goodbye world", replace(`goodbye ${name}`))
+	assert.Equal(t, "goodbye {} world", replace(`goodbye {} ${name}`))
+	assert.Equal(t, "goodbye world}", replace(`goodbye ${name}}`))
+	assert.Equal(t, "goodbye $world", replace(`goodbye \$${name}`))
+	for _, replacement := range []string{`goodbye $ {name}`, `goodbye $\{name}`, `goodbye ${name\}`, `goodbye ${other}`} {
+		_, err := NewJavaRegexReplacer(replacement).Replace(captures)
+		assert.Error(t, err, replacement)
+	}
+}
+
+func TestJavaRegexReplacer_Validate(t *testing.T) {
+	r := MustCompileWithSyntax(`(a)(b)`, SyntaxJava)
+	assert.NoError(t, NewJavaRegexReplacer(`$0$1$2$21`).(*JavaReplacer).Validate(r))
+	assert.NoError(t, NewJavaRegexReplacer(`plain \$3 text`).(*JavaReplacer).Validate(r))
+}
+
+func TestQuoteJavaReplacement(t *testing.T) {
+	assert.Equal(t, "plain", QuoteJavaReplacement("plain"))
+	assert.Equal(t, `\$1 \\ \${x}`, QuoteJavaReplacement(`$1 \ ${x}`))
+
+	r := MustCompileWithSyntax(`world`, SyntaxJava)
+	for _, s := range []string{`$1`, `\`, `a\$b`, `${name}`, `$$\\`} {
+		assert.Equal(t, "hello "+s, r.MustReplaceAll("hello world", QuoteJavaReplacement(s)))
+	}
+}
@@ -0,0 +1,77 @@
+package onig
+
+/*
+#include "regex.h"
+*/
+import "C"
+import (
+	"iter"
+	"runtime"
+)
+
+// OverlappingOptions configure FindOverlappingWithOptions.
+type OverlappingOptions struct {
+	// AllMatches reports every match starting at each position, from every way the regex can match there,
+	// rather than only the first match at each position. Matches with the same start and end are reported once.
+	AllMatches bool
+}
+
+// FindOverlapping returns an iterator over the captures of all matches of the regex in text, including overlapping ones.
+// After each match, the search restarts one character after the start of the match, so there is at most
+// one match starting at each position: `aa` in "aaaa" matches at 0, 1 and 2.
+// Characters follow the encoding of the regex, so the search never restarts inside a multibyte character.
+// The iteration stops if searching fails, which looks like the end of the matches;
+// use FindOverlappingSeq to get the error, as a search can exceed Oniguruma's retry or stack limits.
+func (r *Regex) FindOverlapping(text string) iter.Seq[*Captures] {
+	return r.FindOverlappingWithOptions(text, OverlappingOptions{})
+}
+
+// FindOverlappingWithOptions returns an iterator over the captures of all matches of the regex in text,
+// including overlapping ones, as configured by options.
+// With AllMatches set, `a+` in "aaa" yields "aaa", "aa" and "a" at 0, then "aa" and "a" at 1, and "a" at 2.
+// The iteration stops if searching fails; use FindOverlappingSeq to get the error.
+func (r *Regex) FindOverlappingWithOptions(text string, options OverlappingOptions) iter.Seq[*Captures] {
+	return func(yield func(*Captures) bool) {
+		for captures, err := range r.FindOverlappingSeq(text, options) {
+			if err != nil || !yield(captures) {
+				return
+			}
+		}
+	}
+}
+
+// FindOverlappingSeq is like FindOverlappingWithOptions, but if searching fails, the error is yielded
+// and the iteration stops, so a failed search can be told apart from the end of the matches.
+func (r *Regex) FindOverlappingSeq(text string, options OverlappingOptions) iter.Seq2[*Captures, error] {
+	return func(yield func(*Captures, error) bool) {
+		captures, err := r.overlappingCaptures(text, options)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for i := range captures {
+			if !yield(&captures[i], nil) {
+				return
+			}
+		}
+	}
+}
+
+func (r *Regex) overlappingCaptures(text string, options OverlappingOptions) ([]Captures, error) {
+	allMatches := C.int(0)
+	if options.AllMatches {
+		allMatches = 1
+	}
+	result := C.searchOverlappingWithParam(
+		r.raw,
+		C.CString(text),
+		C.uint(len(text)),
+		C.OnigOptionType(REGEX_OPTION_NONE),
+		allMatches,
+	)
+	runtime.KeepAlive(r)
+	if result.result < 0 {
+		return nil, errorFromCode(result.result)
+	}
+	return r.capturesFromRegionsArray(text, result.array), nil
+}
@@ -0,0 +1,95 @@
+package onig
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func collectOverlapping(r *Regex, text string, options OverlappingOptions) []*Range {
+	matches := make([]*Range, 0)
+	for captures := range r.FindOverlappingWithOptions(text, options) {
+		matches = append(matches, captures.Pos(0))
+	}
+	return matches
+}
+
+func TestRegex_FindOverlapping(t *testing.T) {
+	r := MustCompile(`aa`)
+	matches := make([]*Range, 0)
+	for captures := range r.FindOverlapping("aaaa") {
+		matches = append(matches, captures.Pos(0))
+	}
+	assert.Equal(t, []*Range{NewRange(0, 2), NewRange(1, 3), NewRange(2, 4)}, matches)
+}
+
+func TestRegex_FindOverlapping_Multibyte(t *testing.T) {
+	r := MustCompile(`..`)
+	matches := collectOverlapping(r, "aé€", OverlappingOptions{})
+	assert.Equal(t, []*Range{NewRange(0, 3), NewRange(1, 6)}, matches)
+}
+
+func TestRegex_FindOverlapping_Captures(t *testing.T) {
+	r := MustCompile(`(?<codon>A[CG]T)`)
+	codons := make([]string, 0)
+	for captures := range r.FindOverlapping("ACTGATAGTACT") {
+		codons = append(codons, captures.AtGroupName("codon"))
+	}
+	assert.Equal(t, []string{"ACT", "AGT", "ACT"}, codons)
+}
+
+func TestRegex_FindOverlapping_EmptyMatches(t *testing.T) {
+	r := MustCompile(`x*`)
+	matches := collectOverlapping(r, "ab", OverlappingOptions{})
+	assert.Equal(t, []*Range{NewRange(0, 0), NewRange(1, 1), NewRange(2, 2)}, matches)
+}
+
+func TestRegex_FindOverlapping_AllMatches(t *testing.T) {
+	r := MustCompile(`a+`)
+	matches := collectOverlapping(r, "aaa", OverlappingOptions{AllMatches: true})
+	assert.Equal(t, []*Range{
+		NewRange(0, 3), NewRange(0, 2), NewRange(0, 1),
+		NewRange(1, 3), NewRange(1, 2),
+		NewRange(2, 3),
+	}, matches)
+
+	r = MustCompile(`a|a|ab`)
+	matches = collectOverlapping(r, "ab", OverlappingOptions{AllMatches: true})
+	assert.Equal(t, []*Range{NewRange(0, 1), NewRange(0, 2)}, matches)
+}
+
+func TestRegex_FindOverlapping_Break(t *testing.T) {
+	r := MustCompile(`a`)
+	count := 0
+	for range r.FindOverlapping("aaaa") {
+		count++
+		if count == 2 {
+			break
+		}
+	}
+	assert.Equal(t, 2, count)
+}
+
+func TestRegex_FindOverlappingSeq(t *testing.T) {
+	r := MustCompile(`aa`)
+	matches := make([]*Range, 0)
+	for captures, err := range r.FindOverlappingSeq("aaaa", OverlappingOptions{}) {
+		assert.NoError(t, err)
+		matches = append(matches, captures.Pos(0))
+	}
+	assert.Equal(t, []*Range{NewRange(0, 2), NewRange(1, 3), NewRange(2, 4)}, matches)
+}
+
+func TestRegex_FindOverlappingSeq_Error(t *testing.T) {
+	r := MustCompile(`(a|a)*$`)
+	text := strings.Repeat("a", 40) + "!"
+	var errs []error
+	for captures, err := range r.FindOverlappingSeq(text, OverlappingOptions{}) {
+		assert.Nil(t, captures)
+		errs = append(errs, err)
+	}
+	if assert.Len(t, errs, 1) {
+		assert.Error(t, errs[0])
+	}
+	assert.Empty(t, collectOverlapping(r, text, OverlappingOptions{}))
+}
@@ -0,0 +1,332 @@
+package onig
+
+/*
+#include "regex.h"
+*/
+import "C"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/tmikus/onig-go/v2/syntax/ast"
+	"math"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// DefaultParallelChunkSize is the number of bytes FindAllParallel searches in each chunk, unless set by ParallelOptions.
+const DefaultParallelChunkSize = 1 << 20
+
+// ErrParallelUnsupported is returned by FindAllParallel when the result of a chunked search
+// can't be guaranteed to equal the result of a sequential search.
+var ErrParallelUnsupported = errors.New("regex can't be searched in parallel chunks")
+
+// ParallelOptions configure FindAllParallel. Either MaxMatchLength or Lines must be set.
+type ParallelOptions struct {
+	// MaxMatchLength is an upper bound, in bytes, of the length of every match of the regex.
+	// Each chunk is searched in a window that extends MaxMatchLength bytes past its end,
+	// so a match longer than MaxMatchLength can be cut short or missed.
+	MaxMatchLength int
+	// Lines splits the data after newlines instead, for regexes whose matches never extend past a newline,
+	// such as patterns applied to single lines. MaxMatchLength is ignored.
+	// The pattern must not match a newline except at its end, which is checked with the syntax/ast package.
+	Lines bool
+	// ChunkSize is the number of bytes in each chunk. Zero means DefaultParallelChunkSize.
+	ChunkSize int
+	// Workers is the number of chunks searched at once. Zero means runtime.GOMAXPROCS(0).
+	Workers int
+}
+
+// parallelUnsafeConstructs are the constructs that make the result of a regex depend on the text after
+// the end of the match, or on the position a search starts at, which the chunks of FindAllParallel can't reproduce.
+var parallelUnsafeConstructs = []struct {
+	text string
+	name string
+}{
+	{`(?=`, "lookahead"},
+	{`(?!`, "negative lookahead"},
+	{`(?~`, "absent operator"},
+	{`\G`, `\G anchor`},
+	{`\K`, `\K keep`},
+}
+
+// parallelUnsafeConstruct returns the name of the first construct of pattern that FindAllParallel refuses,
+// or an empty string. Escaped characters are skipped, but the check is otherwise lexical, so it can also
+// refuse a pattern that only contains the construct in a character class or a comment.
+func parallelUnsafeConstruct(pattern string) string {
+	for i := 0; i < len(pattern); i++ {
+		for _, construct := range parallelUnsafeConstructs {
+			if strings.HasPrefix(pattern[i:], construct.text) {
+				return construct.name
+			}
+		}
+		if pattern[i] == '\\' {
+			i++
+		}
+	}
+	return ""
+}
+
+// linesUnsafeReason returns why the matches of the regex can extend past a newline, which FindAllParallel refuses
+// in Lines mode, or an empty string. A pattern can match a newline only as its last item, such as `\w+\n`,
+// since the match then ends where the chunk does. Parts such as `\s`, `\R`, `\X`, `[^x]` and `.` under
+// REGEX_OPTION_MULTILINE can match a newline. The check is conservative, so a lookbehind that can match
+// a newline is refused too, although it doesn't extend the match.
+func (r *Regex) linesUnsafeReason() string {
+	flavor := astFlavor(r.syntax)
+	if flavor == nil {
+		return fmt.Sprintf("syntax %q isn't supported by the syntax/ast package", r.syntax.Name())
+	}
+	tree, err := ast.ParseWithOptions(r.pattern, flavor, ast.Options(r.options))
+	if err != nil {
+		return err.Error()
+	}
+	root := tree.Root
+	if concat, ok := root.(*ast.Concat); ok && len(concat.Items) > 0 {
+		if literal, ok := concat.Items[len(concat.Items)-1].(*ast.Literal); ok && literal.Char == '\n' {
+			root = &ast.Concat{Span: concat.Span, Items: concat.Items[:len(concat.Items)-1]}
+		}
+	}
+	if newAnalyzer(r.pattern, tree, r).mayMatchChar(root, '\n') {
+		return "a part that can match a newline"
+	}
+	return ""
+}
+
+// parallelChunk is a part of the data whose matches are searched by one worker of FindAllParallel.
+type parallelChunk struct {
+	// start and end delimit the start positions of the matches of the chunk.
+	start int
+	end   int
+	// rangeEnd is the position past which matches of the chunk can't extend.
+	rangeEnd int
+	search   chunkSearch
+}
+
+// chunkSearch is the result of searching a chunk: the matches found from a given state, and the state that follows.
+type chunkSearch struct {
+	matches []Range
+	// nextPos is the position the following search starts at.
+	nextPos int
+	// blocked is true when the last match ended at nextPos, so an empty match there is skipped.
+	blocked bool
+	// done is true when no other match starts in the chunk.
+	done bool
+}
+
+// FindAllParallel returns the non-overlapping matches of the regex in data, like FindMatches,
+// searching chunks of data concurrently.
+//
+// The chunks are split at newlines when options.Lines is set, and otherwise searched in windows that extend
+// options.MaxMatchLength bytes past their end. The matches of the chunks are merged, searching again
+// sequentially where a match crosses into the next chunk, so the result equals FindMatches as long as
+// the regex satisfies the option: no match longer than MaxMatchLength, or no match extending past a newline.
+//
+// Patterns whose matches depend on the text after them or on where the search starts can't be split into chunks:
+// FindAllParallel returns ErrParallelUnsupported for lookahead, the absent operator, \G and \K.
+// Lookbehind and anchors such as ^, $ and \b are supported, since every chunk sees the whole data.
+// Neither is REGEX_OPTION_FIND_LONGEST, with which Oniguruma returns the longest match of the whole search range.
+// With options.Lines, neither are patterns that can match a newline before their end, such as `a\nb`, `\s+` or `[^x]+`.
+// Without it, an error is returned when a match reaches the end of the window of its chunk, since it is longer than
+// MaxMatchLength and can have been cut short.
+func (r *Regex) FindAllParallel(data []byte, options ParallelOptions) ([]*Range, error) {
+	if construct := parallelUnsafeConstruct(r.pattern); construct != "" {
+		return nil, fmt.Errorf("%w: pattern %q uses %s", ErrParallelUnsupported, r.pattern, construct)
+	}
+	if r.Options()&REGEX_OPTION_FIND_LONGEST != 0 {
+		return nil, fmt.Errorf("%w: regex is compiled with REGEX_OPTION_FIND_LONGEST", ErrParallelUnsupported)
+	}
+	if !options.Lines && options.MaxMatchLength <= 0 {
+		return nil, fmt.Errorf("%w: either MaxMatchLength or Lines must be set", ErrParallelUnsupported)
+	}
+	if options.Lines {
+		if reason := r.linesUnsafeReason(); reason != "" {
+			return nil, fmt.Errorf("%w: pattern %q can't be split at newlines: %s", ErrParallelUnsupported, r.pattern, reason)
+		}
+	}
+	if uint64(len(data)) >= math.MaxUint32 {
+		return nil, fmt.Errorf("data of %d bytes is too large, the maximum is %d", len(data), uint(math.MaxUint32-1))
+	}
+	chunkSize := options.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultParallelChunkSize
+	}
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if len(data) == 0 {
+		// The data is never empty, so that its first byte can be passed to C.
+		data = make([]byte, 0, 1)
+	}
+	chunks := splitParallelChunks(data, chunkSize, options)
+
+	errs := make([]error, len(chunks))
+	jobs := make(chan int)
+	var wait sync.WaitGroup
+	for range min(workers, len(chunks)) {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for i := range jobs {
+				chunk := &chunks[i]
+				chunk.search, errs[i] = r.searchChunk(data, chunk.start, false, chunk.end, chunk.rangeEnd, 0)
+			}
+		}()
+	}
+	for i := range chunks {
+		jobs <- i
+	}
+	close(jobs)
+	wait.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return r.mergeParallelChunks(data, chunks)
+}
+
+// splitParallelChunks splits data into chunks of about chunkSize bytes, after a newline when options.Lines is set,
+// and at the start of a UTF-8 character otherwise. The last chunk also holds the matches that start at the end of data.
+func splitParallelChunks(data []byte, chunkSize int, options ParallelOptions) []parallelChunk {
+	chunks := make([]parallelChunk, 0, len(data)/chunkSize+1)
+	start := 0
+	for {
+		end := start + chunkSize
+		if end < len(data) {
+			if options.Lines {
+				newline := bytes.IndexByte(data[end-1:], '\n')
+				if newline < 0 {
+					end = len(data)
+				} else {
+					end += newline
+				}
+			} else {
+				for end < len(data) && !utf8.RuneStart(data[end]) {
+					end++
+				}
+			}
+		}
+		if end >= len(data) {
+			chunks = append(chunks, parallelChunk{start: start, end: len(data) + 1, rangeEnd: len(data)})
+			return chunks
+		}
+		rangeEnd := end
+		if !options.Lines {
+			rangeEnd = min(end+options.MaxMatchLength, len(data))
+		}
+		chunks = append(chunks, parallelChunk{start: start, end: end, rangeEnd: rangeEnd})
+		start = end
+	}
+}
+
+// mergeParallelChunks joins the matches of the chunks into the matches of a sequential search.
+//
+// A sequential search reaches the start of a chunk in the state the chunk was searched from, unless a match
+// crosses into the chunk. The matches of that chunk are then searched again from the end of the crossing match,
+// until a match ends where a match of the chunk ends: from there on, both searches are in the same state.
+func (r *Regex) mergeParallelChunks(data []byte, chunks []parallelChunk) ([]*Range, error) {
+	matches := make([]*Range, 0)
+	// A match that ends at the end of the window past a chunk is longer than MaxMatchLength, and the window
+	// may have cut it short. In Lines mode, the window ends with the chunk, after a newline.
+	appendMatches := func(ranges []Range, chunk *parallelChunk) error {
+		for i := range ranges {
+			if ranges[i].To == chunk.rangeEnd && chunk.end < chunk.rangeEnd && chunk.rangeEnd < len(data) {
+				return fmt.Errorf("match at %d reaches the end of the window of its chunk at %d and is longer than MaxMatchLength",
+					ranges[i].From, chunk.rangeEnd)
+			}
+			matches = append(matches, &ranges[i])
+		}
+		return nil
+	}
+	pos, blocked := 0, false
+	for i := range chunks {
+		chunk := &chunks[i]
+		if pos < chunk.start || (pos == chunk.start && !blocked) {
+			if err := appendMatches(chunk.search.matches, chunk); err != nil {
+				return nil, err
+			}
+			pos, blocked = chunk.search.nextState(chunk)
+			continue
+		}
+		for {
+			ends := chunk.search.matches
+			k := sort.Search(len(ends), func(k int) bool { return ends[k].To >= pos })
+			if blocked && k < len(ends) && ends[k].To == pos {
+				if err := appendMatches(ends[k+1:], chunk); err != nil {
+					return nil, err
+				}
+				pos, blocked = chunk.search.nextState(chunk)
+				break
+			}
+			step, err := r.searchChunk(data, pos, blocked, chunk.end, chunk.rangeEnd, 1)
+			if err != nil {
+				return nil, err
+			}
+			if err := appendMatches(step.matches, chunk); err != nil {
+				return nil, err
+			}
+			pos, blocked = step.nextState(chunk)
+			if step.done {
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+// nextState returns the state a sequential search is in after the search of the chunk.
+// When no other match starts in the chunk, a search from a position inside it finds the same matches
+// as a search from its end.
+func (s *chunkSearch) nextState(chunk *parallelChunk) (int, bool) {
+	if s.done && s.nextPos < chunk.end {
+		return chunk.end, false
+	}
+	return s.nextPos, s.blocked
+}
+
+// searchChunk searches sequentially for the matches that start in data between from and chunkEnd,
+// extending at most to rangeEnd, and stops after limit matches if limit isn't 0.
+func (r *Regex) searchChunk(
+	data []byte,
+	from int,
+	blocked bool,
+	chunkEnd int,
+	rangeEnd int,
+	limit int,
+) (chunkSearch, error) {
+	cBlocked := C.int(0)
+	if blocked {
+		cBlocked = 1
+	}
+	result := C.searchChunk(
+		r.raw,
+		(*C.char)(unsafe.Pointer(unsafe.SliceData(data))),
+		C.uint(len(data)),
+		C.uint(from),
+		cBlocked,
+		C.uint(chunkEnd),
+		C.uint(rangeEnd),
+		C.OnigOptionType(REGEX_OPTION_NONE),
+		C.uint(limit),
+	)
+	runtime.KeepAlive(r)
+	if result.result < 0 {
+		return chunkSearch{}, errorFromCode(result.result)
+	}
+	defer C.free(unsafe.Pointer(result.positions))
+	positions := unsafe.Slice(result.positions, int(result.positionsCount))
+	search := chunkSearch{
+		matches: make([]Range, len(positions)/2),
+		nextPos: int(result.nextPos),
+		blocked: result.blocked != 0,
+		done:    result.done != 0,
+	}
+	for i := range search.matches {
+		search.matches[i] = Range{From: int(positions[2*i]), To: int(positions[2*i+1])}
+	}
+	return search, nil
+}
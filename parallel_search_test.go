@@ -0,0 +1,168 @@
+package onig
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func parallelText(seed int64, size int) []byte {
+	random := rand.New(rand.NewSource(seed))
+	words := []string{"foo", "bar", "baz", "über", "qux", "42", "7", " ", " ", "\n", "--", "aaaa", "é"}
+	var builder strings.Builder
+	for builder.Len() < size {
+		builder.WriteString(words[random.Intn(len(words))])
+	}
+	return []byte(builder.String())
+}
+
+func TestRegex_FindAllParallel_MatchesSequential(t *testing.T) {
+	patterns := []struct {
+		pattern        string
+		maxMatchLength int
+	}{
+		{`foo|bar`, 3},
+		{`\d+`, 64},
+		{`a+`, 1000},
+		{`a*`, 1000},
+		{`x*`, 1},
+		{`\b`, 1},
+		{`^`, 1},
+		{`$`, 1},
+		{`^\w+`, 100},
+		{`\w+$`, 100},
+		{`(?<=ba)[rz]`, 1},
+		{`(?<!f)oo`, 2},
+		{`ü|é`, 2},
+		{`.`, 4},
+		{`[^\n]{0,5}`, 10},
+		{`(foo|bar)\s+\1`, 100},
+		{`\z`, 1},
+		{`--\n?`, 3},
+	}
+	for seed := range int64(4) {
+		data := parallelText(seed, 3000)
+		for _, p := range patterns {
+			r := MustCompile(p.pattern)
+			expected := r.MustFindMatches(string(data))
+			for _, chunkSize := range []int{1, 7, 64, 1000} {
+				name := fmt.Sprintf("%s/seed=%d/chunk=%d", p.pattern, seed, chunkSize)
+				matches, err := r.FindAllParallel(data, ParallelOptions{
+					MaxMatchLength: p.maxMatchLength,
+					ChunkSize:      chunkSize,
+					Workers:        3,
+				})
+				assert.NoError(t, err, name)
+				assert.Equal(t, expected, matches, name)
+			}
+		}
+	}
+}
+
+func TestRegex_FindAllParallel_Lines(t *testing.T) {
+	data := parallelText(42, 5000)
+	for _, pattern := range []string{`^\w+`, `\w+$`, `^$`, `[^\n]*`, `\d+ ?`, `(?m:[^\n]+)\n`} {
+		r := MustCompile(pattern)
+		expected := r.MustFindMatches(string(data))
+		for _, chunkSize := range []int{1, 13, 500} {
+			matches, err := r.FindAllParallel(data, ParallelOptions{Lines: true, ChunkSize: chunkSize})
+			assert.NoError(t, err, pattern)
+			assert.Equal(t, expected, matches, "%s/chunk=%d", pattern, chunkSize)
+		}
+	}
+}
+
+func TestRegex_FindAllParallel_CrossingMatches(t *testing.T) {
+	data := []byte(strings.Repeat("ab", 500))
+	r := MustCompile(`(ab)+?b?|a`)
+	expected := r.MustFindMatches(string(data))
+	matches, err := r.FindAllParallel(data, ParallelOptions{MaxMatchLength: 3, ChunkSize: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, matches)
+
+	r = MustCompile(`(?:ab){1,20}`)
+	expected = r.MustFindMatches(string(data))
+	matches, err = r.FindAllParallel(data, ParallelOptions{MaxMatchLength: 40, ChunkSize: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, matches)
+}
+
+func TestRegex_FindAllParallel_Empty(t *testing.T) {
+	matches, err := MustCompile(`a*`).FindAllParallel(nil, ParallelOptions{MaxMatchLength: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Range{NewRange(0, 0)}, matches)
+	matches, err = MustCompile(`a`).FindAllParallel([]byte{}, ParallelOptions{Lines: true})
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestRegex_FindAllParallel_Unsupported(t *testing.T) {
+	for _, pattern := range []string{`a(?=b)`, `a(?!b)`, `\Ga`, `a\Kb`, `(?~ab)`} {
+		_, err := MustCompile(pattern).FindAllParallel([]byte("ab"), ParallelOptions{MaxMatchLength: 2})
+		assert.ErrorIs(t, err, ErrParallelUnsupported, pattern)
+	}
+	_, err := MustCompile(`a`).FindAllParallel([]byte("ab"), ParallelOptions{})
+	assert.ErrorIs(t, err, ErrParallelUnsupported)
+	_, err = MustCompile(`\\G\(?=`).FindAllParallel([]byte("ab"), ParallelOptions{MaxMatchLength: 2})
+	assert.NoError(t, err)
+}
+
+func TestRegex_FindAllParallel_Options(t *testing.T) {
+	data := []byte("a" + strings.Repeat("x", 20) + "aaaa" + strings.Repeat("x", 20) + "aa")
+	options := ParallelOptions{MaxMatchLength: 4, ChunkSize: 5}
+	_, err := MustCompileWithOptions(`a+`, REGEX_OPTION_FIND_LONGEST).FindAllParallel(data, options)
+	assert.ErrorIs(t, err, ErrParallelUnsupported)
+
+	for _, pattern := range []string{`a+`, `a*`, `x?`} {
+		r := MustCompileWithOptions(pattern, REGEX_OPTION_FIND_NOT_EMPTY)
+		matches, err := r.FindAllParallel(data, options)
+		assert.NoError(t, err, pattern)
+		assert.Equal(t, r.MustFindMatches(string(data)), matches, pattern)
+	}
+}
+
+func TestRegex_FindAllParallel_LinesNewline(t *testing.T) {
+	data := []byte("ab\nab\nab\nab\n")
+	for _, test := range []struct {
+		pattern string
+		syntax  *Syntax
+		options RegexOptions
+	}{
+		{`b\na`, SyntaxRuby, REGEX_OPTION_NONE},
+		{`[^x]+`, SyntaxRuby, REGEX_OPTION_NONE},
+		{`[\x00-\x20]`, SyntaxRuby, REGEX_OPTION_NONE},
+		{`\s`, SyntaxRuby, REGEX_OPTION_NONE},
+		{`\D+`, SyntaxRuby, REGEX_OPTION_NONE},
+		{`\p{^Alpha}`, SyntaxRuby, REGEX_OPTION_NONE},
+		{`\R`, SyntaxRuby, REGEX_OPTION_NONE},
+		{`\O`, SyntaxOniguruma, REGEX_OPTION_NONE},
+		{`\X`, SyntaxRuby, REGEX_OPTION_NONE},
+		{`(?m:.)+`, SyntaxRuby, REGEX_OPTION_NONE},
+		{`.+`, SyntaxRuby, REGEX_OPTION_MULTILINE},
+		{`(?s).+`, SyntaxPerl, REGEX_OPTION_NONE},
+		{`(b)\n?\1`, SyntaxRuby, REGEX_OPTION_NONE},
+		{`\n\n`, SyntaxRuby, REGEX_OPTION_NONE},
+		{`a`, SyntaxEmacs, REGEX_OPTION_NONE},
+	} {
+		r := MustCompileWithOptionsAndSyntax(test.pattern, test.options, test.syntax)
+		_, err := r.FindAllParallel(data, ParallelOptions{Lines: true, ChunkSize: 3})
+		assert.ErrorIs(t, err, ErrParallelUnsupported, test.pattern)
+	}
+	for _, pattern := range []string{`b\n`, `.+`, `[^\n]+`, `\S+`, `\w+\n`, `(?:a|b)+\n`} {
+		r := MustCompile(pattern)
+		matches, err := r.FindAllParallel(data, ParallelOptions{Lines: true, ChunkSize: 3})
+		assert.NoError(t, err, pattern)
+		assert.Equal(t, r.MustFindMatches(string(data)), matches, pattern)
+	}
+}
+
+func TestRegex_FindAllParallel_MatchTooLong(t *testing.T) {
+	data := []byte("aaaaaaaaa b")
+	_, err := MustCompile(`a+`).FindAllParallel(data, ParallelOptions{MaxMatchLength: 2, ChunkSize: 2})
+	assert.Error(t, err)
+	matches, err := MustCompile(`a+`).FindAllParallel(data, ParallelOptions{MaxMatchLength: 9, ChunkSize: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Range{NewRange(0, 9)}, matches)
+}
@@ -0,0 +1,174 @@
+package onig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PerlReplacer is a RegexReplacer that replaces capture groups with the corresponding captured text.
+//
+// The replacement pattern follows the interpolation rules of the replacement part of Perl's s/// operator:
+//   - `$1`, `${1}` and `\1` refer to the numbered capture group.
+//   - `${name}` and `$+{name}` refer to the named capture group.
+//   - `$&` is the whole match, "$`" the text before it, `$'` the text after it and `$+` the last matched group.
+//   - `\U`, `\L` and `\Q` upper-case, lower-case or quote the text up to `\E`,
+//     and `\u` and `\l` change the case of the next character.
+//   - `\n`, `\t`, `\r`, `\f`, `\e` and `\a` are control characters and any other `\x` is the literal x.
+//
+// A `$` that doesn't start one of the references above is kept literally.
+type PerlReplacer struct {
+	parts []replacementPart
+	err   error
+}
+
+// NewPerlRegexReplacer creates a new PerlReplacer given a replacement pattern.
+// See https://perldoc.perl.org/perlop#s/PATTERN/REPLACEMENT/msixxpodualngcer for more information.
+func NewPerlRegexReplacer(pattern string) RegexReplacer {
+	parts, err := parsePerlReplacement(pattern)
+	return &PerlReplacer{
+		parts: parts,
+		err:   err,
+	}
+}
+
+// Replace applies the replacement pattern to the captures.
+func (r *PerlReplacer) Replace(captures *Captures) (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+	return string(appendReplacementParts(nil, r.parts, captures)), nil
+}
+
+func (r *PerlReplacer) compileTemplate(regex *Regex) ([]replacementPart, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if err := validateReplacementParts(r.parts, regex); err != nil {
+		return nil, err
+	}
+	return r.parts, nil
+}
+
+// parsePerlReplacement splits a Perl replacement pattern into literal text, group references and case modifiers.
+func parsePerlReplacement(pattern string) ([]replacementPart, error) {
+	var parser replacementParser
+	for index := 0; index < len(pattern); index++ {
+		ch := pattern[index]
+		switch {
+		case ch == '\\':
+			index++
+			if index >= len(pattern) {
+				return nil, fmt.Errorf("trailing backslash in replacement pattern")
+			}
+			ch = pattern[index]
+			switch ch {
+			case 'U':
+				parser.addPart(replacementPart{kind: replacementCase, caseModifier: caseUpper})
+			case 'L':
+				parser.addPart(replacementPart{kind: replacementCase, caseModifier: caseLower})
+			case 'Q':
+				parser.addPart(replacementPart{kind: replacementCase, caseModifier: caseQuote})
+			case 'E':
+				parser.addPart(replacementPart{kind: replacementCase, caseModifier: caseEnd})
+			case 'u':
+				parser.addPart(replacementPart{kind: replacementCase, caseModifier: caseUpperNext})
+			case 'l':
+				parser.addPart(replacementPart{kind: replacementCase, caseModifier: caseLowerNext})
+			case 'n':
+				parser.addLiteralByte('\n')
+			case 't':
+				parser.addLiteralByte('\t')
+			case 'r':
+				parser.addLiteralByte('\r')
+			case 'f':
+				parser.addLiteralByte('\f')
+			case 'e':
+				parser.addLiteralByte('\x1b')
+			case 'a':
+				parser.addLiteralByte('\a')
+			default:
+				if ch >= '1' && ch <= '9' {
+					parser.addPart(replacementPart{kind: replacementGroup, group: int(ch - '0')})
+				} else {
+					parser.addLiteralByte(ch)
+				}
+			}
+		case ch == '$' && index+1 < len(pattern):
+			consumed, err := parsePerlVariable(&parser, pattern[index+1:])
+			if err != nil {
+				return nil, err
+			}
+			if consumed == 0 {
+				parser.addLiteralByte(ch)
+			}
+			index += consumed
+		default:
+			parser.addLiteralByte(ch)
+		}
+	}
+	return parser.result(), nil
+}
+
+// parsePerlVariable parses the variable that follows a `$` and returns the number of bytes it used.
+// Zero means that rest doesn't start with a variable this replacer understands.
+func parsePerlVariable(parser *replacementParser, rest string) (int, error) {
+	switch ch := rest[0]; {
+	case ch == '&':
+		parser.addPart(replacementPart{kind: replacementGroup, group: 0})
+		return 1, nil
+	case ch == '`':
+		parser.addPart(replacementPart{kind: replacementPrematch})
+		return 1, nil
+	case ch == '\'':
+		parser.addPart(replacementPart{kind: replacementPostmatch})
+		return 1, nil
+	case ch == '+':
+		if len(rest) > 1 && rest[1] == '{' {
+			name, length, err := parsePerlBracedName(rest[1:])
+			if err != nil {
+				return 0, err
+			}
+			parser.addPart(replacementPart{kind: replacementNamedGroup, text: name})
+			return 1 + length, nil
+		}
+		parser.addPart(replacementPart{kind: replacementLastGroup})
+		return 1, nil
+	case ch == '{':
+		name, length, err := parsePerlBracedName(rest)
+		if err != nil {
+			return 0, err
+		}
+		if group, err := strconv.Atoi(name); err == nil {
+			parser.addPart(replacementPart{kind: replacementGroup, group: group})
+		} else {
+			parser.addPart(replacementPart{kind: replacementNamedGroup, text: name})
+		}
+		return length, nil
+	case isASCIIDigit(ch):
+		length := 1
+		for length < len(rest) && isASCIIDigit(rest[length]) {
+			length++
+		}
+		group, err := strconv.Atoi(rest[:length])
+		if err != nil {
+			return 0, fmt.Errorf("invalid capture group number: %s", rest[:length])
+		}
+		parser.addPart(replacementPart{kind: replacementGroup, group: group})
+		return length, nil
+	}
+	return 0, nil
+}
+
+// parsePerlBracedName parses a `{name}` and returns the name and the number of bytes it used, including the braces.
+func parsePerlBracedName(rest string) (string, int, error) {
+	end := strings.IndexByte(rest, '}')
+	if end < 0 {
+		return "", 0, fmt.Errorf("missing closing brace in replacement pattern")
+	}
+	name := rest[1:end]
+	if name == "" {
+		return "", 0, fmt.Errorf("empty group name in replacement pattern")
+	}
+	return name, end + 1, nil
+}
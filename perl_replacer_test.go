@@ -0,0 +1,39 @@
+package onig
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPerlRegexReplacer(t *testing.T) {
+	r := MustCompileWithSyntax(`hello (\w+)`, SyntaxPerl)
+	assert.Equal(t, "goodbye world", r.MustReplaceAll("hello world", `goodbye $1`))
+	assert.Equal(t, "goodbye world", r.MustReplaceAll("hello world", `goodbye ${1}`))
+	assert.Equal(t, "goodbye world", r.MustReplaceAll("hello world", `goodbye \1`))
+	assert.Equal(t, "goodbye worlds", r.MustReplaceAll("hello world", `goodbye ${1}s`))
+	assert.Equal(t, "goodbye hello world", r.MustReplaceAll("hello world", `goodbye $&`))
+	assert.Equal(t, "goodbye world", r.MustReplaceAll("hello world", `goodbye $+`))
+	assert.Equal(t, "say <say > <!>!", r.MustReplaceAll("say hello world!", "<$`> <$'>"))
+	assert.Equal(t, `goodbye $1 \`, r.MustReplaceAll("hello world", `goodbye \$1 \\`))
+	assert.Equal(t, "cost: $ $x", r.MustReplaceAll("hello world", `cost: $ $x`))
+	assert.Equal(t, "a\tb\n", r.MustReplaceAll("hello world", `a\tb\n`))
+	assertReplaceError(t, r, "hello world", `goodbye ${1`)
+	assertReplaceError(t, r, "hello world", `goodbye ${}`)
+	assertReplaceError(t, r, "hello world", `goodbye \`)
+
+	r = MustCompileWithSyntax(`hello (?<name>\w+)`, SyntaxPerlNG)
+	assert.Equal(t, "goodbye world", r.MustReplaceAll("hello world", `goodbye $+{name}`))
+	assert.Equal(t, "goodbye world", r.MustReplaceAll("hello world", `goodbye ${name}`))
+	assert.Equal(t, "goodbye world", r.MustReplaceAll("hello world", `goodbye $1`))
+	assertReplaceError(t, r, "hello world", `goodbye $+{name`)
+}
+
+func TestPerlRegexReplacer_CaseModifiers(t *testing.T) {
+	r := MustCompileWithSyntax(`(\w+) (\w+)`, SyntaxPerl)
+	assert.Equal(t, "HELLO world", r.MustReplaceAll("hello world", `\U$1\E $2`))
+	assert.Equal(t, "Hello World", r.MustReplaceAll("hello world", `\u$1 \u$2`))
+	assert.Equal(t, "Hello wORLD", r.MustReplaceAll("HELLO WORLD", `\u\L$1\E \l$2`))
+	assert.Equal(t, "Hello wORLD", r.MustReplaceAll("HELLO WORLD", `\L\u$1\E \l$2`))
+	assert.Equal(t, "HELLO, WORLD", r.MustReplaceAll("hello world", `\U$1, $2`))
+	assert.Equal(t, `x\-y`, MustCompileWithSyntax(`.+`, SyntaxPerl).MustReplaceAll("x-y", `\Q$&`))
+}
@@ -0,0 +1,86 @@
+package onig
+
+import (
+	"fmt"
+)
+
+// PosixReplacer is a RegexReplacer that replaces capture groups with the corresponding captured text.
+//
+// The replacement pattern follows the replacement part of the sed `s` command:
+//   - `&` is the whole match and `\&` is a literal ampersand.
+//   - `\0` to `\9` refer to the numbered capture group, `\0` being the whole match.
+//   - `\n` is a newline and any other `\x` is the literal x.
+//   - The GNU extensions `\U`, `\L`, `\E`, `\u` and `\l` change the case of the inserted text.
+type PosixReplacer struct {
+	parts []replacementPart
+	err   error
+}
+
+// NewPosixRegexReplacer creates a new PosixReplacer given a replacement pattern.
+// See https://pubs.opengroup.org/onlinepubs/9699919799/utilities/sed.html#tag_20_116_13_03 for more information.
+func NewPosixRegexReplacer(pattern string) RegexReplacer {
+	parts, err := parsePosixReplacement(pattern)
+	return &PosixReplacer{
+		parts: parts,
+		err:   err,
+	}
+}
+
+// Replace applies the replacement pattern to the captures.
+func (r *PosixReplacer) Replace(captures *Captures) (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+	return string(appendReplacementParts(nil, r.parts, captures)), nil
+}
+
+func (r *PosixReplacer) compileTemplate(regex *Regex) ([]replacementPart, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if err := validateReplacementParts(r.parts, regex); err != nil {
+		return nil, err
+	}
+	return r.parts, nil
+}
+
+// parsePosixReplacement splits a sed replacement pattern into literal text, group references and case modifiers.
+func parsePosixReplacement(pattern string) ([]replacementPart, error) {
+	var parser replacementParser
+	for index := 0; index < len(pattern); index++ {
+		ch := pattern[index]
+		switch ch {
+		case '&':
+			parser.addPart(replacementPart{kind: replacementGroup, group: 0})
+		case '\\':
+			index++
+			if index >= len(pattern) {
+				return nil, fmt.Errorf("trailing backslash in replacement pattern")
+			}
+			ch = pattern[index]
+			switch ch {
+			case 'U':
+				parser.addPart(replacementPart{kind: replacementCase, caseModifier: caseUpper})
+			case 'L':
+				parser.addPart(replacementPart{kind: replacementCase, caseModifier: caseLower})
+			case 'E':
+				parser.addPart(replacementPart{kind: replacementCase, caseModifier: caseEnd})
+			case 'u':
+				parser.addPart(replacementPart{kind: replacementCase, caseModifier: caseUpperNext})
+			case 'l':
+				parser.addPart(replacementPart{kind: replacementCase, caseModifier: caseLowerNext})
+			case 'n':
+				parser.addLiteralByte('\n')
+			default:
+				if isASCIIDigit(ch) {
+					parser.addPart(replacementPart{kind: replacementGroup, group: int(ch - '0')})
+				} else {
+					parser.addLiteralByte(ch)
+				}
+			}
+		default:
+			parser.addLiteralByte(ch)
+		}
+	}
+	return parser.result(), nil
+}
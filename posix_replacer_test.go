@@ -0,0 +1,29 @@
+package onig
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPosixRegexReplacer(t *testing.T) {
+	for _, syntax := range []*Syntax{SyntaxPosixExtended, SyntaxGnuRegex} {
+		r := MustCompileWithSyntax(`hello (.*)`, syntax)
+		assert.Equal(t, "goodbye hello world", r.MustReplaceAll("hello world", `goodbye &`))
+		assert.Equal(t, "goodbye hello world", r.MustReplaceAll("hello world", `goodbye \0`))
+		assert.Equal(t, "goodbye world", r.MustReplaceAll("hello world", `goodbye \1`))
+		assert.Equal(t, "goodbye & world", r.MustReplaceAll("hello world", `goodbye \& \1`))
+		assert.Equal(t, `goodbye \1`, r.MustReplaceAll("hello world", `goodbye \\1`))
+		assert.Equal(t, "goodbye\nworld", r.MustReplaceAll("hello world", `goodbye\n\1`))
+		assert.Equal(t, "goodbye WORLD", r.MustReplaceAll("hello world", `goodbye \U\1`))
+		assert.Equal(t, "goodbye World", r.MustReplaceAll("hello world", `goodbye \u\1`))
+		assert.Equal(t, "goodbye $1", r.MustReplaceAll("hello world", `goodbye $1`))
+		assertReplaceError(t, r, "hello world", `goodbye \`)
+	}
+
+	r := MustCompileWithSyntax(`hello \(.*\)`, SyntaxPosixBasic)
+	assert.Equal(t, "goodbye world", r.MustReplaceAll("hello world", `goodbye \1`))
+	assert.Equal(t, "[hello world]", r.MustReplaceAll("hello world", `[&]`))
+
+	r = MustCompileWithSyntax(`hello \(.*\)`, SyntaxGrep)
+	assert.Equal(t, "goodbye world", r.MustReplaceAll("hello world", `goodbye \1`))
+}
@@ -0,0 +1,321 @@
+package onig
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// literalEscapes maps the escapes that stand for a single literal character to that character.
+// Escaped punctuation stands for itself and is handled separately.
+var literalEscapes = map[byte]byte{
+	't': '\t',
+	'n': '\n',
+	'r': '\r',
+	'f': '\f',
+}
+
+// prefilterSyntax reports whether the required literals of a pattern can be extracted for a syntax,
+// and whether an opening bracket inside a character class is a literal rather than a nested class.
+func prefilterSyntax(syntax *Syntax) (supported bool, flatClasses bool) {
+	switch syntax.raw {
+	case SyntaxPython.raw, SyntaxPerl.raw, SyntaxPerlNG.raw:
+		return true, true
+	case SyntaxRuby.raw, SyntaxOniguruma.raw, SyntaxJava.raw:
+		return true, false
+	}
+	return false, false
+}
+
+// requiredLiterals returns the literal strings that every match of pattern contains, or nil if none are found.
+//
+// The extraction is conservative: only the literal characters at the top level of the pattern are used,
+// outside of any group, and any construct it doesn't understand makes it give up. Patterns with a top-level
+// alternation, inline options, \Q...\E quoting, case-insensitive or extended options don't have required literals.
+func requiredLiterals(pattern string, syntax *Syntax, options RegexOptions) []string {
+	supported, flatClasses := prefilterSyntax(syntax)
+	if !supported || options&(REGEX_OPTION_IGNORECASE|REGEX_OPTION_EXTEND|REGEX_OPTION_CHECK_VALIDITY_OF_STRING) != 0 {
+		return nil
+	}
+	var literals []string
+	var run []byte
+	endRun := func() {
+		if len(run) > 0 {
+			literals = append(literals, string(run))
+			run = nil
+		}
+	}
+	// dropLast removes the last character from the run, when a quantifier makes it optional or repeats it.
+	dropLast := func() {
+		if len(run) > 0 {
+			_, size := utf8.DecodeLastRune(run)
+			run = run[:len(run)-size]
+		}
+		endRun()
+	}
+	// repeated is set when the last character of the run is repeated by a +. The run ends after it, unless another
+	// quantifier follows and may make it optional, as in ab+* or ab+{0,2}.
+	repeated := false
+	depth := 0
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if repeated {
+			repeated = false
+			if c == '+' || c == '*' || c == '?' || (c == '{' && skipQuantifierBraces(pattern, i) >= 0) {
+				dropLast()
+			} else {
+				endRun()
+			}
+		}
+		switch c {
+		case '\\':
+			if i+1 >= len(pattern) {
+				return nil
+			}
+			next := pattern[i+1]
+			if next == 'Q' {
+				return nil
+			}
+			if depth > 0 {
+				i++
+				continue
+			}
+			if literal, ok := literalEscapes[next]; ok {
+				run = append(run, literal)
+				i++
+			} else if next < utf8.RuneSelf && isASCIIPunctuation(next) {
+				run = append(run, next)
+				i++
+			} else if next >= utf8.RuneSelf {
+				_, size := utf8.DecodeRuneInString(pattern[i+1:])
+				run = append(run, pattern[i+1:i+1+size]...)
+				i += size
+			} else {
+				endRun()
+				end := skipEscapeArguments(pattern, i+1)
+				if end < 0 {
+					return nil
+				}
+				i = end
+			}
+		case '[':
+			end := skipCharacterClass(pattern, i, flatClasses)
+			if end < 0 {
+				return nil
+			}
+			if depth == 0 {
+				endRun()
+			}
+			i = end
+		case '(':
+			if !isScopedGroup(pattern[i:]) {
+				return nil
+			}
+			if depth == 0 {
+				endRun()
+			}
+			depth++
+		case ')':
+			if depth == 0 {
+				return nil
+			}
+			depth--
+		case '|':
+			if depth == 0 {
+				return nil
+			}
+		case '{':
+			end := skipQuantifierBraces(pattern, i)
+			if end < 0 {
+				// Not a quantifier, so a literal brace in the syntaxes that compile it.
+				if depth == 0 {
+					endRun()
+				}
+				continue
+			}
+			if depth == 0 {
+				dropLast()
+			}
+			i = end
+		case '*', '?':
+			if depth == 0 {
+				dropLast()
+			}
+		case '+':
+			if depth == 0 {
+				repeated = len(run) > 0
+			}
+		case '.', '^', '$', ']', '}':
+			if depth == 0 {
+				endRun()
+			}
+		default:
+			if depth == 0 {
+				run = append(run, c)
+			}
+		}
+	}
+	if depth != 0 {
+		return nil
+	}
+	endRun()
+	return literals
+}
+
+// skipEscapeArguments returns the index of the last character of the escape whose letter is at index i,
+// including its arguments, such as the digits of \x41 or the name of \k<name>.
+// It returns -1 for escapes whose arguments it doesn't know how to skip.
+func skipEscapeArguments(pattern string, i int) int {
+	switch pattern[i] {
+	case 'x':
+		if end := skipDelimited(pattern, i+1, '{', '}'); end >= 0 {
+			return end
+		}
+		return skipHexDigits(pattern, i, 2)
+	case 'u':
+		if end := skipDelimited(pattern, i+1, '{', '}'); end >= 0 {
+			return end
+		}
+		return skipHexDigits(pattern, i, 4)
+	case 'U':
+		return skipHexDigits(pattern, i, 8)
+	case 'k', 'g':
+		for _, delimiters := range []string{"<>", "''", "{}"} {
+			if end := skipDelimited(pattern, i+1, delimiters[0], delimiters[1]); end >= 0 {
+				return end
+			}
+		}
+		if i+1 < len(pattern) && pattern[i+1] == '-' {
+			i++
+		}
+		return skipDigits(pattern, i)
+	case 'p', 'P':
+		if end := skipDelimited(pattern, i+1, '{', '}'); end >= 0 {
+			return end
+		}
+		return i + 1
+	case 'c', 'C', 'M', 'o', 'N':
+		return -1
+	}
+	if pattern[i] >= '0' && pattern[i] <= '9' {
+		return skipDigits(pattern, i)
+	}
+	return i
+}
+
+// skipDelimited returns the index of the closing delimiter if pattern has an opening delimiter at index i, or -1.
+func skipDelimited(pattern string, i int, open byte, close byte) int {
+	if i >= len(pattern) || pattern[i] != open {
+		return -1
+	}
+	end := strings.IndexByte(pattern[i+1:], close)
+	if end < 0 {
+		return -1
+	}
+	return i + 1 + end
+}
+
+// skipHexDigits returns the index of the last of at most count hexadecimal digits following index i.
+func skipHexDigits(pattern string, i int, count int) int {
+	for ; count > 0 && i+1 < len(pattern) && strings.IndexByte("0123456789abcdefABCDEF", pattern[i+1]) >= 0; count-- {
+		i++
+	}
+	return i
+}
+
+// skipDigits returns the index of the last of the decimal digits following index i.
+func skipDigits(pattern string, i int) int {
+	for i+1 < len(pattern) && pattern[i+1] >= '0' && pattern[i+1] <= '9' {
+		i++
+	}
+	return i
+}
+
+// skipQuantifierBraces returns the index of the closing brace if the brace at index i opens
+// a quantifier such as {2}, {2,} or {,3}, or -1 otherwise.
+func skipQuantifierBraces(pattern string, i int) int {
+	end := strings.IndexByte(pattern[i:], '}')
+	if end < 0 {
+		return -1
+	}
+	content := pattern[i+1 : i+end]
+	if strings.Trim(content, "0123456789,") != "" || strings.Count(content, ",") > 1 {
+		return -1
+	}
+	return i + end
+}
+
+func isASCIIPunctuation(c byte) bool {
+	return (c >= '!' && c <= '/') || (c >= ':' && c <= '@') || (c >= '[' && c <= '`') || (c >= '{' && c <= '~') || c == ' '
+}
+
+// isScopedGroup reports whether the group that starts text only affects its own content:
+// a capturing, non-capturing, named, atomic or lookaround group.
+func isScopedGroup(text string) bool {
+	if !strings.HasPrefix(text, "(?") {
+		return true
+	}
+	for _, prefix := range []string{"(?:", "(?>", "(?=", "(?!", "(?<=", "(?<!", "(?P<", "(?'"} {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	// A named group such as (?<name>...), but not a lookbehind.
+	if len(text) > 3 && text[2] == '<' && text[3] != '=' && text[3] != '!' {
+		return true
+	}
+	return false
+}
+
+// skipCharacterClass returns the index of the bracket that closes the character class starting at start,
+// or -1 if it isn't closed. Classes nest unless flatClasses is set.
+func skipCharacterClass(pattern string, start int, flatClasses bool) int {
+	depth := 0
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '[':
+			if depth == 0 || !flatClasses {
+				depth++
+				i = skipClassStart(pattern, i)
+			}
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// skipClassStart returns the index of the last character of the opening of the class at start,
+// skipping a negation and a closing bracket that can't end the class because it comes first.
+func skipClassStart(pattern string, start int) int {
+	i := start
+	if i+1 < len(pattern) && pattern[i+1] == '^' {
+		i++
+	}
+	if i+1 < len(pattern) && pattern[i+1] == ']' {
+		i++
+	}
+	return i
+}
+
+// mayMatch reports whether the part of text that a search between the byte indices from and to can match
+// contains the literals required by every match of the regex. When it returns false, the regex can't match,
+// and searching can be skipped. A backward search, with from after to, matches from to onwards.
+// A skipped search finds no match, where the engine could have failed on a retry or stack limit instead.
+func (r *Regex) mayMatch(text string, from uint, to uint) bool {
+	start := min(from, to)
+	if len(r.requiredLiterals) == 0 || start > uint(len(text)) {
+		return true
+	}
+	text = text[start:]
+	for _, literal := range r.requiredLiterals {
+		if !strings.Contains(text, literal) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,156 @@
+package onig
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestRequiredLiterals(t *testing.T) {
+	for _, test := range []struct {
+		pattern  string
+		syntax   *Syntax
+		options  RegexOptions
+		expected []string
+	}{
+		{`ERROR: (\w+) timeout`, SyntaxRuby, REGEX_OPTION_NONE, []string{"ERROR: ", " timeout"}},
+		{`abc`, SyntaxRuby, REGEX_OPTION_NONE, []string{"abc"}},
+		{`ab?c`, SyntaxRuby, REGEX_OPTION_NONE, []string{"a", "c"}},
+		{`ab*c`, SyntaxRuby, REGEX_OPTION_NONE, []string{"a", "c"}},
+		{`ab+c`, SyntaxRuby, REGEX_OPTION_NONE, []string{"ab", "c"}},
+		{`ab+*c`, SyntaxRuby, REGEX_OPTION_NONE, []string{"a", "c"}},
+		{`ab+??c`, SyntaxRuby, REGEX_OPTION_NONE, []string{"a", "c"}},
+		{`ab+{0,2}c`, SyntaxRuby, REGEX_OPTION_NONE, []string{"a", "c"}},
+		{`ab++c`, SyntaxJava, REGEX_OPTION_NONE, []string{"a", "c"}},
+		{`ab+{c`, SyntaxRuby, REGEX_OPTION_NONE, []string{"ab", "c"}},
+		{`ab{2,3}cd`, SyntaxRuby, REGEX_OPTION_NONE, []string{"a", "cd"}},
+		{`x{foo}`, SyntaxRuby, REGEX_OPTION_NONE, []string{"x", "foo"}},
+		{`üb?er`, SyntaxRuby, REGEX_OPTION_NONE, []string{"ü", "er"}},
+		{`\.com\t\$`, SyntaxRuby, REGEX_OPTION_NONE, []string{".com\t$"}},
+		{`\x4142\d\p{L}ab\k<n>cd`, SyntaxRuby, REGEX_OPTION_NONE, []string{"42", "ab", "cd"}},
+		{`\U0001F600x`, SyntaxPython, REGEX_OPTION_NONE, []string{"x"}},
+		{`[a-z]+id=(\d+)[|(]end`, SyntaxRuby, REGEX_OPTION_NONE, []string{"id=", "end"}},
+		{`[[:alpha:]]x`, SyntaxPython, REGEX_OPTION_NONE, []string{"x"}},
+		{`(?<=pre)fix(?=post)`, SyntaxRuby, REGEX_OPTION_NONE, []string{"fix"}},
+		{`(?P<name>a|b)cd`, SyntaxPython, REGEX_OPTION_NONE, []string{"cd"}},
+		{`foo|bar`, SyntaxRuby, REGEX_OPTION_NONE, nil},
+		{`(?i)foo`, SyntaxRuby, REGEX_OPTION_NONE, nil},
+		{`foo`, SyntaxRuby, REGEX_OPTION_IGNORECASE, nil},
+		{`f o o`, SyntaxRuby, REGEX_OPTION_EXTEND, nil},
+		{`\Qa.b\E`, SyntaxJava, REGEX_OPTION_NONE, nil},
+		{`a\cXb`, SyntaxRuby, REGEX_OPTION_NONE, nil},
+		{`foo`, SyntaxPosixExtended, REGEX_OPTION_NONE, nil},
+		{`[[]|x]abc`, SyntaxPerl, REGEX_OPTION_NONE, nil},
+		{`.*`, SyntaxRuby, REGEX_OPTION_NONE, nil},
+	} {
+		assert.Equal(t, test.expected, requiredLiterals(test.pattern, test.syntax, test.options), test.pattern)
+	}
+}
+
+// withoutPrefilter returns a copy of r that always searches.
+func withoutPrefilter(r *Regex) *Regex {
+	clone := *r
+	clone.requiredLiterals = nil
+	return &clone
+}
+
+func TestRegex_Prefilter_MatchesEngine(t *testing.T) {
+	patterns := []struct {
+		pattern string
+		syntax  *Syntax
+	}{
+		{`ERROR: (\w+) timeout`, SyntaxRuby},
+		{`ab?c`, SyntaxRuby},
+		{`a(b|c)+d`, SyntaxRuby},
+		{`(?<=a)bc`, SyntaxRuby},
+		{`b\Kc`, SyntaxRuby},
+		{`^ab$`, SyntaxRuby},
+		{`\bab\b`, SyntaxJava},
+		{`x{2}ab`, SyntaxRuby},
+		{`(?P<w>a)b`, SyntaxPython},
+		{`a[^b]c`, SyntaxPerl},
+		{`ü+b`, SyntaxPerlNG},
+	}
+	random := rand.New(rand.NewSource(1))
+	alphabet := []string{"a", "b", "c", "d", "x", "ü", " ", "\n", "ERROR: ", " timeout", "word"}
+	texts := []string{"", "ab", "abc", "ERROR: disk timeout", "xxab"}
+	for range 200 {
+		var builder strings.Builder
+		for range random.Intn(12) {
+			builder.WriteString(alphabet[random.Intn(len(alphabet))])
+		}
+		texts = append(texts, builder.String())
+	}
+	for _, p := range patterns {
+		r := MustCompileWithSyntax(p.pattern, p.syntax)
+		assert.NotEmpty(t, r.requiredLiterals, p.pattern)
+		engine := withoutPrefilter(r)
+		for _, text := range texts {
+			assert.Equal(t, engine.MustFindMatches(text), r.MustFindMatches(text), "%s in %q", p.pattern, text)
+			assert.Equal(t, engine.MustFindMatch(text), r.MustFindMatch(text), "%s in %q", p.pattern, text)
+			assert.Equal(t, capturePositions(engine.MustAllCaptures(text)), capturePositions(r.MustAllCaptures(text)))
+			assert.Equal(t, engine.MustReplaceAll(text, "<$0>"), r.MustReplaceAll(text, "<$0>"))
+			assert.Equal(t, engine.MustSplit(text), r.MustSplit(text))
+			for _, bounds := range [][2]uint{{1, uint(len(text))}, {uint(len(text)), 0}, {0, uint(len(text)) / 2}} {
+				if bounds[0] > uint(len(text)) || bounds[1] > uint(len(text)) {
+					continue
+				}
+				expected := engine.MustSearchFirstWithParam(text, bounds[0], bounds[1], REGEX_OPTION_NONE, 0, 0)
+				actual := r.MustSearchFirstWithParam(text, bounds[0], bounds[1], REGEX_OPTION_NONE, 0, 0)
+				if expected == nil {
+					assert.Nil(t, actual, "%s in %q %v", p.pattern, text, bounds)
+				} else if assert.NotNil(t, actual, "%s in %q %v", p.pattern, text, bounds) {
+					assert.Equal(t, expected.Pos(0), actual.Pos(0))
+				}
+			}
+		}
+	}
+}
+
+// capturePositions returns the positions of the groups of each of captures.
+func capturePositions(captures []Captures) [][]*Range {
+	positions := make([][]*Range, len(captures))
+	for i := range captures {
+		for pos := range captures[i].AllPos() {
+			positions[i] = append(positions[i], pos)
+		}
+	}
+	return positions
+}
+
+func TestRegex_Prefilter_Generated(t *testing.T) {
+	atoms := []string{`a`, `b`, `ab`, `\.`, `.`, `[ab]`, `(a|b)`, `(?:ab)`, `\d`, `{`}
+	quantifiers := []string{``, ``, `?`, `*`, `+`, `{2}`, `{0,2}`, `{1,}`}
+	random := rand.New(rand.NewSource(1))
+	texts := []string{"", "a", "ab", "ac", "abc", "a.b", "aab{", "1ab2"}
+	for range 50 {
+		var builder strings.Builder
+		for range random.Intn(8) {
+			builder.WriteByte("ab.{1c"[random.Intn(6)])
+		}
+		texts = append(texts, builder.String())
+	}
+	for _, syntax := range []*Syntax{SyntaxRuby, SyntaxOniguruma, SyntaxJava, SyntaxPerl, SyntaxPerlNG, SyntaxPython} {
+		for range 500 {
+			var builder strings.Builder
+			for range 1 + random.Intn(4) {
+				builder.WriteString(atoms[random.Intn(len(atoms))])
+				// Chained quantifiers, such as +* or +?, may make a repeated character optional.
+				for range random.Intn(3) {
+					builder.WriteString(quantifiers[random.Intn(len(quantifiers))])
+				}
+			}
+			pattern := builder.String()
+			r, err := CompileWithSyntax(pattern, syntax)
+			if err != nil {
+				continue
+			}
+			// The prefilter only looks at the top level of a pattern, so the wrapped pattern always searches.
+			engine := MustCompileWithSyntax("(?:"+pattern+")", syntax)
+			for _, text := range texts {
+				assert.Equal(t, engine.MustFindMatches(text), r.MustFindMatches(text), "%s %s in %q", syntax.Name(), pattern, text)
+			}
+		}
+	}
+}
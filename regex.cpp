@@ -2,6 +2,7 @@
 #include "oniguruma.h"
 #include <cstdlib>
 #include <cstring>
+#include <utility>
 #include <vector>
 
 class OptionalInt {
@@ -42,7 +43,7 @@ int appendGroupNameToArray(
     current->indicesCount = nGroupNum;
     if (current->indicesCount > 0) {
         current->indices = (int*)calloc(current->indicesCount, sizeof(int));
-        memcpy(current->indices, groupNums, current->indicesCount);
+        memcpy(current->indices, groupNums, current->indicesCount * sizeof(int));
     } else {
         current->indices = NULL;
     }
@@ -176,13 +177,13 @@ searchAllResult searchAllWithParam(
 
     OnigRegion* onigRegion = onig_region_new();
     int onigSearchResult = 0;
-    int lastEnd = 0;
+    int lastEnd = from;
     OptionalInt lastMatchEnd;
-    while (lastEnd <= textLen) {
+    while (lastEnd <= to) {
         const UChar* begin = (const UChar*)text;
         const UChar* end = begin + textLen;
         const UChar* limitStart = begin + lastEnd;
-        const UChar* limitRange = begin + textLen;
+        const UChar* limitRange = begin + to;
         onigSearchResult = onig_search_with_param(
             reg,
             begin,
@@ -231,6 +232,263 @@ searchAllResult searchAllWithParam(
     return result;
 }
 
+// Searches for the match starting last at or before start, filling region.
+// Oniguruma's backward search only finds matches that end at most one character after start,
+// so the positions following its result are tried again with the whole text available.
+static int searchLastStartingBefore(
+    regex_t* reg,
+    const UChar* begin,
+    const UChar* end,
+    const UChar* start,
+    OnigRegion* region,
+    OnigOptionType option
+) {
+    OnigEncoding encoding = onig_get_encoding(reg);
+    int result = onig_search(reg, begin, end, start, begin, region, option);
+    if (result < 0 && result != ONIG_MISMATCH) {
+        return result;
+    }
+    const UChar* lowest = result >= 0 ? begin + result : NULL;
+    const UChar* position = start;
+    while (position != NULL && position > lowest) {
+        int matchResult = onig_match(reg, begin, end, position, region, option);
+        if (matchResult >= 0) {
+            return (int)(position - begin);
+        }
+        if (matchResult != ONIG_MISMATCH) {
+            return matchResult;
+        }
+        position = position > begin ? onigenc_get_prev_char_head(encoding, begin, position) : NULL;
+    }
+    if (lowest == NULL) {
+        return ONIG_MISMATCH;
+    }
+    // The backward search result may have been cut short, so match again at its position.
+    int matchResult = onig_match(reg, begin, end, lowest, region, option);
+    return matchResult >= 0 ? (int)(lowest - begin) : matchResult;
+}
+
+searchAllResult searchAllBackwardWithParam(
+    regex_t* reg,
+    const char* text,
+    unsigned int textLen,
+    unsigned int from,
+    int exclusive,
+    unsigned int limit,
+    OnigOptionType option
+) {
+    std::vector<region*> regions;
+    OnigEncoding encoding = onig_get_encoding(reg);
+    const UChar* begin = (const UChar*)text;
+    const UChar* end = begin + textLen;
+    const UChar* start = begin + from;
+    if (exclusive) {
+        start = start > begin ? onigenc_get_prev_char_head(encoding, begin, start) : NULL;
+    }
+    OnigRegion* onigRegion = onig_region_new();
+    int onigSearchResult = 0;
+    OptionalInt lastMatchFrom;
+    while (start != NULL && (limit == 0 || regions.size() < limit)) {
+        onigSearchResult = searchLastStartingBefore(reg, begin, end, start, onigRegion, option);
+        if (onigSearchResult < 0) {
+            break;
+        }
+        if (onigRegion->beg == NULL || onigRegion->end == NULL || onigRegion->num_regs <= 0) {
+            break;
+        }
+        int posFrom = *(onigRegion->beg);
+        int posTo = *(onigRegion->end);
+        const UChar* matchStart = begin + posFrom;
+        start = matchStart > begin ? onigenc_get_prev_char_head(encoding, begin, matchStart) : NULL;
+        // Don't accept matches overlapping the last match, which starts further right.
+        if (lastMatchFrom.hasValue && posTo > lastMatchFrom.value) {
+            continue;
+        }
+        lastMatchFrom.setValue(posFrom);
+        regions.push_back(regionPtrFromOnigRegion(onigRegion));
+    }
+    if (onigSearchResult == ONIG_MISMATCH) {
+        onigSearchResult = 0;
+    }
+    onig_region_free(onigRegion, 1);
+    free((void*)text);
+    searchAllResult result;
+    result.result = onigSearchResult;
+    result.array = regionsArrayFromVector(regions);
+    if (onigSearchResult < 0) {
+        freeRegionsArray(result.array);
+        result.array = NULL;
+    }
+    return result;
+}
+
+typedef struct {
+    std::vector<region*>* regions;
+    int lastStart;
+    std::vector<std::pair<int, int> > lastStartMatches;
+} eachMatchState;
+
+// Collects every match reported by Oniguruma, dropping the duplicates produced by different paths through the regex.
+static int collectEachMatch(
+    const UChar* str,
+    const UChar* end,
+    const UChar* matchStart,
+    OnigRegion* onigRegion,
+    void* userData
+) {
+    eachMatchState* state = (eachMatchState*)userData;
+    if (state == NULL || onigRegion == NULL || onigRegion->num_regs <= 0) {
+        return ONIG_NORMAL;
+    }
+    int posFrom = onigRegion->beg[0];
+    int posTo = onigRegion->end[0];
+    if (posFrom != state->lastStart) {
+        state->lastStart = posFrom;
+        state->lastStartMatches.clear();
+    }
+    for (size_t i = 0; i < state->lastStartMatches.size(); i++) {
+        if (state->lastStartMatches[i].second == posTo) {
+            return ONIG_NORMAL;
+        }
+    }
+    state->lastStartMatches.push_back(std::make_pair(posFrom, posTo));
+    state->regions->push_back(regionPtrFromOnigRegion(onigRegion));
+    return ONIG_NORMAL;
+}
+
+searchAllResult searchOverlappingWithParam(
+    regex_t* reg,
+    const char* text,
+    unsigned int textLen,
+    OnigOptionType option,
+    int allMatches
+) {
+    std::vector<region*> regions;
+    OnigEncoding encoding = onig_get_encoding(reg);
+    const UChar* begin = (const UChar*)text;
+    const UChar* end = begin + textLen;
+    OnigMatchParam* match_param = onig_new_match_param();
+    onig_initialize_match_param(match_param);
+    OnigRegion* onigRegion = onig_region_new();
+    int onigSearchResult = 0;
+    if (allMatches) {
+        eachMatchState state;
+        state.regions = &regions;
+        state.lastStart = -1;
+        onig_set_callout_user_data_of_match_param(match_param, &state);
+        onigSearchResult = onig_search_with_param(
+            reg,
+            begin,
+            end,
+            begin,
+            end,
+            onigRegion,
+            option | ONIG_OPTION_CALLBACK_EACH_MATCH,
+            match_param
+        );
+    } else {
+        const UChar* start = begin;
+        while (start <= end) {
+            onigSearchResult = onig_search_with_param(reg, begin, end, start, end, onigRegion, option, match_param);
+            if (onigSearchResult < 0) {
+                break;
+            }
+            regions.push_back(regionPtrFromOnigRegion(onigRegion));
+            // Restart one character after the start of the match.
+            const UChar* matchStart = begin + onigSearchResult;
+            if (matchStart >= end) {
+                break;
+            }
+            start = matchStart + ONIGENC_MBC_ENC_LEN(encoding, matchStart);
+        }
+    }
+    if (onigSearchResult == ONIG_MISMATCH || onigSearchResult >= 0) {
+        onigSearchResult = 0;
+    }
+    onig_region_free(onigRegion, 1);
+    onig_free_match_param(match_param);
+    free((void*)text);
+    searchAllResult result;
+    result.result = onigSearchResult;
+    result.array = regionsArrayFromVector(regions);
+    if (onigSearchResult < 0) {
+        freeRegionsArrayWithRegions(result.array);
+        result.array = NULL;
+    }
+    return result;
+}
+
+searchLinesResult searchLinesWithParam(
+    regex_t* reg,
+    const char* text,
+    unsigned int textLen,
+    OnigOptionType option,
+    int firstOnly
+) {
+    std::vector<lineMatch> matches;
+    OnigRegion* onigRegion = onig_region_new();
+    int onigSearchResult = 0;
+    const UChar* textStart = (const UChar*)text;
+    unsigned int lineStart = 0;
+    while (lineStart <= textLen && onigSearchResult >= 0) {
+        unsigned int lineEnd = lineStart;
+        while (lineEnd < textLen && text[lineEnd] != '\n') {
+            lineEnd++;
+        }
+        unsigned int nextLineStart = lineEnd + 1;
+        if (lineEnd > lineStart && text[lineEnd - 1] == '\r') {
+            lineEnd--;
+        }
+        if (lineStart == textLen) {
+            break;
+        }
+        // Search the line as a string of its own, so anchors behave as they do for a single line.
+        const UChar* begin = textStart + lineStart;
+        const UChar* end = textStart + lineEnd;
+        int lineLen = lineEnd - lineStart;
+        int lastEnd = 0;
+        OptionalInt lastMatchEnd;
+        while (lastEnd <= lineLen) {
+            onigSearchResult = onig_search(reg, begin, end, begin + lastEnd, end, onigRegion, option);
+            if (onigSearchResult < 0) {
+                break;
+            }
+            int posFrom = onigRegion->beg[0];
+            int posTo = onigRegion->end[0];
+            // Don't accept empty matches immediately following the last match.
+            if (posFrom == posTo && lastMatchEnd.hasValue && lastMatchEnd.value == posTo) {
+                lastEnd += 1;
+                continue;
+            }
+            lastEnd = posTo;
+            lastMatchEnd.setValue(posTo);
+            lineMatch match;
+            match.lineStart = lineStart;
+            match.from = posFrom;
+            match.to = posTo;
+            matches.push_back(match);
+            if (firstOnly) {
+                break;
+            }
+        }
+        if (onigSearchResult == ONIG_MISMATCH) {
+            onigSearchResult = 0;
+        }
+        lineStart = nextLineStart;
+    }
+    onig_region_free(onigRegion, 1);
+    free((void*)text);
+    searchLinesResult result;
+    result.result = onigSearchResult < 0 ? onigSearchResult : 0;
+    result.count = matches.size();
+    result.matches = NULL;
+    if (result.result == 0 && result.count > 0) {
+        result.matches = (lineMatch*)calloc(result.count, sizeof(lineMatch));
+        memcpy(result.matches, matches.data(), result.count * sizeof(lineMatch));
+    }
+    return result;
+}
+
 void freeGroupNamesArray(groupNamesArray* array) {
     if (array == NULL) {
         return;
@@ -284,3 +542,228 @@ void freeRegionsArrayWithRegions(regionsArray* array) {
     }
     freeRegionsArray(array);
 }
+
+void freeLineMatches(lineMatch* matches) {
+    if (matches != NULL) {
+        free(matches);
+    }
+}
+
+void initializeOniguruma() {
+    OnigEncoding encodings[] = {ONIG_ENCODING_UTF8};
+    onig_initialize(encodings, 1);
+    // The callback is global, so it is installed once rather than by each search.
+    onig_set_callback_each_match(collectEachMatch);
+}
+
+// Appends the start and end of each non-overlapping match in [begin, end) to positions,
+// following the rules of searchAllWithParam. Returns ONIG_NORMAL or a negative error code.
+static int appendAllPositions(
+    regex_t* reg,
+    const UChar* begin,
+    const UChar* end,
+    OnigOptionType option,
+    OnigMatchParam* match_param,
+    OnigRegion* onigRegion,
+    std::vector<int>& positions
+) {
+    int to = (int)(end - begin);
+    int lastEnd = 0;
+    OptionalInt lastMatchEnd;
+    while (lastEnd <= to) {
+        int onigSearchResult = onig_search_with_param(
+            reg,
+            begin,
+            end,
+            begin + lastEnd,
+            end,
+            onigRegion,
+            option,
+            match_param
+        );
+        if (onigSearchResult == ONIG_MISMATCH) {
+            return ONIG_NORMAL;
+        }
+        if (onigSearchResult < 0) {
+            return onigSearchResult;
+        }
+        int posFrom = onigRegion->beg[0];
+        int posTo = onigRegion->end[0];
+        if (posFrom == posTo && lastMatchEnd.hasValue && lastMatchEnd.value == posTo) {
+            lastEnd += 1;
+            continue;
+        }
+        lastEnd = posTo;
+        lastMatchEnd.setValue(posTo);
+        positions.push_back(posFrom);
+        positions.push_back(posTo);
+    }
+    return ONIG_NORMAL;
+}
+
+int matchMany(
+    regex_t* reg,
+    const char* buffer,
+    const unsigned int* offsets,
+    unsigned int count,
+    OnigOptionType option,
+    char* results
+) {
+    OnigMatchParam* match_param = onig_new_match_param();
+    onig_initialize_match_param(match_param);
+    int result = ONIG_NORMAL;
+    for (unsigned int i = 0; i < count; i++) {
+        const UChar* begin = (const UChar*)buffer + offsets[i];
+        const UChar* end = (const UChar*)buffer + offsets[i + 1];
+        int onigSearchResult = onig_search_with_param(reg, begin, end, begin, end, NULL, option, match_param);
+        if (onigSearchResult < 0 && onigSearchResult != ONIG_MISMATCH) {
+            result = onigSearchResult;
+            break;
+        }
+        results[i] = onigSearchResult >= 0;
+    }
+    onig_free_match_param(match_param);
+    return result;
+}
+
+int matchAtWithParam(
+    regex_t* reg,
+    const char* text,
+    unsigned int textLen,
+    unsigned int at,
+    OnigOptionType option,
+    unsigned int retryLimitInMatch
+) {
+    const UChar* begin = (const UChar*)text;
+    OnigMatchParam* match_param = onig_new_match_param();
+    onig_initialize_match_param(match_param);
+    if (retryLimitInMatch != 0) {
+        onig_set_retry_limit_in_match_of_match_param(match_param, retryLimitInMatch);
+    }
+    int result = onig_match_with_param(reg, begin, begin + textLen, begin + at, NULL, option, match_param);
+    onig_free_match_param(match_param);
+    return result;
+}
+
+findManyResult findMany(
+    regex_t* reg,
+    const char* buffer,
+    const unsigned int* offsets,
+    unsigned int count,
+    OnigOptionType option,
+    unsigned int* matchCounts
+) {
+    std::vector<int> positions;
+    OnigMatchParam* match_param = onig_new_match_param();
+    onig_initialize_match_param(match_param);
+    OnigRegion* onigRegion = onig_region_new();
+    findManyResult result;
+    result.result = ONIG_NORMAL;
+    for (unsigned int i = 0; i < count; i++) {
+        size_t before = positions.size();
+        result.result = appendAllPositions(
+            reg,
+            (const UChar*)buffer + offsets[i],
+            (const UChar*)buffer + offsets[i + 1],
+            option,
+            match_param,
+            onigRegion,
+            positions
+        );
+        if (result.result < 0) {
+            break;
+        }
+        matchCounts[i] = (unsigned int)((positions.size() - before) / 2);
+    }
+    onig_region_free(onigRegion, 1);
+    onig_free_match_param(match_param);
+    result.positions = NULL;
+    result.positionsCount = 0;
+    if (result.result >= 0 && !positions.empty()) {
+        result.positionsCount = positions.size();
+        result.positions = (int*)malloc(positions.size() * sizeof(int));
+        memcpy(result.positions, positions.data(), positions.size() * sizeof(int));
+    }
+    return result;
+}
+
+// Searches for the non-overlapping matches that start before chunkEnd, from the position from,
+// as searchAllWithParam does, with matches limited to rangeEnd. blocked is set when a match ended at from.
+// Stops after limit matches if limit isn't 0, and sets done when no other match starts before chunkEnd.
+chunkSearchResult searchChunk(
+    regex_t* reg,
+    const char* text,
+    unsigned int textLen,
+    unsigned int from,
+    int blocked,
+    unsigned int chunkEnd,
+    unsigned int rangeEnd,
+    OnigOptionType option,
+    unsigned int limit
+) {
+    std::vector<int> positions;
+    const UChar* begin = (const UChar*)text;
+    const UChar* end = begin + textLen;
+    OnigMatchParam* match_param = onig_new_match_param();
+    onig_initialize_match_param(match_param);
+    OnigRegion* onigRegion = onig_region_new();
+    chunkSearchResult result;
+    result.result = ONIG_NORMAL;
+    result.done = 0;
+    unsigned int pos = from;
+    while (1) {
+        if (pos >= chunkEnd || pos > textLen) {
+            result.done = 1;
+            break;
+        }
+        if (limit != 0 && positions.size() / 2 >= limit) {
+            break;
+        }
+        int onigSearchResult = onig_search_with_param(
+            reg,
+            begin,
+            end,
+            begin + pos,
+            begin + rangeEnd,
+            onigRegion,
+            option,
+            match_param
+        );
+        if (onigSearchResult == ONIG_MISMATCH) {
+            result.done = 1;
+            break;
+        }
+        if (onigSearchResult < 0) {
+            result.result = onigSearchResult;
+            break;
+        }
+        unsigned int posFrom = onigRegion->beg[0];
+        unsigned int posTo = onigRegion->end[0];
+        if (posFrom >= chunkEnd) {
+            result.done = 1;
+            break;
+        }
+        // Like searchAllWithParam, don't accept an empty match immediately following the last match.
+        if (posFrom == posTo && blocked && posTo == pos) {
+            pos += 1;
+            blocked = 0;
+            continue;
+        }
+        positions.push_back(posFrom);
+        positions.push_back(posTo);
+        pos = posTo;
+        blocked = 1;
+    }
+    onig_region_free(onigRegion, 1);
+    onig_free_match_param(match_param);
+    result.nextPos = pos;
+    result.blocked = blocked;
+    result.positions = NULL;
+    result.positionsCount = 0;
+    if (result.result >= 0 && !positions.empty()) {
+        result.positionsCount = positions.size();
+        result.positions = (int*)malloc(positions.size() * sizeof(int));
+        memcpy(result.positions, positions.data(), positions.size() * sizeof(int));
+    }
+    return result;
+}
@@ -12,9 +12,14 @@ import (
 	"maps"
 	"runtime"
 	"slices"
+	"strings"
 	"unsafe"
 )
 
+func init() {
+	C.initializeOniguruma()
+}
+
 // ReplacementFunc is a function that takes the matches Captures and returns the replaced string.
 type ReplacementFunc func(capture *Captures) (string, error)
 
@@ -24,6 +29,27 @@ type Regex struct {
 	groupIndicesMap map[string][]int
 	raw             C.OnigRegex
 	syntax          *Syntax
+	pattern         string
+	options         RegexOptions
+	// handle keeps raw alive, so that copies of the Regex share it.
+	handle *regexHandle
+	// requiredLiterals are contained in every match, so a text without them is rejected without searching.
+	requiredLiterals []string
+	// goRegexp runs the searches of the whole text when the regex is compiled with CompileHybrid.
+	goRegexp *goRegexp
+}
+
+// regexHandle owns a compiled Oniguruma regex and frees it once no Regex refers to it.
+type regexHandle struct {
+	raw C.OnigRegex
+}
+
+func newRegexHandle(raw C.OnigRegex) *regexHandle {
+	handle := &regexHandle{raw: raw}
+	runtime.SetFinalizer(handle, func(handle *regexHandle) {
+		C.onig_free(handle.raw)
+	})
+	return handle
 }
 
 // MustCompile creates a new Regex object.
@@ -64,7 +90,7 @@ func MustCompileWithOptionsAndSyntax(pattern string, options RegexOptions, synta
 
 // Compile creates a new Regex object.
 func Compile(pattern string) (*Regex, error) {
-	return CompileWithOptionsAndSyntax(pattern, REGEX_OPTION_NONE, SyntaxDefault)
+	return CompileWithOptionsAndSyntax(pattern, REGEX_OPTION_NONE, DefaultSyntax())
 }
 
 // CompileWithOptions creates a new Regex object with the given options.
@@ -72,7 +98,7 @@ func CompileWithOptions(
 	pattern string,
 	options RegexOptions,
 ) (*Regex, error) {
-	return CompileWithOptionsAndSyntax(pattern, options, SyntaxDefault)
+	return CompileWithOptionsAndSyntax(pattern, options, DefaultSyntax())
 }
 
 // CompileWithSyntax creates a new Regex object with the given syntax.
@@ -86,16 +112,15 @@ func CompileWithOptionsAndSyntax(
 	options RegexOptions,
 	syntax *Syntax,
 ) (*Regex, error) {
+	if err := options.validateCompileTime(); err != nil {
+		return nil, err
+	}
 	instance := &Regex{
 		groupIndicesMap: map[string][]int{},
 		syntax:          syntax,
+		pattern:         pattern,
+		options:         options,
 	}
-	runtime.SetFinalizer(instance, func(regex *Regex) {
-		if regex.raw != nil {
-			C.onig_free(regex.raw)
-			regex.raw = nil
-		}
-	})
 	result := C.newRegex(
 		C.CString(pattern),
 		C.uint(len(pattern)),
@@ -106,6 +131,8 @@ func CompileWithOptionsAndSyntax(
 		return nil, fmt.Errorf("error creating oniguruma regex: onig_new returned %d", int(result.result))
 	}
 	instance.raw = result.regex
+	instance.handle = newRegexHandle(result.regex)
+	instance.requiredLiterals = requiredLiterals(pattern, syntax, instance.Options())
 	if result.groupNames != nil {
 		groupNamesCount := int(result.groupNames.count)
 		if groupNamesCount > 0 && result.groupNames.names != nil {
@@ -134,39 +161,17 @@ func CompileWithOptionsAndSyntax(
 // This is operationally the same as FindMatches, except it yields information about submatches.
 func (r *Regex) AllCaptures(text string) ([]Captures, error) {
 	// Based on https://docs.rs/onig/latest/onig/struct.Regex.html#method.captures_iter
-	cText := C.CString(text)
-	result := C.searchAllWithParam(
-		r.raw,
-		cText,
-		C.uint(len(text)),
-		C.uint(0),
-		C.uint(len(text)),
-		C.uint(REGEX_OPTION_NONE),
-		C.uint(0),
-		C.uint(0),
-	)
-	if result.result == C.ONIG_MISMATCH || result.array == nil {
-		return nil, nil
-	}
-	if result.result < 0 {
-		return nil, errorFromCode(result.result)
-	}
-	length := int(result.array.count)
-	regions := make([]*Region, length)
-	rawRegions := (*[1 << 30]*C.region)(unsafe.Pointer(result.array.regions))[:length:length]
-	for i, rawRegion := range rawRegions {
-		regions[i] = newRegion(r, rawRegion)
-	}
-	C.freeRegionsArray(result.array)
-	captures := make([]Captures, len(regions))
-	for i, region := range regions {
-		captures[i] = Captures{
-			Regex:  r,
-			Region: region,
-			Text:   text,
-		}
+	return r.allCapturesWithParam(text, wholeTextParams(text))
+}
+
+// AllCapturesWithSearchOptions returns a list of all non-overlapping capture groups matched in text,
+// searching as configured by options.
+func (r *Regex) AllCapturesWithSearchOptions(text string, options ...SearchOption) ([]Captures, error) {
+	params, err := newSearchParams(text, options)
+	if err != nil {
+		return nil, err
 	}
-	return captures, nil
+	return r.allCapturesWithParam(text, params)
 }
 
 // CaptureNamesWithIndices returns a map of the names and their indices of all capture groups in the regular expression.
@@ -189,14 +194,17 @@ func (r *Regex) CaptureNames() []string {
 // Capture group 0 always corresponds to the entire match. If no match is found, then nil is returned.
 func (r *Regex) Captures(text string) (*Captures, error) {
 	// Based on https://docs.rs/onig/latest/onig/struct.Regex.html#method.captures
-	region, err := r.SearchFirstWithParam(
-		text,
-		0,
-		uint(len(text)),
-		REGEX_OPTION_NONE,
-		0,
-		0,
-	)
+	return r.CapturesWithSearchOptions(text)
+}
+
+// CapturesWithSearchOptions returns the capture groups corresponding to the leftmost-first match in text,
+// searching as configured by options. If no match is found, then nil is returned.
+func (r *Regex) CapturesWithSearchOptions(text string, options ...SearchOption) (*Captures, error) {
+	params, err := newSearchParams(text, options)
+	if err != nil {
+		return nil, err
+	}
+	region, err := r.searchFirst(text, params)
 	if err != nil {
 		return nil, err
 	}
@@ -212,26 +220,31 @@ func (r *Regex) Captures(text string) (*Captures, error) {
 
 // CreateReplacementFunc creates a ReplacementFunc from the given replacement string.
 // The replacement func is created using the syntax's ReplacerFactory if it exists.
+// If the replacement can't be compiled into a Template, the returned func reports the compilation error.
 func (r *Regex) CreateReplacementFunc(replacement string) ReplacementFunc {
-	if r.syntax != nil && r.syntax.ReplacerFactory != nil {
-		return r.syntax.ReplacerFactory(replacement).Replace
-	}
-	return func(captures *Captures) (string, error) {
-		return replacement, nil
+	template, err := r.CompileTemplate(replacement)
+	if err != nil {
+		return func(captures *Captures) (string, error) {
+			return "", err
+		}
 	}
+	return template.Replace
 }
 
 // FindMatch returns the first match of the regex in the given text.
 // If no match is found, then a nil Range is returned.
 func (r *Regex) FindMatch(text string) (*Range, error) {
-	region, err := r.SearchFirstWithParam(
-		text,
-		0,
-		uint(len(text)),
-		REGEX_OPTION_NONE,
-		0,
-		0,
-	)
+	return r.FindMatchWithSearchOptions(text)
+}
+
+// FindMatchWithSearchOptions returns the first match of the regex in the given text, searching as configured by options.
+// If no match is found, then a nil Range is returned.
+func (r *Regex) FindMatchWithSearchOptions(text string, options ...SearchOption) (*Range, error) {
+	params, err := newSearchParams(text, options)
+	if err != nil {
+		return nil, err
+	}
+	region, err := r.searchFirst(text, params)
 	if err != nil {
 		return nil, err
 	}
@@ -245,23 +258,45 @@ func (r *Regex) FindMatch(text string) (*Range, error) {
 // returning the start and end byte indices with respect to text.
 func (r *Regex) FindMatches(text string) ([]*Range, error) {
 	// Based on https://docs.rs/onig/latest/onig/struct.Regex.html#method.find_iter
+	return r.FindMatchesWithSearchOptions(text)
+}
+
+// FindMatchesWithSearchOptions returns a list containing each non-overlapping match in text,
+// searching as configured by options.
+func (r *Regex) FindMatchesWithSearchOptions(text string, options ...SearchOption) ([]*Range, error) {
+	params, err := newSearchParams(text, options)
+	if err != nil {
+		return nil, err
+	}
+	if !r.mayMatch(text, params.from, params.to) {
+		return []*Range{}, nil
+	}
+	if r.usesGoRegexp(text, params, true) {
+		positions := r.goRegexp.regexp.FindAllStringIndex(text, -1)
+		matches := make([]*Range, len(positions))
+		for i, position := range positions {
+			matches[i] = NewRange(position[0], position[1])
+		}
+		return matches, nil
+	}
 	cText := C.CString(text)
 	result := C.searchAllWithParam(
 		r.raw,
 		cText,
 		C.uint(len(text)),
-		C.uint(0),
-		C.uint(len(text)),
-		C.uint(REGEX_OPTION_NONE),
-		C.uint(0),
-		C.uint(0),
+		C.uint(params.from),
+		C.uint(params.to),
+		C.uint(params.options),
+		C.uint(params.maxStackSize),
+		C.uint(params.retryLimitInMatch),
 	)
-	if result.result == C.ONIG_MISMATCH || result.array == nil {
-		return nil, nil
-	}
+	runtime.KeepAlive(r)
 	if result.result < 0 {
 		return nil, errorFromCode(result.result)
 	}
+	if result.array == nil {
+		return nil, nil
+	}
 	length := int(result.array.count)
 	regions := make([]*Region, length)
 	rawRegions := (*[1 << 30]*C.region)(unsafe.Pointer(result.array.regions))[:length:length]
@@ -436,7 +471,7 @@ func (r *Regex) MustSplit(text string) []string {
 }
 
 // MustSplitN returns a list of at most `limit` substrings of text delimited by a match of the regular expression.
-// A limit of 0 will return no substrings.
+// A limit of 0 will return no substrings, and a negative limit will return all substrings.
 // Namely, each element of the iterator corresponds to text that isn’t matched by the regular expression.
 // The remainder of the string that is not split will be the last element in the iterator.
 // Compared to SplitN, this method panics on error.
@@ -455,6 +490,12 @@ func (r *Regex) Replace(text string, replacement string) (string, error) {
 	return r.ReplaceN(text, replacement, 1)
 }
 
+// ReplaceWithSearchOptions replaces the leftmost-first match with the replacement provided,
+// searching as configured by options.
+func (r *Regex) ReplaceWithSearchOptions(text string, replacement string, options ...SearchOption) (string, error) {
+	return r.ReplaceNWithSearchOptions(text, replacement, 1, options...)
+}
+
 // ReplaceAll replaces all non-overlapping matches in text with the replacement provided.
 // This is the same as calling ReplaceN with limit set to 0.
 // See the documentation for Replace for details on how to access submatches in the replacement string.
@@ -463,6 +504,12 @@ func (r *Regex) ReplaceAll(text string, replacement string) (string, error) {
 	return r.ReplaceN(text, replacement, 0)
 }
 
+// ReplaceAllWithSearchOptions replaces all non-overlapping matches in text with the replacement provided,
+// searching as configured by options.
+func (r *Regex) ReplaceAllWithSearchOptions(text string, replacement string, options ...SearchOption) (string, error) {
+	return r.ReplaceNWithSearchOptions(text, replacement, 0, options...)
+}
+
 // ReplaceAllFunc replaces all non-overlapping matches in text with the replacement function provided.
 // This is the same as calling ReplaceNFunc with limit set to 0.
 // See the documentation for Replace for details on how to access submatches in the replacement string.
@@ -471,6 +518,16 @@ func (r *Regex) ReplaceAllFunc(text string, replacement ReplacementFunc) (string
 	return r.ReplaceNFunc(text, replacement, 0)
 }
 
+// ReplaceAllFuncWithSearchOptions replaces all non-overlapping matches in text with the replacement function provided,
+// searching as configured by options.
+func (r *Regex) ReplaceAllFuncWithSearchOptions(
+	text string,
+	replacement ReplacementFunc,
+	options ...SearchOption,
+) (string, error) {
+	return r.ReplaceNFuncWithSearchOptions(text, replacement, 0, options...)
+}
+
 // ReplaceFunc replaces the leftmost-first match with the replacement provided.
 // The replacement is a function that takes the matches Captures and returns the replaced string.
 // If no match is found, then a copy of the string is returned unchanged.
@@ -479,12 +536,54 @@ func (r *Regex) ReplaceFunc(text string, replacement ReplacementFunc) (string, e
 	return r.ReplaceNFunc(text, replacement, 1)
 }
 
+// ReplaceFuncWithSearchOptions replaces the leftmost-first match with the replacement function provided,
+// searching as configured by options.
+func (r *Regex) ReplaceFuncWithSearchOptions(
+	text string,
+	replacement ReplacementFunc,
+	options ...SearchOption,
+) (string, error) {
+	return r.ReplaceNFuncWithSearchOptions(text, replacement, 1, options...)
+}
+
 // ReplaceN replaces at most limit non-overlapping matches in text with the replacement provided.
 // If limit is 0, then all non-overlapping matches are replaced.
 // See the documentation for Replace for details on how to access submatches in the replacement string.
 func (r *Regex) ReplaceN(text string, replacement string, limit int) (string, error) {
 	// Based on https://docs.rs/onig/latest/onig/struct.Regex.html#method.replacen
-	return r.ReplaceNFunc(text, r.CreateReplacementFunc(replacement), limit)
+	return r.ReplaceNWithSearchOptions(text, replacement, limit)
+}
+
+// ReplaceNWithSearchOptions replaces at most limit non-overlapping matches in text with the replacement provided,
+// searching as configured by options. If limit is 0, then all non-overlapping matches are replaced.
+// Only the text within the search range is replaced; the rest of the text is kept as is.
+func (r *Regex) ReplaceNWithSearchOptions(
+	text string,
+	replacement string,
+	limit int,
+	options ...SearchOption,
+) (string, error) {
+	params, err := newSearchParams(text, options)
+	if err != nil {
+		return "", err
+	}
+	template, err := r.CompileTemplate(replacement)
+	if err != nil {
+		return "", err
+	}
+	newText := make([]byte, 0, len(text))
+	lastMatch := 0
+	err = r.replaceEach(text, limit, params, func(pos *Range, captures *Captures) error {
+		newText = append(newText, text[lastMatch:pos.From]...)
+		newText, err = template.Expand(newText, captures)
+		lastMatch = pos.To
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	newText = append(newText, text[lastMatch:]...)
+	return string(newText), nil
 }
 
 // ReplaceNFunc replaces at most limit non-overlapping matches in text with the replacement provided.
@@ -492,30 +591,40 @@ func (r *Regex) ReplaceN(text string, replacement string, limit int) (string, er
 // See the documentation for Replace for details on how to access submatches in the replacement string.
 func (r *Regex) ReplaceNFunc(text string, replacement ReplacementFunc, limit int) (string, error) {
 	// Based on https://docs.rs/onig/latest/onig/struct.Regex.html#method.replacen
-	newText := ""
-	captures, err := r.AllCaptures(text)
+	return r.ReplaceNFuncWithSearchOptions(text, replacement, limit)
+}
+
+// ReplaceNFuncWithSearchOptions replaces at most limit non-overlapping matches in text with the replacement function provided,
+// searching as configured by options. If limit is 0, then all non-overlapping matches are replaced.
+// Only the text within the search range is replaced; the rest of the text is kept as is.
+func (r *Regex) ReplaceNFuncWithSearchOptions(
+	text string,
+	replacement ReplacementFunc,
+	limit int,
+	options ...SearchOption,
+) (string, error) {
+	params, err := newSearchParams(text, options)
 	if err != nil {
 		return "", err
 	}
+	var builder strings.Builder
+	builder.Grow(len(text))
 	lastMatch := 0
-	for i, capture := range captures {
-		if limit > 0 && i >= limit {
-			break
-		}
-		pos := capture.Pos(0)
-		if pos == nil {
-			continue
-		}
-		newText += text[lastMatch:pos.From]
-		replacedText, err := replacement(&capture)
+	err = r.replaceEach(text, limit, params, func(pos *Range, captures *Captures) error {
+		replacedText, err := replacement(captures)
 		if err != nil {
-			return "", fmt.Errorf("error replacing text: %w", err)
+			return err
 		}
-		newText += replacedText
+		builder.WriteString(text[lastMatch:pos.From])
+		builder.WriteString(replacedText)
 		lastMatch = pos.To
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
-	newText += text[lastMatch:]
-	return newText, nil
+	builder.WriteString(text[lastMatch:])
+	return builder.String(), nil
 }
 
 // SearchFirstWithParam searches pattern in string with match param.
@@ -534,6 +643,13 @@ func (r *Regex) SearchFirstWithParam(
 	maxStackSize uint,
 	retryLimitInMatch uint,
 ) (*Region, error) {
+	if !r.mayMatch(text, from, to) {
+		return nil, nil
+	}
+	params := searchParams{from, to, options, maxStackSize, retryLimitInMatch}
+	if r.usesGoRegexp(text, params, false) {
+		return r.goRegexpFirst(text), nil
+	}
 	cText := C.CString(text)
 	result := C.searchFirstWithParam(
 		r.raw,
@@ -545,6 +661,7 @@ func (r *Regex) SearchFirstWithParam(
 		C.uint(maxStackSize),
 		C.uint(retryLimitInMatch),
 	)
+	runtime.KeepAlive(r)
 	if result.result == C.ONIG_MISMATCH {
 		return nil, nil
 	}
@@ -556,47 +673,127 @@ func (r *Regex) SearchFirstWithParam(
 
 // Split returns a list of substrings of text delimited by a match of the regular expression.
 // Namely, each element of the iterator corresponds to text that isn’t matched by the regular expression.
+// The split follows the conventions of the regex syntax, as configured by its SplitOptions;
+// use SplitWithOptions to choose them.
 func (r *Regex) Split(text string) ([]string, error) {
-	// Based on https://docs.rs/onig/latest/onig/struct.Regex.html#method.split
-	matches, err := r.FindMatches(text)
-	if err != nil {
-		return nil, err
-	}
-	splits := make([]string, 0)
-	last := 0
-	for _, match := range matches {
-		matched := text[last:match.From]
-		last = match.To
-		splits = append(splits, matched)
-	}
-	if last < len(text) {
-		splits = append(splits, text[last:])
-	}
-	return splits, nil
+	return r.SplitWithOptions(text, r.syntax.SplitOptions)
+}
+
+// SplitWithSearchOptions returns a list of substrings of text delimited by a match of the regular expression,
+// searching for the delimiters as configured by options.
+// The split follows the conventions of the regex syntax, as configured by its SplitOptions.
+func (r *Regex) SplitWithSearchOptions(text string, options ...SearchOption) ([]string, error) {
+	return r.SplitWithOptions(text, r.syntax.SplitOptions, options...)
 }
 
 // SplitN returns a list of at most `limit` substrings of text delimited by a match of the regular expression.
-// A limit of 0 will return no substrings.
+// A limit of 0 will return no substrings, and a negative limit will return all substrings.
 // Namely, each element of the iterator corresponds to text that isn’t matched by the regular expression.
 // The remainder of the string that is not split will be the last element in the iterator.
+// Apart from the limit, the split follows the conventions of the regex syntax, as configured by its SplitOptions.
 func (r *Regex) SplitN(text string, limit int) ([]string, error) {
-	// Based on https://docs.rs/onig/latest/onig/struct.Regex.html#method.splitn
-	matches, err := r.FindMatches(text)
+	return r.SplitNWithSearchOptions(text, limit)
+}
+
+// SplitNWithSearchOptions returns a list of at most `limit` substrings of text delimited by a match of the regular expression,
+// searching for the delimiters as configured by options.
+// A limit of 0 will return no substrings, and a negative limit will return all substrings.
+func (r *Regex) SplitNWithSearchOptions(text string, limit int, options ...SearchOption) ([]string, error) {
+	splitOptions := r.syntax.SplitOptions
+	splitOptions.Limit = limit
+	splitOptions.LimitStyle = SplitLimitGo
+	return r.SplitWithOptions(text, splitOptions, options...)
+}
+
+// replaceEach calls replace with the position and the captures of at most limit non-overlapping matches in text,
+// found with the given search parameters,
+// in the order they appear in text. If limit is 0, then all non-overlapping matches are passed to replace.
+// Errors returned by replace are wrapped and stop the iteration.
+func (r *Regex) replaceEach(
+	text string,
+	limit int,
+	params searchParams,
+	replace func(pos *Range, captures *Captures) error,
+) error {
+	captures, err := r.allCapturesWithParam(text, params)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	splits := make([]string, 0)
-	last := 0
-	for i, match := range matches {
-		if i >= limit-1 {
+	for i := range captures {
+		if limit > 0 && i >= limit {
 			break
 		}
-		matched := text[last:match.From]
-		last = match.To
-		splits = append(splits, matched)
+		pos := captures[i].Pos(0)
+		if pos == nil {
+			continue
+		}
+		if err := replace(pos, &captures[i]); err != nil {
+			return fmt.Errorf("error replacing text: %w", err)
+		}
 	}
-	if last < len(text) {
-		splits = append(splits, text[last:])
+	return nil
+}
+
+// allCapturesWithParam returns the capture groups of all non-overlapping matches in text
+// found with the given search parameters.
+func (r *Regex) allCapturesWithParam(text string, params searchParams) ([]Captures, error) {
+	if !r.mayMatch(text, params.from, params.to) {
+		return nil, nil
+	}
+	if r.usesGoRegexp(text, params, true) {
+		return r.goRegexpAll(text), nil
 	}
-	return splits, nil
+	cText := C.CString(text)
+	result := C.searchAllWithParam(
+		r.raw,
+		cText,
+		C.uint(len(text)),
+		C.uint(params.from),
+		C.uint(params.to),
+		C.uint(params.options),
+		C.uint(params.maxStackSize),
+		C.uint(params.retryLimitInMatch),
+	)
+	runtime.KeepAlive(r)
+	if result.result < 0 {
+		return nil, errorFromCode(result.result)
+	}
+	return r.capturesFromRegionsArray(text, result.array), nil
+}
+
+// searchFirst returns the region of the first match in text found with the given search parameters,
+// or nil if there is none.
+func (r *Regex) searchFirst(text string, params searchParams) (*Region, error) {
+	return r.SearchFirstWithParam(
+		text,
+		params.from,
+		params.to,
+		params.options,
+		params.maxStackSize,
+		params.retryLimitInMatch,
+	)
+}
+
+// capturesFromRegionsArray returns the captures of the regions in array, which is freed.
+// The regions themselves are owned by the returned captures.
+func (r *Regex) capturesFromRegionsArray(text string, array *C.regionsArray) []Captures {
+	if array == nil {
+		return nil
+	}
+	length := int(array.count)
+	if length == 0 {
+		C.freeRegionsArray(array)
+		return nil
+	}
+	captures := make([]Captures, length)
+	rawRegions := (*[1 << 30]*C.region)(unsafe.Pointer(array.regions))[:length:length]
+	for i, rawRegion := range rawRegions {
+		captures[i] = Captures{
+			Regex:  r,
+			Region: newRegion(r, rawRegion),
+			Text:   text,
+		}
+	}
+	C.freeRegionsArray(array)
+	return captures
 }
@@ -72,10 +72,105 @@ extern "C" {
         unsigned int retryLimitInMath
     );
 
+    searchAllResult searchAllBackwardWithParam(
+        regex_t* reg,
+        const char* text,
+        unsigned int textLen,
+        unsigned int from,
+        int exclusive,
+        unsigned int limit,
+        OnigOptionType option
+    );
+
+    searchAllResult searchOverlappingWithParam(
+        regex_t* reg,
+        const char* text,
+        unsigned int textLen,
+        OnigOptionType option,
+        int allMatches
+    );
+
+    typedef struct {
+        unsigned int lineStart;
+        int from;
+        int to;
+    } lineMatch;
+
+    typedef struct {
+        int result;
+        unsigned int count;
+        lineMatch* matches;
+    } searchLinesResult;
+
+    searchLinesResult searchLinesWithParam(
+        regex_t* reg,
+        const char* text,
+        unsigned int textLen,
+        OnigOptionType option,
+        int firstOnly
+    );
+
+    int matchMany(
+        regex_t* reg,
+        const char* buffer,
+        const unsigned int* offsets,
+        unsigned int count,
+        OnigOptionType option,
+        char* results
+    );
+
+    int matchAtWithParam(
+        regex_t* reg,
+        const char* text,
+        unsigned int textLen,
+        unsigned int at,
+        OnigOptionType option,
+        unsigned int retryLimitInMatch
+    );
+
+    typedef struct {
+        int result;
+        int* positions;
+        unsigned int positionsCount;
+    } findManyResult;
+
+    findManyResult findMany(
+        regex_t* reg,
+        const char* buffer,
+        const unsigned int* offsets,
+        unsigned int count,
+        OnigOptionType option,
+        unsigned int* matchCounts
+    );
+
+    typedef struct {
+        int result;
+        int* positions;
+        unsigned int positionsCount;
+        unsigned int nextPos;
+        int blocked;
+        int done;
+    } chunkSearchResult;
+
+    chunkSearchResult searchChunk(
+        regex_t* reg,
+        const char* text,
+        unsigned int textLen,
+        unsigned int from,
+        int blocked,
+        unsigned int chunkEnd,
+        unsigned int rangeEnd,
+        OnigOptionType option,
+        unsigned int limit
+    );
+
     void freeGroupNamesArray(groupNamesArray* array);
     void freeRegion(region* region);
     void freeRegionsArray(regionsArray* array);
     void freeRegionsArrayWithRegions(regionsArray* array);
+    void freeLineMatches(lineMatch* matches);
+    void initializeOniguruma();
+
 #ifdef __cplusplus
 }
 #endif
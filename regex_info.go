@@ -0,0 +1,80 @@
+package onig
+
+/*
+#include <oniguruma.h>
+*/
+import "C"
+import "runtime"
+
+// Pattern returns the pattern the regex was compiled from.
+func (r *Regex) Pattern() string {
+	return r.pattern
+}
+
+// String returns the pattern the regex was compiled from.
+func (r *Regex) String() string {
+	return r.pattern
+}
+
+// CompileOptions returns the options the regex was compiled with, as passed to CompileWithOptionsAndSyntax.
+func (r *Regex) CompileOptions() RegexOptions {
+	return r.options
+}
+
+// Options returns the options in effect for the regex, which include the options enabled by its syntax.
+func (r *Regex) Options() RegexOptions {
+	options := RegexOptions(C.onig_get_options(r.raw))
+	runtime.KeepAlive(r)
+	return options
+}
+
+// Syntax returns the syntax the regex was compiled with.
+func (r *Regex) Syntax() *Syntax {
+	return r.syntax
+}
+
+// Encoding returns the name of the encoding of the regex, such as "UTF-8".
+func (r *Regex) Encoding() string {
+	name := C.GoString(C.onig_get_encoding(r.raw).name)
+	runtime.KeepAlive(r)
+	return name
+}
+
+// NumberOfCaptures returns the number of capture groups in the regex, not counting the whole match.
+func (r *Regex) NumberOfCaptures() int {
+	count := int(C.onig_number_of_captures(r.raw))
+	runtime.KeepAlive(r)
+	return count
+}
+
+// NumberOfCaptureHistories returns the number of capture groups whose history is recorded, with `(?@...)`.
+func (r *Regex) NumberOfCaptureHistories() int {
+	count := int(C.onig_number_of_capture_histories(r.raw))
+	runtime.KeepAlive(r)
+	return count
+}
+
+// CaseFoldFlag returns the Oniguruma case fold flags used by the regex when it ignores case.
+func (r *Regex) CaseFoldFlag() uint {
+	flag := uint(C.onig_get_case_fold_flag(r.raw))
+	runtime.KeepAlive(r)
+	return flag
+}
+
+// NonameGroupCaptureIsActive reports whether unnamed groups capture in the regex.
+// Unnamed groups don't capture when the pattern contains named groups, unless REGEX_OPTION_CAPTURE_GROUP is set.
+func (r *Regex) NonameGroupCaptureIsActive() bool {
+	active := C.onig_noname_group_capture_is_active(r.raw) != 0
+	runtime.KeepAlive(r)
+	return active
+}
+
+// Clone returns a new Regex compiled from the same pattern, with the same options, syntax and engine.
+func (r *Regex) Clone() (*Regex, error) {
+	clone, err := CompileWithOptionsAndSyntax(r.pattern, r.options, r.syntax)
+	if err != nil {
+		return nil, err
+	}
+	clone.goRegexp = r.goRegexp
+	return clone, nil
+}
@@ -0,0 +1,44 @@
+package onig
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegex_Introspection(t *testing.T) {
+	pattern := `(?<year>\d{4})-(\d{2})`
+	r, err := CompileWithOptionsAndSyntax(pattern, REGEX_OPTION_IGNORECASE, SyntaxPerlNG)
+	assert.NoError(t, err)
+	assert.Equal(t, pattern, r.Pattern())
+	assert.Equal(t, pattern, r.String())
+	assert.Equal(t, REGEX_OPTION_IGNORECASE, r.CompileOptions())
+	assert.True(t, r.Options()&REGEX_OPTION_IGNORECASE != 0)
+	assert.Same(t, SyntaxPerlNG, r.Syntax())
+	assert.Equal(t, "UTF-8", r.Encoding())
+	assert.Equal(t, 1, r.NumberOfCaptures())
+	assert.Equal(t, 0, r.NumberOfCaptureHistories())
+	assert.False(t, r.NonameGroupCaptureIsActive())
+	assert.NotZero(t, r.CaseFoldFlag())
+}
+
+func TestRegex_NonameGroupCaptureIsActive(t *testing.T) {
+	assert.True(t, MustCompile(`(a)(b)`).NonameGroupCaptureIsActive())
+
+	r, err := CompileWithOptions(`(?<x>a)(b)`, REGEX_OPTION_CAPTURE_GROUP)
+	assert.NoError(t, err)
+	assert.True(t, r.NonameGroupCaptureIsActive())
+	assert.Equal(t, 2, r.NumberOfCaptures())
+}
+
+func TestRegex_Clone(t *testing.T) {
+	r, err := CompileWithOptionsAndSyntax(`a(b+)`, REGEX_OPTION_IGNORECASE, SyntaxPython)
+	assert.NoError(t, err)
+	clone, err := r.Clone()
+	assert.NoError(t, err)
+	assert.NotSame(t, r, clone)
+	assert.Equal(t, r.Pattern(), clone.Pattern())
+	assert.Equal(t, r.CompileOptions(), clone.CompileOptions())
+	assert.Equal(t, r.Options(), clone.Options())
+	assert.Same(t, r.Syntax(), clone.Syntax())
+	assert.Equal(t, r.MustFindMatches("ABB ab"), clone.MustFindMatches("ABB ab"))
+}
@@ -0,0 +1,147 @@
+package onig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// regexDefinition is the serialized form of a Regex, such as
+// {"pattern":"a+","syntax":"ruby","options":["ignorecase"],"encoding":"utf-8"}.
+type regexDefinition struct {
+	Pattern  string   `json:"pattern"`
+	Syntax   string   `json:"syntax,omitempty"`
+	Options  []string `json:"options,omitempty"`
+	Encoding string   `json:"encoding,omitempty"`
+}
+
+// definition returns the serialized form of the regex.
+func (r *Regex) definition() (regexDefinition, error) {
+	var name string
+	if r.syntax != nil {
+		name = r.syntax.Name()
+	}
+	if name == "" {
+		return regexDefinition{}, fmt.Errorf("regex %q has a syntax without a name and can't be serialized", r.pattern)
+	}
+	definition := regexDefinition{
+		Pattern:  r.pattern,
+		Syntax:   name,
+		Encoding: strings.ToLower(r.Encoding()),
+	}
+	if r.options != REGEX_OPTION_NONE {
+		definition.Options = strings.Split(r.options.String(), "|")
+	}
+	return definition, nil
+}
+
+// compile compiles the regex described by the definition.
+// A missing syntax means the default syntax and a missing encoding means UTF-8.
+func (d regexDefinition) compile() (*Regex, error) {
+	syntax := DefaultSyntax()
+	if d.Syntax != "" {
+		var ok bool
+		if syntax, ok = SyntaxByName(d.Syntax); !ok {
+			return nil, fmt.Errorf("unknown regex syntax %q", d.Syntax)
+		}
+	}
+	if d.Encoding != "" && !strings.EqualFold(d.Encoding, "utf-8") && !strings.EqualFold(d.Encoding, "utf8") {
+		return nil, fmt.Errorf("unsupported regex encoding %q", d.Encoding)
+	}
+	options, err := ParseRegexOptions(strings.Join(d.Options, "|"))
+	if err != nil {
+		return nil, err
+	}
+	return CompileWithOptionsAndSyntax(d.Pattern, options, syntax)
+}
+
+// isJSONObject reports whether text holds a JSON object, which the text form of a regex uses
+// for regexes that can't be written as a plain pattern.
+func isJSONObject(text []byte) bool {
+	text = bytes.TrimSpace(text)
+	return len(text) > 0 && text[0] == '{' && json.Valid(text)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+// A regex compiled with SyntaxRuby and no options is written as its pattern,
+// and any other regex as its JSON form, as written by MarshalJSON.
+// The text doesn't depend on the default syntax, which can differ in the process that reads it.
+func (r *Regex) MarshalText() ([]byte, error) {
+	if r.syntax == SyntaxRuby && r.options == REGEX_OPTION_NONE &&
+		!isJSONObject([]byte(r.pattern)) {
+		return []byte(r.pattern), nil
+	}
+	return r.MarshalJSON()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler by compiling the regex written by MarshalText.
+// A text that holds a JSON object is read as the JSON form, and any other text is compiled as a pattern
+// with SyntaxRuby and no options, whatever the default syntax.
+func (r *Regex) UnmarshalText(text []byte) error {
+	if isJSONObject(text) {
+		return r.UnmarshalJSON(text)
+	}
+	compiled, err := CompileWithSyntax(string(text), SyntaxRuby)
+	if err != nil {
+		return err
+	}
+	*r = *compiled
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+// The regex is written as an object holding its pattern, the name of its syntax, the names of its options
+// and its encoding, such as {"pattern":"a+","syntax":"ruby","options":["ignorecase"],"encoding":"utf-8"}.
+// Regexes with a syntax that has no name can't be serialized.
+func (r *Regex) MarshalJSON() ([]byte, error) {
+	definition, err := r.definition()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(definition)
+}
+
+// UnmarshalJSON implements json.Unmarshaler by compiling the regex written by MarshalJSON.
+// The syntax, options and encoding can be left out, and a JSON string is compiled as a pattern
+// with the default syntax and no options. Unknown fields are rejected.
+func (r *Regex) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	var definition regexDefinition
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &definition.Pattern); err != nil {
+			return err
+		}
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&definition); err != nil {
+			return fmt.Errorf("invalid regex definition: %w", err)
+		}
+	}
+	compiled, err := definition.compile()
+	if err != nil {
+		return err
+	}
+	*r = *compiled
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder using the JSON form.
+func (r *Regex) GobEncode() ([]byte, error) {
+	return r.MarshalJSON()
+}
+
+// GobDecode implements gob.GobDecoder using the JSON form.
+func (r *Regex) GobDecode(data []byte) error {
+	return r.UnmarshalJSON(data)
+}
+
+// Set implements flag.Value by compiling value as read by UnmarshalText,
+// so flags accept either a pattern or the JSON form of a regex.
+func (r *Regex) Set(value string) error {
+	return r.UnmarshalText([]byte(value))
+}
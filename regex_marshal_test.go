@@ -0,0 +1,117 @@
+package onig
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"runtime"
+	"testing"
+)
+
+func TestRegex_MarshalJSON(t *testing.T) {
+	r := MustCompileWithOptionsAndSyntax(`a+`, REGEX_OPTION_IGNORECASE|REGEX_OPTION_EXTEND, SyntaxPerlNG)
+	data, err := json.Marshal(r)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"pattern":"a+","syntax":"perl_ng","options":["ignorecase","extend"],"encoding":"utf-8"}`, string(data))
+
+	var decoded Regex
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, `a+`, decoded.Pattern())
+	assert.Same(t, SyntaxPerlNG, decoded.Syntax())
+	assert.Equal(t, REGEX_OPTION_IGNORECASE|REGEX_OPTION_EXTEND, decoded.CompileOptions())
+	assert.Equal(t, []*Range{NewRange(1, 3)}, decoded.MustFindMatches("bAa"))
+}
+
+func TestRegex_UnmarshalJSON_Fields(t *testing.T) {
+	var rules struct {
+		Value   Regex
+		Pointer *Regex
+		Plain   *Regex
+		Missing *Regex
+	}
+	err := json.Unmarshal([]byte(`{
+		"value": {"pattern": "x\\d", "syntax": "Python", "options": ["i"]},
+		"pointer": {"pattern": "y"},
+		"plain": "z+",
+		"missing": null
+	}`), &rules)
+	assert.NoError(t, err)
+	runtime.GC()
+	assert.Same(t, SyntaxPython, rules.Value.Syntax())
+	assert.Equal(t, REGEX_OPTION_IGNORECASE, rules.Value.CompileOptions())
+	assert.Equal(t, NewRange(0, 2), rules.Value.MustFindMatch("X1"))
+	assert.Same(t, SyntaxDefault, rules.Pointer.Syntax())
+	assert.Equal(t, "z+", rules.Plain.Pattern())
+	assert.Nil(t, rules.Missing)
+}
+
+func TestRegex_UnmarshalJSON_Errors(t *testing.T) {
+	var r Regex
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"pattern":"a","syntax":"cobol"}`), &r), "unknown regex syntax")
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"pattern":"a","encoding":"latin1"}`), &r), "unsupported regex encoding")
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"pattern":"a","options":["bogus"]}`), &r), "unknown regex option")
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"pattern":"a","option":["i"]}`), &r), "invalid regex definition")
+	assert.Error(t, json.Unmarshal([]byte(`{"pattern":"a("}`), &r))
+}
+
+func TestRegex_MarshalText(t *testing.T) {
+	text, err := MustCompile(`\d+`).MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, `\d+`, string(text))
+
+	text, err = MustCompileWithSyntax(`\d+`, SyntaxJava).MarshalText()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"pattern":"\\d+","syntax":"java","encoding":"utf-8"}`, string(text))
+
+	text, err = MustCompile(`{"a":1}`).MarshalText()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"pattern":"{\"a\":1}","syntax":"ruby","encoding":"utf-8"}`, string(text))
+
+	for _, r := range []*Regex{
+		MustCompile(`\d+`),
+		MustCompile(`{x}`),
+		MustCompile(`{"a":1}`),
+		MustCompileWithOptionsAndSyntax(`\d+`, REGEX_OPTION_MULTILINE, SyntaxJava),
+	} {
+		text, err := r.MarshalText()
+		assert.NoError(t, err)
+		var decoded Regex
+		assert.NoError(t, decoded.UnmarshalText(text))
+		assert.Equal(t, r.Pattern(), decoded.Pattern())
+		assert.Equal(t, r.CompileOptions(), decoded.CompileOptions())
+		assert.Equal(t, r.Syntax().Name(), decoded.Syntax().Name())
+	}
+}
+
+func TestRegex_Gob(t *testing.T) {
+	type rule struct {
+		Name  string
+		Regex *Regex
+	}
+	var buffer bytes.Buffer
+	original := rule{Name: "digits", Regex: MustCompileWithOptions(`\d+`, REGEX_OPTION_FIND_LONGEST)}
+	assert.NoError(t, gob.NewEncoder(&buffer).Encode(original))
+	var decoded rule
+	assert.NoError(t, gob.NewDecoder(&buffer).Decode(&decoded))
+	assert.Equal(t, "digits", decoded.Name)
+	assert.Equal(t, `\d+`, decoded.Regex.Pattern())
+	assert.Equal(t, REGEX_OPTION_FIND_LONGEST, decoded.Regex.CompileOptions())
+}
+
+func TestRegex_FlagValue(t *testing.T) {
+	var include Regex
+	exclude := MustCompile(`old`)
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Var(&include, "include", "regex of the files to include")
+	flags.Var(exclude, "exclude", "regex of the files to exclude")
+	err := flags.Parse([]string{"-include", `\.go$`, "-exclude", `{"pattern":"_test","syntax":"perl"}`})
+	assert.NoError(t, err)
+	assert.Equal(t, `\.go$`, include.String())
+	assert.Equal(t, "_test", exclude.String())
+	assert.Same(t, SyntaxPerl, exclude.Syntax())
+	assert.Error(t, flags.Parse([]string{"-include", `(`}))
+}
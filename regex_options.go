@@ -1,5 +1,12 @@
 package onig
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RegexOptions are the options used to compile a regex or to search with it.
 type RegexOptions uint
 
 // REGEX_OPTION_NONE no option
@@ -77,3 +84,227 @@ const REGEX_OPTION_CALLBACK_EACH_MATCH = (REGEX_OPTION_NOT_BEGIN_POSITION << 1)
 const REGEX_OPTION_MATCH_WHOLE_STRING = (REGEX_OPTION_CALLBACK_EACH_MATCH << 1)
 
 const REGEX_OPTION_MAXBIT = REGEX_OPTION_MATCH_WHOLE_STRING
+
+// REGEX_OPTIONS_COMPILE_TIME are the options that can be used to compile a regex.
+const REGEX_OPTIONS_COMPILE_TIME = REGEX_OPTION_IGNORECASE |
+	REGEX_OPTION_EXTEND |
+	REGEX_OPTION_MULTILINE |
+	REGEX_OPTION_SINGLELINE |
+	REGEX_OPTION_FIND_LONGEST |
+	REGEX_OPTION_FIND_NOT_EMPTY |
+	REGEX_OPTION_NEGATE_SINGLELINE |
+	REGEX_OPTION_DONT_CAPTURE_GROUP |
+	REGEX_OPTION_CAPTURE_GROUP |
+	REGEX_OPTION_IGNORECASE_IS_ASCII |
+	REGEX_OPTION_WORD_IS_ASCII |
+	REGEX_OPTION_DIGIT_IS_ASCII |
+	REGEX_OPTION_SPACE_IS_ASCII |
+	REGEX_OPTION_POSIX_IS_ASCII |
+	REGEX_OPTION_TEXT_SEGMENT_EXTENDED_GRAPHEME_CLUSTER |
+	REGEX_OPTION_TEXT_SEGMENT_WORD
+
+// REGEX_OPTIONS_SEARCH_TIME are the options that can be used for a search.
+// REGEX_OPTION_FIND_LONGEST and REGEX_OPTION_FIND_NOT_EMPTY are both compile-time and search-time options.
+const REGEX_OPTIONS_SEARCH_TIME = REGEX_OPTION_FIND_LONGEST |
+	REGEX_OPTION_FIND_NOT_EMPTY |
+	REGEX_OPTION_NOTBOL |
+	REGEX_OPTION_NOTEOL |
+	REGEX_OPTION_POSIX_REGION |
+	REGEX_OPTION_CHECK_VALIDITY_OF_STRING |
+	REGEX_OPTION_NOT_BEGIN_STRING |
+	REGEX_OPTION_NOT_END_STRING |
+	REGEX_OPTION_NOT_BEGIN_POSITION |
+	REGEX_OPTION_CALLBACK_EACH_MATCH |
+	REGEX_OPTION_MATCH_WHOLE_STRING
+
+// regexOptionNames holds the name of every option, in the order of its bit.
+var regexOptionNames = []struct {
+	option RegexOptions
+	name   string
+}{
+	{REGEX_OPTION_IGNORECASE, "ignorecase"},
+	{REGEX_OPTION_EXTEND, "extend"},
+	{REGEX_OPTION_MULTILINE, "multiline"},
+	{REGEX_OPTION_SINGLELINE, "singleline"},
+	{REGEX_OPTION_FIND_LONGEST, "find_longest"},
+	{REGEX_OPTION_FIND_NOT_EMPTY, "find_not_empty"},
+	{REGEX_OPTION_NEGATE_SINGLELINE, "negate_singleline"},
+	{REGEX_OPTION_DONT_CAPTURE_GROUP, "dont_capture_group"},
+	{REGEX_OPTION_CAPTURE_GROUP, "capture_group"},
+	{REGEX_OPTION_NOTBOL, "notbol"},
+	{REGEX_OPTION_NOTEOL, "noteol"},
+	{REGEX_OPTION_POSIX_REGION, "posix_region"},
+	{REGEX_OPTION_CHECK_VALIDITY_OF_STRING, "check_validity_of_string"},
+	{REGEX_OPTION_IGNORECASE_IS_ASCII, "ignorecase_is_ascii"},
+	{REGEX_OPTION_WORD_IS_ASCII, "word_is_ascii"},
+	{REGEX_OPTION_DIGIT_IS_ASCII, "digit_is_ascii"},
+	{REGEX_OPTION_SPACE_IS_ASCII, "space_is_ascii"},
+	{REGEX_OPTION_POSIX_IS_ASCII, "posix_is_ascii"},
+	{REGEX_OPTION_TEXT_SEGMENT_EXTENDED_GRAPHEME_CLUSTER, "text_segment_extended_grapheme_cluster"},
+	{REGEX_OPTION_TEXT_SEGMENT_WORD, "text_segment_word"},
+	{REGEX_OPTION_NOT_BEGIN_STRING, "not_begin_string"},
+	{REGEX_OPTION_NOT_END_STRING, "not_end_string"},
+	{REGEX_OPTION_NOT_BEGIN_POSITION, "not_begin_position"},
+	{REGEX_OPTION_CALLBACK_EACH_MATCH, "callback_each_match"},
+	{REGEX_OPTION_MATCH_WHOLE_STRING, "match_whole_string"},
+}
+
+// regexOptionFlags maps the single-letter flags accepted by ParseRegexOptions to the options, following Ruby.
+var regexOptionFlags = map[rune]RegexOptions{
+	'i': REGEX_OPTION_IGNORECASE,
+	'm': REGEX_OPTION_MULTILINE,
+	'x': REGEX_OPTION_EXTEND,
+}
+
+// regexOptionsKnown holds every defined option bit.
+const regexOptionsKnown = REGEX_OPTIONS_COMPILE_TIME | REGEX_OPTIONS_SEARCH_TIME
+
+// regexOptionConflicts lists the pairs of options that onig_new refuses to combine.
+var regexOptionConflicts = [][2]RegexOptions{
+	{REGEX_OPTION_CAPTURE_GROUP, REGEX_OPTION_DONT_CAPTURE_GROUP},
+}
+
+// ParseRegexOptions parses options written either as single-letter flags, such as "imx",
+// or as option names separated by "|", such as "ignorecase|extend".
+//
+// The flags follow Ruby: "i" is REGEX_OPTION_IGNORECASE, "m" is REGEX_OPTION_MULTILINE and "x" is REGEX_OPTION_EXTEND.
+// The names are those of the REGEX_OPTION_* constants without the prefix, in any case, and the prefix itself is allowed.
+// Hexadecimal numbers such as "0x4" are accepted for bits without a name, and "none" or an empty string parse as REGEX_OPTION_NONE.
+// The result is not validated; see RegexOptions.Validate.
+func ParseRegexOptions(text string) (RegexOptions, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return REGEX_OPTION_NONE, nil
+	}
+	if !strings.Contains(text, "|") {
+		if option, ok := parseRegexOptionName(text); ok {
+			return option, nil
+		}
+		if options, ok := parseRegexOptionFlags(text); ok {
+			return options, nil
+		}
+	}
+	options := REGEX_OPTION_NONE
+	for _, part := range strings.Split(text, "|") {
+		option, ok := parseRegexOptionName(strings.TrimSpace(part))
+		if !ok {
+			return REGEX_OPTION_NONE, fmt.Errorf("unknown regex option %q in %q", strings.TrimSpace(part), text)
+		}
+		options |= option
+	}
+	return options, nil
+}
+
+func parseRegexOptionName(name string) (RegexOptions, bool) {
+	name = strings.ToLower(name)
+	name = strings.TrimPrefix(name, "regex_option_")
+	if name == "none" {
+		return REGEX_OPTION_NONE, true
+	}
+	for _, entry := range regexOptionNames {
+		if entry.name == name {
+			return entry.option, true
+		}
+	}
+	if strings.HasPrefix(name, "0x") {
+		if value, err := strconv.ParseUint(name[2:], 16, 0); err == nil {
+			return RegexOptions(value), true
+		}
+	}
+	return REGEX_OPTION_NONE, false
+}
+
+func parseRegexOptionFlags(flags string) (RegexOptions, bool) {
+	options := REGEX_OPTION_NONE
+	for _, flag := range flags {
+		option, ok := regexOptionFlags[flag]
+		if !ok {
+			return REGEX_OPTION_NONE, false
+		}
+		options |= option
+	}
+	return options, true
+}
+
+// String returns the names of the options separated by "|", such as "ignorecase|extend", or "none".
+// Bits without a name are written as a hexadecimal number. The result can be parsed by ParseRegexOptions.
+func (o RegexOptions) String() string {
+	if o == REGEX_OPTION_NONE {
+		return "none"
+	}
+	names := make([]string, 0)
+	for _, entry := range regexOptionNames {
+		if o&entry.option != 0 {
+			names = append(names, entry.name)
+		}
+	}
+	if unknown := o &^ regexOptionsKnown; unknown != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint(unknown)))
+	}
+	return strings.Join(names, "|")
+}
+
+// Validate reports whether the options can be used together.
+// It returns an error for unknown bits, for options that exclude each other,
+// and for compile-time only options combined with search-time only ones.
+func (o RegexOptions) Validate() error {
+	if unknown := o &^ regexOptionsKnown; unknown != 0 {
+		return fmt.Errorf("unknown regex options %#x", uint(unknown))
+	}
+	for _, conflict := range regexOptionConflicts {
+		if o&conflict[0] != 0 && o&conflict[1] != 0 {
+			return fmt.Errorf("regex options %s and %s can't be combined", conflict[0], conflict[1])
+		}
+	}
+	compileOnly := o & (REGEX_OPTIONS_COMPILE_TIME &^ REGEX_OPTIONS_SEARCH_TIME)
+	searchOnly := o & (REGEX_OPTIONS_SEARCH_TIME &^ REGEX_OPTIONS_COMPILE_TIME)
+	if compileOnly != 0 && searchOnly != 0 {
+		return fmt.Errorf(
+			"compile-time regex options %s can't be combined with search-time regex options %s",
+			compileOnly,
+			searchOnly,
+		)
+	}
+	return nil
+}
+
+// IsCompileTime reports whether all the options can be used to compile a regex.
+func (o RegexOptions) IsCompileTime() bool {
+	return o&^REGEX_OPTIONS_COMPILE_TIME == 0
+}
+
+// IsSearchTime reports whether all the options can be used for a search.
+func (o RegexOptions) IsSearchTime() bool {
+	return o&^REGEX_OPTIONS_SEARCH_TIME == 0
+}
+
+// MarshalText implements encoding.TextMarshaler using the format of String.
+func (o RegexOptions) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using ParseRegexOptions.
+func (o *RegexOptions) UnmarshalText(text []byte) error {
+	options, err := ParseRegexOptions(string(text))
+	if err != nil {
+		return err
+	}
+	*o = options
+	return nil
+}
+
+// validateCompileTime returns an error if the options can't be used to compile a regex.
+func (o RegexOptions) validateCompileTime() error {
+	if invalid := o &^ REGEX_OPTIONS_COMPILE_TIME; invalid&regexOptionsKnown != 0 {
+		return fmt.Errorf("invalid compile options %s: %w", invalid, ErrSearchTimeOption)
+	}
+	return o.Validate()
+}
+
+// validateSearchTime returns an error if the options can't be used for a search.
+func (o RegexOptions) validateSearchTime() error {
+	if invalid := o &^ REGEX_OPTIONS_SEARCH_TIME; invalid&regexOptionsKnown != 0 {
+		return fmt.Errorf("invalid search options %s: %w", invalid, ErrCompileTimeOption)
+	}
+	return o.Validate()
+}
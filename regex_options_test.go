@@ -0,0 +1,90 @@
+package onig
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseRegexOptions(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected RegexOptions
+	}{
+		{"", REGEX_OPTION_NONE},
+		{"none", REGEX_OPTION_NONE},
+		{"imx", REGEX_OPTION_IGNORECASE | REGEX_OPTION_MULTILINE | REGEX_OPTION_EXTEND},
+		{"i", REGEX_OPTION_IGNORECASE},
+		{"ignorecase|extend", REGEX_OPTION_IGNORECASE | REGEX_OPTION_EXTEND},
+		{" IGNORECASE | REGEX_OPTION_NOTBOL ", REGEX_OPTION_IGNORECASE | REGEX_OPTION_NOTBOL},
+		{"find_longest", REGEX_OPTION_FIND_LONGEST},
+		{"extend|0x80000000", REGEX_OPTION_EXTEND | 0x80000000},
+	}
+	for _, test := range tests {
+		options, err := ParseRegexOptions(test.text)
+		assert.NoError(t, err, test.text)
+		assert.Equal(t, test.expected, options, test.text)
+	}
+
+	_, err := ParseRegexOptions("iq")
+	assert.Error(t, err)
+	_, err = ParseRegexOptions("ignorecase|unknown")
+	assert.ErrorContains(t, err, `unknown regex option "unknown"`)
+}
+
+func TestRegexOptions_String(t *testing.T) {
+	assert.Equal(t, "none", REGEX_OPTION_NONE.String())
+	assert.Equal(t, "ignorecase|extend", (REGEX_OPTION_EXTEND | REGEX_OPTION_IGNORECASE).String())
+	assert.Equal(t, "notbol|0x80000000", (REGEX_OPTION_NOTBOL | 0x80000000).String())
+	for _, entry := range regexOptionNames {
+		options, err := ParseRegexOptions(entry.option.String())
+		assert.NoError(t, err)
+		assert.Equal(t, entry.option, options)
+	}
+}
+
+func TestRegexOptions_Validate(t *testing.T) {
+	assert.NoError(t, (REGEX_OPTION_IGNORECASE | REGEX_OPTION_EXTEND).Validate())
+	assert.NoError(t, (REGEX_OPTION_NOTBOL | REGEX_OPTION_FIND_LONGEST).Validate())
+	assert.ErrorContains(
+		t,
+		(REGEX_OPTION_CAPTURE_GROUP | REGEX_OPTION_DONT_CAPTURE_GROUP).Validate(),
+		"regex options capture_group and dont_capture_group can't be combined",
+	)
+	// Oniguruma compiles these combinations, so they are valid.
+	for _, options := range []RegexOptions{
+		REGEX_OPTION_SINGLELINE | REGEX_OPTION_NEGATE_SINGLELINE,
+		REGEX_OPTION_TEXT_SEGMENT_EXTENDED_GRAPHEME_CLUSTER | REGEX_OPTION_TEXT_SEGMENT_WORD,
+	} {
+		assert.NoError(t, options.Validate())
+		_, err := CompileWithOptionsAndSyntax(`a`, options, SyntaxRuby)
+		assert.NoError(t, err)
+	}
+	assert.Error(t, (REGEX_OPTION_IGNORECASE | REGEX_OPTION_NOTBOL).Validate())
+	assert.ErrorContains(t, RegexOptions(0x80000000).Validate(), "unknown regex options 0x80000000")
+
+	assert.True(t, (REGEX_OPTION_IGNORECASE | REGEX_OPTION_FIND_LONGEST).IsCompileTime())
+	assert.False(t, REGEX_OPTION_NOTEOL.IsCompileTime())
+	assert.True(t, (REGEX_OPTION_NOTEOL | REGEX_OPTION_FIND_NOT_EMPTY).IsSearchTime())
+	assert.False(t, REGEX_OPTION_EXTEND.IsSearchTime())
+
+	_, err := CompileWithOptions(`a`, REGEX_OPTION_CAPTURE_GROUP|REGEX_OPTION_DONT_CAPTURE_GROUP)
+	assert.Error(t, err)
+}
+
+func TestRegexOptions_MarshalText(t *testing.T) {
+	type config struct {
+		Options RegexOptions `json:"options"`
+	}
+	data, err := json.Marshal(config{Options: REGEX_OPTION_IGNORECASE | REGEX_OPTION_MULTILINE})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"options":"ignorecase|multiline"}`, string(data))
+
+	var decoded config
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, REGEX_OPTION_IGNORECASE|REGEX_OPTION_MULTILINE, decoded.Options)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"options":"ix"}`), &decoded))
+	assert.Equal(t, REGEX_OPTION_IGNORECASE|REGEX_OPTION_EXTEND, decoded.Options)
+	assert.Error(t, json.Unmarshal([]byte(`{"options":"bogus|x"}`), &decoded))
+}
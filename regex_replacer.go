@@ -1,5 +1,11 @@
 package onig
 
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
 // RegexReplacer is an object that can be used to replace capture groups in a string.
 // It implements the Replace method that takes a Captures object and returns the replaced string
 // using the replacement pattern that suits the syntax of the regex.
@@ -9,3 +15,178 @@ type RegexReplacer interface {
 
 // RegexReplacerFactory is a function that creates a RegexReplacer given a replacement pattern.
 type RegexReplacerFactory func(replacement string) RegexReplacer
+
+// replacementPartKind identifies what a replacementPart inserts into the output.
+type replacementPartKind int
+
+const (
+	// replacementLiteral inserts text as it is.
+	replacementLiteral replacementPartKind = iota
+	// replacementGroup inserts the text matched by the numbered group.
+	replacementGroup
+	// replacementNamedGroup inserts the text matched by the named group.
+	replacementNamedGroup
+	// replacementLastGroup inserts the text matched by the highest-numbered group that participated in the match.
+	replacementLastGroup
+	// replacementPrematch inserts the text that precedes the match.
+	replacementPrematch
+	// replacementPostmatch inserts the text that follows the match.
+	replacementPostmatch
+	// replacementCase changes the case of the text inserted by the following parts.
+	replacementCase
+)
+
+// caseModifier is a case conversion requested by a replacement pattern, such as Perl's `\U` or `\u`.
+type caseModifier int
+
+const (
+	// caseEnd ends the active caseUpper, caseLower or caseQuote span (`\E`).
+	caseEnd caseModifier = iota
+	// caseUpper upper-cases the text until caseEnd (`\U`).
+	caseUpper
+	// caseLower lower-cases the text until caseEnd (`\L`).
+	caseLower
+	// caseQuote escapes non-word characters until caseEnd (`\Q`).
+	caseQuote
+	// caseUpperNext upper-cases the next character (`\u`).
+	caseUpperNext
+	// caseLowerNext lower-cases the next character (`\l`).
+	caseLowerNext
+)
+
+// replacementPart is a single pre-parsed piece of a replacement pattern.
+type replacementPart struct {
+	kind         replacementPartKind
+	text         string // the literal text or the group name
+	group        int
+	caseModifier caseModifier
+}
+
+// replacementParser accumulates the parts of a replacement pattern,
+// merging adjacent literal text into a single part.
+type replacementParser struct {
+	parts   []replacementPart
+	literal []byte
+}
+
+func (p *replacementParser) addLiteral(text string) {
+	p.literal = append(p.literal, text...)
+}
+
+func (p *replacementParser) addLiteralByte(ch byte) {
+	p.literal = append(p.literal, ch)
+}
+
+func (p *replacementParser) addPart(part replacementPart) {
+	p.flush()
+	p.parts = append(p.parts, part)
+}
+
+func (p *replacementParser) flush() {
+	if len(p.literal) > 0 {
+		p.parts = append(p.parts, replacementPart{kind: replacementLiteral, text: string(p.literal)})
+		p.literal = p.literal[:0]
+	}
+}
+
+func (p *replacementParser) result() []replacementPart {
+	p.flush()
+	return p.parts
+}
+
+// appendReplacementParts appends the text produced by parts for the given captures to dst.
+func appendReplacementParts(dst []byte, parts []replacementPart, captures *Captures) []byte {
+	var state caseState
+	for _, part := range parts {
+		switch part.kind {
+		case replacementLiteral:
+			dst = state.append(dst, part.text)
+		case replacementGroup:
+			dst = state.append(dst, captures.At(part.group))
+		case replacementNamedGroup:
+			dst = state.append(dst, captures.AtGroupName(part.text))
+		case replacementLastGroup:
+			for i := captures.Len() - 1; i > 0; i-- {
+				if captures.Pos(i) != nil {
+					dst = state.append(dst, captures.At(i))
+					break
+				}
+			}
+		case replacementPrematch:
+			if pos := captures.Pos(0); pos != nil {
+				dst = state.append(dst, captures.Text[:pos.From])
+			}
+		case replacementPostmatch:
+			if pos := captures.Pos(0); pos != nil {
+				dst = state.append(dst, captures.Text[pos.To:])
+			}
+		case replacementCase:
+			state.apply(part.caseModifier)
+		}
+	}
+	return dst
+}
+
+// caseState tracks the case modifiers that apply to the text being written.
+type caseState struct {
+	span  caseModifier // caseEnd, caseUpper or caseLower
+	quote bool
+	next  caseModifier // caseEnd, caseUpperNext or caseLowerNext
+}
+
+func (s *caseState) apply(modifier caseModifier) {
+	switch modifier {
+	case caseEnd:
+		s.span = caseEnd
+		s.quote = false
+	case caseUpper, caseLower:
+		s.span = modifier
+	case caseQuote:
+		s.quote = true
+	case caseUpperNext, caseLowerNext:
+		s.next = modifier
+	}
+}
+
+func (s *caseState) append(dst []byte, text string) []byte {
+	if text == "" {
+		return dst
+	}
+	if s.span == caseEnd && s.next == caseEnd && !s.quote {
+		return append(dst, text...)
+	}
+	switch s.span {
+	case caseUpper:
+		text = strings.ToUpper(text)
+	case caseLower:
+		text = strings.ToLower(text)
+	}
+	if s.next != caseEnd {
+		first, size := utf8.DecodeRuneInString(text)
+		if s.next == caseUpperNext {
+			first = unicode.ToUpper(first)
+		} else {
+			first = unicode.ToLower(first)
+		}
+		text = string(first) + text[size:]
+		s.next = caseEnd
+	}
+	if s.quote {
+		text = quoteMeta(text)
+	}
+	return append(dst, text...)
+}
+
+// quoteMeta escapes every ASCII character that is not a word character, like Perl's quotemeta.
+func quoteMeta(text string) string {
+	var builder strings.Builder
+	builder.Grow(len(text) * 2)
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		if ch < utf8.RuneSelf && !isASCIIAlphanumeric(ch) && ch != '_' {
+			builder.WriteByte('\\')
+		}
+		builder.WriteByte(ch)
+	}
+	return builder.String()
+}
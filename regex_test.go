@@ -243,3 +243,29 @@ func TestRegex_SplitN(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, []string{"Hey", "How", "are you?"}, splits)
 }
+
+func TestRegex_ReplaceAll_SyntaxReplacers(t *testing.T) {
+	tests := []struct {
+		syntax      *Syntax
+		pattern     string
+		replacement string
+		expected    string
+	}{
+		{SyntaxRuby, `(\w+)@(\w+)`, `\2 at \1`, "example at user"},
+		{SyntaxOniguruma, `(\w+)@(\w+)`, `\2 at \1`, "example at user"},
+		{SyntaxPython, `(\w+)@(\w+)`, `\2 at \1`, "example at user"},
+		{SyntaxJava, `(\w+)@(\w+)`, `$2 at $1`, "example at user"},
+		{SyntaxPerl, `(\w+)@(\w+)`, `$2 at $1`, "example at user"},
+		{SyntaxPerlNG, `(\w+)@(\w+)`, `$2 at $1`, "example at user"},
+		{SyntaxPosixExtended, `([a-z]+)@([a-z]+)`, `\2 at \1`, "example at user"},
+		{SyntaxPosixBasic, `\([a-z]*\)@\([a-z]*\)`, `\2 at \1`, "example at user"},
+		{SyntaxGnuRegex, `(\w+)@(\w+)`, `\2 at \1`, "example at user"},
+		{SyntaxGrep, `\(\w*\)@\(\w*\)`, `\2 at \1`, "example at user"},
+		{SyntaxEmacs, `\([a-z]+\)@\([a-z]+\)`, `\2 at \1`, "example at user"},
+		{SyntaxAsis, `user@example`, `$1`, "$1"},
+	}
+	for _, test := range tests {
+		r := MustCompileWithSyntax(test.pattern, test.syntax)
+		assert.Equal(t, test.expected, r.MustReplaceAll("user@example", test.replacement), test.pattern)
+	}
+}
@@ -12,6 +12,8 @@ import (
 type Region struct {
 	raw   *C.region
 	regex *Regex
+	// positions holds the start and end of each group when the region was found by Go's regexp, and raw is nil.
+	positions []int
 }
 
 // newRegion creates a new empty Region.
@@ -34,24 +36,36 @@ func newRawRegion(regex *Regex, raw *C.region) *Region {
 	return region
 }
 
+// newGoRegion creates a Region from the positions of the groups of a match found by Go's regexp.
+func newGoRegion(regex *Regex, positions []int) *Region {
+	return &Region{
+		regex:     regex,
+		positions: positions,
+	}
+}
+
 // Len returns the number of registers in the region.
 func (r *Region) Len() int {
-	return int(r.raw.groupCount)
+	if r.raw == nil {
+		return len(r.positions) / 2
+	}
+	count := int(r.raw.groupCount)
+	runtime.KeepAlive(r)
+	return count
 }
 
 // Pos returns the start and end positions of the Nth capture group.
 // Returns nil if the capture group did not match anything or if index is not a valid capture group.
 // The positions returned are always byte indices with respect to the original string matched.
 func (r *Region) Pos(index int) *Range {
-	if index >= r.Len() {
+	if index < 0 || index >= r.Len() {
 		return nil
 	}
-	begin := offsetInt(r.raw.groupStartIndices, index)
-	end := offsetInt(r.raw.groupEndIndices, index)
+	begin, end := r.groupPos(index)
 	if begin == -1 || end == -1 {
 		return nil
 	}
-	return NewRange(int(begin), int(end))
+	return NewRange(begin, end)
 }
 
 // PosByGroupName returns the start and end positions of the named capture group.
@@ -60,12 +74,25 @@ func (r *Region) Pos(index int) *Range {
 func (r *Region) PosByGroupName(groupName string) *Range {
 	groupIndices := r.regex.GetGroupNumbersForGroupName(groupName)
 	for _, groupIndex := range groupIndices {
-		begin := offsetInt(r.raw.groupStartIndices, groupIndex)
-		end := offsetInt(r.raw.groupEndIndices, groupIndex)
+		if groupIndex < 0 || groupIndex >= r.Len() {
+			continue
+		}
+		begin, end := r.groupPos(groupIndex)
 		if begin == -1 || end == -1 {
 			continue
 		}
-		return NewRange(int(begin), int(end))
+		return NewRange(begin, end)
 	}
 	return nil
 }
+
+// groupPos returns the start and end positions of the Nth capture group, which are -1 if it didn't match.
+func (r *Region) groupPos(index int) (int, int) {
+	if r.raw == nil {
+		return r.positions[2*index], r.positions[2*index+1]
+	}
+	begin := offsetInt(r.raw.groupStartIndices, index)
+	end := offsetInt(r.raw.groupEndIndices, index)
+	runtime.KeepAlive(r)
+	return int(begin), int(end)
+}
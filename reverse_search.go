@@ -0,0 +1,102 @@
+package onig
+
+/*
+#include "regex.h"
+*/
+import "C"
+import (
+	"iter"
+	"runtime"
+)
+
+// FindLastMatch returns the match of the regex that starts last in text, searching backward from its end.
+// If no match is found, then a nil Range is returned.
+//
+// The match is the one a forward search would find at that start position, so it can overlap
+// and differ from the last match returned by FindMatches: `aa` in "aaa" matches at [1, 3] rather than [0, 2].
+// Regexes compiled with REGEX_OPTION_FIND_LONGEST don't work properly with backward search.
+func (r *Regex) FindLastMatch(text string) (*Range, error) {
+	captures, err := r.backwardCaptures(text, len(text), false, 1)
+	if err != nil || len(captures) == 0 {
+		return nil, err
+	}
+	return captures[0].Pos(0), nil
+}
+
+// FindLastBefore returns the match of the regex that starts last before the byte index pos in text,
+// searching backward from pos. The match can extend past pos.
+// If no match is found, then a nil Range is returned.
+// This is the "find previous" operation of editors: calling it with the start of the returned match
+// gives the occurrence before it.
+// Regexes compiled with REGEX_OPTION_FIND_LONGEST don't work properly with backward search.
+func (r *Regex) FindLastBefore(text string, pos int) (*Range, error) {
+	pos = min(max(pos, 0), len(text)+1)
+	captures, err := r.backwardCaptures(text, pos, true, 1)
+	if err != nil || len(captures) == 0 {
+		return nil, err
+	}
+	return captures[0].Pos(0), nil
+}
+
+// MatchesReverse returns an iterator over the captures of the non-overlapping matches of the regex in text,
+// from right to left.
+//
+// The first match is the one returned by FindLastMatch. Each following match is the last one that
+// starts before the previous match and doesn't overlap it, so when matches can overlap the result
+// can differ from FindMatches in reverse order. The iteration stops if searching fails, which looks like
+// the end of the matches; use MatchesReverseSeq to get the error, as a search can exceed Oniguruma's retry
+// or stack limits.
+// Regexes compiled with REGEX_OPTION_FIND_LONGEST don't work properly with backward search.
+func (r *Regex) MatchesReverse(text string) iter.Seq[*Captures] {
+	return func(yield func(*Captures) bool) {
+		for captures, err := range r.MatchesReverseSeq(text) {
+			if err != nil || !yield(captures) {
+				return
+			}
+		}
+	}
+}
+
+// MatchesReverseSeq is like MatchesReverse, but if searching fails, the error is yielded and the iteration stops,
+// so a failed search can be told apart from the end of the matches.
+func (r *Regex) MatchesReverseSeq(text string) iter.Seq2[*Captures, error] {
+	return func(yield func(*Captures, error) bool) {
+		captures, err := r.backwardCaptures(text, len(text), false, 0)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for i := range captures {
+			if !yield(&captures[i], nil) {
+				return
+			}
+		}
+	}
+}
+
+// backwardCaptures returns the captures of at most limit non-overlapping matches in text, from right to left,
+// that start at or before from, or before it if exclusive is true. If limit is 0, then all matches are returned.
+func (r *Regex) backwardCaptures(text string, from int, exclusive bool, limit int) ([]Captures, error) {
+	cExclusive := C.int(0)
+	if exclusive {
+		cExclusive = 1
+	}
+	if from > len(text) {
+		from = len(text)
+		cExclusive = 0
+	}
+	result := C.searchAllBackwardWithParam(
+		r.raw,
+		C.CString(text),
+		C.uint(len(text)),
+		C.uint(from),
+		cExclusive,
+		C.uint(limit),
+		C.OnigOptionType(REGEX_OPTION_NONE),
+	)
+	runtime.KeepAlive(r)
+	if result.result < 0 {
+		return nil, errorFromCode(result.result)
+	}
+	return r.capturesFromRegionsArray(text, result.array), nil
+}
@@ -0,0 +1,126 @@
+package onig
+
+import (
+	"github.com/stretchr/testify/assert"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRegex_FindLastMatch(t *testing.T) {
+	r := MustCompile(`\d+`)
+	match, err := r.FindLastMatch("a12b345c")
+	assert.NoError(t, err)
+	assert.Equal(t, NewRange(6, 7), match)
+
+	match, err = MustCompile(`aa`).FindLastMatch("aaa")
+	assert.NoError(t, err)
+	assert.Equal(t, NewRange(1, 3), match)
+
+	match, err = r.FindLastMatch("abc")
+	assert.NoError(t, err)
+	assert.Nil(t, match)
+
+	match, err = MustCompile(`$`).FindLastMatch("abc")
+	assert.NoError(t, err)
+	assert.Equal(t, NewRange(3, 3), match)
+}
+
+func TestRegex_FindLastBefore(t *testing.T) {
+	r := MustCompile(`foo`)
+	text := "foo bar foo baz foo"
+	match, err := r.FindLastBefore(text, 16)
+	assert.NoError(t, err)
+	assert.Equal(t, NewRange(8, 11), match)
+
+	match, err = r.FindLastBefore(text, match.From)
+	assert.NoError(t, err)
+	assert.Equal(t, NewRange(0, 3), match)
+
+	match, err = r.FindLastBefore(text, match.From)
+	assert.NoError(t, err)
+	assert.Nil(t, match)
+
+	match, err = r.FindLastBefore(text, 9)
+	assert.NoError(t, err)
+	assert.Equal(t, NewRange(8, 11), match)
+}
+
+func TestRegex_FindLastBefore_Multibyte(t *testing.T) {
+	r := MustCompile(`.`)
+	text := "aé€"
+	match, err := r.FindLastBefore(text, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, NewRange(1, 3), match)
+	match, err = r.FindLastBefore(text, len(text))
+	assert.NoError(t, err)
+	assert.Equal(t, NewRange(3, 6), match)
+}
+
+func TestRegex_MatchesReverse(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+	}{
+		{`\d`, "a12b345c6"},
+		{`foo|bar`, "foo bar foobar"},
+		{`\bw\w*`, "one wide word what"},
+		{`é`, "aéébéc"},
+		{`\b\w+`, "one two  three"},
+	}
+	for _, test := range tests {
+		r := MustCompile(test.pattern)
+		expected := r.MustFindMatches(test.text)
+		slices.Reverse(expected)
+		matches := make([]*Range, 0)
+		for captures := range r.MatchesReverse(test.text) {
+			matches = append(matches, captures.Pos(0))
+		}
+		assert.Equal(t, expected, matches, test.pattern)
+	}
+}
+
+func TestRegex_MatchesReverse_Suffixes(t *testing.T) {
+	r := MustCompile(`\d+`)
+	matches := make([]*Range, 0)
+	for captures := range r.MatchesReverse("a12b3") {
+		matches = append(matches, captures.Pos(0))
+	}
+	assert.Equal(t, []*Range{NewRange(4, 5), NewRange(2, 3)}, matches)
+}
+
+func TestRegex_MatchesReverse_Captures(t *testing.T) {
+	r := MustCompile(`(\w)=(\d)`)
+	values := make([]string, 0)
+	for captures := range r.MatchesReverse("a=1 b=2 c=3") {
+		values = append(values, captures.At(1)+captures.At(2))
+		if len(values) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"c3", "b2"}, values)
+}
+
+func TestRegex_MatchesReverseSeq(t *testing.T) {
+	r := MustCompile(`\d`)
+	matches := make([]*Range, 0)
+	for captures, err := range r.MatchesReverseSeq("a12b3") {
+		assert.NoError(t, err)
+		matches = append(matches, captures.Pos(0))
+	}
+	assert.Equal(t, []*Range{NewRange(4, 5), NewRange(2, 3), NewRange(1, 2)}, matches)
+}
+
+func TestRegex_MatchesReverseSeq_Error(t *testing.T) {
+	r := MustCompile(`(a|a)*c`)
+	text := strings.Repeat("a", 40) + "!"
+	var errs []error
+	for captures, err := range r.MatchesReverseSeq(text) {
+		assert.Nil(t, captures)
+		errs = append(errs, err)
+	}
+	if assert.Len(t, errs, 1) {
+		assert.Error(t, errs[0])
+	}
+	assert.Empty(t, slices.Collect(r.MatchesReverse(text)))
+}
@@ -0,0 +1,122 @@
+package onig
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Rule is a regex identified by an ID, matched by a RuleMatcher.
+type Rule struct {
+	ID    string
+	Regex *Regex
+}
+
+// RuleMatch is a rule that matched a text, with the capture groups of its first match.
+type RuleMatch struct {
+	ID       string
+	Captures *Captures
+}
+
+// RuleMatcher matches a text against many rules at once.
+//
+// The longest literal that every match of a rule contains is extracted from its pattern, and the literals of all
+// the rules are searched with a single pass of an Aho-Corasick automaton over the text. Only the rules whose literal
+// occurs in the text are then searched by Oniguruma, along with the rules without an extractable literal, which
+// always run. Literals are extracted like the required literals of Regex searches: from the literal characters
+// outside of groups, in case-sensitive patterns without a top-level alternation.
+//
+// A RuleMatcher is safe for concurrent use.
+type RuleMatcher struct {
+	rules     []Rule
+	automaton *ahoCorasick
+	// literalRules holds, for each literal of the automaton, the indices of the rules that require it.
+	literalRules [][]int
+	// alwaysRun holds the indices of the rules without a literal.
+	alwaysRun []int
+}
+
+// NewRuleMatcher creates a RuleMatcher for rules, which must have distinct IDs and non-nil regexes.
+func NewRuleMatcher(rules []Rule) (*RuleMatcher, error) {
+	matcher := &RuleMatcher{rules: make([]Rule, len(rules))}
+	copy(matcher.rules, rules)
+	ids := make(map[string]struct{}, len(rules))
+	literalIndices := map[string]int{}
+	var literals []string
+	for i, rule := range rules {
+		if rule.Regex == nil {
+			return nil, fmt.Errorf("rule %q has no regex", rule.ID)
+		}
+		if _, ok := ids[rule.ID]; ok {
+			return nil, fmt.Errorf("duplicate rule ID %q", rule.ID)
+		}
+		ids[rule.ID] = struct{}{}
+		literal := longestLiteral(rule.Regex.requiredLiterals)
+		if literal == "" {
+			matcher.alwaysRun = append(matcher.alwaysRun, i)
+			continue
+		}
+		index, ok := literalIndices[literal]
+		if !ok {
+			index = len(literals)
+			literalIndices[literal] = index
+			literals = append(literals, literal)
+			matcher.literalRules = append(matcher.literalRules, nil)
+		}
+		matcher.literalRules[index] = append(matcher.literalRules[index], i)
+	}
+	matcher.automaton = newAhoCorasick(literals)
+	return matcher, nil
+}
+
+// Len returns the number of rules of the matcher.
+func (m *RuleMatcher) Len() int {
+	return len(m.rules)
+}
+
+// Match returns the rules that match text, in the order they were given to NewRuleMatcher,
+// with the capture groups of the first match of each rule.
+// An error from searching a rule stops the matching, and is returned with the ID of the rule.
+func (m *RuleMatcher) Match(text string) ([]RuleMatch, error) {
+	buffer := candidateBuffers.Get().(*[]int)
+	defer candidateBuffers.Put(buffer)
+	*buffer = m.candidates(text, (*buffer)[:0])
+	var matches []RuleMatch
+	for _, i := range *buffer {
+		rule := &m.rules[i]
+		captures, err := rule.Regex.Captures(text)
+		if err != nil {
+			return nil, fmt.Errorf("error matching rule %q: %w", rule.ID, err)
+		}
+		if captures != nil {
+			matches = append(matches, RuleMatch{ID: rule.ID, Captures: captures})
+		}
+	}
+	return matches, nil
+}
+
+// candidateBuffers holds the buffers that Match collects the indices of the candidate rules in.
+var candidateBuffers = sync.Pool{New: func() any { return new([]int) }}
+
+// candidates appends to buffer the indices, in increasing order, of the rules that must be searched in text:
+// the rules whose literal occurs in it and the rules without a literal. Its cost depends on the number of
+// candidates rather than the number of rules.
+func (m *RuleMatcher) candidates(text string, buffer []int) []int {
+	buffer = append(buffer, m.alwaysRun...)
+	m.automaton.find(text, func(literal int) {
+		buffer = append(buffer, m.literalRules[literal]...)
+	})
+	slices.Sort(buffer)
+	return slices.Compact(buffer)
+}
+
+// longestLiteral returns the longest of literals, which is the most selective, or an empty string if there are none.
+func longestLiteral(literals []string) string {
+	longest := ""
+	for _, literal := range literals {
+		if len(literal) > len(longest) {
+			longest = literal
+		}
+	}
+	return longest
+}
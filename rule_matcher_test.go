@@ -0,0 +1,171 @@
+package onig
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAhoCorasick(t *testing.T) {
+	literals := []string{"he", "she", "his", "hers", "", "s", "hers"}
+	automaton := newAhoCorasick(literals)
+	for _, test := range []struct {
+		text     string
+		expected []int
+	}{
+		{"", nil},
+		{"xyz", nil},
+		{"ushers", []int{0, 1, 3, 5, 6}},
+		{"this", []int{2, 5}},
+		{"hhe", []int{0}},
+		{"ahishers", []int{0, 1, 2, 3, 5, 6}},
+	} {
+		var found []int
+		automaton.find(test.text, func(literal int) {
+			found = append(found, literal)
+		})
+		slices.Sort(found)
+		assert.Equal(t, test.expected, found, test.text)
+	}
+}
+
+func TestAhoCorasick_Random(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomString := func(length int) string {
+		var builder strings.Builder
+		for range length {
+			builder.WriteByte("abc"[random.Intn(3)])
+		}
+		return builder.String()
+	}
+	for range 50 {
+		literals := make([]string, 1+random.Intn(20))
+		for i := range literals {
+			literals[i] = randomString(1 + random.Intn(4))
+		}
+		automaton := newAhoCorasick(literals)
+		for range 20 {
+			text := randomString(random.Intn(30))
+			var expected, found []int
+			for i, literal := range literals {
+				if strings.Contains(text, literal) {
+					expected = append(expected, i)
+				}
+			}
+			automaton.find(text, func(literal int) {
+				found = append(found, literal)
+			})
+			slices.Sort(found)
+			assert.Equal(t, expected, found, "%q in %q", literals, text)
+		}
+	}
+}
+
+func TestRuleMatcher(t *testing.T) {
+	matcher, err := NewRuleMatcher([]Rule{
+		{ID: "timeout", Regex: MustCompile(`ERROR: (\w+) timeout`)},
+		{ID: "login", Regex: MustCompile(`user=(?<user>\w+) action=login`)},
+		{ID: "any-digit", Regex: MustCompile(`\d+`)},
+		{ID: "case", Regex: MustCompileWithOptions(`error`, REGEX_OPTION_IGNORECASE)},
+		{ID: "alternation", Regex: MustCompile(`disk|memory`)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, matcher.Len())
+
+	matches, err := matcher.Match("ERROR: disk timeout")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"timeout", "case", "alternation"}, ruleMatchIDs(matches))
+	assert.Equal(t, "disk", matches[0].Captures.At(1))
+	assert.Equal(t, "ERROR", matches[1].Captures.At(0))
+
+	matches, err = matcher.Match("user=alice action=login at 12:00")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"login", "any-digit"}, ruleMatchIDs(matches))
+	assert.Equal(t, "alice", matches[0].Captures.AtGroupName("user"))
+
+	matches, err = matcher.Match("nothing to see")
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+
+	// Only the rules whose literal occurs in the text, and the rules without a literal, are searched.
+	assert.Equal(t, []int{2, 3, 4}, matcher.candidates("user=alice", nil))
+	assert.Equal(t, []int{0, 2, 3, 4}, matcher.candidates("xx timeout", nil))
+	buffer := []int{7, 8, 9, 10, 11}
+	assert.Equal(t, []int{0, 2, 3, 4}, matcher.candidates("xx timeout", buffer[:0]))
+}
+
+func TestRuleMatcher_Errors(t *testing.T) {
+	_, err := NewRuleMatcher([]Rule{{ID: "a", Regex: MustCompile("a")}, {ID: "a", Regex: MustCompile("b")}})
+	assert.ErrorContains(t, err, `duplicate rule ID "a"`)
+	_, err = NewRuleMatcher([]Rule{{ID: "a"}})
+	assert.ErrorContains(t, err, `rule "a" has no regex`)
+
+	matcher, err := NewRuleMatcher(nil)
+	assert.NoError(t, err)
+	matches, err := matcher.Match("text")
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestRuleMatcher_Differential(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	words := []string{"alpha", "beta", "gamma", "delta", "ab", "ba", "a"}
+	var rules []Rule
+	for i := range 300 {
+		var pattern string
+		switch random.Intn(5) {
+		case 0:
+			pattern = words[random.Intn(len(words))] + `\d+` + words[random.Intn(len(words))]
+		case 1:
+			pattern = `(\w+)=` + words[random.Intn(len(words))]
+		case 2:
+			pattern = words[random.Intn(len(words))] + "|" + words[random.Intn(len(words))]
+		case 3:
+			// A quantifier following + makes the last character of the word optional.
+			quantifier := []string{"+*", "+?", "+??", "+{0,2}"}[random.Intn(4)]
+			pattern = words[random.Intn(len(words))] + quantifier + words[random.Intn(len(words))]
+		default:
+			pattern = words[random.Intn(len(words))] + " ?" + words[random.Intn(len(words))]
+		}
+		rules = append(rules, Rule{ID: fmt.Sprintf("rule-%d", i), Regex: MustCompile(pattern)})
+	}
+	rules = append(rules, Rule{ID: "chained", Regex: MustCompile(`ab+*c`)})
+	matcher, err := NewRuleMatcher(rules)
+	assert.NoError(t, err)
+	texts := []string{"ac", "abbc"}
+	for range 100 {
+		var builder strings.Builder
+		for range random.Intn(6) {
+			word := words[random.Intn(len(words))]
+			if random.Intn(3) == 0 {
+				word = word[:len(word)-1]
+			}
+			builder.WriteString(word)
+			builder.WriteString([]string{"", " ", "=", "1", "42"}[random.Intn(5)])
+		}
+		texts = append(texts, builder.String())
+	}
+	for _, text := range texts {
+		var expected []string
+		for _, rule := range rules {
+			if withoutPrefilter(rule.Regex).MustFindMatch(text) != nil {
+				expected = append(expected, rule.ID)
+			}
+		}
+		matches, err := matcher.Match(text)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, ruleMatchIDs(matches), text)
+	}
+}
+
+// ruleMatchIDs returns the IDs of matches.
+func ruleMatchIDs(matches []RuleMatch) []string {
+	var ids []string
+	for _, match := range matches {
+		ids = append(ids, match.ID)
+	}
+	return ids
+}
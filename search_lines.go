@@ -0,0 +1,297 @@
+package onig
+
+/*
+#include "regex.h"
+*/
+import "C"
+import (
+	"bytes"
+	"errors"
+	"io"
+	"iter"
+	"runtime"
+	"unsafe"
+)
+
+// ErrBinaryData is reported by SearchLines when BinaryQuit is set and a NUL byte is found in the input.
+var ErrBinaryData = errors.New("binary data found in input")
+
+// BinaryMode selects how SearchLines treats input that contains NUL bytes.
+type BinaryMode int
+
+const (
+	// BinarySearch searches binary input like any other text.
+	BinarySearch BinaryMode = iota
+	// BinaryQuit stops the search at the first line that contains a NUL byte and reports ErrBinaryData.
+	BinaryQuit
+)
+
+// DefaultSearchLinesBlockSize is the number of bytes SearchLines reads and searches at once by default.
+const DefaultSearchLinesBlockSize = 256 * 1024
+
+// SearchLinesOptions configure SearchLines.
+type SearchLinesOptions struct {
+	// BeforeContext is the number of lines to report before each matching line.
+	BeforeContext int
+	// AfterContext is the number of lines to report after each matching line.
+	AfterContext int
+	// Invert selects the lines that don't match the regex instead of those that do.
+	Invert bool
+	// MaxCount stops the search after this many selected lines. Zero means no limit.
+	MaxCount int
+	// Binary selects how input containing NUL bytes is treated.
+	Binary BinaryMode
+	// BlockSize is the number of bytes read and searched at once.
+	// Lines longer than BlockSize are still searched as a whole.
+	// Zero means DefaultSearchLinesBlockSize.
+	BlockSize int
+}
+
+// ContextLine is a line reported around a LineMatch.
+type ContextLine struct {
+	// LineNumber is the 1-based number of the line.
+	LineNumber int
+	// Offset is the offset of the start of the line from the beginning of the input.
+	Offset int64
+	// Line is the text of the line, without the line terminator.
+	Line string
+}
+
+// LineMatch is a line selected by SearchLines.
+type LineMatch struct {
+	ContextLine
+	// Matches holds the positions of the non-overlapping matches in Line.
+	// It is empty when SearchLinesOptions.Invert is set.
+	Matches []Range
+	// Before holds up to SearchLinesOptions.BeforeContext lines preceding the line,
+	// leaving out the lines already reported with a previous LineMatch.
+	Before []ContextLine
+	// After holds up to SearchLinesOptions.AfterContext lines following the line,
+	// stopping before the next selected line.
+	After []ContextLine
+	// Err is set on the last LineMatch yielded when reading or searching the input fails.
+	// The other fields are empty in that case.
+	Err error
+}
+
+// SearchLines returns an iterator over the lines of the input read from reader that match the regex.
+//
+// Lines are separated by "\n", and a "\r" preceding it is not part of the line.
+// Each line is searched as a string of its own, so `^`, `$`, `\A` and `\z` match at its start and end,
+// but the input is passed to Oniguruma in blocks of many lines to avoid the cost of a call per line.
+// If reading or searching fails, a LineMatch holding the error is yielded and the iteration stops.
+func (r *Regex) SearchLines(reader io.Reader, options SearchLinesOptions) iter.Seq[LineMatch] {
+	return func(yield func(LineMatch) bool) {
+		search := lineSearch{
+			regex:   r,
+			options: options,
+			yield:   yield,
+		}
+		if search.options.BlockSize <= 0 {
+			search.options.BlockSize = DefaultSearchLinesBlockSize
+		}
+		search.run(reader)
+	}
+}
+
+// lineSearch holds the state of a single SearchLines iteration.
+type lineSearch struct {
+	regex   *Regex
+	options SearchLinesOptions
+	yield   func(LineMatch) bool
+
+	lineNumber int
+	offset     int64
+	selected   int
+	before     []ContextLine
+	pending    *LineMatch
+	stopped    bool
+}
+
+func (s *lineSearch) run(reader io.Reader) {
+	buf := make([]byte, 0, s.options.BlockSize)
+	for !s.stopped {
+		if len(buf) == cap(buf) {
+			buf = append(buf, 0)[:len(buf)]
+		}
+		n, err := reader.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		final := errors.Is(err, io.EOF)
+		if err != nil && !final {
+			s.flush()
+			s.fail(err)
+			return
+		}
+		block := buf
+		if !final {
+			end := bytes.LastIndexByte(buf, '\n')
+			if end < 0 || (len(buf) < cap(buf) && n > 0) {
+				continue
+			}
+			block = buf[:end+1]
+		}
+		if s.options.Binary == BinaryQuit {
+			if nul := bytes.IndexByte(block, 0); nul >= 0 {
+				lineStart := bytes.LastIndexByte(block[:nul], '\n') + 1
+				if s.searchBlock(block[:lineStart]) {
+					s.flush()
+					s.fail(ErrBinaryData)
+				}
+				return
+			}
+		}
+		if !s.searchBlock(block) {
+			return
+		}
+		if final {
+			s.flush()
+			return
+		}
+		buf = buf[:copy(buf, buf[len(block):])]
+	}
+}
+
+// searchBlock searches the complete lines in block and reports them.
+// It returns false if the iteration is over.
+func (s *lineSearch) searchBlock(block []byte) bool {
+	if len(block) == 0 {
+		return true
+	}
+	matches, err := s.regex.searchLines(block, s.options.Invert)
+	if err != nil {
+		s.flush()
+		s.fail(err)
+		return false
+	}
+	lineStart := 0
+	for lineStart < len(block) && !s.stopped {
+		lineEnd := bytes.IndexByte(block[lineStart:], '\n')
+		next := len(block)
+		if lineEnd < 0 {
+			lineEnd = len(block)
+		} else {
+			lineEnd += lineStart
+			next = lineEnd + 1
+		}
+		if lineEnd > lineStart && block[lineEnd-1] == '\r' {
+			lineEnd--
+		}
+		s.lineNumber++
+		var ranges []Range
+		for len(matches) > 0 && int(matches[0].lineStart) == lineStart {
+			ranges = append(ranges, Range{From: int(matches[0].from), To: int(matches[0].to)})
+			matches = matches[1:]
+		}
+		s.line(block[lineStart:lineEnd], ranges)
+		s.offset += int64(next - lineStart)
+		lineStart = next
+	}
+	return !s.stopped
+}
+
+// line handles a single line and the positions of the matches found in it.
+func (s *lineSearch) line(text []byte, ranges []Range) {
+	isMatch := len(ranges) > 0
+	current := ContextLine{
+		LineNumber: s.lineNumber,
+		Offset:     s.offset,
+	}
+	if isMatch == s.options.Invert {
+		if s.pending != nil && len(s.pending.After) < s.options.AfterContext {
+			current.Line = string(text)
+			s.pending.After = append(s.pending.After, current)
+			if len(s.pending.After) == s.options.AfterContext {
+				s.flush()
+			}
+		} else if s.options.MaxCount > 0 && s.selected >= s.options.MaxCount {
+			s.stopped = true
+		} else if s.options.BeforeContext > 0 {
+			current.Line = string(text)
+			if len(s.before) == s.options.BeforeContext {
+				s.before = s.before[1:]
+			}
+			s.before = append(s.before, current)
+		}
+		return
+	}
+	s.flush()
+	if s.stopped || (s.options.MaxCount > 0 && s.selected >= s.options.MaxCount) {
+		s.stopped = true
+		return
+	}
+	current.Line = string(text)
+	match := &LineMatch{
+		ContextLine: current,
+		Before:      s.before,
+	}
+	if !s.options.Invert {
+		match.Matches = ranges
+	}
+	s.before = nil
+	s.selected++
+	s.pending = match
+	if s.options.AfterContext == 0 {
+		s.flush()
+	}
+}
+
+// flush yields the pending LineMatch, if any.
+func (s *lineSearch) flush() {
+	if s.pending == nil {
+		return
+	}
+	match := *s.pending
+	s.pending = nil
+	if !s.yield(match) {
+		s.stopped = true
+	}
+}
+
+func (s *lineSearch) fail(err error) {
+	if !s.stopped {
+		s.yield(LineMatch{Err: err})
+		s.stopped = true
+	}
+}
+
+// lineMatchPosition is the position of a match in a line of a block searched by searchLines.
+type lineMatchPosition struct {
+	lineStart int
+	from      int
+	to        int
+}
+
+// searchLines searches every line of block as a separate string.
+// If firstOnly is true, only the first match of each line is returned.
+func (r *Regex) searchLines(block []byte, firstOnly bool) ([]lineMatchPosition, error) {
+	cFirstOnly := C.int(0)
+	if firstOnly {
+		cFirstOnly = 1
+	}
+	result := C.searchLinesWithParam(
+		r.raw,
+		(*C.char)(C.CBytes(block)),
+		C.uint(len(block)),
+		C.OnigOptionType(REGEX_OPTION_NONE),
+		cFirstOnly,
+	)
+	runtime.KeepAlive(r)
+	if result.result < 0 {
+		return nil, errorFromCode(result.result)
+	}
+	count := int(result.count)
+	if count == 0 {
+		return nil, nil
+	}
+	rawMatches := (*[1 << 30]C.lineMatch)(unsafe.Pointer(result.matches))[:count:count]
+	matches := make([]lineMatchPosition, count)
+	for i, rawMatch := range rawMatches {
+		matches[i] = lineMatchPosition{
+			lineStart: int(rawMatch.lineStart),
+			from:      int(rawMatch.from),
+			to:        int(rawMatch.to),
+		}
+	}
+	C.freeLineMatches(result.matches)
+	return matches, nil
+}
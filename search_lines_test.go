@@ -0,0 +1,137 @@
+package onig
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func collectLineMatches(t *testing.T, r *Regex, text string, options SearchLinesOptions) []LineMatch {
+	t.Helper()
+	matches := make([]LineMatch, 0)
+	for match := range r.SearchLines(iotest.OneByteReader(strings.NewReader(text)), options) {
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+func TestRegex_SearchLines(t *testing.T) {
+	r := MustCompile(`o+`)
+	matches := collectLineMatches(t, r, "foo\nbar\r\nboo zoo\r\n", SearchLinesOptions{})
+	assert.Equal(t, []LineMatch{
+		{ContextLine: ContextLine{LineNumber: 1, Offset: 0, Line: "foo"}, Matches: []Range{{1, 3}}},
+		{ContextLine: ContextLine{LineNumber: 3, Offset: 9, Line: "boo zoo"}, Matches: []Range{{1, 3}, {5, 7}}},
+	}, matches)
+}
+
+func TestRegex_SearchLines_MatchesFindMatches(t *testing.T) {
+	text := strings.Repeat("foo 123 bar\nbaz foo42\n\nfoofoo 7 end\r\n", 20) + "last 1"
+	lines := strings.Split(text, "\n")
+	patterns := []string{`\d+`, `\d*`, `^foo`, `bar$`, `\Afoo`, `end\z`, `$`, `^$`, `\r`}
+	for _, pattern := range patterns {
+		r := MustCompile(pattern)
+		expected := make([]LineMatch, 0)
+		offset := 0
+		for i, line := range lines {
+			trimmed := strings.TrimSuffix(line, "\r")
+			ranges := r.MustFindMatches(trimmed)
+			if len(ranges) > 0 {
+				match := LineMatch{ContextLine: ContextLine{LineNumber: i + 1, Offset: int64(offset), Line: trimmed}}
+				for _, pos := range ranges {
+					match.Matches = append(match.Matches, *pos)
+				}
+				expected = append(expected, match)
+			}
+			offset += len(line) + 1
+		}
+		for _, blockSize := range []int{1, 7, 100, 0} {
+			matches := collectLineMatches(t, r, text, SearchLinesOptions{BlockSize: blockSize})
+			assert.Equal(t, expected, matches, "%s with block size %d", pattern, blockSize)
+		}
+	}
+}
+
+func TestRegex_SearchLines_Context(t *testing.T) {
+	r := MustCompile(`x`)
+	text := "1\n2x\n3\n4\n5\n6x\n7x\n8\n9\n"
+	matches := collectLineMatches(t, r, text, SearchLinesOptions{BeforeContext: 2, AfterContext: 1})
+	assert.Equal(t, []LineMatch{
+		{
+			ContextLine: ContextLine{LineNumber: 2, Offset: 2, Line: "2x"},
+			Matches:     []Range{{1, 2}},
+			Before:      []ContextLine{{LineNumber: 1, Offset: 0, Line: "1"}},
+			After:       []ContextLine{{LineNumber: 3, Offset: 5, Line: "3"}},
+		},
+		{
+			ContextLine: ContextLine{LineNumber: 6, Offset: 11, Line: "6x"},
+			Matches:     []Range{{1, 2}},
+			Before:      []ContextLine{{LineNumber: 4, Offset: 7, Line: "4"}, {LineNumber: 5, Offset: 9, Line: "5"}},
+		},
+		{
+			ContextLine: ContextLine{LineNumber: 7, Offset: 14, Line: "7x"},
+			Matches:     []Range{{1, 2}},
+			After:       []ContextLine{{LineNumber: 8, Offset: 17, Line: "8"}},
+		},
+	}, matches)
+}
+
+func TestRegex_SearchLines_Invert(t *testing.T) {
+	r := MustCompile(`x`)
+	matches := collectLineMatches(t, r, "ax\nb\ncx\nd", SearchLinesOptions{Invert: true})
+	assert.Equal(t, []LineMatch{
+		{ContextLine: ContextLine{LineNumber: 2, Offset: 3, Line: "b"}},
+		{ContextLine: ContextLine{LineNumber: 4, Offset: 8, Line: "d"}},
+	}, matches)
+}
+
+func TestRegex_SearchLines_MaxCount(t *testing.T) {
+	r := MustCompile(`\d`)
+	matches := collectLineMatches(t, r, "1\na\n2\nb\n3\n", SearchLinesOptions{MaxCount: 2, AfterContext: 1})
+	assert.Len(t, matches, 2)
+	assert.Equal(t, "1", matches[0].Line)
+	assert.Equal(t, "2", matches[1].Line)
+	assert.Equal(t, []ContextLine{{LineNumber: 4, Offset: 6, Line: "b"}}, matches[1].After)
+}
+
+func TestRegex_SearchLines_Binary(t *testing.T) {
+	r := MustCompile(`a`)
+	text := "a1\na2\nb\x00a\na3\n"
+	matches := collectLineMatches(t, r, text, SearchLinesOptions{Binary: BinaryQuit})
+	assert.Len(t, matches, 3)
+	assert.Equal(t, "a1", matches[0].Line)
+	assert.Equal(t, "a2", matches[1].Line)
+	assert.ErrorIs(t, matches[2].Err, ErrBinaryData)
+
+	matches = collectLineMatches(t, r, text, SearchLinesOptions{})
+	assert.Len(t, matches, 4)
+	assert.Equal(t, "b\x00a", matches[2].Line)
+}
+
+func TestRegex_SearchLines_ReadError(t *testing.T) {
+	r := MustCompile(`a`)
+	readErr := errors.New("read failed")
+	reader := io.MultiReader(strings.NewReader("a\nb\n"), iotest.ErrReader(readErr))
+	var matches []LineMatch
+	for match := range r.SearchLines(reader, SearchLinesOptions{BlockSize: 2}) {
+		matches = append(matches, match)
+	}
+	assert.Len(t, matches, 2)
+	assert.Equal(t, "a", matches[0].Line)
+	assert.ErrorIs(t, matches[1].Err, readErr)
+}
+
+func TestRegex_SearchLines_Break(t *testing.T) {
+	r := MustCompile(`\d`)
+	count := 0
+	for match := range r.SearchLines(strings.NewReader(strings.Repeat("1\n", 100)), SearchLinesOptions{}) {
+		assert.NoError(t, match.Err)
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	assert.Equal(t, 3, count)
+}
@@ -0,0 +1,115 @@
+package onig
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrSearchTimeOption is returned when a regex is compiled with an option that only applies to searches.
+var ErrSearchTimeOption = errors.New("option only applies to searches")
+
+// ErrCompileTimeOption is returned when a search is given an option that only applies to compiling a regex.
+var ErrCompileTimeOption = errors.New("option only applies to compiling a regex")
+
+// SearchOptions configure a single search.
+//
+// A zero value searches the whole text without options.
+type SearchOptions struct {
+	// Range restricts the search to the matches that lie within it, in byte indices with respect to the text.
+	// The text outside of Range stays visible to anchors and lookbehind assertions, so `^` and `\b`
+	// at the start of Range depend on the text in front of it. A nil Range searches the whole text.
+	Range *Range
+	// Options are the search-time options, as listed in REGEX_OPTIONS_SEARCH_TIME.
+	Options RegexOptions
+	// MaxStackSize limits the size of the backtracking stack. Zero means the Oniguruma default.
+	MaxStackSize uint
+	// RetryLimitInMatch limits the number of backtracking retries. Zero means the Oniguruma default.
+	RetryLimitInMatch uint
+}
+
+// SearchOption is a functional option that modifies SearchOptions.
+type SearchOption func(options *SearchOptions)
+
+// WithRange restricts a search to the matches that lie between the byte indices from and to.
+func WithRange(from int, to int) SearchOption {
+	return func(options *SearchOptions) {
+		options.Range = NewRange(from, to)
+	}
+}
+
+// WithSearchTimeOptions adds search-time options, such as REGEX_OPTION_NOTBOL, to a search.
+// Options that only apply to compiling a regex make the search fail with ErrCompileTimeOption.
+func WithSearchTimeOptions(regexOptions RegexOptions) SearchOption {
+	return func(options *SearchOptions) {
+		options.Options |= regexOptions
+	}
+}
+
+// WithMaxStackSize limits the size of the backtracking stack of a search.
+func WithMaxStackSize(maxStackSize uint) SearchOption {
+	return func(options *SearchOptions) {
+		options.MaxStackSize = maxStackSize
+	}
+}
+
+// WithRetryLimitInMatch limits the number of backtracking retries of a search.
+func WithRetryLimitInMatch(retryLimitInMatch uint) SearchOption {
+	return func(options *SearchOptions) {
+		options.RetryLimitInMatch = retryLimitInMatch
+	}
+}
+
+// WithSearchOptions replaces the options of a search by the given SearchOptions.
+func WithSearchOptions(searchOptions SearchOptions) SearchOption {
+	return func(options *SearchOptions) {
+		*options = searchOptions
+	}
+}
+
+// searchParams are the parameters of a search, resolved for a given text.
+type searchParams struct {
+	from              uint
+	to                uint
+	options           RegexOptions
+	maxStackSize      uint
+	retryLimitInMatch uint
+}
+
+// wholeTextParams returns the parameters of a search over the whole text without options.
+func wholeTextParams(text string) searchParams {
+	return searchParams{to: uint(len(text))}
+}
+
+// newSearchParams applies options and resolves them for text.
+func newSearchParams(text string, options []SearchOption) (searchParams, error) {
+	var searchOptions SearchOptions
+	for _, option := range options {
+		option(&searchOptions)
+	}
+	return searchOptions.params(text)
+}
+
+func (o SearchOptions) params(text string) (searchParams, error) {
+	if err := o.Options.validateSearchTime(); err != nil {
+		return searchParams{}, err
+	}
+	params := searchParams{
+		to:                uint(len(text)),
+		options:           o.Options,
+		maxStackSize:      o.MaxStackSize,
+		retryLimitInMatch: o.RetryLimitInMatch,
+	}
+	if o.Range != nil {
+		if o.Range.From < 0 || o.Range.From > o.Range.To || o.Range.To > len(text) {
+			return searchParams{}, fmt.Errorf(
+				"invalid search range [%d, %d] for a text of length %d",
+				o.Range.From,
+				o.Range.To,
+				len(text),
+			)
+		}
+		params.from = uint(o.Range.From)
+		params.to = uint(o.Range.To)
+	}
+	return params, nil
+}
@@ -0,0 +1,102 @@
+package onig
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegex_FindMatchesWithSearchOptions_Range(t *testing.T) {
+	r := MustCompile(`\bfoo`)
+	matches, err := r.FindMatchesWithSearchOptions("xfoo foo foo", WithRange(1, 8))
+	assert.NoError(t, err)
+	assert.Equal(t, []*Range{NewRange(5, 8)}, matches)
+
+	r = MustCompile(`(?<=a)b`)
+	match, err := r.FindMatchWithSearchOptions("abab", WithRange(1, 2))
+	assert.NoError(t, err)
+	assert.Equal(t, NewRange(1, 2), match)
+
+	r = MustCompile(`\d+`)
+	match, err = r.FindMatchWithSearchOptions("12345", WithRange(1, 3))
+	assert.NoError(t, err)
+	assert.Equal(t, NewRange(1, 3), match)
+}
+
+func TestRegex_FindMatchWithSearchOptions_Options(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		text     string
+		options  RegexOptions
+		expected *Range
+	}{
+		{`^a`, "abc", REGEX_OPTION_NONE, NewRange(0, 1)},
+		{`^a`, "abc", REGEX_OPTION_NOTBOL, nil},
+		{`c$`, "abc", REGEX_OPTION_NOTEOL, nil},
+		{`\Aa`, "abc", REGEX_OPTION_NOT_BEGIN_STRING, nil},
+		{`c\z`, "abc", REGEX_OPTION_NOT_END_STRING, nil},
+		{`x*`, "abx", REGEX_OPTION_FIND_NOT_EMPTY, NewRange(2, 3)},
+		{`a|bcd`, "a bcd", REGEX_OPTION_FIND_LONGEST, NewRange(2, 5)},
+	}
+	for _, test := range tests {
+		r := MustCompile(test.pattern)
+		match, err := r.FindMatchWithSearchOptions(test.text, WithSearchTimeOptions(test.options))
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, match, test.pattern)
+	}
+}
+
+func TestRegex_WithSearchOptions_Errors(t *testing.T) {
+	r := MustCompile(`a`)
+	_, err := r.FindMatchWithSearchOptions("abc", WithSearchTimeOptions(REGEX_OPTION_IGNORECASE))
+	assert.ErrorIs(t, err, ErrCompileTimeOption)
+	_, err = r.FindMatchesWithSearchOptions("abc", WithRange(2, 1))
+	assert.Error(t, err)
+	_, err = r.AllCapturesWithSearchOptions("abc", WithRange(0, 4))
+	assert.Error(t, err)
+	_, err = r.SplitWithSearchOptions("abc", WithRange(-1, 2))
+	assert.Error(t, err)
+
+	_, err = CompileWithOptions(`a`, REGEX_OPTION_NOTBOL)
+	assert.ErrorIs(t, err, ErrSearchTimeOption)
+	_, err = CompileWithOptions(`a`, REGEX_OPTION_IGNORECASE|REGEX_OPTION_FIND_LONGEST)
+	assert.NoError(t, err)
+}
+
+func TestRegex_CapturesWithSearchOptions(t *testing.T) {
+	r := MustCompile(`(\w)=(\d)`)
+	captures, err := r.CapturesWithSearchOptions("a=1 b=2", WithRange(1, 7))
+	assert.NoError(t, err)
+	assert.Equal(t, "b", captures.At(1))
+
+	all, err := r.AllCapturesWithSearchOptions("a=1 b=2 c=3", WithSearchOptions(SearchOptions{Range: NewRange(0, 7)}))
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	r = MustCompile(`(a|aa)+$`)
+	_, err = r.CapturesWithSearchOptions("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaab", WithRetryLimitInMatch(10))
+	assert.Error(t, err)
+}
+
+func TestRegex_ReplaceWithSearchOptions(t *testing.T) {
+	r := MustCompile(`a`)
+	replaced, err := r.ReplaceAllWithSearchOptions("a a a", "b", WithRange(2, 5))
+	assert.NoError(t, err)
+	assert.Equal(t, "a b b", replaced)
+
+	replaced, err = r.ReplaceWithSearchOptions("a a a", "b", WithRange(1, 5))
+	assert.NoError(t, err)
+	assert.Equal(t, "a b a", replaced)
+
+	replaced, err = r.ReplaceNFuncWithSearchOptions("a a a", func(*Captures) (string, error) {
+		return "c", nil
+	}, 0, WithSearchTimeOptions(REGEX_OPTION_NOTBOL), WithRange(0, 3))
+	assert.NoError(t, err)
+	assert.Equal(t, "c c a", replaced)
+}
+
+func TestRegex_SplitWithSearchOptions(t *testing.T) {
+	r := MustCompile(`,`)
+	splits, err := r.SplitNWithSearchOptions("a,b,c,d", -1, WithRange(2, 7))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a,b", "c", "d"}, splits)
+}
@@ -0,0 +1,214 @@
+package onig
+
+import "iter"
+
+// SplitLimitStyle selects how SplitOptions.Limit is interpreted, and the conventions that come with it.
+type SplitLimitStyle int
+
+const (
+	// SplitLimitDefault splits into at most Limit fields if Limit is positive, and into all fields otherwise.
+	// Like SplitLimitGo, empty matches at the start and at the end of the text don't produce empty fields.
+	SplitLimitDefault SplitLimitStyle = iota
+	// SplitLimitGo follows regexp.Regexp.Split: a positive Limit gives at most Limit fields,
+	// a Limit of 0 gives no fields and a negative Limit gives all fields.
+	// Empty matches at the start and at the end of the text don't produce empty fields.
+	SplitLimitGo
+	// SplitLimitPython follows re.split: a positive Limit is the maximum number of splits,
+	// a Limit of 0 gives all fields and a negative Limit doesn't split at all.
+	// Empty matches at the start and at the end of the text produce empty fields, and unmatched
+	// captured groups are kept as empty strings.
+	SplitLimitPython
+	// SplitLimitJava follows String.split: a positive Limit gives at most Limit fields,
+	// a Limit of 0 gives all fields with the empty trailing fields removed and a negative Limit gives all fields.
+	// An empty match at the start of the text doesn't produce an empty field.
+	SplitLimitJava
+	// SplitLimitRuby follows String#split, which shares the limit semantics of SplitLimitJava.
+	// Splitting an empty text gives no fields, and unmatched captured groups are left out.
+	SplitLimitRuby
+)
+
+// SplitOptions configure how a text is split into fields delimited by the matches of a regex.
+type SplitOptions struct {
+	// Limit restricts the number of fields, as described by LimitStyle.
+	// The last field holds the rest of the text.
+	Limit int
+	// LimitStyle selects how Limit is interpreted.
+	LimitStyle SplitLimitStyle
+	// KeepCaptures adds the text of the capture groups of each delimiter to the fields, after the field it ends.
+	// Captured groups don't count towards Limit.
+	KeepCaptures bool
+	// TrimTrailing removes the empty fields at the end of the result.
+	// The SplitLimitJava and SplitLimitRuby styles also do it when Limit is 0.
+	// A text that isn't split at all is returned as is.
+	TrimTrailing bool
+}
+
+// splitLimit is the effect of SplitOptions.Limit for its style.
+type splitLimit struct {
+	// maxFields is the maximum number of fields, or 0 if there is no limit.
+	maxFields int
+	// none is true if no fields are returned at all.
+	none bool
+	// trim is true if empty trailing fields are removed.
+	trim bool
+}
+
+func (o SplitOptions) limit() splitLimit {
+	switch o.LimitStyle {
+	case SplitLimitGo:
+		if o.Limit == 0 {
+			return splitLimit{none: true}
+		}
+	case SplitLimitPython:
+		if o.Limit < 0 {
+			return splitLimit{maxFields: 1}
+		}
+		if o.Limit > 0 {
+			return splitLimit{maxFields: o.Limit + 1}
+		}
+		return splitLimit{}
+	case SplitLimitJava, SplitLimitRuby:
+		if o.Limit == 0 {
+			return splitLimit{trim: true}
+		}
+	}
+	return splitLimit{maxFields: max(o.Limit, 0)}
+}
+
+// SplitWithOptions returns the fields of text delimited by the matches of the regex, as configured by options.
+// The delimiters are searched for as configured by searchOptions.
+func (r *Regex) SplitWithOptions(text string, options SplitOptions, searchOptions ...SearchOption) ([]string, error) {
+	splits := make([]string, 0)
+	err := r.splitEach(text, options, searchOptions, func(field *Range) bool {
+		splits = append(splits, fieldText(text, field))
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return splits, nil
+}
+
+// SplitWithRanges returns the positions of the fields of text delimited by the matches of the regex,
+// as configured by options. The position of a captured group that didn't participate in the match is nil.
+// The delimiters are searched for as configured by searchOptions.
+func (r *Regex) SplitWithRanges(text string, options SplitOptions, searchOptions ...SearchOption) ([]*Range, error) {
+	ranges := make([]*Range, 0)
+	err := r.splitEach(text, options, searchOptions, func(field *Range) bool {
+		ranges = append(ranges, field)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ranges, nil
+}
+
+// SplitSeq returns an iterator over the fields of text delimited by the matches of the regex, as configured by options.
+// The matches are found when the iteration starts, and the fields are produced as it proceeds.
+// The delimiters are searched for as configured by searchOptions.
+// If searching fails, the error is yielded and the iteration stops.
+func (r *Regex) SplitSeq(text string, options SplitOptions, searchOptions ...SearchOption) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		err := r.splitEach(text, options, searchOptions, func(field *Range) bool {
+			return yield(fieldText(text, field), nil)
+		})
+		if err != nil {
+			yield("", err)
+		}
+	}
+}
+
+// splitEach passes the position of each field of text to yield, in order, until yield returns false.
+// Captured groups that didn't participate in the match are passed as nil.
+func (r *Regex) splitEach(
+	text string,
+	options SplitOptions,
+	searchOptions []SearchOption,
+	yield func(field *Range) bool,
+) error {
+	params, err := newSearchParams(text, searchOptions)
+	if err != nil {
+		return err
+	}
+	limit := options.limit()
+	if limit.none || (text == "" && options.LimitStyle == SplitLimitRuby) {
+		return nil
+	}
+	captures, err := r.allCapturesWithParam(text, params)
+	if err != nil {
+		return err
+	}
+	trim := limit.trim || options.TrimTrailing
+	// Empty fields are held back while trimming, until a non-empty field shows they aren't trailing.
+	var held []*Range
+	stopped := false
+	emit := func(field *Range) {
+		if stopped {
+			return
+		}
+		if trim && (field == nil || field.From == field.To) {
+			held = append(held, field)
+			return
+		}
+		for _, heldField := range held {
+			if !yield(heldField) {
+				stopped = true
+				return
+			}
+		}
+		held = held[:0]
+		stopped = !yield(field)
+	}
+	keepEdgeMatches := options.LimitStyle == SplitLimitPython
+	keepEndMatch := keepEdgeMatches || options.LimitStyle == SplitLimitJava || options.LimitStyle == SplitLimitRuby
+	fields := 0
+	last := 0
+	for i := range captures {
+		if stopped || (limit.maxFields > 0 && fields >= limit.maxFields-1) {
+			break
+		}
+		pos := captures[i].Pos(0)
+		if pos == nil {
+			continue
+		}
+		if pos.From == pos.To {
+			if pos.From == 0 && !keepEdgeMatches {
+				continue
+			}
+			if pos.From == len(text) && !keepEndMatch {
+				continue
+			}
+		}
+		emit(NewRange(last, pos.From))
+		fields++
+		if options.KeepCaptures {
+			for group := 1; group < captures[i].Len(); group++ {
+				groupPos := captures[i].Pos(group)
+				if groupPos == nil && options.LimitStyle == SplitLimitRuby {
+					continue
+				}
+				emit(groupPos)
+			}
+		}
+		last = pos.To
+	}
+	emit(NewRange(last, len(text)))
+	if fields == 0 && !stopped {
+		// A text that isn't split is returned as is, even if it is empty.
+		for _, heldField := range held {
+			if !yield(heldField) {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// fieldText returns the text of a field, or an empty string for a captured group that didn't participate in the match.
+func fieldText(text string, field *Range) string {
+	if field == nil {
+		return ""
+	}
+	return text[field.From:field.To]
+}
@@ -0,0 +1,116 @@
+package onig
+
+import (
+	"bufio"
+	"unicode/utf8"
+)
+
+// DelimiterMode selects what happens to the text matched by the delimiter regex of a SplitFunc.
+type DelimiterMode int
+
+const (
+	// DelimiterDrop leaves the delimiter out of the tokens.
+	DelimiterDrop DelimiterMode = iota
+	// DelimiterSuffix keeps the delimiter at the end of the token it terminates.
+	DelimiterSuffix
+	// DelimiterPrefix keeps the delimiter at the start of the token that follows it.
+	DelimiterPrefix
+)
+
+// SplitFuncOptions configure the bufio.SplitFunc returned by SplitFunc.
+type SplitFuncOptions struct {
+	// Delimiter selects whether the delimiter is dropped or kept as a suffix or prefix of the tokens.
+	Delimiter DelimiterMode
+	// MaxTokenSize is the length, in bytes, of the longest token that can be returned.
+	// Longer tokens make the split function fail with bufio.ErrTooLong.
+	// Zero means no limit other than the buffer size of the bufio.Scanner.
+	MaxTokenSize int
+	// DeferMargin is the number of bytes that must follow a delimiter match before the end of the buffered data
+	// for the match to be accepted. Matches ending closer to the end of the buffer are deferred
+	// until more data arrives or the end of the input is reached, so a delimiter can't be cut short
+	// or found where the rest of the data would change it.
+	// It should be at least the number of bytes that lookahead assertions in the delimiter examine past its end.
+	// Matches that end exactly at the end of the buffer are always deferred.
+	DeferMargin int
+}
+
+// SplitFunc returns a bufio.SplitFunc that splits its input into tokens delimited by the matches of the regex.
+//
+// Before the end of the input, `$`, `\z` and `\Z` don't match at the end of the buffered data,
+// and matches close to it are deferred as described by SplitFuncOptions.DeferMargin.
+// Empty matches at the start of the remaining data are ignored, so every token is followed by a delimiter
+// that consumes at least one byte or starts at a later position.
+// The remaining data at the end of the input is returned as the last token if it is not empty.
+func (r *Regex) SplitFunc(options SplitFuncOptions) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		match, deferred, err := r.findDelimiter(data, atEOF, options)
+		if err != nil {
+			return 0, nil, err
+		}
+		if match == nil || deferred {
+			// The token ends at the latest where a deferred delimiter starts.
+			tokenEnd := len(data)
+			if match != nil {
+				tokenEnd = match.From
+			}
+			if options.MaxTokenSize > 0 && tokenEnd > options.MaxTokenSize {
+				return 0, nil, bufio.ErrTooLong
+			}
+			if atEOF && match == nil {
+				return len(data), data, nil
+			}
+			return 0, nil, nil
+		}
+		advance, token := match.To, data[:match.From]
+		switch options.Delimiter {
+		case DelimiterSuffix:
+			token = data[:match.To]
+		case DelimiterPrefix:
+			advance = match.From
+		}
+		if options.MaxTokenSize > 0 && len(token) > options.MaxTokenSize {
+			return 0, nil, bufio.ErrTooLong
+		}
+		return advance, token, nil
+	}
+}
+
+// findDelimiter returns the first delimiter in data that ends a token, or nil if there is none before the end
+// of the input, and whether more data is needed to accept the delimiter it returns.
+func (r *Regex) findDelimiter(data []byte, atEOF bool, options SplitFuncOptions) (*Range, bool, error) {
+	text := string(data)
+	searchOptions := REGEX_OPTION_NONE
+	if !atEOF {
+		searchOptions |= REGEX_OPTION_NOT_END_STRING | REGEX_OPTION_NOTEOL
+	}
+	from := 0
+	for from <= len(text) {
+		region, err := r.SearchFirstWithParam(text, uint(from), uint(len(text)), searchOptions, 0, 0)
+		if err != nil {
+			return nil, false, err
+		}
+		if region == nil {
+			return nil, false, nil
+		}
+		match := region.Pos(0)
+		if !atEOF && match.To+options.DeferMargin >= len(text) {
+			return match, true, nil
+		}
+		// A delimiter at the start of the data is the one that ended the previous token:
+		// either an empty match that would produce no progress, or the prefix of the current token.
+		if match.From == 0 && (match.From == match.To || options.Delimiter == DelimiterPrefix) {
+			if match.To > 0 {
+				from = match.To
+			} else {
+				_, size := utf8.DecodeRuneInString(text)
+				from = max(size, 1)
+			}
+			continue
+		}
+		return match, false, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,90 @@
+package onig
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func scanTokens(t *testing.T, r *Regex, text string, options SplitFuncOptions) ([]string, error) {
+	t.Helper()
+	scanner := bufio.NewScanner(iotest.OneByteReader(strings.NewReader(text)))
+	scanner.Buffer(make([]byte, 4), 1024)
+	scanner.Split(r.SplitFunc(options))
+	tokens := make([]string, 0)
+	for scanner.Scan() {
+		tokens = append(tokens, scanner.Text())
+	}
+	return tokens, scanner.Err()
+}
+
+func TestRegex_SplitFunc(t *testing.T) {
+	r := MustCompile(`,\s*`)
+	tokens, err := scanTokens(t, r, "a, b,c,  d", SplitFuncOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, tokens)
+
+	tokens, err = scanTokens(t, r, "a, b,c,  d, ", SplitFuncOptions{Delimiter: DelimiterSuffix})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a, ", "b,", "c,  ", "d, "}, tokens)
+
+	tokens, err = scanTokens(t, r, ", a, b", SplitFuncOptions{Delimiter: DelimiterPrefix})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{", a", ", b"}, tokens)
+}
+
+func TestRegex_SplitFunc_LogRecords(t *testing.T) {
+	r := MustCompile(`\n(?=\d{4}-\d{2}-\d{2} )`)
+	text := "2024-01-01 first\n  continued\n2024-01-02 second\n2024-01-03 third\n  more\n"
+	tokens, err := scanTokens(t, r, text, SplitFuncOptions{DeferMargin: 11})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"2024-01-01 first\n  continued",
+		"2024-01-02 second",
+		"2024-01-03 third\n  more\n",
+	}, tokens)
+}
+
+func TestRegex_SplitFunc_MatchesSplit(t *testing.T) {
+	text := "foo  bar baz\t\tqux  "
+	r := MustCompile(`\s+`)
+	expected := r.MustSplit(text)
+	tokens, err := scanTokens(t, r, text, SplitFuncOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, tokens)
+}
+
+func TestRegex_SplitFunc_EndAnchor(t *testing.T) {
+	r := MustCompile(`;$`)
+	tokens, err := scanTokens(t, r, "a;b;", SplitFuncOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a;b"}, tokens)
+}
+
+func TestRegex_SplitFunc_EmptyMatches(t *testing.T) {
+	r := MustCompile(`x*`)
+	tokens, err := scanTokens(t, r, "axxbc", SplitFuncOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, tokens)
+}
+
+func TestRegex_SplitFunc_MaxTokenSize(t *testing.T) {
+	r := MustCompile(`,`)
+	tokens, err := scanTokens(t, r, "ab,abcdef,a", SplitFuncOptions{MaxTokenSize: 4})
+	assert.ErrorIs(t, err, bufio.ErrTooLong)
+	assert.Equal(t, []string{"ab"}, tokens)
+}
+
+func TestRegex_SplitFunc_MaxTokenSizeWithDeferMargin(t *testing.T) {
+	r := MustCompile(`,`)
+	options := SplitFuncOptions{MaxTokenSize: 4, DeferMargin: 8}
+	tokens, err := scanTokens(t, r, "ab,cd,efgh,ij,kl", options)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ab", "cd", "efgh", "ij", "kl"}, tokens)
+
+	tokens, err = scanTokens(t, r, "ab,cd,efgh,ij,klmnopqrstu", options)
+	assert.ErrorIs(t, err, bufio.ErrTooLong)
+	assert.Equal(t, []string{"ab", "cd", "efgh", "ij"}, tokens)
+}
@@ -0,0 +1,95 @@
+package onig
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegex_SplitWithOptions(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		text     string
+		options  SplitOptions
+		expected []string
+	}{
+		{`,`, "a,b,,", SplitOptions{}, []string{"a", "b", "", ""}},
+		{`,`, "a,b,c", SplitOptions{Limit: 2}, []string{"a", "b,c"}},
+		{`,`, "a,b,,", SplitOptions{TrimTrailing: true}, []string{"a", "b"}},
+		{`x*`, "abc", SplitOptions{}, []string{"a", "b", "c"}},
+		// regexp.MustCompile(...).Split(text, limit)
+		{`,`, "a,b", SplitOptions{LimitStyle: SplitLimitGo}, []string{}},
+		{`,`, "a,b,", SplitOptions{Limit: -1, LimitStyle: SplitLimitGo}, []string{"a", "b", ""}},
+		{`,`, "", SplitOptions{Limit: -1, LimitStyle: SplitLimitGo}, []string{""}},
+		{`x*`, "abc", SplitOptions{Limit: -1, LimitStyle: SplitLimitGo}, []string{"a", "b", "c"}},
+		// re.split(pattern, text, maxsplit)
+		{`,`, "a,b,c,", SplitOptions{LimitStyle: SplitLimitPython}, []string{"a", "b", "c", ""}},
+		{`,`, "a,b,c", SplitOptions{Limit: 1, LimitStyle: SplitLimitPython}, []string{"a", "b,c"}},
+		{`,`, "a,b,c", SplitOptions{Limit: -1, LimitStyle: SplitLimitPython}, []string{"a,b,c"}},
+		{`(,)|(;)`, "a,b", SplitOptions{LimitStyle: SplitLimitPython, KeepCaptures: true}, []string{"a", ",", "", "b"}},
+		{`x*`, "abc", SplitOptions{LimitStyle: SplitLimitPython}, []string{"", "a", "b", "c", ""}},
+		// "text".split(pattern, limit)
+		{`,`, "a,b,,", SplitOptions{LimitStyle: SplitLimitJava}, []string{"a", "b"}},
+		{`,`, "a,b,,", SplitOptions{Limit: -1, LimitStyle: SplitLimitJava}, []string{"a", "b", "", ""}},
+		{`,`, "a,b,c", SplitOptions{Limit: 2, LimitStyle: SplitLimitJava}, []string{"a", "b,c"}},
+		{`,`, "", SplitOptions{LimitStyle: SplitLimitJava}, []string{""}},
+		{`,`, ",,", SplitOptions{LimitStyle: SplitLimitJava}, []string{}},
+		{`x*`, "abc", SplitOptions{LimitStyle: SplitLimitJava}, []string{"a", "b", "c"}},
+		{`x*`, "abc", SplitOptions{Limit: -1, LimitStyle: SplitLimitJava}, []string{"a", "b", "c", ""}},
+		// "text".split(/pattern/, limit)
+		{`,`, "a,b,,", SplitOptions{LimitStyle: SplitLimitRuby}, []string{"a", "b"}},
+		{`,`, "", SplitOptions{Limit: -1, LimitStyle: SplitLimitRuby}, []string{}},
+		{`(,)|(;)`, "a,b;c", SplitOptions{LimitStyle: SplitLimitRuby, KeepCaptures: true}, []string{"a", ",", "b", ";", "c"}},
+		{`(,)`, "a,b,c", SplitOptions{Limit: 2, LimitStyle: SplitLimitRuby, KeepCaptures: true}, []string{"a", ",", "b,c"}},
+	}
+	for _, test := range tests {
+		r := MustCompile(test.pattern)
+		splits, err := r.SplitWithOptions(test.text, test.options)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, splits, "%q split on %q with %+v", test.text, test.pattern, test.options)
+
+		seq := make([]string, 0)
+		for split, err := range r.SplitSeq(test.text, test.options) {
+			assert.NoError(t, err)
+			seq = append(seq, split)
+		}
+		assert.Equal(t, test.expected, seq, "%q split on %q with %+v", test.text, test.pattern, test.options)
+	}
+}
+
+func TestRegex_SplitWithRanges(t *testing.T) {
+	r := MustCompile(`(,)|(;)`)
+	ranges, err := r.SplitWithRanges("ab,c", SplitOptions{KeepCaptures: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Range{NewRange(0, 2), NewRange(2, 3), nil, NewRange(3, 4)}, ranges)
+}
+
+func TestRegex_SplitSeq_Break(t *testing.T) {
+	r := MustCompile(`,`)
+	splits := make([]string, 0)
+	for split, err := range r.SplitSeq("a,,,b", SplitOptions{TrimTrailing: true}) {
+		assert.NoError(t, err)
+		splits = append(splits, split)
+		if len(splits) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"a", ""}, splits)
+}
+
+func TestRegex_Split_SyntaxDefaults(t *testing.T) {
+	text := "a,b,,"
+	splits := MustCompileWithSyntax(`(,)`, SyntaxRuby).MustSplit(text)
+	assert.Equal(t, []string{"a", ",", "b", ",", "", ","}, splits)
+	splits = MustCompileWithSyntax(`(,)`, SyntaxPython).MustSplit(text)
+	assert.Equal(t, []string{"a", ",", "b", ",", "", ",", ""}, splits)
+	splits = MustCompileWithSyntax(`(,)`, SyntaxJava).MustSplit(text)
+	assert.Equal(t, []string{"a", "b"}, splits)
+	splits = MustCompileWithSyntax(`\(,\)`, SyntaxGrep).MustSplit(text)
+	assert.Equal(t, []string{"a", "b", "", ""}, splits)
+}
+
+func TestRegex_SplitN_Zero(t *testing.T) {
+	r := MustCompile(`,`)
+	assert.Empty(t, r.MustSplitN("a,b", 0))
+	assert.Equal(t, []string{"a", "b", ""}, r.MustSplitN("a,b,", -1))
+}
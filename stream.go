@@ -0,0 +1,136 @@
+package onig
+
+// DefaultStreamOptions are the StreamOptions used when a zero value is given.
+var DefaultStreamOptions = StreamOptions{
+	MaxMatchLength: 4096,
+	ChunkSize:      64 * 1024,
+}
+
+// StreamOptions configure how a regex is applied to a stream of data that is processed in chunks.
+//
+// Data is searched once at least ChunkSize bytes beyond the last processed position are buffered,
+// plus MaxMatchLength bytes of lookahead. A match is only accepted once the data that follows its start
+// by MaxMatchLength bytes has been read, and MaxMatchLength bytes of already processed data
+// are kept in front of the buffer for anchors and lookbehind.
+//
+// Anchors behave as they do when searching the whole stream at once: `\A` only matches at the beginning
+// of the stream, and `\z`, `\Z` and `$` don't match at the end of the buffered data unless it is the end of the stream.
+//
+// The results are identical to searching the whole stream at once as long as no match,
+// including the text examined by its lookahead and lookbehind assertions, is longer than MaxMatchLength.
+// They can differ for:
+//   - matches longer than MaxMatchLength, which are cut short or missed,
+//   - lookbehind or lookahead assertions that examine text more than MaxMatchLength bytes away,
+//   - `\G`, which anchors to the start of each search and so to the start of each chunk.
+type StreamOptions struct {
+	// MaxMatchLength is the length, in bytes, of the longest match the regex can produce,
+	// including the text examined by lookaround assertions.
+	MaxMatchLength int
+	// ChunkSize is the number of bytes searched with each call into Oniguruma.
+	ChunkSize int
+}
+
+// withDefaults returns the options with every zero value replaced by the value from DefaultStreamOptions.
+func (o StreamOptions) withDefaults() StreamOptions {
+	if o.MaxMatchLength <= 0 {
+		o.MaxMatchLength = DefaultStreamOptions.MaxMatchLength
+	}
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = DefaultStreamOptions.ChunkSize
+	}
+	return o
+}
+
+// streamSearcher finds the matches of a regex in data that arrives in chunks.
+//
+// The buffer holds some already processed data, kept as context for anchors and lookbehind,
+// followed by the data that hasn't been processed yet.
+type streamSearcher struct {
+	regex   *Regex
+	options StreamOptions
+	buf     []byte
+	// start is the position in buf of the first byte that hasn't been processed yet.
+	start int
+	// base is the offset of buf[0] from the beginning of the stream.
+	base int64
+	// lastMatchEnd is the stream offset at which the last match ended, or -1 if there was none.
+	lastMatchEnd int64
+}
+
+func newStreamSearcher(regex *Regex, options StreamOptions) *streamSearcher {
+	return &streamSearcher{
+		regex:        regex,
+		options:      options.withDefaults(),
+		lastMatchEnd: -1,
+	}
+}
+
+// write appends data to the buffer.
+func (s *streamSearcher) write(data []byte) {
+	s.buf = append(s.buf, data...)
+}
+
+// ready reports whether enough data is buffered to process a chunk before the end of the stream.
+func (s *streamSearcher) ready() bool {
+	return len(s.buf)-s.start >= s.options.ChunkSize+s.options.MaxMatchLength
+}
+
+// search passes the captures of the matches in the unprocessed data to yield, in order,
+// and returns the position in buf up to which the data has been processed.
+//
+// When final is false, only the matches starting MaxMatchLength bytes or more before the end of the buffer
+// are passed to yield, since more data could change them. When final is true, the buffer holds the end of the stream.
+// The captures refer to the buffer, whose offset in the stream is s.base.
+func (s *streamSearcher) search(final bool, yield func(captures *Captures) error) (int, error) {
+	limit := len(s.buf)
+	options := REGEX_OPTION_NONE
+	if !final {
+		limit -= s.options.MaxMatchLength
+		options |= REGEX_OPTION_NOT_END_STRING | REGEX_OPTION_NOTEOL
+	}
+	if s.base > 0 {
+		options |= REGEX_OPTION_NOT_BEGIN_STRING
+	}
+	if limit <= s.start {
+		return s.start, nil
+	}
+	captures, err := s.regex.allCapturesWithParam(string(s.buf), searchParams{
+		from:    uint(s.start),
+		to:      uint(len(s.buf)),
+		options: options,
+	})
+	if err != nil {
+		return s.start, err
+	}
+	processed := limit
+	for i := range captures {
+		pos := captures[i].Pos(0)
+		if pos == nil {
+			continue
+		}
+		if !final && pos.From >= limit {
+			break
+		}
+		// Like a whole-stream search, don't accept an empty match immediately following the last match.
+		if pos.From == pos.To && s.base+int64(pos.From) == s.lastMatchEnd {
+			continue
+		}
+		if err := yield(&captures[i]); err != nil {
+			return s.start, err
+		}
+		s.lastMatchEnd = s.base + int64(pos.To)
+		processed = max(processed, pos.To)
+	}
+	return processed, nil
+}
+
+// discard drops the data processed up to the position processed in buf,
+// keeping MaxMatchLength bytes in front of it as context.
+func (s *streamSearcher) discard(processed int) {
+	keep := max(0, processed-s.options.MaxMatchLength)
+	if keep > 0 {
+		s.buf = s.buf[:copy(s.buf, s.buf[keep:])]
+		s.base += int64(keep)
+	}
+	s.start = processed - keep
+}
@@ -0,0 +1,129 @@
+package onig
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ReplacingWriter is an io.WriteCloser that replaces all non-overlapping matches of a regex
+// in the data written to it and writes the result to an underlying writer.
+//
+// The data is processed in chunks as described by StreamOptions, so only a bounded window of it is kept in memory.
+// The Captures passed to the ReplacementFunc refer to the current window rather than the whole stream.
+// Close must be called to process the end of the stream; it doesn't close the underlying writer.
+type ReplacingWriter struct {
+	dst         io.Writer
+	replacement ReplacementFunc
+	searcher    *streamSearcher
+	written     int64
+	err         error
+}
+
+// NewReplacingWriter creates a new ReplacingWriter that writes the data written to it to dst,
+// with every match of regex replaced by the result of replacement.
+// Zero values in options are replaced by the corresponding values from DefaultStreamOptions.
+func NewReplacingWriter(
+	dst io.Writer,
+	regex *Regex,
+	replacement ReplacementFunc,
+	options StreamOptions,
+) *ReplacingWriter {
+	return &ReplacingWriter{
+		dst:         dst,
+		replacement: replacement,
+		searcher:    newStreamSearcher(regex, options),
+	}
+}
+
+// Write buffers data and writes the part of it that can no longer be affected by the data that follows.
+func (w *ReplacingWriter) Write(data []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.searcher.write(data)
+	for w.searcher.ready() {
+		if err := w.process(false); err != nil {
+			return len(data), err
+		}
+	}
+	return len(data), nil
+}
+
+// Close processes and writes the rest of the buffered data.
+// It doesn't close the underlying writer.
+func (w *ReplacingWriter) Close() error {
+	if w.err != nil {
+		if errors.Is(w.err, errWriterClosed) {
+			return nil
+		}
+		return w.err
+	}
+	if err := w.process(true); err != nil {
+		return err
+	}
+	w.err = errWriterClosed
+	return nil
+}
+
+// Written returns the number of bytes written to the underlying writer so far.
+func (w *ReplacingWriter) Written() int64 {
+	return w.written
+}
+
+var errWriterClosed = errors.New("write to closed ReplacingWriter")
+
+func (w *ReplacingWriter) process(final bool) error {
+	searcher := w.searcher
+	cursor := searcher.start
+	output := make([]byte, 0, len(searcher.buf)-searcher.start)
+	processed, err := searcher.search(final, func(captures *Captures) error {
+		pos := captures.Pos(0)
+		replacedText, err := w.replacement(captures)
+		if err != nil {
+			return fmt.Errorf("error replacing text: %w", err)
+		}
+		output = append(output, searcher.buf[cursor:pos.From]...)
+		output = append(output, replacedText...)
+		cursor = pos.To
+		return nil
+	})
+	if err != nil {
+		w.err = err
+		return err
+	}
+	output = append(output, searcher.buf[cursor:processed]...)
+	searcher.discard(processed)
+	n, err := w.dst.Write(output)
+	w.written += int64(n)
+	if err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// ReplaceAllReader copies src to dst, replacing all non-overlapping matches with the replacement provided.
+// The input is processed in chunks using DefaultStreamOptions; see StreamOptions for when the result
+// can differ from calling ReplaceAll on the whole input.
+// It returns the number of bytes written to dst.
+func (r *Regex) ReplaceAllReader(dst io.Writer, src io.Reader, replacement string) (int64, error) {
+	template, err := r.CompileTemplate(replacement)
+	if err != nil {
+		return 0, err
+	}
+	return r.ReplaceAllFuncReader(dst, src, template.Replace)
+}
+
+// ReplaceAllFuncReader copies src to dst, replacing all non-overlapping matches with the replacement function provided.
+// The input is processed in chunks using DefaultStreamOptions; see StreamOptions for when the result
+// can differ from calling ReplaceAllFunc on the whole input.
+// It returns the number of bytes written to dst.
+func (r *Regex) ReplaceAllFuncReader(dst io.Writer, src io.Reader, replacement ReplacementFunc) (int64, error) {
+	writer := NewReplacingWriter(dst, r, replacement, DefaultStreamOptions)
+	if _, err := io.Copy(writer, src); err != nil {
+		return writer.Written(), err
+	}
+	err := writer.Close()
+	return writer.Written(), err
+}
@@ -0,0 +1,80 @@
+package onig
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestRegex_ReplaceAllReader(t *testing.T) {
+	r := MustCompile(`(\d+)`)
+	var output bytes.Buffer
+	written, err := r.ReplaceAllReader(&output, strings.NewReader("a12b2c"), `<\1>`)
+	assert.NoError(t, err)
+	assert.Equal(t, "a<12>b<2>c", output.String())
+	assert.Equal(t, int64(output.Len()), written)
+
+	_, err = r.ReplaceAllReader(&output, strings.NewReader("a12b2c"), `\k<missing>`)
+	assert.Error(t, err)
+}
+
+func TestRegex_ReplaceAllFuncReader_Error(t *testing.T) {
+	r := MustCompile(`\d+`)
+	_, err := r.ReplaceAllFuncReader(io.Discard, strings.NewReader("a12b2c"), func(captures *Captures) (string, error) {
+		return "", fmt.Errorf("failed")
+	})
+	assert.ErrorContains(t, err, "failed")
+}
+
+func TestReplacingWriter_MatchesReplaceAll(t *testing.T) {
+	text := strings.Repeat("foo 123 bar\nbaz foo42\n\nfoofoo 7 end", 5)
+	tests := []struct {
+		pattern     string
+		replacement string
+	}{
+		{`\d+`, `<\0>`},
+		{`\d*`, `<\0>`},
+		{`^foo`, `F`},
+		{`bar$`, `B`},
+		{`\bfoo\b`, `X`},
+		{`\Afoo`, `START`},
+		{`end\z`, `END`},
+		{`(?<=foo)\d`, `#`},
+		{`o(?=o)`, `0`},
+		{`\n\n`, `|`},
+		{`$`, `;`},
+		{`^`, `>`},
+	}
+	for _, test := range tests {
+		r := MustCompile(test.pattern)
+		expected := r.MustReplaceAll(text, test.replacement)
+		template := r.MustCompileTemplate(test.replacement)
+		for _, chunkSize := range []int{1, 3, 16, 1000} {
+			var output bytes.Buffer
+			writer := NewReplacingWriter(&output, r, template.Replace, StreamOptions{MaxMatchLength: 8, ChunkSize: chunkSize})
+			_, err := io.Copy(writer, iotest.OneByteReader(strings.NewReader(text)))
+			assert.NoError(t, err)
+			assert.NoError(t, writer.Close())
+			assert.Equal(t, expected, output.String(), "%s with chunk size %d", test.pattern, chunkSize)
+			assert.Equal(t, int64(output.Len()), writer.Written())
+		}
+	}
+}
+
+func TestReplacingWriter_Close(t *testing.T) {
+	r := MustCompile(`a`)
+	var output bytes.Buffer
+	writer := NewReplacingWriter(&output, r, r.CreateReplacementFunc("b"), StreamOptions{})
+	_, err := writer.Write([]byte("banana"))
+	assert.NoError(t, err)
+	assert.Equal(t, "", output.String())
+	assert.NoError(t, writer.Close())
+	assert.Equal(t, "bbnbnb", output.String())
+	assert.NoError(t, writer.Close())
+	_, err = writer.Write([]byte("more"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package onig
+
+import (
+	"errors"
+	"io"
+	"iter"
+)
+
+// Match is a match found in a stream.
+type Match struct {
+	// From is the offset of the start of the match from the beginning of the stream.
+	From int64
+	// To is the offset of the end of the match from the beginning of the stream.
+	To int64
+	// Text is the matched text.
+	Text string
+	// Captures holds the capture groups of the match.
+	// Its positions are relative to the window of the stream that was searched, which starts at Offset.
+	Captures *Captures
+	// Offset is the offset of the searched window from the beginning of the stream.
+	Offset int64
+}
+
+// FindReaderIndex returns the first match of the regex in the data read from reader.
+// If no match is found, then nil is returned.
+// The data is read in chunks using DefaultStreamOptions; see StreamOptions for when the result
+// can differ from calling FindMatch on the whole input.
+func (r *Regex) FindReaderIndex(reader io.Reader) (*Match, error) {
+	return r.FindReaderIndexWithOptions(reader, DefaultStreamOptions)
+}
+
+// FindReaderIndexWithOptions returns the first match of the regex in the data read from reader.
+// If no match is found, then nil is returned.
+// Zero values in options are replaced by the corresponding values from DefaultStreamOptions.
+func (r *Regex) FindReaderIndexWithOptions(reader io.Reader, options StreamOptions) (*Match, error) {
+	for match, err := range r.MatchesReaderWithOptions(reader, options) {
+		if err != nil {
+			return nil, err
+		}
+		return &match, nil
+	}
+	return nil, nil
+}
+
+// MatchesReader returns an iterator over all non-overlapping matches of the regex in the data read from reader.
+// The offsets of the matches are relative to the beginning of the stream.
+// If reading or searching fails, the error is yielded and the iteration stops.
+//
+// Only a bounded window of the data is kept in memory, so it works with files and pipes of any size.
+// The data is read in chunks using DefaultStreamOptions; see StreamOptions for when the result
+// can differ from calling FindMatches on the whole input.
+func (r *Regex) MatchesReader(reader io.Reader) iter.Seq2[Match, error] {
+	return r.MatchesReaderWithOptions(reader, DefaultStreamOptions)
+}
+
+// MatchesReaderWithOptions returns an iterator over all non-overlapping matches of the regex in the data read from reader.
+// Zero values in options are replaced by the corresponding values from DefaultStreamOptions.
+func (r *Regex) MatchesReaderWithOptions(reader io.Reader, options StreamOptions) iter.Seq2[Match, error] {
+	return func(yield func(Match, error) bool) {
+		searcher := newStreamSearcher(r, options)
+		chunk := make([]byte, searcher.options.ChunkSize)
+		stopped := false
+		errStopped := errors.New("iteration stopped")
+		emit := func(captures *Captures) error {
+			pos := captures.Pos(0)
+			match := Match{
+				From:     searcher.base + int64(pos.From),
+				To:       searcher.base + int64(pos.To),
+				Text:     captures.At(0),
+				Captures: captures,
+				Offset:   searcher.base,
+			}
+			if !yield(match, nil) {
+				stopped = true
+				return errStopped
+			}
+			return nil
+		}
+		for {
+			n, err := reader.Read(chunk)
+			searcher.write(chunk[:n])
+			final := errors.Is(err, io.EOF)
+			if err != nil && !final {
+				yield(Match{}, err)
+				return
+			}
+			for final || searcher.ready() {
+				processed, err := searcher.search(final, emit)
+				if stopped {
+					return
+				}
+				if err != nil {
+					yield(Match{}, err)
+					return
+				}
+				searcher.discard(processed)
+				if final {
+					return
+				}
+			}
+		}
+	}
+}
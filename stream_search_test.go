@@ -0,0 +1,111 @@
+package onig
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func collectReaderMatches(t *testing.T, r *Regex, reader io.Reader, options StreamOptions) []*Range {
+	t.Helper()
+	matches := make([]*Range, 0)
+	for match, err := range r.MatchesReaderWithOptions(reader, options) {
+		assert.NoError(t, err)
+		assert.Equal(t, match.Text, match.Captures.At(0))
+		matches = append(matches, NewRange(int(match.From), int(match.To)))
+	}
+	return matches
+}
+
+func TestRegex_MatchesReader(t *testing.T) {
+	r := MustCompile(`\d+`)
+	matches := collectReaderMatches(t, r, strings.NewReader("a12b2"), StreamOptions{})
+	assert.Equal(t, []*Range{NewRange(1, 3), NewRange(4, 5)}, matches)
+}
+
+func TestRegex_MatchesReader_MatchesFindMatches(t *testing.T) {
+	text := strings.Repeat("foo 123 bar\nbaz foo42\n\nfoofoo 7 end", 7)
+	patterns := []string{`\d+`, `\d*`, `^foo`, `bar$`, `\bfoo\b`, `\Afoo`, `end\z`, `end\Z`, `(?<=foo)\d`, `o(?=o)`, `$`, `^$`}
+	for _, pattern := range patterns {
+		r := MustCompile(pattern)
+		expected := r.MustFindMatches(text)
+		if expected == nil {
+			expected = []*Range{}
+		}
+		for _, chunkSize := range []int{1, 5, 64, 4096} {
+			options := StreamOptions{MaxMatchLength: 8, ChunkSize: chunkSize}
+			matches := collectReaderMatches(t, r, iotest.OneByteReader(strings.NewReader(text)), options)
+			assert.Equal(t, expected, matches, "%s with chunk size %d", pattern, chunkSize)
+			matches = collectReaderMatches(t, r, iotest.DataErrReader(strings.NewReader(text)), options)
+			assert.Equal(t, expected, matches, "%s with chunk size %d", pattern, chunkSize)
+		}
+	}
+}
+
+func TestRegex_MatchesReader_Break(t *testing.T) {
+	r := MustCompile(`\d`)
+	count := 0
+	for _, err := range r.MatchesReaderWithOptions(strings.NewReader(strings.Repeat("1", 100)), StreamOptions{ChunkSize: 4, MaxMatchLength: 1}) {
+		assert.NoError(t, err)
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	assert.Equal(t, 3, count)
+}
+
+func TestRegex_MatchesReader_Error(t *testing.T) {
+	r := MustCompile(`\d`)
+	failure := errors.New("read failed")
+	var lastErr error
+	for _, err := range r.MatchesReader(iotest.ErrReader(failure)) {
+		lastErr = err
+	}
+	assert.ErrorIs(t, lastErr, failure)
+
+	_, err := r.FindReaderIndex(io.MultiReader(strings.NewReader("abc"), iotest.ErrReader(failure)))
+	assert.ErrorIs(t, err, failure)
+}
+
+func TestRegex_FindReaderIndex(t *testing.T) {
+	r := MustCompile(`needle`)
+	text := strings.Repeat("hay ", 50000) + "needle" + strings.Repeat(" hay", 10)
+	match, err := r.FindReaderIndex(strings.NewReader(text))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(200000), match.From)
+	assert.Equal(t, int64(200006), match.To)
+	assert.Equal(t, "needle", match.Text)
+
+	match, err = r.FindReaderIndex(strings.NewReader("no match here"))
+	assert.NoError(t, err)
+	assert.Nil(t, match)
+}
+
+func TestRegex_FindReaderIndex_Sources(t *testing.T) {
+	r := MustCompile(`^end$`)
+	text := strings.Repeat("line\n", 30000) + "end\n"
+
+	path := filepath.Join(t.TempDir(), "input.txt")
+	assert.NoError(t, os.WriteFile(path, []byte(text), 0o600))
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	match, err := r.FindReaderIndex(file)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(150000), match.From)
+
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		_, _ = io.Copy(pipeWriter, iotest.HalfReader(strings.NewReader(text)))
+		_ = pipeWriter.Close()
+	}()
+	match, err = r.FindReaderIndex(pipeReader)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(150000), match.From)
+}
@@ -4,20 +4,32 @@ package onig
 #include <oniguruma.h>
 */
 import "C"
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
 
 // Syntax is a wrapper for Onig Syntax
 //
 // Each syntax defines a flavour of regex syntax.
-// This type allows interaction with the built-in syntaxes through the static accessor functions
-// (Syntax::emacs(), Syntax::default() etc.) and the creation of custom syntaxes.
+// This type allows interaction with the built-in syntaxes through the package-level variables
+// (SyntaxRuby, SyntaxPython etc.) and SyntaxByName. A custom syntax is made by copying a built-in one
+// and changing its exported fields, and can be registered under a name with RegisterSyntax.
 type Syntax struct {
 	ReplacerFactory RegexReplacerFactory
-	raw             *C.OnigSyntaxType
+	// SplitOptions are the options used by Regex.Split, matching the split function of the language the syntax comes from.
+	SplitOptions SplitOptions
+	raw          *C.OnigSyntaxType
 }
 
 // SyntaxPython is the Python syntax.
 var SyntaxPython = &Syntax{
 	ReplacerFactory: NewPythonRegexReplacer,
+	SplitOptions:    SplitOptions{LimitStyle: SplitLimitPython, KeepCaptures: true},
 	raw:             C.ONIG_SYNTAX_PYTHON,
 }
 
@@ -28,58 +40,221 @@ var SyntaxAsis = &Syntax{
 
 // SyntaxPosixBasic is the POSIX Basic regular expression syntax.
 var SyntaxPosixBasic = &Syntax{
-	raw: C.ONIG_SYNTAX_POSIX_BASIC,
+	ReplacerFactory: NewPosixRegexReplacer,
+	raw:             C.ONIG_SYNTAX_POSIX_BASIC,
 }
 
 // SyntaxPosixExtended is the POSIX Extended regular expression syntax.
 var SyntaxPosixExtended = &Syntax{
-	raw: C.ONIG_SYNTAX_POSIX_EXTENDED,
+	ReplacerFactory: NewPosixRegexReplacer,
+	raw:             C.ONIG_SYNTAX_POSIX_EXTENDED,
 }
 
 // SyntaxEmacs is the Emacs regular expression syntax.
 var SyntaxEmacs = &Syntax{
-	raw: C.ONIG_SYNTAX_EMACS,
+	ReplacerFactory: NewEmacsRegexReplacer,
+	raw:             C.ONIG_SYNTAX_EMACS,
 }
 
 // SyntaxGrep is the grep regular expression syntax.
 var SyntaxGrep = &Syntax{
-	raw: C.ONIG_SYNTAX_GREP,
+	ReplacerFactory: NewPosixRegexReplacer,
+	raw:             C.ONIG_SYNTAX_GREP,
 }
 
 // SyntaxGnuRegex is the GNU regex regular expression syntax.
 var SyntaxGnuRegex = &Syntax{
-	raw: C.ONIG_SYNTAX_GNU_REGEX,
+	ReplacerFactory: NewPosixRegexReplacer,
+	raw:             C.ONIG_SYNTAX_GNU_REGEX,
 }
 
 // SyntaxJava is the Java (Sun java.util.regex) regular expression syntax.
 var SyntaxJava = &Syntax{
 	ReplacerFactory: NewJavaRegexReplacer,
+	SplitOptions:    SplitOptions{LimitStyle: SplitLimitJava},
 	raw:             C.ONIG_SYNTAX_JAVA,
 }
 
 // SyntaxPerl is the Perl regular expression syntax.
 var SyntaxPerl = &Syntax{
-	raw: C.ONIG_SYNTAX_PERL,
+	ReplacerFactory: NewPerlRegexReplacer,
+	SplitOptions:    SplitOptions{LimitStyle: SplitLimitRuby, KeepCaptures: true},
+	raw:             C.ONIG_SYNTAX_PERL,
 }
 
 // SyntaxPerlNG is the Perl + named group regular expression syntax.
 var SyntaxPerlNG = &Syntax{
-	raw: C.ONIG_SYNTAX_PERL_NG,
+	ReplacerFactory: NewPerlRegexReplacer,
+	SplitOptions:    SplitOptions{LimitStyle: SplitLimitRuby, KeepCaptures: true},
+	raw:             C.ONIG_SYNTAX_PERL_NG,
 }
 
 // SyntaxRuby is the Ruby regular expression syntax.
 var SyntaxRuby = &Syntax{
 	ReplacerFactory: NewRubyRegexReplacer,
+	SplitOptions:    SplitOptions{LimitStyle: SplitLimitRuby, KeepCaptures: true},
 	raw:             C.ONIG_SYNTAX_RUBY,
 }
 
 // SyntaxOniguruma is the Oniguruma regular expression syntax.
 var SyntaxOniguruma = &Syntax{
-	raw: C.ONIG_SYNTAX_ONIGURUMA,
+	ReplacerFactory: NewRubyRegexReplacer,
+	SplitOptions:    SplitOptions{LimitStyle: SplitLimitRuby, KeepCaptures: true},
+	raw:             C.ONIG_SYNTAX_ONIGURUMA,
 }
 
-// SyntaxDefault is the default syntax (Ruby syntax).
-var SyntaxDefault = &Syntax{
-	ReplacerFactory: NewRubyRegexReplacer,
-	raw:             C.ONIG_SYNTAX_RUBY,
+// SyntaxDefault is the default syntax (Ruby syntax). It is the same value as SyntaxRuby.
+// Compile uses DefaultSyntax, which is SyntaxDefault unless changed by SetDefaultSyntax.
+var SyntaxDefault = SyntaxRuby
+
+// ErrSyntaxRegistered is returned when registering a syntax or an alias under a name that is already taken.
+var ErrSyntaxRegistered = errors.New("syntax name is already registered")
+
+// syntaxRegistry holds the syntaxes known by name, the name of each registered syntax, and the aliases of their names.
+// Names and aliases are stored in lower case. A syntax is identified by its pointer, so a copy of a registered
+// syntax has no name until it is registered itself.
+var syntaxRegistry = struct {
+	sync.RWMutex
+	syntaxes map[string]*Syntax
+	names    map[*Syntax]string
+	aliases  map[string]string
+}{
+	syntaxes: map[string]*Syntax{},
+	names:    map[*Syntax]string{},
+	aliases:  map[string]string{},
+}
+
+var defaultSyntax atomic.Pointer[Syntax]
+
+func init() {
+	for name, syntax := range map[string]*Syntax{
+		"asis":           SyntaxAsis,
+		"posix_basic":    SyntaxPosixBasic,
+		"posix_extended": SyntaxPosixExtended,
+		"emacs":          SyntaxEmacs,
+		"grep":           SyntaxGrep,
+		"gnu_regex":      SyntaxGnuRegex,
+		"java":           SyntaxJava,
+		"perl":           SyntaxPerl,
+		"perl_ng":        SyntaxPerlNG,
+		"ruby":           SyntaxRuby,
+		"oniguruma":      SyntaxOniguruma,
+		"python":         SyntaxPython,
+	} {
+		syntaxRegistry.syntaxes[name] = syntax
+		syntaxRegistry.names[syntax] = name
+	}
+	for alias, name := range map[string]string{
+		"default": "ruby",
+		"onig":    "oniguruma",
+		"pcre":    "perl_ng",
+		"bre":     "posix_basic",
+		"ere":     "posix_extended",
+		"posix":   "posix_extended",
+		"gnu":     "gnu_regex",
+	} {
+		syntaxRegistry.aliases[alias] = name
+	}
+	defaultSyntax.Store(SyntaxDefault)
+}
+
+// Name returns the name the syntax is registered under, such as "ruby" or "perl_ng",
+// or an empty string if it isn't registered. A copy of a built-in syntax isn't registered
+// until it is passed to RegisterSyntax.
+func (s *Syntax) Name() string {
+	syntaxRegistry.RLock()
+	defer syntaxRegistry.RUnlock()
+	return syntaxRegistry.names[s]
+}
+
+// SyntaxByName returns the syntax registered under the given name or alias, ignoring case.
+func SyntaxByName(name string) (*Syntax, bool) {
+	key := strings.ToLower(name)
+	syntaxRegistry.RLock()
+	defer syntaxRegistry.RUnlock()
+	if target, ok := syntaxRegistry.aliases[key]; ok {
+		key = target
+	}
+	syntax, ok := syntaxRegistry.syntaxes[key]
+	return syntax, ok
+}
+
+// SyntaxNames returns the sorted names of the registered syntaxes, without their aliases.
+func SyntaxNames() []string {
+	syntaxRegistry.RLock()
+	defer syntaxRegistry.RUnlock()
+	names := make([]string, 0, len(syntaxRegistry.syntaxes))
+	for name := range syntaxRegistry.syntaxes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// RegisterSyntax registers a custom syntax under the given name, which is stored in lower case
+// and becomes the name of the syntax. The syntax is usually a copy of a built-in one:
+//
+//	custom := *onig.SyntaxJava
+//	custom.SplitOptions = onig.SplitOptions{LimitStyle: onig.SplitLimitGo}
+//	err := onig.RegisterSyntax("java_go_split", &custom)
+//
+// It returns ErrSyntaxRegistered if the name is taken by a syntax or an alias,
+// and an error if the syntax is already registered under another name.
+func RegisterSyntax(name string, syntax *Syntax) error {
+	if syntax == nil || syntax.raw == nil {
+		return fmt.Errorf("syntax %q must be a copy of a built-in syntax", name)
+	}
+	key := strings.ToLower(name)
+	if key == "" {
+		return errors.New("syntax name can't be empty")
+	}
+	syntaxRegistry.Lock()
+	defer syntaxRegistry.Unlock()
+	if _, ok := syntaxRegistry.syntaxes[key]; ok {
+		return fmt.Errorf("%w: %q", ErrSyntaxRegistered, name)
+	}
+	if _, ok := syntaxRegistry.aliases[key]; ok {
+		return fmt.Errorf("%w: %q", ErrSyntaxRegistered, name)
+	}
+	if registeredName, ok := syntaxRegistry.names[syntax]; ok {
+		return fmt.Errorf("syntax %q is already registered as %q", name, registeredName)
+	}
+	syntaxRegistry.syntaxes[key] = syntax
+	syntaxRegistry.names[syntax] = key
+	return nil
+}
+
+// RegisterSyntaxAlias makes alias refer to the syntax registered under name, ignoring case.
+// An alias can be registered again to point it at another syntax, but it can't take the name of a syntax.
+func RegisterSyntaxAlias(alias string, name string) error {
+	aliasKey := strings.ToLower(alias)
+	key := strings.ToLower(name)
+	syntaxRegistry.Lock()
+	defer syntaxRegistry.Unlock()
+	if _, ok := syntaxRegistry.syntaxes[aliasKey]; ok {
+		return fmt.Errorf("%w: %q", ErrSyntaxRegistered, alias)
+	}
+	if _, ok := syntaxRegistry.syntaxes[key]; !ok {
+		return fmt.Errorf("unknown syntax %q", name)
+	}
+	syntaxRegistry.aliases[aliasKey] = key
+	return nil
+}
+
+// DefaultSyntax returns the syntax used by Compile and CompileWithOptions, which is SyntaxDefault
+// unless changed by SetDefaultSyntax.
+func DefaultSyntax() *Syntax {
+	return defaultSyntax.Load()
+}
+
+// SetDefaultSyntax changes the syntax used by Compile and CompileWithOptions for the whole process,
+// and the default syntax of Oniguruma itself. Regexes that were already compiled keep their syntax.
+// It returns an error, leaving the default unchanged, if syntax is nil or isn't a copy of a built-in syntax.
+func SetDefaultSyntax(syntax *Syntax) error {
+	if syntax == nil || syntax.raw == nil {
+		return errors.New("default syntax must be a copy of a built-in syntax")
+	}
+	defaultSyntax.Store(syntax)
+	C.onig_set_default_syntax(syntax.raw)
+	return nil
 }
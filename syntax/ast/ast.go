@@ -0,0 +1,339 @@
+// Package ast parses Oniguruma patterns into a syntax tree, without compiling them.
+//
+// Parse accepts and rejects the same patterns as Oniguruma does for the supported flavors, and reports the same
+// error codes. The tree keeps the position of every node in the pattern, so that tools such as linters can point
+// at the part of a pattern they are reporting on. The package doesn't depend on the onig package, or on cgo.
+package ast
+
+// Span is the range of bytes of the pattern that a node was parsed from.
+type Span struct {
+	Start int
+	End   int
+}
+
+// Pos returns the span itself, so that Span can be embedded in the nodes.
+func (s Span) Pos() Span {
+	return s
+}
+
+// Node is a node of the syntax tree.
+type Node interface {
+	// Pos returns the part of the pattern the node was parsed from.
+	Pos() Span
+}
+
+// Literal matches a single character, given by its code point, or a single byte given with an escape such as \xHH
+// or an octal escape, when Byte is set.
+type Literal struct {
+	Span
+	Char rune
+	// Byte is set when the literal is a raw byte rather than a character. Consecutive raw bytes are merged into
+	// one literal when they make a valid UTF-8 character.
+	Byte bool
+	// IgnoreCase is set when the literal is matched case insensitively.
+	IgnoreCase bool
+}
+
+// AnyCharKind is the kind of an AnyChar node.
+type AnyCharKind int
+
+const (
+	// AnyCharDot is the . meta character, which matches a newline only in multiline mode.
+	AnyCharDot AnyCharKind = iota
+	// AnyCharNoNewline is \N, which never matches a newline.
+	AnyCharNoNewline
+	// AnyCharTrue is \O, which matches any character.
+	AnyCharTrue
+)
+
+// AnyChar matches any character.
+type AnyChar struct {
+	Span
+	Kind AnyCharKind
+	// Multiline is set when the . meta character matches a newline.
+	Multiline bool
+}
+
+// CharTypeKind is the kind of a CharType node.
+type CharTypeKind int
+
+const (
+	CharTypeWord CharTypeKind = iota
+	CharTypeDigit
+	CharTypeSpace
+	CharTypeHexDigit
+)
+
+// CharType is a character type escape such as \w, \d, \s or \h, or their negations.
+type CharType struct {
+	Span
+	Kind    CharTypeKind
+	Negated bool
+	// ASCII is set when the character type only matches ASCII characters, because of an option.
+	ASCII bool
+}
+
+// Property is a character property such as \p{Alpha} or \p{^Greek}.
+type Property struct {
+	Span
+	// Name is the name of the property, as written in the pattern.
+	Name    string
+	Negated bool
+}
+
+// PosixClass is a POSIX bracket such as [:alpha:], inside a character class.
+type PosixClass struct {
+	Span
+	Name    string
+	Negated bool
+	// ASCII is set when the bracket only matches ASCII characters, because of an option.
+	ASCII bool
+}
+
+// Class is a bracketed character class. Its items are Literal, ClassRange, CharType, Property, PosixClass,
+// Class for nested classes and ClassIntersection nodes.
+type Class struct {
+	Span
+	Negated bool
+	Items   []Node
+	// IgnoreCase is set when the class is matched case insensitively.
+	IgnoreCase bool
+}
+
+// ClassRange is a range of characters such as a-z inside a character class.
+type ClassRange struct {
+	Span
+	From *Literal
+	To   *Literal
+}
+
+// ClassIntersection is the intersection of the operands of the && operator inside a character class.
+// Each operand is a class made of the items between two operators.
+type ClassIntersection struct {
+	Span
+	Operands []*Class
+}
+
+// GroupKind is the kind of a Group node.
+type GroupKind int
+
+const (
+	GroupCapture GroupKind = iota
+	GroupNonCapture
+	GroupAtomic
+	GroupLookahead
+	GroupNegativeLookahead
+	GroupLookbehind
+	GroupNegativeLookbehind
+	// GroupOptions is a group that changes options for its content, such as (?i:...).
+	GroupOptions
+)
+
+// Group is a parenthesized group. In the flavors where only named groups capture when a pattern has some, such
+// as Ruby, the unnamed groups of such a pattern are GroupNonCapture groups.
+type Group struct {
+	Span
+	Kind GroupKind
+	// Index is the number of a capturing group, starting from 1.
+	Index int
+	// Name is the name of a named capturing group.
+	Name string
+	// On and Off are the options that a GroupOptions group turns on and off.
+	On  Options
+	Off Options
+	Sub Node
+}
+
+// Flags is an isolated option setting such as (?i), which applies to the rest of the enclosing group.
+// Sub is the rest of the enclosing group, or only the rest of the branch in the flavors such as Perl where the
+// options continue into the following alternatives, which are then outside of Sub.
+type Flags struct {
+	Span
+	On  Options
+	Off Options
+	Sub Node
+}
+
+// QuantifierKind is the kind of a Quantifier node.
+type QuantifierKind int
+
+const (
+	Greedy QuantifierKind = iota
+	Lazy
+	Possessive
+)
+
+// Quantifier repeats its sub node between Min and Max times. Max is -1 when there's no upper bound.
+type Quantifier struct {
+	Span
+	Sub  Node
+	Min  int
+	Max  int
+	Kind QuantifierKind
+}
+
+// Alternation matches one of its alternatives.
+type Alternation struct {
+	Span
+	Alternatives []Node
+}
+
+// Concat matches its items one after the other. An empty Concat matches the empty string.
+type Concat struct {
+	Span
+	Items []Node
+}
+
+// Backref is a back reference to captured groups, such as \1, \k<name> or \k<name+1>.
+type Backref struct {
+	Span
+	// Name is the name of the referenced group, when the reference is by name.
+	Name string
+	// Groups are the numbers of the referenced groups. A name can refer to several groups.
+	Groups []int
+	// Level is the nest level of a reference such as \k<name+1>, when HasLevel is set.
+	Level    int
+	HasLevel bool
+	// IgnoreCase is set when the reference is matched case insensitively.
+	IgnoreCase bool
+}
+
+// Call is a subexpression call such as \g<name>, \g<1> or (?P>name). Group 0 is the whole pattern.
+type Call struct {
+	Span
+	Name  string
+	Group int
+}
+
+// AnchorKind is the kind of an Anchor node.
+type AnchorKind int
+
+const (
+	AnchorBeginLine AnchorKind = iota
+	AnchorEndLine
+	AnchorBeginBuffer
+	AnchorEndBuffer
+	// AnchorSemiEndBuffer is \Z, the end of the text or before a final newline.
+	AnchorSemiEndBuffer
+	AnchorBeginPosition
+	AnchorWordBoundary
+	AnchorNotWordBoundary
+	AnchorWordBegin
+	AnchorWordEnd
+	AnchorTextSegmentBoundary
+	AnchorNotTextSegmentBoundary
+)
+
+// Anchor is a zero-width assertion such as ^, \A or \b.
+type Anchor struct {
+	Span
+	Kind AnchorKind
+}
+
+// Keep is \K, which keeps the text matched so far out of the match.
+type Keep struct {
+	Span
+}
+
+// GeneralNewline is \R, which matches any newline sequence.
+type GeneralNewline struct {
+	Span
+}
+
+// TextSegment is \X, which matches a text segment such as an extended grapheme cluster.
+type TextSegment struct {
+	Span
+}
+
+// AbsentKind is the kind of an Absent node.
+type AbsentKind int
+
+const (
+	// AbsentRepeater is (?~absent), which matches any text that doesn't contain a match of absent.
+	AbsentRepeater AbsentKind = iota
+	// AbsentExpression is (?~|absent|exp), which matches exp where it doesn't contain a match of absent.
+	AbsentExpression
+	// AbsentStopper is (?~|absent), which limits the rest of the pattern to text without a match of absent.
+	AbsentStopper
+	// AbsentClear is (?~|), which clears the effect of an absent stopper.
+	AbsentClear
+)
+
+// Absent is an absent group.
+type Absent struct {
+	Span
+	Kind   AbsentKind
+	Absent Node
+	Expr   Node
+}
+
+// Conditional is a conditional group such as (?(1)yes|no). The condition is a Backref, or a pattern for
+// (?(cond)yes|no) in the flavors that allow it. No is nil when there's no else branch, and Yes is nil too for a
+// group with only a condition, such as (?(1)).
+type Conditional struct {
+	Span
+	Cond Node
+	Yes  Node
+	No   Node
+}
+
+// Callout is a callout of contents such as (?{...}) or a callout by name such as (*FAIL) or (*COUNT[tag]{X}).
+type Callout struct {
+	Span
+	// Name is the name of a callout by name.
+	Name string
+	// Contents are the contents of a callout of contents.
+	Contents string
+	Tag      string
+	Args     []string
+}
+
+// Tree is a parsed pattern.
+type Tree struct {
+	Root Node
+	// Captures is the number of capturing groups.
+	Captures int
+	// Names maps the name of each named group to the numbers of the groups with that name.
+	Names map[string][]int
+}
+
+// Walk calls visit for node and, while visit returns true, for the nodes below it, depth first.
+func Walk(node Node, visit func(Node) bool) {
+	if node == nil || !visit(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *Class:
+		for _, item := range n.Items {
+			Walk(item, visit)
+		}
+	case *ClassRange:
+		Walk(n.From, visit)
+		Walk(n.To, visit)
+	case *ClassIntersection:
+		for _, operand := range n.Operands {
+			Walk(operand, visit)
+		}
+	case *Group:
+		Walk(n.Sub, visit)
+	case *Flags:
+		Walk(n.Sub, visit)
+	case *Quantifier:
+		Walk(n.Sub, visit)
+	case *Alternation:
+		for _, alternative := range n.Alternatives {
+			Walk(alternative, visit)
+		}
+	case *Concat:
+		for _, item := range n.Items {
+			Walk(item, visit)
+		}
+	case *Absent:
+		Walk(n.Absent, visit)
+		Walk(n.Expr, visit)
+	case *Conditional:
+		Walk(n.Cond, visit)
+		Walk(n.Yes, visit)
+		Walk(n.No, visit)
+	}
+}
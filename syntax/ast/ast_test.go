@@ -0,0 +1,159 @@
+package ast
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParse_Tree(t *testing.T) {
+	for _, test := range []struct {
+		pattern  string
+		flavor   *Flavor
+		expected Node
+	}{
+		{`a(?<n>b+?)\k<n>`, FlavorRuby, &Concat{Span{0, 15}, []Node{
+			&Literal{Span: Span{0, 1}, Char: 'a'},
+			&Group{Span: Span{1, 10}, Kind: GroupCapture, Index: 1, Name: "n",
+				Sub: &Quantifier{Span{6, 9}, &Literal{Span: Span{6, 7}, Char: 'b'}, 1, -1, Lazy}},
+			&Backref{Span: Span{10, 15}, Name: "n", Groups: []int{1}},
+		}}},
+		{`[a-c&&[^b]]`, FlavorRuby, &Class{Span: Span{0, 11}, Items: []Node{
+			&ClassIntersection{Span{1, 10}, []*Class{
+				{Span: Span{1, 4}, Items: []Node{
+					&ClassRange{Span{1, 4}, &Literal{Span: Span{1, 2}, Char: 'a'}, &Literal{Span: Span{3, 4}, Char: 'c'}},
+				}},
+				{Span: Span{6, 10}, Items: []Node{
+					&Class{Span: Span{6, 10}, Negated: true, Items: []Node{&Literal{Span: Span{8, 9}, Char: 'b'}}},
+				}},
+			}},
+		}}},
+		{`a(?i)b|c`, FlavorRuby, &Concat{Span{0, 8}, []Node{
+			&Literal{Span: Span{0, 1}, Char: 'a'},
+			&Flags{Span: Span{1, 8}, On: OPTION_IGNORECASE, Sub: &Alternation{Span{5, 8}, []Node{
+				&Literal{Span: Span{5, 6}, Char: 'b', IgnoreCase: true},
+				&Literal{Span: Span{7, 8}, Char: 'c', IgnoreCase: true},
+			}}},
+		}}},
+		{`(?~|ab|c*)`, FlavorOniguruma, &Absent{Span{0, 10}, AbsentExpression,
+			&Concat{Span{4, 6}, []Node{&Literal{Span: Span{4, 5}, Char: 'a'}, &Literal{Span: Span{5, 6}, Char: 'b'}}},
+			&Quantifier{Span{7, 9}, &Literal{Span: Span{7, 8}, Char: 'c'}, 0, -1, Greedy},
+		}},
+		{`(?(1)a|b)()`, FlavorRuby, &Concat{Span{0, 11}, []Node{
+			&Conditional{Span{0, 9}, &Backref{Span: Span{3, 5}, Groups: []int{1}},
+				&Literal{Span: Span{5, 6}, Char: 'a'}, &Literal{Span: Span{7, 8}, Char: 'b'}},
+			&Group{Span: Span{9, 11}, Kind: GroupCapture, Index: 1, Sub: &Concat{Span: Span{10, 10}}},
+		}}},
+		{`(?<=a+)`, FlavorJava, &Group{Span: Span{0, 7}, Kind: GroupLookbehind,
+			Sub: &Quantifier{Span{4, 6}, &Literal{Span: Span{4, 5}, Char: 'a'}, 1, -1, Greedy}}},
+		{`(?:ab){2,}+`, FlavorOniguruma, &Quantifier{Span{0, 11},
+			&Quantifier{Span{0, 10}, &Group{Span: Span{0, 6}, Kind: GroupNonCapture, Sub: &Concat{Span{3, 5}, []Node{
+				&Literal{Span: Span{3, 4}, Char: 'a'}, &Literal{Span: Span{4, 5}, Char: 'b'},
+			}}}, 2, -1, Greedy}, 1, -1, Greedy}},
+		{`a{3,2}`, FlavorRuby, &Quantifier{Span{0, 6}, &Literal{Span: Span{0, 1}, Char: 'a'}, 2, 3, Possessive}},
+	} {
+		tree, err := Parse(test.pattern, test.flavor)
+		if assert.NoError(t, err, test.pattern) {
+			assert.Equal(t, test.expected, tree.Root, test.pattern)
+		}
+	}
+}
+
+func TestParse_Captures(t *testing.T) {
+	for _, test := range []struct {
+		pattern  string
+		flavor   *Flavor
+		captures int
+		kinds    []GroupKind
+		indexes  []int
+	}{
+		{`(a)(?<n>b)`, FlavorRuby, 1, []GroupKind{GroupNonCapture, GroupCapture}, []int{0, 1}},
+		{`(a)(?<n>b)`, FlavorPerlNG, 1, []GroupKind{GroupNonCapture, GroupCapture}, []int{0, 1}},
+		{`(?P<n>a)(b)`, FlavorPython, 2, []GroupKind{GroupCapture, GroupCapture}, []int{1, 2}},
+		{`(a)(?:b)`, FlavorJava, 1, []GroupKind{GroupCapture, GroupNonCapture}, []int{1, 0}},
+	} {
+		tree, err := Parse(test.pattern, test.flavor)
+		if !assert.NoError(t, err, test.pattern) {
+			continue
+		}
+		var kinds []GroupKind
+		var indexes []int
+		Walk(tree.Root, func(n Node) bool {
+			if g, ok := n.(*Group); ok {
+				kinds = append(kinds, g.Kind)
+				indexes = append(indexes, g.Index)
+			}
+			return true
+		})
+		assert.Equal(t, test.captures, tree.Captures, test.pattern)
+		assert.Equal(t, test.kinds, kinds, test.pattern)
+		assert.Equal(t, test.indexes, indexes, test.pattern)
+	}
+}
+
+func TestParse_Names(t *testing.T) {
+	tree, err := Parse(`(?<a>x)(?<b>y)(?<a>z)`, FlavorOniguruma)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, tree.Captures)
+	assert.Equal(t, map[string][]int{"a": {1, 3}, "b": {2}}, tree.Names)
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		flavor  *Flavor
+		code    ErrorCode
+		offset  int
+	}{
+		{`a)`, FlavorRuby, ERR_UNMATCHED_CLOSE_PARENTHESIS, 2},
+		{`[b-a]`, FlavorPython, ERR_EMPTY_RANGE_IN_CHAR_CLASS, 4},
+		{`a{3,2}`, FlavorPerlNG, ERR_UPPER_SMALLER_THAN_LOWER_IN_REPEAT_RANGE, 6},
+		{`(a)(?<n>b)`, FlavorPython, ERR_UNDEFINED_GROUP_OPTION, 6},
+		{`(?P<n>a)`, FlavorRuby, ERR_UNDEFINED_GROUP_OPTION, 3},
+		{`\k<x>`, FlavorOniguruma, ERR_UNDEFINED_NAME_REFERENCE, 5},
+		{`(?<n>a)(?<n>b)\g<n>`, FlavorRuby, ERR_MULTIPLEX_DEFINITION_NAME_CALL, 14},
+		{`(a\g<1>)`, FlavorRuby, ERR_NEVER_ENDING_RECURSION, 0},
+	} {
+		_, err := Parse(test.pattern, test.flavor)
+		if assert.IsType(t, &Error{}, err, test.pattern) {
+			assert.Equal(t, &Error{Code: test.code, Offset: test.offset}, err, test.pattern)
+		}
+	}
+}
+
+func TestError_Error(t *testing.T) {
+	_, err := Parse(`a)`, FlavorRuby)
+	assert.EqualError(t, err, "invalid pattern at offset 2: unmatched close parenthesis")
+}
+
+func TestParse_UnsupportedFlavor(t *testing.T) {
+	basic := &Flavor{Name: "posix_basic", Op: SYN_OP_ESC_LPAREN_SUBEXP | SYN_OP_ESC_BRACE_INTERVAL}
+	assert.False(t, basic.Supported())
+	_, err := Parse(`\(a\)`, basic)
+	assert.Equal(t, ErrUnsupportedFlavor, err)
+
+	for _, flavor := range Flavors() {
+		assert.True(t, flavor.Supported(), flavor.Name)
+	}
+}
+
+func TestParseWithOptions(t *testing.T) {
+	tree, err := ParseWithOptions(`a`, FlavorRuby, OPTION_IGNORECASE)
+	assert.NoError(t, err)
+	assert.Equal(t, &Literal{Span: Span{0, 1}, Char: 'a', IgnoreCase: true}, tree.Root)
+
+	_, err = ParseWithOptions(`a`, FlavorRuby, OPTION_CAPTURE_GROUP|OPTION_DONT_CAPTURE_GROUP)
+	assert.Equal(t, &Error{Code: ERR_INVALID_COMBINATION_OF_OPTIONS}, err)
+}
+
+func TestWalk(t *testing.T) {
+	tree, err := Parse(`(a|[b-c])+(?=d)`, FlavorRuby)
+	assert.NoError(t, err)
+	var spans []Span
+	Walk(tree.Root, func(n Node) bool {
+		spans = append(spans, n.Pos())
+		// The look-ahead isn't visited below.
+		g, ok := n.(*Group)
+		return !ok || g.Kind != GroupLookahead
+	})
+	assert.Equal(t, []Span{{0, 15}, {0, 10}, {0, 9}, {1, 8}, {1, 2}, {3, 8}, {4, 7}, {4, 5}, {6, 7}, {10, 15}}, spans)
+}
@@ -0,0 +1,327 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+)
+
+// calloutArgType is the set of types an argument of a built-in callout can have.
+type calloutArgType int
+
+const (
+	argLong calloutArgType = 1 << iota
+	argChar
+	argString
+	argTag
+)
+
+// tagRef is a tag given as the argument of a callout, which must be defined somewhere in the pattern.
+type tagRef struct {
+	name   string
+	offset int
+}
+
+// calloutMaxArgs is the largest number of arguments of a callout, and calloutMaxArgLen the longest argument.
+const (
+	calloutMaxArgs   = 4
+	calloutMaxArgLen = 128
+)
+
+// builtinCallout describes the arguments of a built-in callout by name.
+type builtinCallout struct {
+	args     []calloutArgType
+	optional int
+}
+
+// builtinCallouts are the callouts by name that Oniguruma defines.
+var builtinCallouts = map[string]builtinCallout{
+	"FAIL":        {},
+	"MISMATCH":    {},
+	"ERROR":       {args: []calloutArgType{argLong}, optional: 1},
+	"COUNT":       {args: []calloutArgType{argChar}, optional: 1},
+	"TOTAL_COUNT": {args: []calloutArgType{argChar}, optional: 1},
+	"MAX":         {args: []calloutArgType{argTag | argLong, argChar}, optional: 1},
+	"CMP":         {args: []calloutArgType{argTag | argLong, argString, argTag | argLong}},
+}
+
+// isCalloutName reports whether s is a valid name of a callout or of a tag.
+func isCalloutName(s string) bool {
+	if s == "" || isDigit(rune(s[0])) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(rune(c)) || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// calloutOfContents parses a callout of contents such as (?{...}), with the position after the first brace.
+func (p *parser) calloutOfContents(start int) (*Callout, error) {
+	if p.end() {
+		return nil, p.errorf(ERR_INVALID_CALLOUT_PATTERN)
+	}
+	braces := 0
+	for p.peekIs('{') {
+		braces++
+		p.pos++
+		if p.end() {
+			return nil, p.errorf(ERR_INVALID_CALLOUT_PATTERN)
+		}
+	}
+	contentsStart, contentsEnd := p.pos, p.pos
+	for {
+		if p.end() {
+			return nil, p.errorf(ERR_INVALID_CALLOUT_PATTERN)
+		}
+		contentsEnd = p.pos
+		if p.fetch() != '}' {
+			continue
+		}
+		i := braces
+		for i > 0 {
+			if p.end() {
+				return nil, p.errorf(ERR_INVALID_CALLOUT_PATTERN)
+			}
+			if p.fetch() != '}' {
+				break
+			}
+			i--
+		}
+		if i == 0 {
+			break
+		}
+	}
+	if p.end() {
+		return nil, p.errorf(ERR_END_PATTERN_IN_GROUP)
+	}
+	c := p.fetch()
+	callout := &Callout{Contents: p.pattern[contentsStart:contentsEnd]}
+	if c == '[' {
+		tag, err := p.calloutTag()
+		if err != nil {
+			return nil, err
+		}
+		callout.Tag = tag
+		c = p.fetch()
+	}
+	if c == 'X' || c == '<' || c == '>' {
+		if p.end() {
+			return nil, p.errorf(ERR_END_PATTERN_IN_GROUP)
+		}
+		c = p.fetch()
+	}
+	if c != ')' {
+		return nil, p.errorf(ERR_INVALID_CALLOUT_PATTERN)
+	}
+	return p.addCallout(callout, start)
+}
+
+// calloutTag reads the tag of a callout, with the position after the opening bracket. It returns with the
+// position after the closing bracket, which isn't at the end of the pattern.
+func (p *parser) calloutTag() (string, error) {
+	if p.end() {
+		return "", p.errorf(ERR_END_PATTERN_IN_GROUP)
+	}
+	tagStart, tagEnd := p.pos, p.pos
+	for !p.end() {
+		tagEnd = p.pos
+		if p.fetch() == ']' {
+			break
+		}
+	}
+	tag := p.pattern[tagStart:tagEnd]
+	if !isCalloutName(tag) {
+		return "", p.errorf(ERR_INVALID_CALLOUT_TAG_NAME)
+	}
+	if p.end() {
+		return "", p.errorf(ERR_END_PATTERN_IN_GROUP)
+	}
+	return tag, nil
+}
+
+// addCallout records the tag of a callout that ends at the current position.
+func (p *parser) addCallout(callout *Callout, start int) (*Callout, error) {
+	callout.Span = Span{start, p.pos}
+	if callout.Tag != "" {
+		if p.tags[callout.Tag] {
+			return nil, p.errorf(ERR_MULTIPLEX_DEFINED_NAME)
+		}
+		p.tags[callout.Tag] = true
+	}
+	return callout, nil
+}
+
+// calloutOfName parses a callout by name such as (*FAIL) or (*MAX[tag]{10}), with the position after the
+// asterisk.
+func (p *parser) calloutOfName(start int) (*Callout, error) {
+	if p.end() {
+		return nil, p.errorf(ERR_INVALID_CALLOUT_PATTERN)
+	}
+	nameStart, nameEnd := p.pos, p.pos
+	var c rune
+	for {
+		if p.end() {
+			return nil, p.errorf(ERR_END_PATTERN_IN_GROUP)
+		}
+		nameEnd = p.pos
+		c = p.fetch()
+		if c == ')' || c == '[' || c == '{' {
+			break
+		}
+	}
+	callout := &Callout{Name: p.pattern[nameStart:nameEnd]}
+	if !isCalloutName(callout.Name) {
+		return nil, p.errorf(ERR_INVALID_CALLOUT_NAME)
+	}
+	if c == '[' {
+		tag, err := p.calloutTag()
+		if err != nil {
+			return nil, err
+		}
+		callout.Tag = tag
+		c = p.fetch()
+	}
+	builtin, ok := builtinCallouts[callout.Name]
+	var tagArgs []string
+	if c == '{' {
+		if p.end() {
+			return nil, p.errorf(ERR_END_PATTERN_IN_GROUP)
+		}
+		argsStart := p.pos
+		if _, _, err := p.calloutArgs(nil); err != nil {
+			return nil, err
+		}
+		if !p.peekIs(')') {
+			ok = false
+		}
+		if !ok {
+			return nil, p.errorf(ERR_UNDEFINED_CALLOUT_NAME)
+		}
+		p.pos = argsStart
+		args, tags, err := p.calloutArgs(builtin.args)
+		if err != nil {
+			return nil, err
+		}
+		callout.Args, tagArgs = args, tags
+		if p.end() {
+			return nil, p.errorf(ERR_END_PATTERN_IN_GROUP)
+		}
+		c = p.fetch()
+	} else if !ok {
+		return nil, p.errorf(ERR_UNDEFINED_CALLOUT_NAME)
+	}
+	if n := len(callout.Args); n > len(builtin.args) || n < len(builtin.args)-builtin.optional {
+		return nil, p.errorf(ERR_INVALID_CALLOUT_ARG)
+	}
+	if c != ')' {
+		return nil, p.errorf(ERR_INVALID_CALLOUT_PATTERN)
+	}
+	if _, err := p.addCallout(callout, start); err != nil {
+		return nil, err
+	}
+	for _, tag := range tagArgs {
+		p.tagRefs = append(p.tagRefs, tagRef{tag, start})
+	}
+	return callout, nil
+}
+
+// calloutArgs reads the arguments of a callout by name up to the closing brace, with the position after the
+// opening brace. When types is nil, the arguments are only scanned. It returns the arguments, and the ones that
+// are references to tags.
+func (p *parser) calloutArgs(types []calloutArgType) ([]string, []string, error) {
+	var args, tags []string
+	var c rune
+	for len(args) < calloutMaxArgs {
+		var buf strings.Builder
+		chars := 0
+		escaped := false
+		for {
+			if p.end() {
+				return nil, nil, p.errorf(ERR_INVALID_CALLOUT_PATTERN)
+			}
+			c = p.fetch()
+			if c == '\\' {
+				if p.end() {
+					return nil, nil, p.errorf(ERR_INVALID_CALLOUT_PATTERN)
+				}
+				c = p.fetch()
+				if c == '\\' || c == '}' || c == ',' {
+					buf.WriteRune(c)
+					chars++
+				} else {
+					buf.WriteByte('\\')
+					buf.WriteRune(c)
+					chars += 2
+					escaped = true
+				}
+			} else if c == '}' || c == ',' {
+				break
+			} else {
+				buf.WriteRune(c)
+				chars++
+			}
+			if buf.Len() > calloutMaxArgLen {
+				return nil, nil, p.errorf(ERR_INVALID_CALLOUT_ARG)
+			}
+		}
+		if chars != 0 {
+			arg := buf.String()
+			if types != nil {
+				n := len(args)
+				if n >= len(types) {
+					return nil, nil, p.errorf(ERR_INVALID_CALLOUT_ARG)
+				}
+				t := types[n]
+				if t&argLong != 0 {
+					if parseCalloutLong(arg) {
+						t = argLong
+					} else if t &^= argLong; t == 0 {
+						return nil, nil, p.errorf(ERR_INVALID_CALLOUT_ARG)
+					}
+				}
+				switch t {
+				case argChar:
+					if chars != 1 {
+						return nil, nil, p.errorf(ERR_INVALID_CALLOUT_ARG)
+					}
+				case argTag:
+					if escaped || !isCalloutName(arg) {
+						return nil, nil, p.errorf(ERR_INVALID_CALLOUT_TAG_NAME)
+					}
+					tags = append(tags, arg)
+				}
+			}
+			args = append(args, arg)
+		}
+		if c == '}' {
+			break
+		}
+	}
+	if c != '}' {
+		return nil, nil, p.errorf(ERR_INVALID_CALLOUT_PATTERN)
+	}
+	return args, tags, nil
+}
+
+// parseCalloutLong reports whether s is a number argument, made of digits and signs.
+func parseCalloutLong(s string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r == '+' || r == '-' {
+			return -1
+		}
+		return r
+	}, s)
+	if digits == "" {
+		return false
+	}
+	for _, r := range digits {
+		if !isDigit(r) {
+			return false
+		}
+	}
+	_, err := strconv.ParseInt(digits, 10, 64)
+	return err == nil
+}
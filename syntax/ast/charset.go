@@ -0,0 +1,408 @@
+package ast
+
+import "unicode"
+
+// charSet is a set of characters as Oniguruma compiles a character class: a bitset of the single byte
+// characters, a set of multibyte characters that is nil when empty, and a flag for a negated class.
+// Oniguruma tells whether two sets are exclusive by looking at this representation.
+type charSet struct {
+	bs  [4]uint64
+	mb  func(rune) bool
+	not bool
+}
+
+func (s *charSet) bit(c rune) bool {
+	return s.bs[c>>6]&(1<<(c&63)) != 0
+}
+
+func (s *charSet) setBit(c rune) {
+	s.bs[c>>6] |= 1 << (c & 63)
+}
+
+// addMultibyte adds the multibyte characters for which f is true.
+func (s *charSet) addMultibyte(f func(rune) bool) {
+	if prev := s.mb; prev != nil {
+		s.mb = func(c rune) bool { return prev(c) || f(c) }
+	} else {
+		s.mb = f
+	}
+}
+
+// contains reports whether the set matches the character, as onig_is_code_in_cc does.
+func (s *charSet) contains(c rune) bool {
+	found := false
+	if c < 0x80 {
+		found = s.bit(c)
+	} else if s.mb != nil {
+		found = s.mb(c)
+	}
+	return found != s.not
+}
+
+func (s *charSet) addCode(c rune) {
+	if c < 0x80 {
+		s.setBit(c)
+		return
+	}
+	s.addMultibyte(func(r rune) bool { return r == c })
+}
+
+// addRange adds a range of characters. As in Oniguruma, a range between a single byte and a multibyte
+// character is added to both parts of the set.
+func (s *charSet) addRange(from, to rune) {
+	if from < 0x80 {
+		for c := from; c <= to && c <= 0xff; c++ {
+			s.setBit(c)
+		}
+		if to < 0x80 {
+			return
+		}
+	}
+	s.addMultibyte(func(r rune) bool { return r >= from && r <= to })
+}
+
+// addType adds the characters of a character type or property, or the ones that are not in it when not is set.
+// In ASCII mode only the ASCII characters are in the type. hasMultibyte tells whether the type has members
+// outside of ASCII.
+func (s *charSet) addType(f func(rune) bool, ascii, not, hasMultibyte bool) {
+	for c := rune(0); c < 0x80; c++ {
+		if f(c) != not {
+			s.setBit(c)
+		}
+	}
+	switch {
+	case not && ascii:
+		s.addMultibyte(func(rune) bool { return true })
+	case not:
+		s.addMultibyte(func(c rune) bool { return !f(c) })
+	case !ascii && hasMultibyte:
+		s.addMultibyte(f)
+	}
+}
+
+// or adds the characters of another set.
+func (s *charSet) or(o *charSet) {
+	for i := range s.bs {
+		if o.not {
+			s.bs[i] |= ^o.bs[i]
+		} else {
+			s.bs[i] |= o.bs[i]
+		}
+	}
+	if o.not {
+		s.addMultibyte(func(c rune) bool { return o.mb == nil || !o.mb(c) })
+	} else if o.mb != nil {
+		s.addMultibyte(o.mb)
+	}
+}
+
+// and keeps the characters that are also in another set.
+func (s *charSet) and(o *charSet) {
+	for i := range s.bs {
+		if o.not {
+			s.bs[i] &= ^o.bs[i]
+		} else {
+			s.bs[i] &= o.bs[i]
+		}
+	}
+	prev := s.mb
+	switch {
+	case prev == nil:
+	case o.not:
+		s.mb = func(c rune) bool { return prev(c) && (o.mb == nil || !o.mb(c)) }
+	case o.mb == nil:
+		s.mb = nil
+	default:
+		s.mb = func(c rune) bool { return prev(c) && o.mb(c) }
+	}
+}
+
+// caseFoldClosure adds the characters that are case fold equivalents of the characters of the set.
+func (s *charSet) caseFoldClosure(asciiOnly bool) {
+	base := *s
+	base.not = false
+	inOrbit := func(c rune) bool {
+		for r := caseFoldNext(c, asciiOnly); r != c; r = caseFoldNext(r, asciiOnly) {
+			if base.contains(r) {
+				return true
+			}
+		}
+		return false
+	}
+	multibyte := false
+	for c := rune(0); c < 0x80; c++ {
+		if base.contains(c) {
+			for r := caseFoldNext(c, asciiOnly); r != c; r = caseFoldNext(r, asciiOnly) {
+				multibyte = multibyte || r >= 0x80
+			}
+		} else if inOrbit(c) {
+			s.setBit(c)
+		}
+	}
+	if base.mb != nil || multibyte {
+		s.addMultibyte(inOrbit)
+	}
+}
+
+// caseFoldNext returns the next character of the simple case folding orbit of c.
+func caseFoldNext(c rune, asciiOnly bool) rune {
+	if asciiOnly {
+		switch {
+		case c >= 'a' && c <= 'z':
+			return c - 'a' + 'A'
+		case c >= 'A' && c <= 'Z':
+			return c - 'A' + 'a'
+		}
+		return c
+	}
+	return unicode.SimpleFold(c)
+}
+
+// hasCaseFold reports whether c has a case fold equivalent of one character.
+func hasCaseFold(c rune, asciiOnly bool) bool {
+	return caseFoldNext(c, asciiOnly) != c
+}
+
+// newClassSet returns the set of characters of a class. Case folding is applied to the outermost class only,
+// as in Oniguruma.
+func newClassSet(cls *Class, icASCII bool) *charSet {
+	s := &charSet{}
+	for _, item := range cls.Items {
+		switch n := item.(type) {
+		case *Literal:
+			s.addCode(n.Char)
+		case *ClassRange:
+			s.addRange(n.From.Char, n.To.Char)
+		case *CharType:
+			f, hasMultibyte := charTypeFunc(n.Kind)
+			s.addType(f, n.ASCII, n.Negated, hasMultibyte)
+		case *Property:
+			f, hasMultibyte := propertyFunc(n.Name)
+			s.addType(f, false, n.Negated, hasMultibyte)
+		case *PosixClass:
+			f, hasMultibyte := propertyFunc(n.Name)
+			s.addType(f, n.ASCII, n.Negated, hasMultibyte)
+		case *Class:
+			nested := *n
+			nested.IgnoreCase = false
+			s.or(newClassSet(&nested, icASCII))
+		case *ClassIntersection:
+			var result *charSet
+			for _, operand := range n.Operands {
+				o := *operand
+				o.IgnoreCase = false
+				set := newClassSet(&o, icASCII)
+				if result == nil {
+					result = set
+				} else {
+					result.and(set)
+				}
+			}
+			s.or(result)
+		}
+	}
+	if cls.IgnoreCase {
+		s.caseFoldClosure(icASCII)
+	}
+	s.not = cls.Negated
+	return s
+}
+
+// newCharTypeSet returns the set of characters of a character type outside of a class, which Oniguruma
+// compiles to a class except for \w.
+func newCharTypeSet(ct *CharType) *charSet {
+	f, hasMultibyte := charTypeFunc(ct.Kind)
+	s := &charSet{}
+	s.addType(f, ct.ASCII, false, hasMultibyte)
+	s.not = ct.Negated
+	return s
+}
+
+// newPropertySet returns the set of characters of a property outside of a class.
+func newPropertySet(prop *Property, ignoreCase, icASCII bool) *charSet {
+	f, hasMultibyte := propertyFunc(prop.Name)
+	s := &charSet{}
+	s.addType(f, false, false, hasMultibyte)
+	if ignoreCase {
+		s.caseFoldClosure(icASCII)
+	}
+	s.not = prop.Negated
+	return s
+}
+
+// isWord reports whether c matches \w.
+func isWord(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsMark(c) || unicode.Is(unicode.Nd, c) || unicode.Is(unicode.Pc, c) ||
+		unicode.Is(unicode.Nl, c) || unicode.Is(unicode.Other_Alphabetic, c) || unicode.Is(unicode.Join_Control, c)
+}
+
+// isASCIIWord reports whether c matches \w when it only matches ASCII.
+func isASCIIWord(c rune) bool {
+	return c < 0x80 && isWord(c)
+}
+
+// charTypeFunc returns the function telling whether a character is of a character type, and whether the type
+// has characters outside of ASCII.
+func charTypeFunc(kind CharTypeKind) (func(rune) bool, bool) {
+	switch kind {
+	case CharTypeWord:
+		return isWord, true
+	case CharTypeDigit:
+		return func(c rune) bool { return unicode.Is(unicode.Nd, c) }, true
+	case CharTypeSpace:
+		return func(c rune) bool { return unicode.Is(unicode.White_Space, c) }, true
+	}
+	return isHexDigit, false
+}
+
+// categoryNames are the long names of the general categories.
+var categoryNames = map[string]string{
+	"letter": "L", "casedletter": "LC", "uppercaseletter": "Lu", "lowercaseletter": "Ll", "titlecaseletter": "Lt",
+	"modifierletter": "Lm", "otherletter": "Lo", "mark": "M", "combiningmark": "M", "nonspacingmark": "Mn",
+	"spacingmark": "Mc", "enclosingmark": "Me", "number": "N", "decimalnumber": "Nd", "letternumber": "Nl",
+	"othernumber": "No", "punctuation": "P", "connectorpunctuation": "Pc", "dashpunctuation": "Pd",
+	"openpunctuation": "Ps", "closepunctuation": "Pe", "initialpunctuation": "Pi", "finalpunctuation": "Pf",
+	"otherpunctuation": "Po", "symbol": "S", "mathsymbol": "Sm", "currencysymbol": "Sc", "modifiersymbol": "Sk",
+	"othersymbol": "So", "separator": "Z", "spaceseparator": "Zs", "lineseparator": "Zl",
+	"paragraphseparator": "Zp", "other": "C", "control": "Cc", "format": "Cf", "surrogate": "Cs",
+	"privateuse": "Co", "unassigned": "Cn",
+}
+
+// scriptCodes are the short names of common scripts.
+var scriptCodes = map[string]string{
+	"arab": "Arabic", "armn": "Armenian", "beng": "Bengali", "cyrl": "Cyrillic", "deva": "Devanagari",
+	"geor": "Georgian", "grek": "Greek", "gujr": "Gujarati", "guru": "Gurmukhi", "hang": "Hangul", "hani": "Han",
+	"hebr": "Hebrew", "hira": "Hiragana", "kana": "Katakana", "khmr": "Khmer", "knda": "Kannada", "laoo": "Lao",
+	"latn": "Latin", "mlym": "Malayalam", "mong": "Mongolian", "mymr": "Myanmar", "orya": "Oriya",
+	"sinh": "Sinhala", "syrc": "Syriac", "taml": "Tamil", "telu": "Telugu", "thaa": "Thaana", "thai": "Thai",
+	"tibt": "Tibetan", "zinh": "Inherited", "qaai": "Inherited", "zyyy": "Common",
+}
+
+// propertyTables are the Unicode tables of Go by normalized name: general categories, scripts and properties.
+var propertyTables = map[string]*unicode.RangeTable{}
+
+func init() {
+	for _, tables := range []map[string]*unicode.RangeTable{unicode.Categories, unicode.Scripts, unicode.Properties} {
+		for name, table := range tables {
+			if key, ok := propertyKey(name); ok {
+				propertyTables[key] = table
+			}
+		}
+	}
+	for name, category := range categoryNames {
+		propertyTables[name] = unicode.Categories[category]
+	}
+	for code, script := range scriptCodes {
+		propertyTables[code] = unicode.Scripts[script]
+	}
+}
+
+// propertyFunc returns the function telling whether a character has a property or is in a POSIX bracket, and
+// whether the property has characters outside of ASCII. It approximates the Unicode data of Oniguruma with the
+// tables of Go, and returns a function matching no character for the properties Go doesn't know.
+func propertyFunc(name string) (func(rune) bool, bool) {
+	key, _ := propertyKey(name)
+	switch key {
+	case "alnum":
+		return func(c rune) bool { return isAlphabetic(c) || unicode.Is(unicode.Nd, c) }, true
+	case "alpha", "alphabetic":
+		return isAlphabetic, true
+	case "blank":
+		return func(c rune) bool { return c == '\t' || unicode.Is(unicode.Zs, c) }, true
+	case "cntrl":
+		return func(c rune) bool { return unicode.Is(unicode.Cc, c) }, true
+	case "digit":
+		return func(c rune) bool { return unicode.Is(unicode.Nd, c) }, true
+	case "graph":
+		return func(c rune) bool { return unicode.IsGraphic(c) && !unicode.Is(unicode.Zs, c) }, true
+	case "print":
+		return unicode.IsGraphic, true
+	case "lower", "lowercase":
+		return func(c rune) bool { return unicode.IsLower(c) || unicode.Is(unicode.Other_Lowercase, c) }, true
+	case "upper", "uppercase":
+		return func(c rune) bool { return unicode.IsUpper(c) || unicode.Is(unicode.Other_Uppercase, c) }, true
+	case "punct":
+		return func(c rune) bool { return unicode.IsPunct(c) || c < 0x80 && unicode.IsSymbol(c) }, true
+	case "space", "whitespace", "wspace":
+		return func(c rune) bool { return unicode.Is(unicode.White_Space, c) }, true
+	case "xdigit", "asciihexdigit", "ahex":
+		return isHexDigit, false
+	case "ascii":
+		return func(c rune) bool { return c < 0x80 }, false
+	case "word":
+		return isWord, true
+	case "any":
+		return func(rune) bool { return true }, true
+	case "assigned":
+		return isAssigned, true
+	case "lc", "casedletter":
+		return func(c rune) bool { return unicode.In(c, unicode.Lu, unicode.Ll, unicode.Lt) }, true
+	case "cn", "unassigned":
+		return func(c rune) bool { return !isAssigned(c) }, true
+	}
+	if table := propertyTables[key]; table != nil {
+		return func(c rune) bool { return unicode.Is(table, c) }, !isASCIITable(table)
+	}
+	return func(rune) bool { return false }, true
+}
+
+func isAssigned(c rune) bool {
+	return unicode.In(c, unicode.L, unicode.M, unicode.N, unicode.P, unicode.S, unicode.Z, unicode.C)
+}
+
+func isAlphabetic(c rune) bool {
+	return unicode.IsLetter(c) || unicode.Is(unicode.Nl, c) || unicode.Is(unicode.Other_Alphabetic, c)
+}
+
+// isASCIITable reports whether a table only has ASCII characters.
+func isASCIITable(table *unicode.RangeTable) bool {
+	if len(table.R32) > 0 {
+		return false
+	}
+	return len(table.R16) > 0 && table.R16[len(table.R16)-1].Hi < 0x80
+}
+
+// multiCharFoldStrings returns the case folds of several characters of the characters of a set, which
+// Oniguruma adds as alternatives to a case insensitive class.
+func multiCharFoldStrings(s *charSet) []string {
+	var folds []string
+	for c, fold := range multiCharFolds {
+		if s.contains(c) {
+			folds = append(folds, fold)
+		}
+	}
+	return folds
+}
+
+// isMultiCharFoldTarget reports whether the characters are the case fold of a single character.
+func isMultiCharFoldTarget(chars []rune, asciiOnly bool) bool {
+	for _, fold := range multiCharFolds {
+		target := []rune(fold)
+		if len(target) != len(chars) {
+			continue
+		}
+		match := true
+		for i, c := range chars {
+			if !foldEqual(c, target[i], asciiOnly) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// foldEqual reports whether two characters are equal under simple case folding.
+func foldEqual(a, b rune, asciiOnly bool) bool {
+	for r := caseFoldNext(a, asciiOnly); ; r = caseFoldNext(r, asciiOnly) {
+		if r == b {
+			return true
+		}
+		if r == a {
+			return false
+		}
+	}
+}
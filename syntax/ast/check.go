@@ -0,0 +1,617 @@
+package ast
+
+// Oniguruma checks a pattern further once it has parsed it, on a tree of its own that differs from the syntax
+// tree: non-capturing groups are dropped, consecutive characters are merged into strings, nested quantifiers are
+// reduced, and character classes are compiled. Some errors depend on the shape of that tree, such as whether a
+// look-behind has a fixed length, so the checks are done on a copy of it built from the syntax tree.
+
+// nodeType is the type of a node of the tree the checks are done on.
+type nodeType int
+
+const (
+	ndString nodeType = iota
+	ndClass
+	// ndCType is \w or \W, which Oniguruma doesn't compile to a class.
+	ndCType
+	ndAnyChar
+	ndBackref
+	ndQuant
+	ndBag
+	ndAnchor
+	ndList
+	ndAlt
+	ndCall
+	ndGimmick
+	ndAbsent
+	// ndPending is a part of the syntax tree not converted yet, such as the body of a quantifier.
+	ndPending
+)
+
+// bagType is the type of an ndBag node.
+type bagType int
+
+const (
+	bagMemory bagType = iota
+	bagOptions
+	bagStopBacktrack
+	bagIfElse
+)
+
+// lookType is the look-around of an ndAnchor node, which has a body.
+type lookType int
+
+const (
+	lookNone lookType = iota
+	lookAhead
+	lookAheadNot
+	lookBehind
+	lookBehindNot
+)
+
+// repeatInfinite is the upper count of a quantifier without upper bound.
+const repeatInfinite = -1
+
+// inode is a node of the tree the checks are done on, as Oniguruma builds it.
+type inode struct {
+	typ  nodeType
+	span Span
+	src  Node
+
+	// ndString: the characters, and whether they are matched case insensitively or given as raw bytes.
+	chars      []rune
+	ignoreCase bool
+	crude      bool
+	// ndClass
+	set *charSet
+	// ndCType
+	not   bool
+	ascii bool
+	// ndQuant
+	lower      int
+	upper      int
+	greedy     bool
+	possessive bool
+	dropped    bool
+	// ndBag
+	bag    bagType
+	regnum int
+	then   *inode
+	els    *inode
+	// ndAnchor
+	anchor AnchorKind
+	look   lookType
+	// ndBackref
+	refs      []int
+	checker   bool
+	nestLevel bool
+	byName    bool
+	// ndCall
+	call   *Call
+	target *inode
+	// ndGimmick
+	keep bool
+	// ndAbsent: oneCharRepeat is set when the expression of (?~|absent|exp) is a greedy repetition of one
+	// character, which Oniguruma matches without the general absent engine, up to repeatUpper times.
+	absent        AbsentKind
+	oneCharRepeat bool
+	repeatUpper   int
+
+	body *inode
+	kids []*inode
+
+	// The status of the node during the checks.
+	mark1     bool
+	mark2     bool
+	recursion bool
+	called    bool
+	// referenced is set on a group that a back reference or a call refers to, even one that is never matched.
+	referenced bool
+	// Lengths cached on memory nodes.
+	fixedMin       bool
+	minByteLen     uint32
+	fixedCharLen   bool
+	charLenMinSure bool
+	minCharLen     uint32
+	maxCharLen     uint32
+	// tuned is set on a case insensitive string once its case folding is expanded outside of a look-behind, or
+	// once it is tuned when it is made of raw bytes, with the lengths it can match in minCharLen and maxCharLen.
+	tuned bool
+}
+
+// checker holds the state of the checks of a parsed pattern.
+type checker struct {
+	p *parser
+	// mems are the memory nodes by group number.
+	mems  []*inode
+	calls []*inode
+	refs  []*inode
+	// onlyNamed is set when unnamed groups don't capture because of named groups.
+	onlyNamed bool
+	// uncaptured are the unnamed groups that don't capture because of named groups.
+	uncaptured map[*Group]bool
+	icASCII    bool
+}
+
+// check does the checks of Oniguruma on a parsed pattern, in the order Oniguruma does them.
+func (p *parser) check(tree *Tree, options Options) error {
+	// A call to the whole pattern wraps it in a group, so that its options for the whole pattern aren't at the top.
+	if p.whole != nil && (p.hasCallZero || unwrapGroup(tree.Root) != p.whole) {
+		return &Error{Code: ERR_INVALID_GROUP_OPTION, Offset: p.whole.Pos().Start}
+	}
+	for _, ref := range p.tagRefs {
+		if !p.tags[ref.name] {
+			return &Error{Code: ERR_INVALID_CALLOUT_TAG_NAME, Offset: ref.offset}
+		}
+	}
+	c := &checker{p: p, mems: make([]*inode, p.captures+1), uncaptured: map[*Group]bool{}}
+	c.icASCII = options&OPTION_IGNORECASE_IS_ASCII != 0
+	switch w := p.whole.(type) {
+	case *Flags:
+		c.icASCII = c.icASCII || w.On&OPTION_IGNORECASE_IS_ASCII != 0
+		options |= w.On
+	case *Group:
+		c.icASCII = c.icASCII || w.On&OPTION_IGNORECASE_IS_ASCII != 0
+		options |= w.On
+	}
+	c.onlyNamed = p.flavor.Behavior&SYN_CAPTURE_ONLY_NAMED_GROUP != 0 && p.numNamed > 0 &&
+		options&OPTION_CAPTURE_GROUP == 0
+	if c.onlyNamed && p.numNamed != p.captures {
+		c.disableUnnamedGroups(tree)
+	}
+	root, err := c.convert(tree.Root)
+	if err != nil {
+		return err
+	}
+	if err := c.checkBackrefs(tree.Captures); err != nil {
+		return err
+	}
+	if p.hasCallZero {
+		root = &inode{typ: ndBag, bag: bagMemory, span: root.span, body: root}
+		c.mems[0] = root
+	}
+	if len(c.calls) > 0 {
+		if err := c.tuneCalls(tree); err != nil {
+			return err
+		}
+		markCalled(root)
+		c.recursiveCallCheckTrav(root, false)
+		if err := c.infiniteRecursiveCallCheckTrav(root); err != nil {
+			return err
+		}
+	}
+	return c.tune(root, 0)
+}
+
+// disableUnnamedGroups makes the unnamed groups non-capturing, and renumbers the named groups and the
+// references to them.
+func (c *checker) disableUnnamedGroups(tree *Tree) {
+	renumber := make([]int, tree.Captures+1)
+	count := 0
+	Walk(tree.Root, func(node Node) bool {
+		if g, ok := node.(*Group); ok && g.Kind == GroupCapture {
+			if g.Name == "" {
+				c.uncaptured[g] = true
+				g.Kind = GroupNonCapture
+				renumber[g.Index] = 0
+				g.Index = 0
+			} else {
+				count++
+				renumber[g.Index] = count
+				g.Index = count
+			}
+		}
+		return true
+	})
+	Walk(tree.Root, func(node Node) bool {
+		if ref, ok := node.(*Backref); ok && ref.Name != "" {
+			for i, num := range ref.Groups {
+				ref.Groups[i] = renumber[num]
+			}
+		}
+		return true
+	})
+	for name, groups := range tree.Names {
+		for i, num := range groups {
+			groups[i] = renumber[num]
+		}
+		tree.Names[name] = groups
+	}
+	tree.Captures = count
+	c.mems = make([]*inode, count+1)
+}
+
+// checkBackrefs checks the groups the back references refer to, and marks them as referenced.
+func (c *checker) checkBackrefs(captures int) error {
+	if c.onlyNamed {
+		for _, ref := range c.refs {
+			if !ref.byName {
+				return &Error{Code: ERR_NUMBERED_BACKREF_OR_CALL_NOT_ALLOWED, Offset: ref.span.Start}
+			}
+		}
+	}
+	for _, ref := range c.refs {
+		for _, num := range ref.refs {
+			if num > captures {
+				return &Error{Code: ERR_INVALID_BACKREF, Offset: ref.span.Start}
+			}
+			c.mems[num].referenced = true
+		}
+	}
+	return nil
+}
+
+// tuneCalls finds the groups the calls refer to.
+func (c *checker) tuneCalls(tree *Tree) error {
+	for _, call := range c.calls {
+		n := call.call
+		num := n.Group
+		if n.Name != "" {
+			groups := tree.Names[n.Name]
+			switch {
+			case len(groups) == 0:
+				return &Error{Code: ERR_UNDEFINED_NAME_REFERENCE, Offset: n.Start}
+			case len(groups) > 1:
+				return &Error{Code: ERR_MULTIPLEX_DEFINITION_NAME_CALL, Offset: n.Start}
+			}
+			num = groups[0]
+		} else {
+			if c.onlyNamed && c.p.numberedCalls[n] {
+				return &Error{Code: ERR_NUMBERED_BACKREF_OR_CALL_NOT_ALLOWED, Offset: n.Start}
+			}
+			if num > tree.Captures {
+				return &Error{Code: ERR_UNDEFINED_GROUP_REFERENCE, Offset: n.Start}
+			}
+		}
+		call.target = c.mems[num]
+		call.target.referenced = true
+	}
+	return nil
+}
+
+// markCalled marks the groups that are called, as tune_call2 does. The calls in a part of the pattern repeated
+// zero times don't count, unless that part is called.
+func markCalled(n *inode) {
+	switch n.typ {
+	case ndQuant:
+		if n.upper == 0 {
+			return
+		}
+	case ndCall:
+		markCalledTarget(n)
+		return
+	case ndAbsent:
+		if n.skipsAbsent() {
+			markCalled(n.kids[1])
+			return
+		}
+	}
+	for _, kid := range n.children() {
+		markCalled(kid)
+	}
+}
+
+func markCalledTarget(n *inode) {
+	if n.mark1 {
+		return
+	}
+	n.mark1 = true
+	defer func() { n.mark1 = false }()
+	switch n.typ {
+	case ndCall:
+		n.target.called = true
+		markCalledTarget(n.target)
+		return
+	}
+	for _, kid := range n.children() {
+		markCalledTarget(kid)
+	}
+}
+
+// convert builds the tree of Oniguruma from a node of the syntax tree.
+func (c *checker) convert(node Node) (*inode, error) {
+	span := node.Pos()
+	switch n := node.(type) {
+	case *Literal:
+		return &inode{typ: ndString, span: span, chars: []rune{n.Char}, ignoreCase: n.IgnoreCase, crude: n.Byte}, nil
+	case *AnyChar:
+		return &inode{typ: ndAnyChar, span: span}, nil
+	case *CharType:
+		if n.Kind == CharTypeWord {
+			return &inode{typ: ndCType, span: span, not: n.Negated, ascii: n.ASCII}, nil
+		}
+		return &inode{typ: ndClass, span: span, set: newCharTypeSet(n)}, nil
+	case *Property:
+		return &inode{typ: ndClass, span: span, set: newPropertySet(n, false, false)}, nil
+	case *Class:
+		return c.convertClass(n), nil
+	case *Group:
+		return c.convertGroup(n)
+	case *Flags:
+		body, err := c.convert(n.Sub)
+		if err != nil || c.p.continued[n] {
+			return body, err
+		}
+		return &inode{typ: ndBag, bag: bagOptions, span: span, body: body}, nil
+	case *Quantifier:
+		return c.convertQuantifier(n)
+	case *Alternation:
+		alt := &inode{typ: ndAlt, span: span}
+		for _, alternative := range n.Alternatives {
+			kid, err := c.convert(alternative)
+			if err != nil {
+				return nil, err
+			}
+			alt.kids = append(alt.kids, kid)
+		}
+		return alt, nil
+	case *Concat:
+		return c.convertConcat(n)
+	case *Backref:
+		ref := &inode{typ: ndBackref, span: span, refs: n.Groups, checker: c.p.checkers[n],
+			recursion: c.p.recursiveRefs[n], nestLevel: n.HasLevel, byName: n.Name != ""}
+		c.refs = append(c.refs, ref)
+		return ref, nil
+	case *Call:
+		call := &inode{typ: ndCall, span: span, call: n}
+		c.calls = append(c.calls, call)
+		return call, nil
+	case *Anchor:
+		return &inode{typ: ndAnchor, span: span, anchor: n.Kind}, nil
+	case *Keep:
+		return &inode{typ: ndGimmick, span: span, keep: true}, nil
+	case *GeneralNewline:
+		// \R is (?>\x0D\x0A|[\x0A-\x0D\x{85}\x{2028}\x{2029}]).
+		set := &charSet{}
+		set.addRange(0x0a, 0x0d)
+		for _, code := range []rune{0x85, 0x2028, 0x2029} {
+			set.addCode(code)
+		}
+		alt := &inode{typ: ndAlt, span: span, kids: []*inode{
+			{typ: ndString, span: span, chars: []rune{0x0d, 0x0a}},
+			{typ: ndClass, span: span, set: set},
+		}}
+		return &inode{typ: ndBag, bag: bagStopBacktrack, span: span, body: alt}, nil
+	case *TextSegment:
+		// \X is (?>\O(?:\Y\O)*).
+		rest := &inode{typ: ndList, span: span, kids: []*inode{
+			{typ: ndAnchor, span: span, anchor: AnchorNotTextSegmentBoundary},
+			{typ: ndAnyChar, span: span},
+		}}
+		list := &inode{typ: ndList, span: span, kids: []*inode{
+			{typ: ndAnyChar, span: span},
+			{typ: ndQuant, span: span, upper: repeatInfinite, greedy: true, body: rest},
+		}}
+		return &inode{typ: ndBag, bag: bagStopBacktrack, span: span, body: list}, nil
+	case *Absent:
+		absent := &inode{typ: ndAbsent, span: span, absent: n.Kind}
+		for _, sub := range []Node{n.Absent, n.Expr} {
+			if sub == nil {
+				continue
+			}
+			kid, err := c.convert(sub)
+			if err != nil {
+				return nil, err
+			}
+			absent.kids = append(absent.kids, kid)
+		}
+		if c.isClass(n.Absent) && n.Kind == AbsentStopper && absent.kids[0].typ == ndAlt {
+			// Oniguruma takes the alternation of the class for the absent and the expression of (?~|absent|exp).
+			absent.absent = AbsentExpression
+			absent.kids = splitClassAlt(absent.kids[0])
+		}
+		if absent.absent == AbsentExpression && len(absent.kids) > 1 {
+			if q := oneCharRepeat(absent.kids[1]); q != nil {
+				absent.oneCharRepeat, absent.repeatUpper = true, q.upper
+			}
+		}
+		return absent, nil
+	case *Conditional:
+		return c.convertConditional(n)
+	case *Callout:
+		return &inode{typ: ndGimmick, span: span}, nil
+	}
+	return &inode{typ: ndString, span: span}, nil
+}
+
+// oneCharRepeat returns the repetition when a node is a greedy repetition of one character or class, possibly
+// possessive, as is_simple_one_char_repeat does.
+func oneCharRepeat(n *inode) *inode {
+	if n.typ == ndBag && n.bag == bagStopBacktrack {
+		n = n.body
+	}
+	if n.typ != ndQuant || !n.greedy {
+		return nil
+	}
+	switch n.body.typ {
+	case ndString:
+		if len(n.body.chars) == 1 {
+			return n
+		}
+	case ndClass:
+		return n
+	}
+	return nil
+}
+
+// isClass reports whether a node is a class, possibly after isolated options that continue into the following
+// alternatives.
+func (c *checker) isClass(node Node) bool {
+	for {
+		flags, ok := node.(*Flags)
+		if !ok || !c.p.continued[flags] {
+			break
+		}
+		node = flags.Sub
+	}
+	_, ok := node.(*Class)
+	return ok
+}
+
+// splitClassAlt splits the alternation of a case insensitive class that matches strings into the class and the
+// alternation of the strings, as Oniguruma does when it looks for two alternatives in a group.
+func splitClassAlt(alt *inode) []*inode {
+	rest := alt.kids[1]
+	if len(alt.kids) > 2 {
+		rest = &inode{typ: ndAlt, span: alt.span, kids: alt.kids[1:]}
+	}
+	return []*inode{alt.kids[0], rest}
+}
+
+// convertClass converts a class. A case insensitive class with characters whose case fold is several
+// characters long matches these strings too, which Oniguruma compares case insensitively when matching, and
+// so doesn't expand like case insensitive strings.
+func (c *checker) convertClass(cls *Class) *inode {
+	cc := &inode{typ: ndClass, span: cls.Span, set: newClassSet(cls, c.icASCII)}
+	if !cls.IgnoreCase || cls.Negated || c.icASCII {
+		return cc
+	}
+	folds := multiCharFoldStrings(cc.set)
+	if len(folds) == 0 {
+		return cc
+	}
+	alt := &inode{typ: ndAlt, span: cls.Span, kids: []*inode{cc}}
+	for _, fold := range folds {
+		alt.kids = append(alt.kids, &inode{typ: ndString, span: cls.Span, chars: []rune(fold)})
+	}
+	return alt
+}
+
+func (c *checker) convertGroup(g *Group) (*inode, error) {
+	body, err := c.convert(g.Sub)
+	if err != nil {
+		return nil, err
+	}
+	node := &inode{typ: ndBag, span: g.Span, body: body}
+	switch g.Kind {
+	case GroupCapture:
+		node.bag = bagMemory
+		node.regnum = g.Index
+		c.mems[g.Index] = node
+	case GroupNonCapture:
+		return body, nil
+	case GroupAtomic:
+		node.bag = bagStopBacktrack
+	case GroupOptions:
+		node.bag = bagOptions
+	default:
+		node.typ = ndAnchor
+		node.look = map[GroupKind]lookType{GroupLookahead: lookAhead, GroupNegativeLookahead: lookAheadNot,
+			GroupLookbehind: lookBehind, GroupNegativeLookbehind: lookBehindNot}[g.Kind]
+	}
+	return node, nil
+}
+
+func (c *checker) convertConcat(concat *Concat) (*inode, error) {
+	if c.p.stringRuns[concat] {
+		first := concat.Items[0].(*Literal)
+		str := &inode{typ: ndString, span: concat.Span, ignoreCase: first.IgnoreCase}
+		for _, item := range concat.Items {
+			str.chars = append(str.chars, item.(*Literal).Char)
+		}
+		return str, nil
+	}
+	if len(concat.Items) == 0 {
+		return &inode{typ: ndString, span: concat.Span}, nil
+	}
+	list := &inode{typ: ndList, span: concat.Span}
+	var add func(items []Node) error
+	add = func(items []Node) error {
+		for _, item := range items {
+			if flags, ok := item.(*Flags); ok && c.p.continued[flags] {
+				// The options continue into the rest of the branch.
+				if rest, ok := flags.Sub.(*Concat); ok && !c.p.stringRuns[rest] && len(rest.Items) > 0 {
+					if err := add(rest.Items); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			kid, err := c.convert(item)
+			if err != nil {
+				return err
+			}
+			list.kids = append(list.kids, kid)
+		}
+		return nil
+	}
+	if err := add(concat.Items); err != nil {
+		return nil, err
+	}
+	// Merge consecutive strings, as Oniguruma does.
+	kids := list.kids[:0]
+	for _, kid := range list.kids {
+		if n := len(kids); n > 0 && kid.typ == ndString && kids[n-1].typ == ndString &&
+			kid.ignoreCase == kids[n-1].ignoreCase && kid.crude == kids[n-1].crude {
+			prev := *kids[n-1]
+			prev.chars = append(append([]rune(nil), prev.chars...), kid.chars...)
+			prev.span.End = kid.span.End
+			kids[n-1] = &prev
+			continue
+		}
+		kids = append(kids, kid)
+	}
+	list.kids = kids
+	return list, nil
+}
+
+func (c *checker) convertQuantifier(q *Quantifier) (*inode, error) {
+	qn := c.p.qnodes[q]
+	if qn.dropped {
+		return c.convert(q.Sub)
+	}
+	if err := c.convertBody(qn); err != nil {
+		return nil, err
+	}
+	if qn.possessive {
+		return &inode{typ: ndBag, bag: bagStopBacktrack, span: q.Span, body: qn}, nil
+	}
+	return qn, nil
+}
+
+// convertBody converts the body of a quantifier, which is the body of the innermost quantifier it was reduced
+// with.
+func (c *checker) convertBody(qn *inode) error {
+	if qn.body.typ == ndQuant {
+		return c.convertBody(qn.body)
+	}
+	if qn.body.typ != ndPending {
+		return nil
+	}
+	src := qn.body.src
+	body, err := c.convert(src)
+	if err != nil {
+		return err
+	}
+	qn.body = body
+	if g, ok := c.p.transparent(src, c.uncaptured).(*Group); ok && c.uncaptured[g] && body.typ == ndQuant {
+		// The body was a group that doesn't capture anymore, which Oniguruma reduces once removed.
+		if !reduceNestedQuantifier(qn, body) {
+			return &Error{Code: ERR_TOO_BIG_NUMBER_FOR_REPEAT_RANGE, Offset: qn.span.End}
+		}
+	}
+	return nil
+}
+
+func (c *checker) convertConditional(cond *Conditional) (*inode, error) {
+	node := &inode{typ: ndBag, bag: bagIfElse, span: cond.Span}
+	var err error
+	if node.body, err = c.convert(cond.Cond); err != nil {
+		return nil, err
+	}
+	if cond.Yes != nil {
+		if node.then, err = c.convert(cond.Yes); err != nil {
+			return nil, err
+		}
+		if c.isClass(cond.Yes) && cond.No == nil && node.then.typ == ndAlt {
+			// Oniguruma takes the alternation of the class for both branches.
+			branches := splitClassAlt(node.then)
+			node.then, node.els = branches[0], branches[1]
+		}
+	}
+	if cond.No != nil {
+		if node.els, err = c.convert(cond.No); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
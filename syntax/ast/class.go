@@ -0,0 +1,626 @@
+package ast
+
+// fetchTokenClass reads the next token inside a character class.
+func (p *parser) fetchTokenClass() (*token, error) {
+	if len(p.pendingClass) > 0 {
+		tok := p.pendingClass[0]
+		p.pendingClass = p.pendingClass[1:]
+		return tok, nil
+	}
+	op, op2 := p.flavor.Op, p.flavor.Op2
+	tok := &token{start: p.pos}
+	if p.end() {
+		tok.kind = tokEOT
+		return tok, nil
+	}
+	c := p.fetch()
+	tok.kind = tokString
+	tok.code = c
+	switch c {
+	case ']':
+		tok.kind = tokClassClose
+	case '-':
+		tok.kind = tokClassRange
+	case '\\':
+		if p.flavor.Behavior&SYN_BACKSLASH_ESCAPE_IN_CC == 0 {
+			break
+		}
+		if p.end() {
+			return nil, p.errorf(ERR_END_PATTERN_AT_ESCAPE)
+		}
+		c = p.fetch()
+		tok.escaped = true
+		tok.code = c
+		switch c {
+		case 'w', 'W':
+			tok.kind, tok.ctype, tok.negated = tokCharType, CharTypeWord, c == 'W'
+		case 'd', 'D':
+			tok.kind, tok.ctype, tok.negated = tokCharType, CharTypeDigit, c == 'D'
+		case 's', 'S':
+			tok.kind, tok.ctype, tok.negated = tokCharType, CharTypeSpace, c == 'S'
+		case 'h', 'H':
+			if op2&SYN_OP2_ESC_H_XDIGIT != 0 {
+				tok.kind, tok.ctype, tok.negated = tokCharType, CharTypeHexDigit, c == 'H'
+			}
+		case 'p', 'P':
+			if p.peekIs('{') && op2&SYN_OP2_ESC_P_BRACE_CHAR_PROPERTY != 0 {
+				p.pos++
+				tok.kind = tokCharProperty
+				tok.negated = c == 'P'
+				if op2&SYN_OP2_ESC_P_BRACE_CIRCUMFLEX_NOT != 0 && p.peekIs('^') {
+					p.pos++
+					tok.negated = !tok.negated
+				}
+			}
+		case 'x', 'o':
+			if p.end() {
+				break
+			}
+			base := 16
+			if c == 'o' {
+				base = 8
+			}
+			if p.peekIs('{') && ((base == 16 && op&SYN_OP_ESC_X_BRACE_HEX8 != 0) || (base == 8 && op&SYN_OP_ESC_O_BRACE_OCTAL != 0)) {
+				p.pos++
+				ok, err := p.fetchBraceCodePoint(tok, base, true)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					p.queueClassCodes(tok)
+					break
+				}
+				// A brace that isn't a code point leaves the escaped letter.
+				p.pos--
+				break
+			}
+			if base == 16 && op&SYN_OP_ESC_X_HEX2 != 0 {
+				code, _, _ := p.scanBase(16, 2)
+				tok.kind = tokCrudeByte
+				tok.base = 16
+				tok.code = code
+			}
+		case 'u':
+			if p.end() {
+				break
+			}
+			if op2&SYN_OP2_ESC_U_HEX4 != 0 {
+				code, digits, _ := p.scanBase(16, 4)
+				if digits < 4 {
+					return nil, p.errorf(ERR_INVALID_CODE_POINT_VALUE)
+				}
+				tok.kind = tokCodePoint
+				tok.base = 16
+				tok.code = code
+			}
+		case 'U':
+			if p.end() {
+				break
+			}
+			if p.flavor.Behavior&SYN_PYTHON != 0 {
+				code, digits, err := p.scanBase(16, 8)
+				if err != nil {
+					return nil, err
+				}
+				if digits < 8 {
+					return nil, p.errorf(ERR_INVALID_CODE_POINT_VALUE)
+				}
+				tok.kind = tokCodePoint
+				tok.base = 16
+				tok.code = code
+			}
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			if op&SYN_OP_ESC_OCTAL3 != 0 {
+				p.pos--
+				code, _, _ := p.scanBase(8, 3)
+				if code >= 256 {
+					return nil, p.errorf(ERR_TOO_BIG_NUMBER)
+				}
+				tok.kind = tokCrudeByte
+				tok.base = 8
+				tok.code = code
+			}
+		default:
+			p.pos = tok.start + 1
+			value, err := p.fetchEscapedValue()
+			if err != nil {
+				return nil, err
+			}
+			if value != c {
+				tok.kind = tokCodePoint
+				tok.code = value
+			}
+		}
+	case '[':
+		if op&SYN_OP_POSIX_BRACKET != 0 && p.peekIs(':') {
+			tok.backp = p.pos
+			p.pos++
+			if p.posixBracketEndExists() {
+				tok.kind = tokClassPosixOpen
+				break
+			}
+			p.pos--
+		}
+		if op2&SYN_OP2_CCLASS_SET_OP != 0 {
+			tok.kind = tokClassOpen
+		}
+	case '&':
+		if op2&SYN_OP2_CCLASS_SET_OP != 0 && p.peekIs('&') {
+			p.pos++
+			tok.kind = tokClassAnd
+		}
+	}
+	return tok, nil
+}
+
+// queueClassCodes turns the code points after the first one of a \x{...} sequence inside a class into tokens,
+// with range tokens between the ends of ranges, to be returned as the next tokens.
+func (p *parser) queueClassCodes(tok *token) {
+	ranges := tok.ranges
+	for i, code := range tok.codes {
+		if len(ranges) > 0 && ranges[0] == i {
+			ranges = ranges[1:]
+			p.pendingClass = append(p.pendingClass, &token{kind: tokClassRange, start: tok.start, code: '-'})
+		}
+		p.pendingClass = append(p.pendingClass, &token{kind: tokCodePoint, start: tok.start, code: code, base: tok.base})
+	}
+	tok.codes, tok.ranges = nil, nil
+}
+
+// posixBracketEndExists reports whether :] follows before an unescaped ], from the current position.
+func (p *parser) posixBracketEndExists() bool {
+	inEscape := false
+	for i := p.pos; i < len(p.pattern); {
+		c, size := p.charAt(i)
+		if inEscape {
+			inEscape = false
+			i += size
+			continue
+		}
+		if c == ':' {
+			if next, _ := p.charAt(i + size); next == ']' {
+				return true
+			}
+			i += size
+			continue
+		}
+		if c == ']' {
+			return false
+		}
+		if c == '\\' {
+			inEscape = true
+		}
+		i += size
+	}
+	return false
+}
+
+// posixBrackets are the names of the POSIX brackets, in the order Oniguruma checks them.
+var posixBrackets = []string{"alnum", "alpha", "blank", "cntrl", "digit", "graph", "lower", "print", "punct", "space",
+	"upper", "xdigit", "ascii", "word"}
+
+// prsPosixBracket reads a POSIX bracket such as [:alpha:], with the position after the colon. It returns nil,
+// with the position unchanged, when the text is not a POSIX bracket.
+func (p *parser) prsPosixBracket(start int) (*PosixClass, error) {
+	save := p.pos
+	negated := false
+	if p.peekIs('^') {
+		p.pos++
+		negated = true
+	}
+	chars := 0
+	for i := p.pos; i < len(p.pattern) && chars < 7; chars++ {
+		_, size := p.charAt(i)
+		i += size
+	}
+	if chars >= 7 {
+		for _, name := range posixBrackets {
+			if len(p.pattern)-p.pos >= len(name) && p.pattern[p.pos:p.pos+len(name)] == name {
+				p.pos += len(name)
+				if len(p.pattern)-p.pos < 2 || p.pattern[p.pos:p.pos+2] != ":]" {
+					return nil, p.errorf(ERR_INVALID_POSIX_BRACKET_TYPE)
+				}
+				p.pos += 2
+				return &PosixClass{Span: Span{start, p.pos}, Name: name, Negated: negated}, nil
+			}
+		}
+	}
+	var c rune
+	for i := 0; !p.end(); {
+		c, _ = p.peek()
+		if c == ':' || c == ']' {
+			break
+		}
+		p.fetch()
+		if i++; i > 20 {
+			break
+		}
+	}
+	if c == ':' && !p.end() {
+		p.pos++
+		if !p.end() && p.fetch() == ']' {
+			return nil, p.errorf(ERR_INVALID_POSIX_BRACKET_TYPE)
+		}
+	}
+	p.pos = save
+	return nil, nil
+}
+
+// fetchProperty reads the name of a property such as \p{Alpha}, with the position after the opening brace.
+func (p *parser) fetchProperty(tok *token) (*Property, error) {
+	start := p.pos
+	for !p.end() {
+		prev := p.pos
+		c := p.fetch()
+		switch c {
+		case '}':
+			name := p.pattern[start:prev]
+			if key, ok := propertyKey(name); !ok || !propertyNames[key] {
+				return nil, &Error{Code: ERR_INVALID_CHAR_PROPERTY_NAME, Offset: start}
+			}
+			return &Property{Span: Span{tok.start, p.pos}, Name: name, Negated: tok.negated}, nil
+		case '(', ')', '{', '|':
+			return nil, p.errorf(ERR_END_PATTERN_WITH_UNMATCHED_PARENTHESIS)
+		}
+	}
+	return nil, p.errorf(ERR_END_PATTERN_WITH_UNMATCHED_PARENTHESIS)
+}
+
+// classState is the state of the parsing of a class, as in Oniguruma.
+type classState int
+
+const (
+	classStart classState = iota
+	classValue
+	classRange
+	classComplete
+)
+
+// classValueType is the type of the last value of a class: a single byte character, a multibyte character,
+// or a character type or property.
+type classValueType int
+
+const (
+	classUndef classValueType = iota
+	classSingleByte
+	classMultiByte
+	classProperty
+)
+
+// classBuilder holds the state of the class being parsed.
+type classBuilder struct {
+	class     *Class
+	state     classState
+	currType  classValueType
+	curr      *Literal
+	rangeFrom *Literal
+}
+
+// charNext adds the pending value of the class and makes lit the pending value, or the end of a range.
+func (p *parser) charNext(b *classBuilder, lit *Literal, inType classValueType) error {
+	switch b.state {
+	case classValue:
+		if b.currType == classSingleByte && b.curr.Char > 0xff {
+			return p.errorf(ERR_INVALID_CODE_POINT_VALUE)
+		}
+		if b.currType == classSingleByte || b.currType == classMultiByte {
+			b.class.Items = append(b.class.Items, b.curr)
+		}
+	case classRange:
+		from := b.curr
+		if inType == b.currType && inType == classSingleByte && (from.Char > 0xff || lit.Char > 0xff) {
+			return p.errorf(ERR_INVALID_CODE_POINT_VALUE)
+		}
+		if from.Char > lit.Char {
+			if p.flavor.Behavior&SYN_ALLOW_EMPTY_RANGE_IN_CC == 0 {
+				return p.errorf(ERR_EMPTY_RANGE_IN_CHAR_CLASS)
+			}
+		} else {
+			b.class.Items = append(b.class.Items, &ClassRange{Span: Span{from.Start, lit.End}, From: from, To: lit})
+		}
+		b.state = classComplete
+	case classComplete, classStart:
+		b.state = classValue
+	}
+	b.curr = lit
+	b.currType = inType
+	return nil
+}
+
+// cpropNext adds the pending value of the class before a character type or property.
+func (p *parser) cpropNext(b *classBuilder) error {
+	if b.state == classRange {
+		return p.errorf(ERR_CHAR_CLASS_VALUE_AT_END_OF_RANGE)
+	}
+	if b.state == classValue && b.currType != classProperty {
+		b.class.Items = append(b.class.Items, b.curr)
+	}
+	b.state = classValue
+	b.currType = classProperty
+	return nil
+}
+
+// flushValue adds the pending value of the class, before a nested class, an intersection or the end of the class.
+func (p *parser) flushValue(b *classBuilder) error {
+	if b.state == classValue {
+		return p.charNext(b, nil, b.currType)
+	}
+	return nil
+}
+
+// codeLength returns the UTF-8 length Oniguruma gives a code point in a class, or 0 when it's invalid.
+func codeLength(code rune) int {
+	switch {
+	case code < 0x80:
+		return 1
+	case code < 0x800:
+		return 2
+	case code < 0x10000:
+		return 3
+	case code <= maxClassCodePoint:
+		return 4
+	}
+	return 0
+}
+
+// prsClass parses a bracketed character class, with the position after the opening bracket.
+func (p *parser) prsClass(open *token) (*Class, error) {
+	if err := p.incDepth(); err != nil {
+		return nil, err
+	}
+	defer p.decDepth()
+	tok, err := p.fetchTokenClass()
+	if err != nil {
+		return nil, err
+	}
+	negated := false
+	if tok.kind == tokString && tok.code == '^' && !tok.escaped {
+		negated = true
+		if tok, err = p.fetchTokenClass(); err != nil {
+			return nil, err
+		}
+	}
+	if tok.kind == tokClassClose {
+		if !p.closeBracketExists() {
+			return nil, p.errorf(ERR_EMPTY_CHAR_CLASS)
+		}
+		tok.kind = tokString
+	}
+	cls := &Class{Negated: negated, IgnoreCase: p.options&OPTION_IGNORECASE != 0}
+	b := &classBuilder{class: &Class{}, state: classStart}
+	var operands []*Class
+	for tok.kind != tokClassClose {
+		fetched := false
+		lit := &Literal{Span: Span{tok.start, p.pos}, Char: tok.code}
+		switch tok.kind {
+		case tokString:
+			if err := p.charNext(b, lit, charValueType(tok.code)); err != nil {
+				return nil, err
+			}
+		case tokCrudeByte:
+			lit.Byte = true
+			code, size, next, err := p.classCrudeBytes(tok)
+			if err != nil {
+				return nil, err
+			}
+			lit.Char = code
+			lit.End = p.pos
+			inType := classMultiByte
+			if size == 1 {
+				inType = classSingleByte
+			}
+			if next != nil {
+				tok, fetched = next, true
+			}
+			if err := p.charNext(b, lit, inType); err != nil {
+				return nil, err
+			}
+		case tokCodePoint:
+			if err := p.classCodePoint(b, lit); err != nil {
+				return nil, err
+			}
+		case tokClassPosixOpen:
+			posix, err := p.prsPosixBracket(tok.start)
+			if err != nil {
+				return nil, err
+			}
+			if posix == nil {
+				p.pos = tok.backp
+				lit.End = p.pos
+				if err := p.classCodePoint(b, lit); err != nil {
+					return nil, err
+				}
+				break
+			}
+			posix.ASCII = p.options&OPTION_POSIX_IS_ASCII != 0
+			if err := p.cpropNext(b); err != nil {
+				return nil, err
+			}
+			b.class.Items = append(b.class.Items, posix)
+		case tokCharType:
+			if err := p.cpropNext(b); err != nil {
+				return nil, err
+			}
+			b.class.Items = append(b.class.Items, p.newCharType(tok))
+		case tokCharProperty:
+			prop, err := p.fetchProperty(tok)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.cpropNext(b); err != nil {
+				return nil, err
+			}
+			b.class.Items = append(b.class.Items, prop)
+		case tokClassRange:
+			switch b.state {
+			case classValue:
+				next, err := p.fetchTokenClass()
+				if err != nil {
+					return nil, err
+				}
+				tok, fetched = next, true
+				if next.kind == tokClassClose || next.kind == tokClassAnd {
+					if err := p.classCodePoint(b, lit); err != nil {
+						return nil, err
+					}
+					break
+				}
+				if b.currType == classProperty {
+					return nil, p.errorf(ERR_UNMATCHED_RANGE_SPECIFIER_IN_CHAR_CLASS)
+				}
+				b.state = classRange
+			case classStart:
+				next, err := p.fetchTokenClass()
+				if err != nil {
+					return nil, err
+				}
+				tok, fetched = next, true
+				if err := p.classCodePoint(b, lit); err != nil {
+					return nil, err
+				}
+			case classRange:
+				if err := p.charNext(b, lit, classSingleByte); err != nil {
+					return nil, err
+				}
+			default:
+				next, err := p.fetchTokenClass()
+				if err != nil {
+					return nil, err
+				}
+				tok, fetched = next, true
+				if next.kind == tokClassClose || next.kind == tokClassAnd ||
+					p.flavor.Behavior&SYN_ALLOW_DOUBLE_RANGE_OP_IN_CC != 0 {
+					if err := p.classCodePoint(b, lit); err != nil {
+						return nil, err
+					}
+					break
+				}
+				return nil, p.errorf(ERR_UNMATCHED_RANGE_SPECIFIER_IN_CHAR_CLASS)
+			}
+		case tokClassOpen:
+			if err := p.flushValue(b); err != nil {
+				return nil, err
+			}
+			b.state = classComplete
+			nested, err := p.prsClass(tok)
+			if err != nil {
+				return nil, err
+			}
+			b.class.Items = append(b.class.Items, nested)
+		case tokClassAnd:
+			if err := p.flushValue(b); err != nil {
+				return nil, err
+			}
+			b.state = classStart
+			operands = append(operands, b.class)
+			b.class = &Class{}
+		case tokEOT:
+			return nil, p.errorf(ERR_PREMATURE_END_OF_CHAR_CLASS)
+		}
+		if !fetched {
+			if tok, err = p.fetchTokenClass(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := p.flushValue(b); err != nil {
+		return nil, err
+	}
+	if operands != nil {
+		operands = append(operands, b.class)
+		for _, operand := range operands {
+			operand.IgnoreCase = cls.IgnoreCase
+			operand.Span = spanOfItems(operand.Items, tok.start)
+		}
+		cls.Items = []Node{&ClassIntersection{Span: Span{operands[0].Start, tok.start}, Operands: operands}}
+	} else {
+		cls.Items = b.class.Items
+	}
+	cls.Span = Span{open.start, p.pos}
+	return cls, nil
+}
+
+// spanOfItems returns the span of the items of an operand of an intersection, or an empty span at end.
+func spanOfItems(items []Node, end int) Span {
+	if len(items) == 0 {
+		return Span{end, end}
+	}
+	return Span{items[0].Pos().Start, items[len(items)-1].Pos().End}
+}
+
+// charValueType returns the type of a character of the pattern in a class.
+func charValueType(code rune) classValueType {
+	if code < 0x80 {
+		return classSingleByte
+	}
+	return classMultiByte
+}
+
+// classCodePoint adds a character given by its code point to the class.
+func (p *parser) classCodePoint(b *classBuilder, lit *Literal) error {
+	length := codeLength(lit.Char)
+	if length == 0 && (b.state != classRange || p.flavor.Behavior&SYN_ALLOW_INVALID_CODE_END_OF_RANGE_IN_CC == 0 ||
+		lit.Char < 0x100) {
+		return p.errorf(ERR_INVALID_CODE_POINT_VALUE)
+	}
+	inType := classMultiByte
+	if length == 1 {
+		inType = classSingleByte
+	}
+	return p.charNext(b, lit, inType)
+}
+
+// classCrudeBytes reads the bytes of a character given by escaped bytes inside a class, such as \xe3\x81\x82.
+// It returns the code point, the number of bytes, and the next token when it was read and is not part of
+// the character.
+func (p *parser) classCrudeBytes(tok *token) (rune, int, *token, error) {
+	buf := []byte{byte(tok.code)}
+	save := p.pos
+	var next *token
+	for len(buf) < 4 {
+		t, err := p.fetchTokenClass()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		if t.kind != tokCrudeByte || t.base != tok.base {
+			next = t
+			break
+		}
+		buf = append(buf, byte(t.code))
+	}
+	length := utf8Len(buf[0])
+	if len(buf) < length {
+		return 0, 0, nil, p.errorf(ERR_TOO_SHORT_MULTI_BYTE_STRING)
+	}
+	if len(buf) > length {
+		// Read back the bytes that don't belong to the character.
+		p.pos = save
+		p.pendingClass = nil
+		for i := 1; i < length; i++ {
+			if _, err := p.fetchTokenClass(); err != nil {
+				return 0, 0, nil, err
+			}
+		}
+		next = nil
+		buf = buf[:length]
+	}
+	if length == 1 {
+		return rune(buf[0]), 1, next, nil
+	}
+	code := rune(buf[0]) & (1<<(7-length) - 1)
+	for _, c := range buf[1:] {
+		code = code<<6 | rune(c)&0x3f
+	}
+	return code, length, next, nil
+}
+
+// closeBracketExists reports whether a closing bracket follows, for a bracket at the start of a class,
+// which is then a literal. Like Oniguruma, escaped brackets are found too.
+func (p *parser) closeBracketExists() bool {
+	for i := p.pos; i < len(p.pattern); i++ {
+		if p.pattern[i] == ']' {
+			return true
+		}
+	}
+	return false
+}
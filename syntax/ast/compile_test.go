@@ -0,0 +1,148 @@
+package ast_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tmikus/onig-go/v2"
+	"github.com/tmikus/onig-go/v2/syntax/ast"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The flavors are compared with the Oniguruma syntaxes of the same names.
+var compileFlavors = []*ast.Flavor{ast.FlavorRuby, ast.FlavorOniguruma, ast.FlavorPerlNG, ast.FlavorPython, ast.FlavorJava}
+
+// compileCorpus are patterns on which the parser and Oniguruma are easy to get apart: look-behinds, recursive
+// calls, absent groups, conditionals, case folding and the options that continue into the following alternatives.
+var compileCorpus = []string{
+	`abc`, `a|b|c`, `(a)(b)\2\1`, `(?<n>a)\k<n>`, `(?<n>a)(?(<n>)b)`, `(a)(?(1)b|c)`, `(?i)(?<!ss)x`,
+	`(?i)(?<=ss)x`, `(?i)(?<=ß)x`, `(?i)(?<!ß)x`, `(?<=a*a*)`, `(?<=a{0}b{0})`, `(?<=(?:a{0})(?:b{0}))`,
+	`(?<=a{65535})`, `(?<=a{65536})`, `(?<=(?:a{65536})*)`, `(?<=(?:a{65536})?)`, `(?i)(ß)(?<=\1)x`,
+	`(?i)(?<=\1)(a)`, `(?<=\X)`, `(?<=\R)`, `(?<n>a)(?:(b{100000})){100000}\1`, `(?<n>a)(?:x(?R))?`,
+	`(?<n>a)\g<0>`, `(?<=\g<0>)a`, `(?<=(?~a))b`, `(?<=(?~a)x)`, `(?<=(?~|a))b`, `(?<=(?~|a|b))b`, `(?<=(?~))b`,
+	`(?<=a$)`, `(?<=a\z)`, `(?<=a\Z)`, `(?<=(?=a))`, `(?<=(?!a))`, `(?<=a|bc)`, `(?<!a|bc)`, `(?<=a|b*)`,
+	`(?<=a+)`, `(?<=a?)`, `(?<=(a))`, `(?<!(a))`, `(?<=(a))\1`, `(?<=\K)a`, `(?<=(?<!a)b)`, `(?<!(?<=a)b)`,
+	`(?<!(?<!a)b)`, `(?<=a(?<!b)c)`, `(a|\g<1>b)`, `(a\g<1>)`, `(a\g<1>?)`, `(a|b\g<1>)`, `(\g<1>a|b)`,
+	`(?<a>\g<a>)`, `(?<a>|\g<a>)`, `(?<a>a|(?<b>\g<a>))`, `(?<a>\g<b>)(?<b>\g<a>)`, `(?<a>x\g<b>)(?<b>\g<a>)`,
+	`(?<a>(?(1)\g<a>|b))`, `()\g<1>`, `\g<2>()`, `\g<n>`, `(?<n>)(?<n>)\g<n>`, `(?<n>)(?<n>)\k<n>`,
+	`(a)(?<n>b)\1`, `(a)(?<n>b)\g<1>`, `(a)(?<n>b)\k<n>`, `(a)\2`, `(?:a*)*`, `(?:a+)?`, `(?:a*){2,3}`,
+	`(?:a{2}){3}`, `(?:a{100000}){100000}`, `(?:a{2}){3}b`, `a{,3}`, `a{3,}`, `a{3,2}`, `a{100001}`, `a**`,
+	`a*+`, `a+?+`, `(?i)ab(?-i)c|d`, `a(?i)b|c`, `(?i:a|b)`, `(?m)a.b`, `(?s)a.b`, `(?x) a b # c`, `(?W)\w`,
+	`(?I)\w`, `(?y{g})\X`, `[a-z&&[^aeiou]]`, `[[:alpha:]]`, `[\p{L}]`, `\p{Greek}`, `\p{^L}`, `\P{L}`,
+	`[a-\d]`, `[\w-a]`, `[]a]`, `[a-]`, `[z-a]`, `\x{110000}`, `A`, `\o{101}`, `\101`, `\8`, `\cA`, `\C-a`,
+	`\M-a`, `(*FAIL)`, `(*MAX{2})`, `(*COUNT[a]{>})(*CMP{a,==,a})`, `(?{foo})`, `(?~abc)`, `(?~|abc|def)`,
+	`(?~|abc)`, `(?~|)`, `\Qa.b\E`, `(?#comment)`, `(?P<n>a)(?P=n)(?P>n)`, `(?'n'a)\k'n'\g'n'`, `\k<-1>`,
+	`(a)\k<1+0>`, `(a)\k<-1>`, `(?<n>a)\k<n+1>`, `(?i)(?:$){2}`, `(?:(?i)(?:$){2})`, `(?:x(?i)(?:$){2})`,
+	`(?~x(?i)(?:$){2})`, `(?~(?i)x(?:$){2})`, `(?~(?i)(?i)(?:$){2})`, `(?~(?i)(?:$){2}|b)`,
+	`(?~a|(?i)(?:$){2})`, `(?(1)(?i)(?:$){2})()`, `(?(?{x})(?i)(?:$){2})`, `(?~(?i)(?i:$){2})`, `(?<=(?~||\Y))`,
+	`(?<=(?~||\b))`, `(?<=(?~||^))`, `(?<=(?~||\Ya))`, `(?<=(?~||a\Y))`, `(?<=(?~|\Y|))`, `(?<=(?~|\Y|a*))`,
+	`(?<=(?~||\G))`, `(?<=((?<n>))|(?~\?))\g<n>{0}`, `(?<=((?<n><))|(()))\g<n>{0}`, `((?<!(?~||\Y)))`,
+	`(?<=(?~||()))`, `(?<=(?~||(?:\b)*))`, `(?<=(?~||\b*))`, `((?~|\g<1>|b{0}))`, `(?>((?~|))(?<!\1))`,
+	`(?:)(?<!\1)((?i:(?~a)))`, `(?(2)(?i)|(?:\A)?)`, `(?(2)(?i)|a)`, `(?(2)(?i)|(?:\A)?)()()`,
+	`(?(1)(?i)a|b|c)()`, `(?(1)a|(?i)b|c)()`, `(?(1)(?i)|(?:\A)?)()`, `(?(1)x(?i)|(?:\A)?)()`,
+	`(?(1)(?i)x|(?:\A)?)()`, `(?<!\1)((?i)[ß])`, `(?<!\1)((?i:[ß]))`, `(?<=\1)((?i)[ß])`, `(?i)(?<=[ß])`,
+	`(?i)(?<=[ßa]b)`, `(?i)(?<!x[ß])`, `(?i)[ß]*s`, `(?i)(?<=[ß]{2})`, `(?i)(?<=[ß]+)`, `(?i)(?<=[ﬀ])`,
+	`(?i)(?()[ßﬀ])`, `(?i)(?(1)[ßﬀ])()`, `(?i)(?<=(?()[ß]))`, `(?i)(?<=(?(1)[ß]))()`, `(?i)(?()[ß]|)`,
+	`(?i)(?()[ß]x)`, `(?i)(?()[ß])`, `(?i)(?()[ßa])`, `(?i)(?<=(?()[ßﬀ]))`, `(?i)(?<=(?(?{x})[ß]))`,
+	`(?i)(?<=\x41)`, `(?i)(?<=\xff)`, `(?<=\g<n>)(?<n>(?i:\x41))`, `(?<n>(?i:\x41))(?<=\g<n>)`,
+	`(?<=\1)((?i:\x41))`, `((?i:\x41))(?<=\1)`, `(?i)(?<=\x41b)`, `(?<=(?~|(?i)[ß]))`, `(?<=(?~|(?:(?i)[ß])))`,
+	`(?i)(?<=(?~|(?:[ß])))`, `(?i)(?<=(?~|[ß]))`, `(?<=(?()(?i)[ß]))`, `(?i)(?<=(?()(?:[ß])))`,
+	`(?<=(?()(?i)(?i)[ß]))`, `(?<=(?~|(?i)(?-x)[ß]))`, `(?<=(?~)|(?~|(?i)[ß]))`, `(?()(?i)[ß])\g<-1>?`, `^\x{q`,
+	`^[\x{]+$`, `[>-\x{`, `[>-\x{]`, `[a-\x{`, `[\x{`, `[\x{]`, `[z-\x{]`, `[z-\x{`, `\x{`, `[z-\x{4`,
+	`[a-\x{4`, `[\x{4`, `[\o{]`, `[a-\o{]`, `[z-\o{]`, `(?R)`, `x(?R)`, `(?R)+`, `^(?R)$`, `(?<=(?R))`,
+	`(?<=a(?R))`, `(?<!(?R))`, `(?<=(?R)|ab)`, `(?R){0}`, `(?~(?R))`, `(?<n>a)(?R)`, `(?<n>a)(?R)\1`,
+	`(a)(?R)\1`, `(?<=(?R)a)`, `(?<=a|(?R)bc)`, `(?<n>a)|(?R)\k<n>`, `(?R)a`, `a(?R)`, `(?R`, `(?Rx)`, `(?R)\1`,
+	`(?R)\2`, `(?R)(?R)\2`, `(?L)\g<-1>`, `(?L)a`, `(?L)\g<0>`, `(?L:\g<0>)`, `a(?L)b`, `(?L)(a)\g<1>`,
+	`(a)\g<1>(?L)`, `(?L)(?~a)`, `(?L)\1()`, `(?-L)\g<0>`, `(?L)a|\g<0>`, `\xF8`, `\xF9`, `\xFA`, `\xFB`, `\xFC`,
+	`\xFD`, `\xFE`, `\xFF`, `\xF8abcd`, `\xFCabcdef`, `n'\xFAILn`, `[\xF8-\xFA]`, `\xF8+`, `(?i)\xFB`,
+}
+
+// compileResult returns "ok", or the error code Oniguruma fails with, when compiling a pattern.
+func compileResult(pattern string, flavor *ast.Flavor) string {
+	syntax, _ := onig.SyntaxByName(flavor.Name)
+	if _, err := onig.CompileWithSyntax(pattern, syntax); err != nil {
+		return strings.TrimPrefix(err.Error(), "error creating oniguruma regex: onig_new returned ")
+	}
+	return "ok"
+}
+
+// parseResult returns "ok", or the error code of the error, when parsing a pattern.
+func parseResult(pattern string, flavor *ast.Flavor) string {
+	_, err := ast.Parse(pattern, flavor)
+	if e, ok := err.(*ast.Error); ok {
+		return strconv.Itoa(int(e.Code))
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+func assertParseMatchesCompile(t *testing.T, pattern string) {
+	for _, flavor := range compileFlavors {
+		assert.Equal(t, compileResult(pattern, flavor), parseResult(pattern, flavor), "%s %q", flavor.Name, pattern)
+	}
+}
+
+func TestParse_MatchesCompile(t *testing.T) {
+	for _, pattern := range compileCorpus {
+		assertParseMatchesCompile(t, pattern)
+	}
+}
+
+// generatePattern returns a random pattern of up to depth nested groups, made of the given atoms, groups and
+// quantifiers.
+func generatePattern(rng *rand.Rand, atoms, groups, quantifiers []string, depth int) string {
+	var sb strings.Builder
+	n := 1 + rng.Intn(3)
+	for i := 0; i < n; i++ {
+		if i > 0 && rng.Intn(4) == 0 {
+			sb.WriteString("|")
+		}
+		if depth > 0 && rng.Intn(3) == 0 {
+			group := groups[rng.Intn(len(groups))]
+			sb.WriteString(group)
+			sb.WriteString(generatePattern(rng, atoms, groups, quantifiers, depth-1))
+			if strings.HasPrefix(group, "(?(") && rng.Intn(2) == 0 {
+				sb.WriteString("|")
+				sb.WriteString(generatePattern(rng, atoms, groups, quantifiers, depth-1))
+			}
+			sb.WriteString(")")
+		} else {
+			sb.WriteString(atoms[rng.Intn(len(atoms))])
+		}
+		if rng.Intn(3) == 0 {
+			sb.WriteString(quantifiers[rng.Intn(len(quantifiers))])
+		}
+	}
+	return sb.String()
+}
+
+func TestParse_MatchesCompile_Generated(t *testing.T) {
+	for _, test := range []struct {
+		atoms       string
+		groups      string
+		quantifiers string
+	}{
+		{
+			`a b ß ss . \w \W \d [a-c] [^a] [ß] \p{L} ^ $ \A \z \Z \b \K \R \X \1 \2 \k<n> \k<m> \g<n> \g<1> \g<0> ` +
+				`\g<m> (*FAIL) (?i)ab (?i) (?-i) x (?~a) (?~|a|b) (?~|a) (?~|) \O \y \Y`,
+			`( ( (?: (?<n> (?<m> (?= (?! (?<= (?<! (?> (?i: (?(1) (?(<n>) (?(2) (?~ (?~| (?-i:`,
+			`* + ? {2} {1,3} {,2} *? ++ {0} {3}+ ?? {2,}`,
+		},
+		{
+			`a b ß \xff \xf8 \xfa \xfc \xfd \xe3\x81\x82 [ab] [ß] (?i)[ß] \d \w . (*FAIL) (?{x}) (*MAX{2}) (?{x}[x]) (*COUNT[c]{X}) \g<1> ` +
+				`\g<n> \k<n> \1 \k<-1> \g<-1> \g<+1> \Y \b ^ \K (?~a) (?~|a|b{2}) (?~|a|b*) (?~|\g<1>|b{0}) (?~|) ` +
+				`(?~|a) (?i) (?x) (?-i)`,
+			`( (?: (?<n> (?= (?<= (?<! (?> (?i: (?(1) (?(<n>) (?(?{x}) (?(*FAIL) (?~ (?~| (?~|a| (?(?=a)`,
+			`* + ? {2} {0} {1,3} {3}? *+ {2}+ ?+ {70000} {0,2}`,
+		},
+	} {
+		atoms, groups, quantifiers := strings.Fields(test.atoms), strings.Fields(test.groups), strings.Fields(test.quantifiers)
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 5000; i++ {
+			assertParseMatchesCompile(t, generatePattern(rng, atoms, groups, quantifiers, 3))
+		}
+	}
+}
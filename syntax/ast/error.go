@@ -0,0 +1,152 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorCode is the reason a pattern is invalid, with the value of the Oniguruma error code for the same reason.
+type ErrorCode int
+
+const (
+	ERR_PARSE_DEPTH_LIMIT_OVER                   ErrorCode = -16
+	ERR_END_PATTERN_AT_LEFT_BRACE                ErrorCode = -100
+	ERR_END_PATTERN_AT_LEFT_BRACKET              ErrorCode = -101
+	ERR_EMPTY_CHAR_CLASS                         ErrorCode = -102
+	ERR_PREMATURE_END_OF_CHAR_CLASS              ErrorCode = -103
+	ERR_END_PATTERN_AT_ESCAPE                    ErrorCode = -104
+	ERR_END_PATTERN_AT_META                      ErrorCode = -105
+	ERR_END_PATTERN_AT_CONTROL                   ErrorCode = -106
+	ERR_META_CODE_SYNTAX                         ErrorCode = -108
+	ERR_CONTROL_CODE_SYNTAX                      ErrorCode = -109
+	ERR_CHAR_CLASS_VALUE_AT_END_OF_RANGE         ErrorCode = -110
+	ERR_CHAR_CLASS_VALUE_AT_START_OF_RANGE       ErrorCode = -111
+	ERR_UNMATCHED_RANGE_SPECIFIER_IN_CHAR_CLASS  ErrorCode = -112
+	ERR_TARGET_OF_REPEAT_OPERATOR_NOT_SPECIFIED  ErrorCode = -113
+	ERR_TARGET_OF_REPEAT_OPERATOR_INVALID        ErrorCode = -114
+	ERR_NESTED_REPEAT_OPERATOR                   ErrorCode = -115
+	ERR_UNMATCHED_CLOSE_PARENTHESIS              ErrorCode = -116
+	ERR_END_PATTERN_WITH_UNMATCHED_PARENTHESIS   ErrorCode = -117
+	ERR_END_PATTERN_IN_GROUP                     ErrorCode = -118
+	ERR_UNDEFINED_GROUP_OPTION                   ErrorCode = -119
+	ERR_INVALID_GROUP_OPTION                     ErrorCode = -120
+	ERR_INVALID_POSIX_BRACKET_TYPE               ErrorCode = -121
+	ERR_INVALID_LOOK_BEHIND_PATTERN              ErrorCode = -122
+	ERR_INVALID_REPEAT_RANGE_PATTERN             ErrorCode = -123
+	ERR_TOO_BIG_NUMBER                           ErrorCode = -200
+	ERR_TOO_BIG_NUMBER_FOR_REPEAT_RANGE          ErrorCode = -201
+	ERR_UPPER_SMALLER_THAN_LOWER_IN_REPEAT_RANGE ErrorCode = -202
+	ERR_EMPTY_RANGE_IN_CHAR_CLASS                ErrorCode = -203
+	ERR_TOO_SHORT_MULTI_BYTE_STRING              ErrorCode = -206
+	ERR_INVALID_BACKREF                          ErrorCode = -208
+	ERR_NUMBERED_BACKREF_OR_CALL_NOT_ALLOWED     ErrorCode = -209
+	ERR_TOO_MANY_CAPTURES                        ErrorCode = -210
+	ERR_TOO_LONG_WIDE_CHAR_VALUE                 ErrorCode = -212
+	ERR_UNDEFINED_OPERATOR                       ErrorCode = -213
+	ERR_EMPTY_GROUP_NAME                         ErrorCode = -214
+	ERR_INVALID_GROUP_NAME                       ErrorCode = -215
+	ERR_INVALID_CHAR_IN_GROUP_NAME               ErrorCode = -216
+	ERR_UNDEFINED_NAME_REFERENCE                 ErrorCode = -217
+	ERR_UNDEFINED_GROUP_REFERENCE                ErrorCode = -218
+	ERR_MULTIPLEX_DEFINED_NAME                   ErrorCode = -219
+	ERR_MULTIPLEX_DEFINITION_NAME_CALL           ErrorCode = -220
+	ERR_NEVER_ENDING_RECURSION                   ErrorCode = -221
+	ERR_INVALID_CHAR_PROPERTY_NAME               ErrorCode = -223
+	ERR_INVALID_IF_ELSE_SYNTAX                   ErrorCode = -224
+	ERR_INVALID_ABSENT_GROUP_PATTERN             ErrorCode = -225
+	ERR_INVALID_ABSENT_GROUP_GENERATOR_PATTERN   ErrorCode = -226
+	ERR_INVALID_CALLOUT_PATTERN                  ErrorCode = -227
+	ERR_INVALID_CALLOUT_NAME                     ErrorCode = -228
+	ERR_UNDEFINED_CALLOUT_NAME                   ErrorCode = -229
+	ERR_INVALID_CALLOUT_BODY                     ErrorCode = -230
+	ERR_INVALID_CALLOUT_TAG_NAME                 ErrorCode = -231
+	ERR_INVALID_CALLOUT_ARG                      ErrorCode = -232
+	ERR_INVALID_CODE_POINT_VALUE                 ErrorCode = -400
+	ERR_TOO_BIG_WIDE_CHAR_VALUE                  ErrorCode = -401
+	ERR_INVALID_COMBINATION_OF_OPTIONS           ErrorCode = -403
+
+	// ERR_LOOK_BEHIND_REDUCED isn't an error code of Oniguruma. It is the value Oniguruma fails with when the
+	// repetitions of an alternative of a look-behind are all reduced to nothing, such as in (?<=a*a*).
+	ERR_LOOK_BEHIND_REDUCED ErrorCode = 1
+)
+
+var errorMessages = map[ErrorCode]string{
+	ERR_PARSE_DEPTH_LIMIT_OVER:                   "parse depth limit over",
+	ERR_END_PATTERN_AT_LEFT_BRACE:                "end pattern at left brace",
+	ERR_END_PATTERN_AT_LEFT_BRACKET:              "end pattern at left bracket",
+	ERR_EMPTY_CHAR_CLASS:                         "empty char-class",
+	ERR_PREMATURE_END_OF_CHAR_CLASS:              "premature end of char-class",
+	ERR_END_PATTERN_AT_ESCAPE:                    "end pattern at escape",
+	ERR_END_PATTERN_AT_META:                      "end pattern at meta",
+	ERR_END_PATTERN_AT_CONTROL:                   "end pattern at control",
+	ERR_META_CODE_SYNTAX:                         "invalid meta-code syntax",
+	ERR_CONTROL_CODE_SYNTAX:                      "invalid control-code syntax",
+	ERR_CHAR_CLASS_VALUE_AT_END_OF_RANGE:         "char-class value at end of range",
+	ERR_CHAR_CLASS_VALUE_AT_START_OF_RANGE:       "char-class value at start of range",
+	ERR_UNMATCHED_RANGE_SPECIFIER_IN_CHAR_CLASS:  "unmatched range specifier in char-class",
+	ERR_TARGET_OF_REPEAT_OPERATOR_NOT_SPECIFIED:  "target of repeat operator is not specified",
+	ERR_TARGET_OF_REPEAT_OPERATOR_INVALID:        "target of repeat operator is invalid",
+	ERR_NESTED_REPEAT_OPERATOR:                   "nested repeat operator",
+	ERR_UNMATCHED_CLOSE_PARENTHESIS:              "unmatched close parenthesis",
+	ERR_END_PATTERN_WITH_UNMATCHED_PARENTHESIS:   "end pattern with unmatched parenthesis",
+	ERR_END_PATTERN_IN_GROUP:                     "end pattern in group",
+	ERR_UNDEFINED_GROUP_OPTION:                   "undefined group option",
+	ERR_INVALID_GROUP_OPTION:                     "invalid group option",
+	ERR_INVALID_POSIX_BRACKET_TYPE:               "invalid POSIX bracket type",
+	ERR_INVALID_LOOK_BEHIND_PATTERN:              "invalid pattern in look-behind",
+	ERR_INVALID_REPEAT_RANGE_PATTERN:             "invalid repeat range {lower,upper}",
+	ERR_TOO_BIG_NUMBER:                           "too big number",
+	ERR_TOO_BIG_NUMBER_FOR_REPEAT_RANGE:          "too big number for repeat range",
+	ERR_UPPER_SMALLER_THAN_LOWER_IN_REPEAT_RANGE: "upper is smaller than lower in repeat range",
+	ERR_EMPTY_RANGE_IN_CHAR_CLASS:                "empty range in char class",
+	ERR_TOO_SHORT_MULTI_BYTE_STRING:              "too short multibyte code string",
+	ERR_INVALID_BACKREF:                          "invalid backref number/name",
+	ERR_NUMBERED_BACKREF_OR_CALL_NOT_ALLOWED:     "numbered backref/call is not allowed. (use name)",
+	ERR_TOO_MANY_CAPTURES:                        "too many captures",
+	ERR_TOO_LONG_WIDE_CHAR_VALUE:                 "too long wide-char value",
+	ERR_UNDEFINED_OPERATOR:                       "undefined operator",
+	ERR_EMPTY_GROUP_NAME:                         "group name is empty",
+	ERR_INVALID_GROUP_NAME:                       "invalid group name",
+	ERR_INVALID_CHAR_IN_GROUP_NAME:               "invalid char in group name",
+	ERR_UNDEFINED_NAME_REFERENCE:                 "undefined name reference",
+	ERR_UNDEFINED_GROUP_REFERENCE:                "undefined group reference",
+	ERR_MULTIPLEX_DEFINED_NAME:                   "multiplex defined name",
+	ERR_MULTIPLEX_DEFINITION_NAME_CALL:           "multiplex definition name call",
+	ERR_NEVER_ENDING_RECURSION:                   "never ending recursion",
+	ERR_INVALID_CHAR_PROPERTY_NAME:               "invalid character property name",
+	ERR_INVALID_IF_ELSE_SYNTAX:                   "invalid if-else syntax",
+	ERR_INVALID_ABSENT_GROUP_PATTERN:             "invalid absent group pattern",
+	ERR_INVALID_ABSENT_GROUP_GENERATOR_PATTERN:   "invalid absent group generator pattern",
+	ERR_INVALID_CALLOUT_PATTERN:                  "invalid callout pattern",
+	ERR_INVALID_CALLOUT_NAME:                     "invalid callout name",
+	ERR_UNDEFINED_CALLOUT_NAME:                   "undefined callout name",
+	ERR_INVALID_CALLOUT_BODY:                     "invalid callout body",
+	ERR_INVALID_CALLOUT_TAG_NAME:                 "invalid callout tag name",
+	ERR_INVALID_CALLOUT_ARG:                      "invalid callout arg",
+	ERR_INVALID_CODE_POINT_VALUE:                 "invalid code point value",
+	ERR_TOO_BIG_WIDE_CHAR_VALUE:                  "too big wide-char value",
+	ERR_INVALID_COMBINATION_OF_OPTIONS:           "invalid combination of options",
+	ERR_LOOK_BEHIND_REDUCED:                      "look-behind reduced to nothing",
+}
+
+// String returns the message Oniguruma gives for the error code.
+func (c ErrorCode) String() string {
+	if message, ok := errorMessages[c]; ok {
+		return message
+	}
+	return fmt.Sprintf("error %d", int(c))
+}
+
+// Error is an error in a pattern.
+type Error struct {
+	Code ErrorCode
+	// Offset is the byte offset in the pattern where the error was found.
+	Offset int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid pattern at offset %d: %s", e.Offset, e.Code)
+}
+
+// ErrUnsupportedFlavor is returned when parsing a pattern with a flavor the parser doesn't handle.
+var ErrUnsupportedFlavor = errors.New("unsupported regex flavor")
@@ -0,0 +1,228 @@
+package ast
+
+// Op is a set of the operators of a flavor, with the values of Oniguruma's ONIG_SYN_OP_* flags.
+type Op uint32
+
+// Op2 is a second set of the operators of a flavor, with the values of Oniguruma's ONIG_SYN_OP2_* flags.
+type Op2 uint32
+
+// Behavior is a set of the behaviors of a flavor, with the values of Oniguruma's syntax behavior flags.
+type Behavior uint32
+
+// Options are the options a pattern is parsed with, with the values of Oniguruma's ONIG_OPTION_* flags.
+// Only the options that change how a pattern is parsed are defined.
+type Options uint32
+
+const (
+	SYN_OP_VARIABLE_META_CHARACTERS   Op = 1 << 0
+	SYN_OP_DOT_ANYCHAR                Op = 1 << 1  // .
+	SYN_OP_ASTERISK_ZERO_INF          Op = 1 << 2  // *
+	SYN_OP_ESC_ASTERISK_ZERO_INF      Op = 1 << 3  // \*
+	SYN_OP_PLUS_ONE_INF               Op = 1 << 4  // +
+	SYN_OP_ESC_PLUS_ONE_INF           Op = 1 << 5  // \+
+	SYN_OP_QMARK_ZERO_ONE             Op = 1 << 6  // ?
+	SYN_OP_ESC_QMARK_ZERO_ONE         Op = 1 << 7  // \?
+	SYN_OP_BRACE_INTERVAL             Op = 1 << 8  // {lower,upper}
+	SYN_OP_ESC_BRACE_INTERVAL         Op = 1 << 9  // \{lower,upper\}
+	SYN_OP_VBAR_ALT                   Op = 1 << 10 // |
+	SYN_OP_ESC_VBAR_ALT               Op = 1 << 11 // \|
+	SYN_OP_LPAREN_SUBEXP              Op = 1 << 12 // (...)
+	SYN_OP_ESC_LPAREN_SUBEXP          Op = 1 << 13 // \(...\)
+	SYN_OP_ESC_AZ_BUF_ANCHOR          Op = 1 << 14 // \A, \Z, \z
+	SYN_OP_ESC_CAPITAL_G_BEGIN_ANCHOR Op = 1 << 15 // \G
+	SYN_OP_DECIMAL_BACKREF            Op = 1 << 16 // \num
+	SYN_OP_BRACKET_CC                 Op = 1 << 17 // [...]
+	SYN_OP_ESC_W_WORD                 Op = 1 << 18 // \w, \W
+	SYN_OP_ESC_LTGT_WORD_BEGIN_END    Op = 1 << 19 // \<, \>
+	SYN_OP_ESC_B_WORD_BOUND           Op = 1 << 20 // \b, \B
+	SYN_OP_ESC_S_WHITE_SPACE          Op = 1 << 21 // \s, \S
+	SYN_OP_ESC_D_DIGIT                Op = 1 << 22 // \d, \D
+	SYN_OP_LINE_ANCHOR                Op = 1 << 23 // ^, $
+	SYN_OP_POSIX_BRACKET              Op = 1 << 24 // [:xxxx:]
+	SYN_OP_QMARK_NON_GREEDY           Op = 1 << 25 // ??, *?, +?, {n,m}?
+	SYN_OP_ESC_CONTROL_CHARS          Op = 1 << 26 // \n, \r, \t, \a ...
+	SYN_OP_ESC_C_CONTROL              Op = 1 << 27 // \cx
+	SYN_OP_ESC_OCTAL3                 Op = 1 << 28 // \OOO
+	SYN_OP_ESC_X_HEX2                 Op = 1 << 29 // \xHH
+	SYN_OP_ESC_X_BRACE_HEX8           Op = 1 << 30 // \x{7HHHHHHH}
+	SYN_OP_ESC_O_BRACE_OCTAL          Op = 1 << 31 // \o{1OOOOOOOOOO}
+)
+
+const (
+	SYN_OP2_ESC_CAPITAL_Q_QUOTE           Op2 = 1 << 0  // \Q...\E
+	SYN_OP2_QMARK_GROUP_EFFECT            Op2 = 1 << 1  // (?...)
+	SYN_OP2_OPTION_PERL                   Op2 = 1 << 2  // (?imsx), (?-imsx)
+	SYN_OP2_OPTION_RUBY                   Op2 = 1 << 3  // (?imx), (?-imx)
+	SYN_OP2_PLUS_POSSESSIVE_REPEAT        Op2 = 1 << 4  // ?+, *+, ++
+	SYN_OP2_PLUS_POSSESSIVE_INTERVAL      Op2 = 1 << 5  // {n,m}+
+	SYN_OP2_CCLASS_SET_OP                 Op2 = 1 << 6  // [...&&..[..]..]
+	SYN_OP2_QMARK_LT_NAMED_GROUP          Op2 = 1 << 7  // (?<name>...)
+	SYN_OP2_ESC_K_NAMED_BACKREF           Op2 = 1 << 8  // \k<name>
+	SYN_OP2_ESC_G_SUBEXP_CALL             Op2 = 1 << 9  // \g<name>, \g<n>
+	SYN_OP2_ATMARK_CAPTURE_HISTORY        Op2 = 1 << 10 // (?@..), (?@<x>..)
+	SYN_OP2_ESC_CAPITAL_C_BAR_CONTROL     Op2 = 1 << 11 // \C-x
+	SYN_OP2_ESC_CAPITAL_M_BAR_META        Op2 = 1 << 12 // \M-x
+	SYN_OP2_ESC_V_VTAB                    Op2 = 1 << 13 // \v as VTAB
+	SYN_OP2_ESC_U_HEX4                    Op2 = 1 << 14 // \uHHHH
+	SYN_OP2_ESC_GNU_BUF_ANCHOR            Op2 = 1 << 15 // \`, \'
+	SYN_OP2_ESC_P_BRACE_CHAR_PROPERTY     Op2 = 1 << 16 // \p{...}, \P{...}
+	SYN_OP2_ESC_P_BRACE_CIRCUMFLEX_NOT    Op2 = 1 << 17 // \p{^..}, \P{^..}
+	SYN_OP2_ESC_H_XDIGIT                  Op2 = 1 << 19 // \h, \H
+	SYN_OP2_INEFFECTIVE_ESCAPE            Op2 = 1 << 20 // \
+	SYN_OP2_QMARK_LPAREN_IF_ELSE          Op2 = 1 << 21 // (?(n)) (?(...)...|...)
+	SYN_OP2_ESC_CAPITAL_K_KEEP            Op2 = 1 << 22 // \K
+	SYN_OP2_ESC_CAPITAL_R_GENERAL_NEWLINE Op2 = 1 << 23 // \R
+	SYN_OP2_ESC_CAPITAL_N_O_SUPER_DOT     Op2 = 1 << 24 // \N, \O
+	SYN_OP2_QMARK_TILDE_ABSENT_GROUP      Op2 = 1 << 25 // (?~...)
+	SYN_OP2_ESC_X_Y_TEXT_SEGMENT          Op2 = 1 << 26 // \X, \y, \Y
+	SYN_OP2_QMARK_PERL_SUBEXP_CALL        Op2 = 1 << 27 // (?R), (?&name)...
+	SYN_OP2_QMARK_BRACE_CALLOUT_CONTENTS  Op2 = 1 << 28 // (?{...}) (?{{...}})
+	SYN_OP2_ASTERISK_CALLOUT_NAME         Op2 = 1 << 29 // (*name) (*name{a,..})
+	SYN_OP2_OPTION_ONIGURUMA              Op2 = 1 << 30 // (?imxWDSPy)
+	SYN_OP2_QMARK_CAPITAL_P_NAME          Op2 = 1 << 31 // (?P<name>...) (?P=name)
+)
+
+const (
+	SYN_CONTEXT_INDEP_REPEAT_OPS                Behavior = 1 << 0  // ?, *, +, {n,m}
+	SYN_CONTEXT_INVALID_REPEAT_OPS              Behavior = 1 << 1  // error or ignore
+	SYN_ALLOW_UNMATCHED_CLOSE_SUBEXP            Behavior = 1 << 2  // ...)...
+	SYN_ALLOW_INVALID_INTERVAL                  Behavior = 1 << 3  // {???
+	SYN_ALLOW_INTERVAL_LOW_ABBREV               Behavior = 1 << 4  // {,n} => {0,n}
+	SYN_STRICT_CHECK_BACKREF                    Behavior = 1 << 5  // /(\1)/,/\1()/ ..
+	SYN_DIFFERENT_LEN_ALT_LOOK_BEHIND           Behavior = 1 << 6  // (?<=a|bc)
+	SYN_CAPTURE_ONLY_NAMED_GROUP                Behavior = 1 << 7  // named groups disable unnamed captures
+	SYN_ALLOW_MULTIPLEX_DEFINITION_NAME         Behavior = 1 << 8  // (?<x>)(?<x>)
+	SYN_FIXED_INTERVAL_IS_GREEDY_ONLY           Behavior = 1 << 9  // a{n}?=(?:a{n})?
+	SYN_ISOLATED_OPTION_CONTINUE_BRANCH         Behavior = 1 << 10 // ..(?i)...|...
+	SYN_VARIABLE_LEN_LOOK_BEHIND                Behavior = 1 << 11 // (?<=a+|..)
+	SYN_PYTHON                                  Behavior = 1 << 12 // \UHHHHHHHH
+	SYN_WHOLE_OPTIONS                           Behavior = 1 << 13 // (?Ie)
+	SYN_BRE_ANCHOR_AT_EDGE_OF_SUBEXP            Behavior = 1 << 14 // \(^abc$\)
+	SYN_NOT_NEWLINE_IN_NEGATIVE_CC              Behavior = 1 << 20 // [^...]
+	SYN_BACKSLASH_ESCAPE_IN_CC                  Behavior = 1 << 21 // [..\w..] etc..
+	SYN_ALLOW_EMPTY_RANGE_IN_CC                 Behavior = 1 << 22
+	SYN_ALLOW_DOUBLE_RANGE_OP_IN_CC             Behavior = 1 << 23 // [0-9-a]=[0-9\-a]
+	SYN_WARN_CC_OP_NOT_ESCAPED                  Behavior = 1 << 24 // [,-,]
+	SYN_WARN_REDUNDANT_NESTED_REPEAT            Behavior = 1 << 25 // (?:a*)+
+	SYN_ALLOW_INVALID_CODE_END_OF_RANGE_IN_CC   Behavior = 1 << 26
+	SYN_ALLOW_CHAR_TYPE_FOLLOWED_BY_MINUS_IN_CC Behavior = 1 << 27 // [\w-%]=[\w\-%]
+	SYN_CONTEXT_INDEP_ANCHORS                   Behavior = 1 << 31
+)
+
+const (
+	OPTION_NONE                                   Options = 0
+	OPTION_IGNORECASE                             Options = 1 << 0
+	OPTION_EXTEND                                 Options = 1 << 1
+	OPTION_MULTILINE                              Options = 1 << 2
+	OPTION_SINGLELINE                             Options = 1 << 3
+	OPTION_FIND_LONGEST                           Options = 1 << 4
+	OPTION_FIND_NOT_EMPTY                         Options = 1 << 5
+	OPTION_NEGATE_SINGLELINE                      Options = 1 << 6
+	OPTION_DONT_CAPTURE_GROUP                     Options = 1 << 7
+	OPTION_CAPTURE_GROUP                          Options = 1 << 8
+	OPTION_IGNORECASE_IS_ASCII                    Options = 1 << 15
+	OPTION_WORD_IS_ASCII                          Options = 1 << 16
+	OPTION_DIGIT_IS_ASCII                         Options = 1 << 17
+	OPTION_SPACE_IS_ASCII                         Options = 1 << 18
+	OPTION_POSIX_IS_ASCII                         Options = 1 << 19
+	OPTION_TEXT_SEGMENT_EXTENDED_GRAPHEME_CLUSTER Options = 1 << 20
+	OPTION_TEXT_SEGMENT_WORD                      Options = 1 << 21
+)
+
+// Flavor is a regex syntax: the operators a pattern can use and how they behave.
+// The predefined flavors have the flags of the Oniguruma syntaxes of the same names.
+type Flavor struct {
+	Name     string
+	Op       Op
+	Op2      Op2
+	Behavior Behavior
+	// Options are the options that patterns of the flavor are always parsed with.
+	Options Options
+}
+
+// unsupportedOps are the operators of the flavors the parser doesn't handle, such as POSIX basic
+// regular expressions, where groups and repetitions are escaped.
+const unsupportedOps = SYN_OP_VARIABLE_META_CHARACTERS | SYN_OP_ESC_ASTERISK_ZERO_INF | SYN_OP_ESC_PLUS_ONE_INF |
+	SYN_OP_ESC_QMARK_ZERO_ONE | SYN_OP_ESC_BRACE_INTERVAL | SYN_OP_ESC_VBAR_ALT | SYN_OP_ESC_LPAREN_SUBEXP
+
+const unsupportedOps2 = SYN_OP2_ATMARK_CAPTURE_HISTORY | SYN_OP2_INEFFECTIVE_ESCAPE
+
+const unsupportedBehaviors = SYN_BRE_ANCHOR_AT_EDGE_OF_SUBEXP
+
+// Supported reports whether Parse handles patterns of the flavor. Flavors with escaped operators,
+// such as POSIX basic regular expressions, Emacs and grep, and flavors with capture history aren't supported.
+func (f *Flavor) Supported() bool {
+	return f.Op&unsupportedOps == 0 && f.Op2&unsupportedOps2 == 0 && f.Behavior&unsupportedBehaviors == 0
+}
+
+// FlavorRuby is the Ruby syntax.
+var FlavorRuby = &Flavor{
+	Name:     "ruby",
+	Op:       0xfff7d556,
+	Op2:      0x06eb7bda,
+	Behavior: 0x83a003db,
+}
+
+// FlavorOniguruma is the Oniguruma syntax.
+var FlavorOniguruma = &Flavor{
+	Name:     "oniguruma",
+	Op:       0xfff7d556,
+	Op2:      0x77eb7bd2,
+	Behavior: 0x87a02bdb,
+}
+
+// FlavorPerl is the Perl syntax.
+var FlavorPerl = &Flavor{
+	Name:     "perl",
+	Op:       0xfff7d556,
+	Op2:      0x37e30037,
+	Behavior: 0x80a0040b,
+	Options:  OPTION_SINGLELINE,
+}
+
+// FlavorPerlNG is the Perl syntax with named groups.
+var FlavorPerlNG = &Flavor{
+	Name:     "perl_ng",
+	Op:       0xfff7d556,
+	Op2:      0x3fe303b7,
+	Behavior: 0x80a0058b,
+	Options:  OPTION_SINGLELINE,
+}
+
+// FlavorPython is the Python syntax.
+var FlavorPython = &Flavor{
+	Name:     "python",
+	Op:       0x3ff7d556,
+	Op2:      0xa0636006,
+	Behavior: 0x80a0141b,
+	Options:  OPTION_SINGLELINE,
+}
+
+// FlavorJava is the Java syntax.
+var FlavorJava = &Flavor{
+	Name:     "java",
+	Op:       0x3ff7d556,
+	Op2:      0x00016077,
+	Behavior: 0x80a00c4b,
+	Options:  OPTION_SINGLELINE,
+}
+
+// FlavorPosixExtended is the POSIX extended regular expression syntax.
+var FlavorPosixExtended = &Flavor{
+	Name:     "posix_extended",
+	Op:       0x05831556,
+	Behavior: 0x80800007,
+	Options:  OPTION_SINGLELINE,
+}
+
+// FlavorGnuRegex is the GNU regex syntax.
+var FlavorGnuRegex = &Flavor{
+	Name:     "gnu_regex",
+	Op:       0x01ffd556,
+	Behavior: 0x80a0000b,
+}
+
+// Flavors returns the predefined flavors.
+func Flavors() []*Flavor {
+	return []*Flavor{FlavorRuby, FlavorOniguruma, FlavorPerl, FlavorPerlNG, FlavorPython, FlavorJava, FlavorPosixExtended, FlavorGnuRegex}
+}
@@ -0,0 +1,518 @@
+package ast
+
+// bagKind tells how prsExp continues after the node returned by prsBag.
+type bagKind int
+
+const (
+	// bagGroup is a node that can be followed by quantifiers.
+	bagGroup bagKind = iota
+	// bagOption is an isolated option setting such as (?i).
+	bagOption
+)
+
+// prsBag parses a parenthesized construct, with the position after the opening parenthesis. It returns with
+// the position after the closing parenthesis.
+func (p *parser) prsBag(open *token) (Node, bagKind, error) {
+	start := open.start
+	if open.perlCall {
+		// Oniguruma parses (?&name) and (?R) as groups starting with the ampersand or the R.
+		return p.capture(start, "")
+	}
+	if p.end() {
+		return nil, bagGroup, p.errorf(ERR_END_PATTERN_WITH_UNMATCHED_PARENTHESIS)
+	}
+	op2 := p.flavor.Op2
+	if !p.peekIs('?') || op2&SYN_OP2_QMARK_GROUP_EFFECT == 0 {
+		if p.peekIs('*') && op2&SYN_OP2_ASTERISK_CALLOUT_NAME != 0 {
+			p.pos++
+			callout, err := p.calloutOfName(start)
+			return callout, bagGroup, err
+		}
+		if p.options&OPTION_DONT_CAPTURE_GROUP != 0 {
+			return p.group(&Group{Span: Span{Start: start}, Kind: GroupNonCapture})
+		}
+		return p.capture(start, "")
+	}
+	p.pos++
+	if p.end() {
+		return nil, bagGroup, p.errorf(ERR_END_PATTERN_IN_GROUP)
+	}
+	c := p.fetch()
+	switch c {
+	case ':':
+		return p.group(&Group{Span: Span{Start: start}, Kind: GroupNonCapture})
+	case '=':
+		return p.group(&Group{Span: Span{Start: start}, Kind: GroupLookahead})
+	case '!':
+		return p.group(&Group{Span: Span{Start: start}, Kind: GroupNegativeLookahead})
+	case '>':
+		return p.group(&Group{Span: Span{Start: start}, Kind: GroupAtomic})
+	case '~':
+		if op2&SYN_OP2_QMARK_TILDE_ABSENT_GROUP == 0 {
+			return nil, bagGroup, p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+		}
+		absent, err := p.absent(start)
+		return absent, bagGroup, err
+	case '\'':
+		if op2&SYN_OP2_QMARK_LT_NAMED_GROUP == 0 {
+			return nil, bagGroup, p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+		}
+		return p.namedGroup(start, c)
+	case '<':
+		if p.end() {
+			return nil, bagGroup, p.errorf(ERR_END_PATTERN_WITH_UNMATCHED_PARENTHESIS)
+		}
+		switch next, _ := p.peek(); next {
+		case '=':
+			p.pos++
+			return p.group(&Group{Span: Span{Start: start}, Kind: GroupLookbehind})
+		case '!':
+			p.pos++
+			return p.group(&Group{Span: Span{Start: start}, Kind: GroupNegativeLookbehind})
+		}
+		if op2&SYN_OP2_QMARK_LT_NAMED_GROUP == 0 {
+			return nil, bagGroup, p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+		}
+		return p.namedGroup(start, c)
+	case 'P':
+		if op2&SYN_OP2_QMARK_CAPITAL_P_NAME != 0 {
+			return p.capitalP(start)
+		}
+	case '(':
+		if op2&SYN_OP2_QMARK_LPAREN_IF_ELSE != 0 {
+			cond, err := p.conditional(start)
+			return cond, bagGroup, err
+		}
+	case '{':
+		if op2&SYN_OP2_QMARK_BRACE_CALLOUT_CONTENTS == 0 {
+			return nil, bagGroup, p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+		}
+		callout, err := p.calloutOfContents(start)
+		return callout, bagGroup, err
+	}
+	return p.optionGroup(start, c)
+}
+
+// group parses the content of a group up to the closing parenthesis.
+func (p *parser) group(g *Group) (Node, bagKind, error) {
+	if err := p.next(); err != nil {
+		return nil, bagGroup, err
+	}
+	sub, err := p.prsAlts(tokClose)
+	if err != nil {
+		return nil, bagGroup, err
+	}
+	g.Sub = sub
+	g.End = p.pos
+	return g, bagGroup, nil
+}
+
+// capture parses a capturing group, named when name isn't empty.
+func (p *parser) capture(start int, name string) (Node, bagKind, error) {
+	if p.captures >= maxCaptureNum {
+		return nil, bagGroup, p.errorf(ERR_TOO_MANY_CAPTURES)
+	}
+	p.captures++
+	g := &Group{Span: Span{Start: start}, Kind: GroupCapture, Index: p.captures, Name: name}
+	p.groups = append(p.groups, g)
+	p.closed = append(p.closed, false)
+	if name != "" {
+		if len(p.names[name]) > 0 && p.flavor.Behavior&SYN_ALLOW_MULTIPLEX_DEFINITION_NAME == 0 {
+			return nil, bagGroup, p.errorf(ERR_MULTIPLEX_DEFINED_NAME)
+		}
+		p.names[name] = append(p.names[name], g.Index)
+		p.numNamed++
+	}
+	node, kind, err := p.group(g)
+	if err != nil {
+		return nil, bagGroup, err
+	}
+	p.closed[g.Index] = true
+	return node, kind, nil
+}
+
+// namedGroup parses a named group, with the position after the character opening the name.
+func (p *parser) namedGroup(start int, open rune) (Node, bagKind, error) {
+	name, _, _, err := p.fetchName(open, false)
+	if err != nil {
+		return nil, bagGroup, err
+	}
+	return p.capture(start, name)
+}
+
+// capitalP parses the Python (?P<name>...), (?P=name) and (?P>name) forms, with the position after the P.
+func (p *parser) capitalP(start int) (Node, bagKind, error) {
+	if p.end() {
+		return nil, bagGroup, p.errorf(ERR_END_PATTERN_IN_GROUP)
+	}
+	switch c := p.fetch(); c {
+	case '<':
+		return p.namedGroup(start, c)
+	case '=':
+		tok := &token{start: start}
+		name, kind, _, err := p.fetchNameWithLevel('(', tok)
+		if err != nil {
+			return nil, bagGroup, err
+		}
+		if kind != refName {
+			return nil, bagGroup, p.errorf(ERR_INVALID_BACKREF)
+		}
+		groups := p.names[name]
+		if len(groups) == 0 {
+			return nil, bagGroup, p.errorf(ERR_UNDEFINED_NAME_REFERENCE)
+		}
+		tok.name = name
+		tok.byName = true
+		tok.refs = append([]int(nil), groups...)
+		tok.end = p.pos
+		return p.newBackref(tok), bagGroup, nil
+	case '>':
+		name, kind, _, err := p.fetchName('(', true)
+		if err != nil {
+			return nil, bagGroup, err
+		}
+		if kind != refName {
+			return nil, bagGroup, p.errorf(ERR_UNDEFINED_GROUP_REFERENCE)
+		}
+		call := &Call{Span: Span{start, p.pos}, Name: name}
+		p.calls = append(p.calls, call)
+		return call, bagGroup, nil
+	}
+	return nil, bagGroup, p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+}
+
+// newBackref returns the node of a back reference token.
+func (p *parser) newBackref(tok *token) *Backref {
+	ref := &Backref{Span: Span{tok.start, tok.end}, Groups: tok.refs, Level: tok.level, HasLevel: tok.hasLevel,
+		IgnoreCase: p.options&OPTION_IGNORECASE != 0}
+	if tok.byName {
+		ref.Name = tok.name
+	}
+	for _, num := range tok.refs {
+		if num <= p.captures && !p.closed[num] {
+			p.recursiveRefs[ref] = true
+			break
+		}
+	}
+	return ref
+}
+
+// absent parses an absent group, with the position after the tilde.
+func (p *parser) absent(start int) (Node, error) {
+	if p.end() {
+		return nil, p.errorf(ERR_END_PATTERN_IN_GROUP)
+	}
+	headBar := false
+	if p.peekIs('|') {
+		p.pos++
+		if p.end() {
+			return nil, p.errorf(ERR_END_PATTERN_IN_GROUP)
+		}
+		if p.peekIs(')') {
+			p.pos++
+			return &Absent{Span: Span{start, p.pos}, Kind: AbsentClear}, nil
+		}
+		headBar = true
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	p.groupHead = true
+	sub, err := p.prsAlts(tokClose)
+	if err != nil {
+		return nil, err
+	}
+	node := &Absent{Span: Span{start, p.pos}, Kind: AbsentRepeater, Absent: sub}
+	if headBar {
+		alt, ok := sub.(*Alternation)
+		switch {
+		case !ok:
+			node.Kind = AbsentStopper
+		case len(alt.Alternatives) == 2:
+			node.Kind = AbsentExpression
+			node.Absent, node.Expr = alt.Alternatives[0], alt.Alternatives[1]
+		default:
+			rest := alt.Alternatives[1:]
+			node.Kind = AbsentExpression
+			node.Absent = alt.Alternatives[0]
+			node.Expr = &Alternation{Span: Span{rest[0].Pos().Start, alt.End}, Alternatives: rest}
+		}
+	}
+	return node, nil
+}
+
+// conditional parses a conditional group, with the position after the parenthesis opening the condition.
+func (p *parser) conditional(start int) (Node, error) {
+	if p.end() {
+		return nil, p.errorf(ERR_END_PATTERN_IN_GROUP)
+	}
+	condStart := p.pos
+	c := p.fetch()
+	if p.end() {
+		return nil, p.errorf(ERR_END_PATTERN_IN_GROUP)
+	}
+	var cond Node
+	checker := false
+	switch {
+	case isDigit(c) || c == '-' || c == '+' || c == '<' || c == '\'':
+		enclosed := c == '<' || c == '\''
+		open := '('
+		if enclosed {
+			open = c
+		} else {
+			p.pos = condStart
+		}
+		ref, err := p.checker(condStart, open)
+		if e, ok := err.(*Error); ok && (enclosed || e.Code == ERR_INVALID_BACKREF) {
+			// Only an unenclosed condition that isn't a number can be an expression.
+			return nil, err
+		}
+		if err == nil {
+			if enclosed {
+				if !p.peekIs(')') {
+					return nil, p.errorf(ERR_END_PATTERN_IN_GROUP)
+				}
+				p.pos++
+			}
+			ref.End = p.pos
+			cond, checker = ref, true
+			break
+		}
+		fallthrough
+	default:
+		if c == '?' && p.peekIs('{') && p.flavor.Op2&SYN_OP2_QMARK_BRACE_CALLOUT_CONTENTS != 0 {
+			p.pos++
+			callout, err := p.calloutOfContents(condStart)
+			if err != nil {
+				return nil, err
+			}
+			cond = callout
+			break
+		}
+		if c == '*' && p.flavor.Op2&SYN_OP2_ASTERISK_CALLOUT_NAME != 0 {
+			callout, err := p.calloutOfName(condStart)
+			if err != nil {
+				return nil, err
+			}
+			cond = callout
+			break
+		}
+		p.pos = condStart
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		sub, err := p.prsAlts(tokClose)
+		if err != nil {
+			return nil, err
+		}
+		cond = sub
+	}
+	if p.end() {
+		return nil, p.errorf(ERR_END_PATTERN_IN_GROUP)
+	}
+	node := &Conditional{Span: Span{Start: start}, Cond: cond}
+	if p.peekIs(')') {
+		if !checker {
+			return nil, p.errorf(ERR_INVALID_IF_ELSE_SYNTAX)
+		}
+		p.pos++
+		node.End = p.pos
+		return node, nil
+	}
+	thenEmpty := false
+	if p.peekIs('|') {
+		p.pos++
+		thenEmpty = true
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	_, callout := cond.(*Callout)
+	p.groupHead = checker || callout
+	target, err := p.prsAlts(tokClose)
+	if err != nil {
+		return nil, err
+	}
+	node.End = p.pos
+	alt, isAlt := target.(*Alternation)
+	switch {
+	case thenEmpty:
+		node.No = target
+	case !isAlt:
+		node.Yes = target
+	case len(alt.Alternatives) == 2:
+		node.Yes, node.No = alt.Alternatives[0], alt.Alternatives[1]
+	default:
+		rest := alt.Alternatives[1:]
+		node.Yes = alt.Alternatives[0]
+		node.No = &Alternation{Span: Span{rest[0].Pos().Start, alt.End}, Alternatives: rest}
+	}
+	return node, nil
+}
+
+// checker reads the group reference of the condition of a conditional group, with the position after the
+// character opening the reference.
+func (p *parser) checker(start int, open rune) (*Backref, error) {
+	tok := &token{start: start}
+	name, kind, num, err := p.fetchNameWithLevel(open, tok)
+	if err != nil {
+		return nil, err
+	}
+	if kind != refName {
+		if kind == refRelative {
+			num = p.relativeToAbsolute(num)
+		}
+		if num <= 0 {
+			return nil, p.errorf(ERR_INVALID_BACKREF)
+		}
+		tok.refs = []int{num}
+	} else {
+		groups := p.names[name]
+		if len(groups) == 0 {
+			return nil, p.errorf(ERR_UNDEFINED_NAME_REFERENCE)
+		}
+		tok.name = name
+		tok.byName = true
+		tok.refs = append([]int(nil), groups...)
+	}
+	tok.end = p.pos
+	ref := p.newBackref(tok)
+	p.checkers[ref] = true
+	return ref, nil
+}
+
+// optionGroup parses the option letters of an option group such as (?i) or (?i-x:...), with c the first letter.
+func (p *parser) optionGroup(start int, c rune) (Node, bagKind, error) {
+	op2 := p.flavor.Op2
+	behavior := p.flavor.Behavior
+	var on, off Options
+	set := func(options Options, value bool) {
+		if value {
+			on, off = on|options, off&^options
+		} else {
+			on, off = on&^options, off|options
+		}
+	}
+	if c == ':' || c == ')' {
+		// (?) and (?:) aren't groups of options.
+		return nil, bagGroup, p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+	}
+	neg := false
+	whole := false
+	for {
+		switch c {
+		case ':', ')':
+		case '-':
+			neg = true
+		case 'x':
+			set(OPTION_EXTEND, !neg)
+		case 'i':
+			set(OPTION_IGNORECASE, !neg)
+		case 's':
+			if op2&SYN_OP2_OPTION_PERL == 0 {
+				return nil, bagGroup, p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+			}
+			set(OPTION_MULTILINE, !neg)
+		case 'm':
+			switch {
+			case op2&SYN_OP2_OPTION_PERL != 0:
+				set(OPTION_SINGLELINE, neg)
+			case op2&(SYN_OP2_OPTION_ONIGURUMA|SYN_OP2_OPTION_RUBY) != 0:
+				set(OPTION_MULTILINE, !neg)
+			default:
+				return nil, bagGroup, p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+			}
+		case 'W', 'D', 'S', 'P':
+			if op2&SYN_OP2_OPTION_ONIGURUMA == 0 {
+				return nil, bagGroup, p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+			}
+			set(map[rune]Options{'W': OPTION_WORD_IS_ASCII, 'D': OPTION_DIGIT_IS_ASCII, 'S': OPTION_SPACE_IS_ASCII,
+				'P': OPTION_POSIX_IS_ASCII}[c], !neg)
+		case 'y':
+			if op2&SYN_OP2_OPTION_ONIGURUMA == 0 || neg {
+				return nil, bagGroup, p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+			}
+			if err := p.textSegmentOption(set); err != nil {
+				return nil, bagGroup, err
+			}
+		case 'a':
+			if behavior&SYN_PYTHON == 0 {
+				return nil, bagGroup, p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+			}
+			set(OPTION_WORD_IS_ASCII|OPTION_DIGIT_IS_ASCII|OPTION_SPACE_IS_ASCII|OPTION_POSIX_IS_ASCII, !neg)
+		case 'C', 'I', 'L':
+			if behavior&SYN_WHOLE_OPTIONS == 0 {
+				return nil, bagGroup, p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+			}
+			if neg {
+				return nil, bagGroup, p.errorf(ERR_INVALID_GROUP_OPTION)
+			}
+			set(map[rune]Options{'C': OPTION_DONT_CAPTURE_GROUP, 'I': OPTION_IGNORECASE_IS_ASCII,
+				'L': OPTION_FIND_LONGEST}[c], true)
+			whole = true
+		default:
+			return nil, bagGroup, p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+		}
+		if c == ')' || c == ':' {
+			break
+		}
+		if p.end() {
+			return nil, bagGroup, p.errorf(ERR_END_PATTERN_IN_GROUP)
+		}
+		c = p.fetch()
+	}
+	var node Node
+	if c == ')' {
+		node = &Flags{Span: Span{start, p.pos}, On: on, Off: off}
+	} else {
+		saved := p.options
+		p.options = p.options&^off | on
+		g, _, err := p.group(&Group{Span: Span{Start: start}, Kind: GroupOptions, On: on, Off: off})
+		if err != nil {
+			return nil, bagGroup, err
+		}
+		p.options = saved
+		node = g
+	}
+	if whole {
+		if p.whole != nil {
+			return nil, bagGroup, p.errorf(ERR_INVALID_GROUP_OPTION)
+		}
+		p.whole = node
+	}
+	if c == ')' {
+		return node, bagOption, nil
+	}
+	return node, bagGroup, nil
+}
+
+// textSegmentOption reads the {g} or {w} of the y option, with the position after the y.
+func (p *parser) textSegmentOption(set func(Options, bool)) error {
+	if p.end() {
+		return p.errorf(ERR_END_PATTERN_IN_GROUP)
+	}
+	if !p.peekIs('{') {
+		return p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+	}
+	p.pos++
+	if p.end() {
+		return p.errorf(ERR_END_PATTERN_IN_GROUP)
+	}
+	switch p.fetch() {
+	case 'g':
+		set(OPTION_TEXT_SEGMENT_EXTENDED_GRAPHEME_CLUSTER, true)
+		set(OPTION_TEXT_SEGMENT_WORD, false)
+	case 'w':
+		set(OPTION_TEXT_SEGMENT_WORD, true)
+		set(OPTION_TEXT_SEGMENT_EXTENDED_GRAPHEME_CLUSTER, false)
+	default:
+		return p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+	}
+	if p.end() {
+		return p.errorf(ERR_END_PATTERN_IN_GROUP)
+	}
+	if p.fetch() != '}' {
+		return p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+	}
+	return nil
+}
@@ -0,0 +1,732 @@
+package ast
+
+import (
+	"unicode"
+)
+
+// tokenKind is the kind of a token of the pattern.
+type tokenKind int
+
+const (
+	tokEOT tokenKind = iota
+	tokString
+	tokCrudeByte
+	tokCodePoint
+	tokAnyChar
+	tokCharType
+	tokCharProperty
+	tokBackref
+	tokCall
+	tokAnchor
+	tokRepeat
+	tokInterval
+	tokAlt
+	tokOpen
+	tokClose
+	tokOpenClass
+	tokQuoteOpen
+	tokKeep
+	tokGeneralNewline
+	tokNoNewline
+	tokTrueAnyChar
+	tokTextSegment
+	// Tokens inside a character class.
+	tokClassClose
+	tokClassRange
+	tokClassPosixOpen
+	tokClassAnd
+	tokClassOpen
+)
+
+// refKind is the kind of a group reference read by fetchName.
+type refKind int
+
+const (
+	refName refKind = iota
+	refAbsolute
+	refRelative
+)
+
+// maxBackrefNum is the largest number of a back reference written as \n.
+const maxBackrefNum = 1000
+
+// maxRepeatNum is the largest number in an interval.
+const maxRepeatNum = 100000
+
+// maxCaptureNum is the largest number of capturing groups.
+const maxCaptureNum = 32767
+
+// maxCodePoint is the largest code point of a character outside a class, and maxClassCodePoint inside one.
+const (
+	maxCodePoint      = 0x13ffff
+	maxClassCodePoint = 0x1fffff
+)
+
+// token is a token of the pattern.
+type token struct {
+	kind  tokenKind
+	start int
+	end   int
+	// backp is the offset of the character of a string token, after the backslash of an escape.
+	backp   int
+	escaped bool
+	base    int
+	code    rune
+	// codes are the following code points of a \x{...} or \o{...} sequence, and ranges the indices of the codes
+	// that are preceded by a range operator, inside a class.
+	codes  []rune
+	ranges []int
+
+	// Repetitions.
+	lower, upper int
+	greedy       bool
+	possessive   bool
+	fixed        bool
+
+	// Character types, properties and anchors.
+	ctype   CharTypeKind
+	negated bool
+	anchor  AnchorKind
+
+	// Back references and calls.
+	name     string
+	refs     []int
+	byName   bool
+	level    int
+	hasLevel bool
+	group    int
+	byNumber bool
+	// perlCall is set for the (?&name) and (?R) forms, which are parsed as capturing groups.
+	perlCall bool
+}
+
+// peek returns the character at the current position and its size in bytes, or -1 at the end of the pattern.
+func (p *parser) peek() (rune, int) {
+	return p.charAt(p.pos)
+}
+
+// charAt returns the character at offset i of the pattern, decoded the way Oniguruma decodes UTF-8,
+// and its size in bytes, or -1 at the end of the pattern.
+func (p *parser) charAt(i int) (rune, int) {
+	if i >= len(p.pattern) {
+		return -1, 0
+	}
+	c := p.pattern[i]
+	size := utf8Len(c)
+	if size > len(p.pattern)-i {
+		size = len(p.pattern) - i
+	}
+	if size == 1 {
+		return rune(c), 1
+	}
+	n := rune(c) & (1<<(7-size) - 1)
+	for j := 1; j < size; j++ {
+		n = n<<6 | rune(p.pattern[i+j])&0x3f
+	}
+	return n, size
+}
+
+// utf8Len returns the length of the UTF-8 sequence led by byte c, with Oniguruma's table,
+// in which the bytes from 0xf8 on don't lead longer sequences.
+func utf8Len(c byte) int {
+	switch {
+	case c < 0xc0 || c >= 0xf8:
+		return 1
+	case c < 0xe0:
+		return 2
+	case c < 0xf0:
+		return 3
+	}
+	return 4
+}
+
+func (p *parser) end() bool {
+	return p.pos >= len(p.pattern)
+}
+
+// fetch returns the character at the current position and moves past it, or -1 at the end of the pattern.
+func (p *parser) fetch() rune {
+	c, size := p.peek()
+	p.pos += size
+	return c
+}
+
+func (p *parser) peekIs(c rune) bool {
+	next, _ := p.peek()
+	return next == c
+}
+
+func (p *parser) errorf(code ErrorCode) error {
+	return &Error{Code: code, Offset: p.pos}
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isOctalDigit(c rune) bool {
+	return c >= '0' && c <= '7'
+}
+
+func isHexDigit(c rune) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c rune) rune {
+	switch {
+	case isDigit(c):
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+// isWordChar reports whether c is a word character, as allowed in group names.
+func isWordChar(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c) || unicode.IsMark(c) ||
+		unicode.Is(unicode.Pc, c) || unicode.Is(unicode.Nl, c) || unicode.Is(unicode.Other_Alphabetic, c) ||
+		c == 0x200c || c == 0x200d
+}
+
+// scanNumber reads a decimal number. It returns -1 when the number overflows an int32, and -2 when there's no digit.
+func (p *parser) scanNumber() int {
+	start := p.pos
+	num := 0
+	for !p.end() && isDigit(rune(p.pattern[p.pos])) {
+		val := int(p.pattern[p.pos] - '0')
+		if (1<<31-1-val)/10 < num {
+			return -1
+		}
+		num = num*10 + val
+		p.pos++
+	}
+	if p.pos == start {
+		return -2
+	}
+	return num
+}
+
+// scanBase reads at most maxDigits digits of the given base. It returns the number of digits read,
+// or an error when the value overflows 32 bits.
+func (p *parser) scanBase(base int, maxDigits int) (rune, int, error) {
+	var value uint64
+	digits := 0
+	for digits < maxDigits && !p.end() {
+		c := rune(p.pattern[p.pos])
+		if (base == 16 && !isHexDigit(c)) || (base == 8 && !isOctalDigit(c)) {
+			break
+		}
+		value = value*uint64(base) + uint64(hexValue(c))
+		if value > 0xffffffff {
+			return 0, 0, p.errorf(ERR_TOO_BIG_NUMBER)
+		}
+		p.pos++
+		digits++
+	}
+	return rune(value), digits, nil
+}
+
+// baseDigits is the largest number of digits of a code point between braces.
+func baseDigits(base int) int {
+	if base == 16 {
+		return 8
+	}
+	return 11
+}
+
+// checkTooLong returns an error if a digit follows the digits of a code point between braces.
+func (p *parser) checkTooLong(base int) error {
+	c, _ := p.peek()
+	if (base == 16 && isHexDigit(c)) || (base == 8 && isDigit(c)) {
+		return p.errorf(ERR_TOO_LONG_WIDE_CHAR_VALUE)
+	}
+	return nil
+}
+
+// fetchBraceCodePoint reads a \x{...} or \o{...} code point or sequence of code points, with the position after
+// the opening brace. It returns false, with the position unchanged, when there's no digit after the brace.
+func (p *parser) fetchBraceCodePoint(tok *token, base int, inClass bool) (bool, error) {
+	start := p.pos
+	code, digits, err := p.scanBase(base, baseDigits(base))
+	if err != nil {
+		return false, err
+	}
+	if err := p.checkTooLong(base); err != nil {
+		return false, err
+	}
+	if digits == 0 {
+		p.pos = start
+		return false, nil
+	}
+	if p.end() {
+		return false, p.errorf(ERR_INVALID_CODE_POINT_VALUE)
+	}
+	tok.kind = tokCodePoint
+	tok.base = base
+	tok.code = code
+	if p.peekIs('}') {
+		p.pos++
+		return true, nil
+	}
+	// A sequence of code points separated by spaces, and ranges inside a class.
+	count := 0
+	inRange := false
+	rangeAllowed := inClass
+	for {
+		for p.peekIs(' ') {
+			p.pos++
+		}
+		if p.end() {
+			return false, p.errorf(ERR_INVALID_CODE_POINT_VALUE)
+		}
+		if p.peekIs('}') {
+			if count == 0 || inRange {
+				return false, p.errorf(ERR_INVALID_CODE_POINT_VALUE)
+			}
+			p.pos++
+			return true, nil
+		}
+		if p.peekIs('-') && inClass {
+			if !rangeAllowed || inRange {
+				return false, p.errorf(ERR_INVALID_CODE_POINT_VALUE)
+			}
+			p.pos++
+			inRange = true
+			continue
+		}
+		next, digits, err := p.scanBase(base, baseDigits(base))
+		if err != nil {
+			return false, err
+		}
+		if err := p.checkTooLong(base); err != nil {
+			return false, err
+		}
+		if digits == 0 {
+			return false, p.errorf(ERR_INVALID_CODE_POINT_VALUE)
+		}
+		if c, _ := p.peek(); c != ' ' && c != '}' && !(c == '-' && inClass) {
+			return false, p.errorf(ERR_INVALID_CODE_POINT_VALUE)
+		}
+		if inRange {
+			tok.ranges = append(tok.ranges, len(tok.codes))
+			// A range can't start at the end of another range.
+			rangeAllowed = false
+		} else {
+			rangeAllowed = inClass
+		}
+		inRange = false
+		tok.codes = append(tok.codes, next)
+		count++
+	}
+}
+
+// fetchEscapedValue reads the value of an escape that stands for a character, such as \n, \cx or \M-x,
+// with the position after the backslash.
+func (p *parser) fetchEscapedValue() (rune, error) {
+	if p.end() {
+		return 0, p.errorf(ERR_END_PATTERN_AT_ESCAPE)
+	}
+	c := p.fetch()
+	switch c {
+	case 'M':
+		if p.flavor.Op2&SYN_OP2_ESC_CAPITAL_M_BAR_META == 0 {
+			return p.convBackslashValue(c), nil
+		}
+		if p.end() {
+			return 0, p.errorf(ERR_END_PATTERN_AT_META)
+		}
+		if p.fetch() != '-' {
+			return 0, p.errorf(ERR_META_CODE_SYNTAX)
+		}
+		if p.end() {
+			return 0, p.errorf(ERR_END_PATTERN_AT_META)
+		}
+		c = p.fetch()
+		if c == '\\' {
+			var err error
+			if c, err = p.fetchEscapedValue(); err != nil {
+				return 0, err
+			}
+		}
+		return c&0xff | 0x80, nil
+	case 'C':
+		if p.flavor.Op2&SYN_OP2_ESC_CAPITAL_C_BAR_CONTROL == 0 {
+			return p.convBackslashValue(c), nil
+		}
+		if p.end() {
+			return 0, p.errorf(ERR_END_PATTERN_AT_CONTROL)
+		}
+		if p.fetch() != '-' {
+			return 0, p.errorf(ERR_CONTROL_CODE_SYNTAX)
+		}
+		return p.fetchControl()
+	case 'c':
+		if p.flavor.Op&SYN_OP_ESC_C_CONTROL == 0 {
+			return p.convBackslashValue(c), nil
+		}
+		return p.fetchControl()
+	}
+	return p.convBackslashValue(c), nil
+}
+
+// fetchControl reads the character of a control escape such as \cx or \C-x.
+func (p *parser) fetchControl() (rune, error) {
+	if p.end() {
+		return 0, p.errorf(ERR_END_PATTERN_AT_CONTROL)
+	}
+	c := p.fetch()
+	if c == '?' {
+		return 0x7f, nil
+	}
+	if c == '\\' {
+		var err error
+		if c, err = p.fetchEscapedValue(); err != nil {
+			return 0, err
+		}
+	}
+	return c & 0x9f, nil
+}
+
+func (p *parser) convBackslashValue(c rune) rune {
+	if p.flavor.Op&SYN_OP_ESC_CONTROL_CHARS != 0 {
+		switch c {
+		case 'n':
+			return '\n'
+		case 't':
+			return '\t'
+		case 'r':
+			return '\r'
+		case 'f':
+			return '\f'
+		case 'a':
+			return '\a'
+		case 'b':
+			return '\b'
+		case 'e':
+			return 0x1b
+		case 'v':
+			if p.flavor.Op2&SYN_OP2_ESC_V_VTAB != 0 {
+				return '\v'
+			}
+		}
+	}
+	return c
+}
+
+// nameEndCode returns the character that closes a name opened by start.
+func nameEndCode(start rune) rune {
+	switch start {
+	case '<':
+		return '>'
+	case '\'':
+		return '\''
+	case '(':
+		return ')'
+	}
+	return 0
+}
+
+// fetchName reads the name or number of a group definition or of a call, with the position after the opening
+// character. isRef allows numbers, for calls. It returns the name, its kind and the number for a number.
+func (p *parser) fetchName(start rune, isRef bool) (string, refKind, int, error) {
+	endCode := nameEndCode(start)
+	nameStart := p.pos
+	kind := refName
+	numStart := p.pos
+	sign := 1
+	digits := 0
+	if p.end() {
+		return "", kind, 0, p.errorf(ERR_EMPTY_GROUP_NAME)
+	}
+	c := p.fetch()
+	if c == endCode {
+		return "", kind, 0, p.errorf(ERR_EMPTY_GROUP_NAME)
+	}
+	var code ErrorCode
+	switch {
+	case isDigit(c):
+		if !isRef {
+			code = ERR_INVALID_GROUP_NAME
+		}
+		kind = refAbsolute
+		digits++
+	case c == '-' || c == '+':
+		if !isRef {
+			code = ERR_INVALID_GROUP_NAME
+		}
+		kind = refRelative
+		if c == '-' {
+			sign = -1
+		}
+		numStart = p.pos
+	case !isWordChar(c):
+		code = ERR_INVALID_CHAR_IN_GROUP_NAME
+	}
+	nameEnd := len(p.pattern)
+	if code == 0 {
+		for !p.end() {
+			nameEnd = p.pos
+			c = p.fetch()
+			if c == endCode || c == ')' {
+				if kind != refName && digits == 0 {
+					code = ERR_INVALID_GROUP_NAME
+				}
+				break
+			}
+			if kind != refName {
+				if isDigit(c) {
+					digits++
+				} else {
+					kind = refName
+				}
+			}
+		}
+		if code == 0 && c != endCode {
+			code = ERR_INVALID_GROUP_NAME
+		}
+	}
+	if code != 0 {
+		return "", kind, 0, p.errorf(code)
+	}
+	name := p.pattern[nameStart:nameEnd]
+	if kind == refName {
+		return name, kind, 0, nil
+	}
+	num := 0
+	for _, d := range p.pattern[numStart:nameEnd] {
+		num = num*10 + int(d-'0')
+		if num > 1<<31-1 {
+			return "", kind, 0, p.errorf(ERR_TOO_BIG_NUMBER)
+		}
+	}
+	if num == 0 && kind == refRelative {
+		return "", kind, 0, p.errorf(ERR_INVALID_GROUP_NAME)
+	}
+	return name, kind, num * sign, nil
+}
+
+// fetchNameWithLevel reads the name or number of a back reference, with an optional nest level such as
+// \k<name+1>, with the position after the opening character.
+func (p *parser) fetchNameWithLevel(start rune, tok *token) (string, refKind, int, error) {
+	endCode := nameEndCode(start)
+	nameStart := p.pos
+	kind := refName
+	numStart := p.pos
+	sign := 1
+	digits := 0
+	if p.end() {
+		return "", kind, 0, p.errorf(ERR_EMPTY_GROUP_NAME)
+	}
+	c := p.fetch()
+	if c == endCode {
+		return "", kind, 0, p.errorf(ERR_EMPTY_GROUP_NAME)
+	}
+	var code ErrorCode
+	switch {
+	case isDigit(c):
+		kind = refAbsolute
+		digits++
+	case c == '-' || c == '+':
+		kind = refRelative
+		if c == '-' {
+			sign = -1
+		}
+		numStart = p.pos
+	case !isWordChar(c):
+		code = ERR_INVALID_CHAR_IN_GROUP_NAME
+	}
+	nameEnd := len(p.pattern)
+	for !p.end() {
+		nameEnd = p.pos
+		c = p.fetch()
+		if c == endCode || c == ')' || c == '+' || c == '-' {
+			if kind != refName && digits == 0 {
+				code = ERR_INVALID_GROUP_NAME
+			}
+			break
+		}
+		if kind != refName {
+			if isDigit(c) {
+				digits++
+			} else {
+				code = ERR_INVALID_GROUP_NAME
+				kind = refName
+			}
+		} else if !isWordChar(c) {
+			code = ERR_INVALID_CHAR_IN_GROUP_NAME
+		}
+	}
+	if code == 0 && c != endCode {
+		code = ERR_INVALID_GROUP_NAME
+		if c == '+' || c == '-' {
+			flag := 1
+			if c == '-' {
+				flag = -1
+			}
+			if p.end() {
+				return "", kind, 0, p.errorf(ERR_INVALID_CHAR_IN_GROUP_NAME)
+			}
+			if next, _ := p.peek(); isDigit(next) {
+				level := p.scanNumber()
+				if level < 0 {
+					return "", kind, 0, p.errorf(ERR_TOO_BIG_NUMBER)
+				}
+				tok.level = level * flag
+				tok.hasLevel = true
+				if !p.end() && p.fetch() == endCode {
+					code = 0
+				}
+			}
+		}
+	}
+	if code != 0 {
+		return "", kind, 0, p.errorf(code)
+	}
+	name := p.pattern[nameStart:nameEnd]
+	if kind == refName {
+		return name, kind, 0, nil
+	}
+	num := 0
+	for _, d := range p.pattern[numStart:nameEnd] {
+		num = num*10 + int(d-'0')
+		if num > 1<<31-1 {
+			return "", kind, 0, p.errorf(ERR_TOO_BIG_NUMBER)
+		}
+	}
+	if num == 0 && kind == refRelative {
+		return "", kind, 0, p.errorf(ERR_INVALID_GROUP_NAME)
+	}
+	return name, kind, num * sign, nil
+}
+
+// relativeToAbsolute converts the number of a relative reference to the number of the group it refers to.
+func (p *parser) relativeToAbsolute(rel int) int {
+	if rel > 0 {
+		return p.captures + rel
+	}
+	return p.captures + 1 + rel
+}
+
+// fetchNamedBackref reads a back reference by name or number such as \k<name>, with the position after the
+// opening character.
+func (p *parser) fetchNamedBackref(start rune, tok *token) error {
+	name, kind, num, err := p.fetchNameWithLevel(start, tok)
+	if err != nil {
+		return err
+	}
+	tok.kind = tokBackref
+	if kind != refName {
+		if kind == refRelative {
+			num = p.relativeToAbsolute(num)
+		}
+		if num <= 0 {
+			return p.errorf(ERR_INVALID_BACKREF)
+		}
+		tok.refs = []int{num}
+		return nil
+	}
+	groups := p.names[name]
+	if len(groups) == 0 {
+		return p.errorf(ERR_UNDEFINED_NAME_REFERENCE)
+	}
+	tok.name = name
+	tok.byName = true
+	tok.refs = append([]int(nil), groups...)
+	return nil
+}
+
+// greedyCheck reads the ? or + that makes the repetition of the token lazy or possessive.
+func (p *parser) greedyCheck(tok *token) {
+	if !tok.possessive && p.peekIs('?') && p.flavor.Op&SYN_OP_QMARK_NON_GREEDY != 0 {
+		p.pos++
+		tok.greedy = false
+		return
+	}
+	p.possessiveCheck(tok)
+}
+
+func (p *parser) possessiveCheck(tok *token) {
+	tok.greedy = true
+	if p.peekIs('+') && ((tok.kind != tokInterval && p.flavor.Op2&SYN_OP2_PLUS_POSSESSIVE_REPEAT != 0) ||
+		(tok.kind == tokInterval && p.flavor.Op2&SYN_OP2_PLUS_POSSESSIVE_INTERVAL != 0)) {
+		p.pos++
+		tok.possessive = true
+	}
+}
+
+// fetchInterval reads an interval such as {n,m}, with the position after the opening brace. It returns false,
+// with the position unchanged, when the brace is a literal.
+func (p *parser) fetchInterval(tok *token) (bool, error) {
+	start := p.pos
+	allowInvalid := p.flavor.Behavior&SYN_ALLOW_INVALID_INTERVAL != 0
+	invalid := func() (bool, error) {
+		p.pos = start
+		if allowInvalid {
+			return false, nil
+		}
+		return false, p.errorf(ERR_INVALID_REPEAT_RANGE_PATTERN)
+	}
+	if p.end() {
+		if allowInvalid {
+			return false, nil
+		}
+		return false, p.errorf(ERR_END_PATTERN_AT_LEFT_BRACE)
+	}
+	if !allowInvalid {
+		if c, _ := p.peek(); c == ')' || c == '(' || c == '|' {
+			return false, p.errorf(ERR_END_PATTERN_AT_LEFT_BRACE)
+		}
+	}
+	low := p.scanNumber()
+	if low == -1 || low > maxRepeatNum {
+		return false, p.errorf(ERR_TOO_BIG_NUMBER_FOR_REPEAT_RANGE)
+	}
+	noLow := false
+	if low == -2 {
+		if p.flavor.Behavior&SYN_ALLOW_INTERVAL_LOW_ABBREV == 0 {
+			return invalid()
+		}
+		low = 0
+		noLow = true
+	}
+	if p.end() {
+		return invalid()
+	}
+	up := low
+	fixed := false
+	if p.fetch() == ',' {
+		up = p.scanNumber()
+		if up == -1 || up > maxRepeatNum {
+			return false, p.errorf(ERR_TOO_BIG_NUMBER_FOR_REPEAT_RANGE)
+		}
+		if up == -2 {
+			if noLow {
+				return invalid()
+			}
+			up = -1
+		}
+	} else {
+		if noLow {
+			return invalid()
+		}
+		p.pos--
+		fixed = true
+	}
+	if p.end() || p.fetch() != '}' {
+		return invalid()
+	}
+	tok.possessive = false
+	if up != -1 && low > up {
+		if p.flavor.Op2&SYN_OP2_PLUS_POSSESSIVE_INTERVAL != 0 {
+			return false, p.errorf(ERR_UPPER_SMALLER_THAN_LOWER_IN_REPEAT_RANGE)
+		}
+		tok.possessive = true
+		low, up = up, low
+	}
+	tok.kind = tokInterval
+	tok.lower = low
+	tok.upper = up
+	tok.fixed = fixed
+	return true, nil
+}
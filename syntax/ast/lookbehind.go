@@ -0,0 +1,442 @@
+package ast
+
+// lookBehindMaxCharLen is the longest text a look-behind can match, in characters.
+const lookBehindMaxCharLen = 65535
+
+// charLen is the range of lengths in characters of the texts a node matches. minSure is unset when the node
+// may not match the empty string even though min is 0, such as a capturing group.
+type charLen struct {
+	min     uint32
+	max     uint32
+	minSure bool
+}
+
+func fixedCharLen(n uint32) charLen {
+	return charLen{min: n, max: n, minSure: true}
+}
+
+func (l charLen) fixed() bool {
+	return l.min == l.max && l.min != infiniteLen
+}
+
+func (l *charLen) add(o charLen) {
+	l.min = distanceAdd(l.min, o.min)
+	l.max = distanceAdd(l.max, o.max)
+	l.minSure = l.minSure && o.minSure
+}
+
+func (l *charLen) altMerge(o charLen) {
+	if l.min > o.min {
+		l.min, l.minSure = o.min, o.minSure
+	} else if l.min == o.min && o.minSure {
+		l.minSure = true
+	}
+	if l.max < o.max {
+		l.max = o.max
+	}
+}
+
+func distanceAdd(a, b uint32) uint32 {
+	if a == infiniteLen || b == infiniteLen || a > infiniteLen-b {
+		return infiniteLen
+	}
+	return a + b
+}
+
+func distanceMultiply(d uint32, m int) uint32 {
+	if m == 0 {
+		return 0
+	}
+	if d < infiniteLen/uint32(m) {
+		return d * uint32(m)
+	}
+	return infiniteLen
+}
+
+// charLenResult tells how the length of the body of a look-behind was found.
+type charLenResult int
+
+const (
+	charLenNormal charLenResult = iota
+	// charLenTopAltFixed is for an alternation whose alternatives have fixed but different lengths.
+	charLenTopAltFixed
+)
+
+// tuneLookBehind checks a look-behind, as tune_look_behind does.
+func (c *checker) tuneLookBehind(n *inode, state int) error {
+	invalid := &Error{Code: ERR_INVALID_LOOK_BEHIND_PATTERN, Offset: n.span.Start}
+	used := false
+	if !c.allowedInLookBehind(n.body, n.look == lookBehindNot, &used) {
+		return invalid
+	}
+	if err := c.tune(n.body, state|inLookBehind); err != nil {
+		return err
+	}
+	if altReduceInLookBehind(n.body) {
+		return &Error{Code: ERR_LOOK_BEHIND_REDUCED, Offset: n.span.Start}
+	}
+	length, result, err := c.charLen(n.body, 0)
+	if err != nil {
+		return invalid
+	}
+	if length.max > lookBehindMaxCharLen && length.max != infiniteLen || length.min > lookBehindMaxCharLen {
+		return invalid
+	}
+	if length.min == 0 && length.minSure && !used {
+		// The look-behind always matches, or never for a negative one.
+		if n.look == lookBehind {
+			*n = inode{typ: ndString, span: n.span}
+		} else {
+			*n = inode{typ: ndGimmick, span: n.span}
+		}
+		return nil
+	}
+	behavior := c.p.flavor.Behavior
+	if result == charLenTopAltFixed {
+		switch {
+		case behavior&SYN_DIFFERENT_LEN_ALT_LOOK_BEHIND != 0:
+			// Each alternative becomes a look-behind of its own, one of which must match, or none for a negative
+			// look-behind.
+			divided := inode{typ: ndAlt, span: n.span}
+			if n.look == lookBehindNot {
+				divided.typ = ndList
+			}
+			for _, kid := range n.body.kids {
+				divided.kids = append(divided.kids, &inode{typ: ndAnchor, span: n.span, look: n.look, body: kid})
+			}
+			*n = divided
+			for _, kid := range n.kids {
+				if err := c.tuneLookBehind(kid, state); err != nil {
+					return err
+				}
+			}
+			return nil
+		case behavior&SYN_VARIABLE_LEN_LOOK_BEHIND == 0:
+			return invalid
+		}
+	}
+	if length.min == infiniteLen || length.min != length.max && behavior&SYN_VARIABLE_LEN_LOOK_BEHIND == 0 {
+		return invalid
+	}
+	return nil
+}
+
+// allowedInLookBehind reports whether a node can be in a look-behind, as check_node_in_look_behind does. Captures
+// aren't allowed in a negative look-behind. used is set when the look-behind has \K, a group that is
+// referred to or a recursive call.
+func (c *checker) allowedInLookBehind(n *inode, not bool, used *bool) bool {
+	switch n.typ {
+	case ndAbsent:
+		if n.absent == AbsentStopper || n.absent == AbsentClear {
+			return false
+		}
+		fallthrough
+	case ndList, ndAlt:
+		for _, kid := range n.kids {
+			if !c.allowedInLookBehind(kid, not, used) {
+				return false
+			}
+		}
+	case ndQuant:
+		return c.allowedInLookBehind(n.body, not, used)
+	case ndBag:
+		if n.bag == bagMemory && not {
+			return false
+		}
+		if !c.allowedInLookBehind(n.body, not, used) {
+			return false
+		}
+		switch n.bag {
+		case bagMemory:
+			if n.referenced || n.called {
+				*used = true
+			}
+		case bagIfElse:
+			for _, branch := range []*inode{n.then, n.els} {
+				if branch != nil && !c.allowedInLookBehind(branch, not, used) {
+					return false
+				}
+			}
+		}
+	case ndAnchor:
+		switch n.look {
+		case lookAhead, lookAheadNot:
+			return false
+		case lookBehindNot:
+			if !not {
+				return false
+			}
+			fallthrough
+		case lookBehind:
+			return c.allowedInLookBehind(n.body, not, used)
+		}
+		return n.anchor != AnchorEndBuffer && n.anchor != AnchorSemiEndBuffer
+	case ndGimmick:
+		if n.keep {
+			*used = true
+		}
+	case ndCall:
+		if n.recursion {
+			// Oniguruma doesn't look into the groups that are called recursively.
+			*used = true
+			return true
+		}
+		return !hasAbsentStopper(n.target)
+	}
+	return true
+}
+
+// hasAbsentStopper reports whether a called group has an absent stopper, which a look-behind can't call.
+func hasAbsentStopper(n *inode) bool {
+	if n.mark1 {
+		return false
+	}
+	n.mark1 = true
+	defer func() { n.mark1 = false }()
+	return findNode(n, func(x *inode) bool {
+		if x.typ == ndCall {
+			return hasAbsentStopper(x.target)
+		}
+		return x.typ == ndAbsent && (x.absent == AbsentStopper || x.absent == AbsentClear)
+	})
+}
+
+// findNode reports whether f is true for a node below n, without entering the nodes f is true for.
+func findNode(n *inode, f func(*inode) bool) bool {
+	for _, kid := range n.children() {
+		if f(kid) || findNode(kid, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipsAbsent reports whether an absent node never matches its absent, because its expression is a simple
+// repetition that repeats zero times.
+func (n *inode) skipsAbsent() bool {
+	return n.oneCharRepeat && n.repeatUpper == 0
+}
+
+// children returns the nodes directly below a node.
+func (n *inode) children() []*inode {
+	var kids []*inode
+	for _, kid := range []*inode{n.body, n.then, n.els} {
+		if kid != nil {
+			kids = append(kids, kid)
+		}
+	}
+	return append(kids, n.kids...)
+}
+
+// altReduceInLookBehind makes the leading repetitions of simple nodes in the alternatives of a look-behind
+// repeat their lower count, as alt_reduce_in_look_behind does. It reports whether an alternative was reduced to
+// nothing, which Oniguruma doesn't handle.
+func altReduceInLookBehind(n *inode) bool {
+	if n.typ != ndAlt {
+		return listReduceInLookBehind(n)
+	}
+	for _, kid := range n.kids {
+		if listReduceInLookBehind(kid) {
+			return true
+		}
+	}
+	return false
+}
+
+func listReduceInLookBehind(n *inode) bool {
+	switch n.typ {
+	case ndQuant:
+		reduceInLookBehind(n)
+	case ndList:
+		for _, kid := range n.kids {
+			if !reduceInLookBehind(kid) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// reduceInLookBehind makes a repetition of a simple node repeat its lower count. It reports whether the
+// repetition was reduced to nothing.
+func reduceInLookBehind(n *inode) bool {
+	if n.typ != ndQuant {
+		return false
+	}
+	switch n.body.typ {
+	case ndString, ndCType, ndAnyChar, ndClass, ndBackref:
+		n.upper = n.lower
+		return n.upper == 0
+	}
+	return false
+}
+
+// charLen returns the lengths of the texts a node matches, as node_char_len does. level is the depth of the
+// node in the body of the look-behind.
+func (c *checker) charLen(n *inode, level int) (charLen, charLenResult, error) {
+	level++
+	result := charLenNormal
+	var length charLen
+	switch n.typ {
+	case ndList:
+		for i, kid := range n.kids {
+			l, _, err := c.charLen(kid, level)
+			if err != nil {
+				return length, result, err
+			}
+			if i == 0 {
+				length = l
+			} else {
+				length.add(l)
+			}
+		}
+	case ndAlt:
+		var err error
+		if length, _, err = c.charLen(n.kids[0], level); err != nil {
+			return length, result, err
+		}
+		fixed := true
+		for _, kid := range n.kids[1:] {
+			l, _, err := c.charLen(kid, level)
+			if err != nil {
+				return length, result, err
+			}
+			fixed = fixed && l.fixed()
+			length.altMerge(l)
+		}
+		if !length.fixed() && fixed && level == 1 {
+			result = charLenTopAltFixed
+		}
+	case ndString:
+		switch {
+		case n.tuned:
+			length = charLen{min: n.minCharLen, max: n.maxCharLen, minSure: true}
+		case n.ignoreCase && !c.icASCII:
+			// The string is in a group referred to before the group was tuned.
+			return length, result, &Error{Code: ERR_INVALID_LOOK_BEHIND_PATTERN}
+		default:
+			length = fixedCharLen(uint32(len(n.chars)))
+		}
+	case ndQuant:
+		if n.lower == n.upper && n.upper == 0 {
+			return fixedCharLen(0), result, nil
+		}
+		l, _, err := c.charLen(n.body, level)
+		if err != nil {
+			return length, result, err
+		}
+		length = l
+		length.min = distanceMultiply(length.min, n.lower)
+		switch {
+		case n.upper == repeatInfinite:
+			length.max = infiniteLen
+		default:
+			length.max = distanceMultiply(length.max, n.upper)
+		}
+	case ndCall:
+		if n.recursion {
+			return charLen{max: infiniteLen}, result, nil
+		}
+		return c.charLen(n.target, level)
+	case ndClass, ndCType, ndAnyChar:
+		length = fixedCharLen(1)
+	case ndBag:
+		return c.bagCharLen(n, level)
+	case ndBackref:
+		if n.checker {
+			return charLen{}, result, nil
+		}
+		if n.recursion {
+			if n.nestLevel {
+				return charLen{max: infiniteLen}, result, nil
+			}
+			return charLen{}, result, nil
+		}
+		for i, num := range n.refs {
+			l, _, err := c.charLen(c.mems[num], level)
+			if err != nil {
+				return length, result, err
+			}
+			if !l.fixed() {
+				l.minSure = false
+			}
+			if i == 0 {
+				length = l
+			} else {
+				length.altMerge(l)
+			}
+		}
+	case ndAnchor:
+		// A look-behind with an anchor can't be reduced.
+		length = charLen{}
+	case ndAbsent:
+		if n.absent == AbsentClear {
+			return fixedCharLen(0), result, nil
+		}
+		length = charLen{max: infiniteLen, minSure: true}
+		if !n.skipsAbsent() {
+			if _, _, err := c.charLen(n.kids[0], level); err != nil {
+				return length, result, err
+			}
+		}
+		if n.absent != AbsentExpression || len(n.kids) < 2 {
+			break
+		}
+		l, _, err := c.charLen(n.kids[1], level)
+		if err != nil {
+			return length, result, err
+		}
+		switch {
+		case !n.oneCharRepeat:
+			length.min, length.minSure = l.min, l.minSure
+		case n.repeatUpper != repeatInfinite:
+			length.max = uint32(n.repeatUpper)
+		}
+	default:
+		length = fixedCharLen(0)
+	}
+	return length, result, nil
+}
+
+func (c *checker) bagCharLen(n *inode, level int) (charLen, charLenResult, error) {
+	switch n.bag {
+	case bagMemory:
+		if !n.fixedCharLen {
+			if n.mark1 {
+				return charLen{max: infiniteLen}, charLenNormal, nil
+			}
+			n.mark1 = true
+			l, _, err := c.charLen(n.body, level)
+			n.mark1 = false
+			if err != nil {
+				return l, charLenNormal, err
+			}
+			n.minCharLen, n.maxCharLen, n.charLenMinSure = l.min, l.max, l.minSure
+			n.fixedCharLen = true
+		}
+		// A look-behind with a capture can't be reduced.
+		return charLen{min: n.minCharLen, max: n.maxCharLen}, charLenNormal, nil
+	case bagIfElse:
+		length, _, err := c.charLen(n.body, level)
+		if err != nil {
+			return length, charLenNormal, err
+		}
+		if n.then != nil {
+			l, _, err := c.charLen(n.then, level)
+			if err != nil {
+				return length, charLenNormal, err
+			}
+			length.add(l)
+		}
+		els := fixedCharLen(0)
+		if n.els != nil {
+			if els, _, err = c.charLen(n.els, level); err != nil {
+				return length, charLenNormal, err
+			}
+		}
+		length.altMerge(els)
+		return length, charLenNormal, nil
+	}
+	return c.charLen(n.body, level)
+}
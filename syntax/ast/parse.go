@@ -0,0 +1,500 @@
+package ast
+
+import (
+	"strings"
+)
+
+// parseDepthLimit is the largest nesting depth of groups, classes and repetitions.
+const parseDepthLimit = 4096
+
+// parser holds the state of the parsing of a pattern.
+type parser struct {
+	flavor  *Flavor
+	pattern string
+	pos     int
+	options Options
+	depth   int
+	tok     *token
+
+	// The rest of a \x{...} sequence, outside and inside of a class.
+	pendingCodes []rune
+	pendingStart int
+	pendingClass []*token
+
+	captures int
+	numNamed int
+	groups   []*Group
+	closed   []bool
+	names    map[string][]int
+
+	calls       []*Call
+	hasCallZero bool
+	// numberedCalls are the calls by number, which aren't allowed when only named groups capture.
+	numberedCalls map[*Call]bool
+	tags          map[string]bool
+	tagRefs       []tagRef
+	whole         Node
+
+	// stringRuns are the concatenations of characters that Oniguruma parses as a single string.
+	stringRuns map[*Concat]bool
+	// recursiveRefs are the back references that appear inside the group they refer to.
+	recursiveRefs map[*Backref]bool
+	// checkers are the back references that are the condition of a conditional group.
+	checkers map[*Backref]bool
+	// continued are the isolated options that continue into the following alternatives.
+	continued map[*Flags]bool
+	// qnodes are the quantifiers as Oniguruma reduces them while parsing.
+	qnodes map[*Quantifier]*inode
+	// groupHead is set before parsing the body of an absent group or of a conditional.
+	groupHead bool
+}
+
+// Parse parses a pattern of the given flavor into a syntax tree.
+func Parse(pattern string, flavor *Flavor) (*Tree, error) {
+	return ParseWithOptions(pattern, flavor, OPTION_NONE)
+}
+
+// ParseWithOptions parses a pattern of the given flavor into a syntax tree, with options in addition to the ones
+// of the flavor. It returns an *Error when the pattern is invalid, and ErrUnsupportedFlavor when the flavor isn't
+// supported.
+func ParseWithOptions(pattern string, flavor *Flavor, options Options) (*Tree, error) {
+	if !flavor.Supported() {
+		return nil, ErrUnsupportedFlavor
+	}
+	if options&OPTION_DONT_CAPTURE_GROUP != 0 && options&OPTION_CAPTURE_GROUP != 0 {
+		return nil, &Error{Code: ERR_INVALID_COMBINATION_OF_OPTIONS}
+	}
+	options |= flavor.Options
+	if options&OPTION_NEGATE_SINGLELINE != 0 {
+		options &^= OPTION_SINGLELINE
+	}
+	p := &parser{
+		flavor:        flavor,
+		pattern:       pattern,
+		options:       options,
+		groups:        []*Group{nil},
+		closed:        []bool{false},
+		names:         map[string][]int{},
+		tags:          map[string]bool{},
+		stringRuns:    map[*Concat]bool{},
+		recursiveRefs: map[*Backref]bool{},
+		checkers:      map[*Backref]bool{},
+		continued:     map[*Flags]bool{},
+		numberedCalls: map[*Call]bool{},
+		qnodes:        map[*Quantifier]*inode{},
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	root, err := p.prsAlts(tokEOT)
+	if err != nil {
+		return nil, err
+	}
+	tree := &Tree{Root: root, Captures: p.captures, Names: p.names}
+	if err := p.check(tree, options); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// next reads the next token into p.tok.
+func (p *parser) next() error {
+	tok, err := p.fetchToken()
+	if err != nil {
+		return err
+	}
+	tok.end = p.pos
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) incDepth() error {
+	p.depth++
+	if p.depth > parseDepthLimit {
+		return p.errorf(ERR_PARSE_DEPTH_LIMIT_OVER)
+	}
+	return nil
+}
+
+func (p *parser) decDepth() {
+	p.depth--
+}
+
+// newCharType returns the node of a character type token such as \w.
+func (p *parser) newCharType(tok *token) *CharType {
+	ct := &CharType{Span: Span{tok.start, p.pos}, Kind: tok.ctype, Negated: tok.negated}
+	switch tok.ctype {
+	case CharTypeWord:
+		ct.ASCII = p.options&OPTION_WORD_IS_ASCII != 0
+	case CharTypeDigit:
+		ct.ASCII = p.options&OPTION_DIGIT_IS_ASCII != 0
+	case CharTypeSpace:
+		ct.ASCII = p.options&OPTION_SPACE_IS_ASCII != 0
+	}
+	return ct
+}
+
+// prsAlts parses alternatives up to the term token, which is left in p.tok.
+func (p *parser) prsAlts(term tokenKind) (Node, error) {
+	if err := p.incDepth(); err != nil {
+		return nil, err
+	}
+	saved := p.options
+	node, err := p.prsBranch(term)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokAlt {
+		alt := &Alternation{Alternatives: []Node{node}}
+		for p.tok.kind == tokAlt {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if node, err = p.prsBranch(term); err != nil {
+				return nil, err
+			}
+			alt.Alternatives = append(alt.Alternatives, node)
+		}
+		alt.Span = Span{alt.Alternatives[0].Pos().Start, node.Pos().End}
+		node = alt
+	}
+	if p.tok.kind != term {
+		return nil, p.errorf(ERR_END_PATTERN_WITH_UNMATCHED_PARENTHESIS)
+	}
+	p.options = saved
+	p.decDepth()
+	return node, nil
+}
+
+// prsBranch parses a sequence of expressions up to an alternation or the term token.
+func (p *parser) prsBranch(term tokenKind) (Node, error) {
+	if err := p.incDepth(); err != nil {
+		return nil, err
+	}
+	start := p.tok.start
+	var segments [][]Node
+	for {
+		nodes, err := p.prsExp(term)
+		if err != nil {
+			return nil, err
+		}
+		if nodes != nil {
+			segments = append(segments, nodes)
+		}
+		if k := p.tok.kind; k == tokEOT || k == term || k == tokAlt {
+			break
+		}
+	}
+	// The head of a group is at the start of its first branch only.
+	p.groupHead = false
+	p.decDepth()
+	return p.sequence(segments, start), nil
+}
+
+// sequence makes a node of the results of consecutive expressions. The options set by isolated options in the
+// flavors where they continue into the following alternatives apply to the expressions that follow them.
+func (p *parser) sequence(segments [][]Node, start int) Node {
+	for i, segment := range segments {
+		if flags, ok := segment[0].(*Flags); ok && flags.Sub == nil {
+			flags.Sub = p.sequence(segments[i+1:], flags.End)
+			flags.End = flags.Sub.Pos().End
+			segments = segments[:i+1]
+			break
+		}
+	}
+	var items []Node
+	for _, segment := range segments {
+		items = append(items, segment...)
+	}
+	switch len(items) {
+	case 0:
+		return &Concat{Span: Span{start, start}}
+	case 1:
+		return items[0]
+	}
+	concat := &Concat{Span: Span{items[0].Pos().Start, items[len(items)-1].Pos().End}, Items: items}
+	if len(segments) == 1 && isStringRun(items) {
+		p.stringRuns[concat] = true
+	}
+	return concat
+}
+
+func isStringRun(items []Node) bool {
+	for _, item := range items {
+		if _, ok := item.(*Literal); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// prsExp parses an expression with its quantifiers. It returns the nodes of the expression, several nodes
+// for a string whose last character is the target of a quantifier, and nil at the end of a branch.
+func (p *parser) prsExp(term tokenKind) ([]Node, error) {
+	tok := p.tok
+	head := p.groupHead
+	p.groupHead = false
+	if tok.kind == term || tok.kind == tokAlt || tok.kind == tokEOT {
+		return nil, nil
+	}
+	depth := p.depth
+	ignoreCase := p.options&OPTION_IGNORECASE != 0
+	var nodes []Node
+	var target Node
+	fetched := false
+	// checkTarget is unset for a group at the head of the body of an absent group or of a conditional, which
+	// Oniguruma repeats whatever it contains.
+	checkTarget := true
+	switch tok.kind {
+	case tokOpen:
+		node, kind, err := p.prsBag(tok)
+		if err != nil {
+			return nil, err
+		}
+		if kind == bagOption {
+			return p.isolatedOptions(node.(*Flags), term, head)
+		}
+		if g, ok := node.(*Group); ok && head && (g.Kind == GroupNonCapture || g.Kind == GroupOptions) {
+			checkTarget = false
+		}
+		target = node
+	case tokClose:
+		if p.flavor.Behavior&SYN_ALLOW_UNMATCHED_CLOSE_SUBEXP == 0 {
+			return nil, p.errorf(ERR_UNMATCHED_CLOSE_PARENTHESIS)
+		}
+		fallthrough
+	case tokString:
+		for {
+			nodes = append(nodes, &Literal{Span: Span{p.tok.start, p.tok.end}, Char: p.tok.code, IgnoreCase: ignoreCase})
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokString {
+				break
+			}
+		}
+		fetched = true
+	case tokCrudeByte:
+		lit, err := p.crudeBytes()
+		if err != nil {
+			return nil, err
+		}
+		lit.IgnoreCase = ignoreCase
+		target = lit
+		fetched = true
+	case tokCodePoint:
+		if uint32(tok.code) > maxCodePoint {
+			return nil, p.errorf(ERR_INVALID_CODE_POINT_VALUE)
+		}
+		target = &Literal{Span: Span{tok.start, tok.end}, Char: tok.code, IgnoreCase: ignoreCase}
+	case tokQuoteOpen:
+		nodes = p.quote(tok, ignoreCase)
+		if len(nodes) == 0 {
+			target = &Concat{Span: Span{tok.start, p.pos}}
+		}
+	case tokCharType:
+		target = p.newCharType(tok)
+	case tokCharProperty:
+		prop, err := p.fetchProperty(tok)
+		if err != nil {
+			return nil, err
+		}
+		target = prop
+	case tokOpenClass:
+		cls, err := p.prsClass(tok)
+		if err != nil {
+			return nil, err
+		}
+		target = cls
+	case tokAnyChar:
+		target = &AnyChar{Span: Span{tok.start, tok.end}, Kind: AnyCharDot, Multiline: p.options&OPTION_MULTILINE != 0}
+	case tokNoNewline:
+		target = &AnyChar{Span: Span{tok.start, tok.end}, Kind: AnyCharNoNewline}
+	case tokTrueAnyChar:
+		target = &AnyChar{Span: Span{tok.start, tok.end}, Kind: AnyCharTrue, Multiline: true}
+	case tokBackref:
+		target = p.newBackref(tok)
+	case tokCall:
+		call := &Call{Span: Span{tok.start, tok.end}}
+		if tok.byNumber {
+			call.Group = tok.group
+			if tok.group == 0 {
+				p.hasCallZero = true
+			}
+			p.numberedCalls[call] = true
+		} else {
+			call.Name = tok.name
+		}
+		p.calls = append(p.calls, call)
+		target = call
+	case tokAnchor:
+		target = &Anchor{Span: Span{tok.start, tok.end}, Kind: tok.anchor}
+	case tokRepeat, tokInterval:
+		return nil, p.errorf(ERR_TARGET_OF_REPEAT_OPERATOR_NOT_SPECIFIED)
+	case tokKeep:
+		target = &Keep{Span: Span{tok.start, tok.end}}
+	case tokGeneralNewline:
+		target = &GeneralNewline{Span: Span{tok.start, tok.end}}
+	case tokTextSegment:
+		target = &TextSegment{Span: Span{tok.start, tok.end}}
+	}
+	if target == nil {
+		// The quantifiers of a string apply to its last character.
+		target = nodes[len(nodes)-1]
+		nodes = nodes[:len(nodes)-1]
+	}
+	if !fetched {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	for p.tok.kind == tokRepeat || p.tok.kind == tokInterval {
+		if checkTarget && p.invalidQuantifierTarget(target) {
+			return nil, p.errorf(ERR_TARGET_OF_REPEAT_OPERATOR_INVALID)
+		}
+		if depth++; depth > parseDepthLimit {
+			return nil, p.errorf(ERR_PARSE_DEPTH_LIMIT_OVER)
+		}
+		q := &Quantifier{Span: Span{target.Pos().Start, p.tok.end}, Sub: target, Min: p.tok.lower, Max: p.tok.upper}
+		switch {
+		case p.tok.possessive:
+			q.Kind = Possessive
+		case !p.tok.greedy:
+			q.Kind = Lazy
+		}
+		if err := p.assignQuantifierBody(q); err != nil {
+			return nil, err
+		}
+		target = q
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	return append(nodes, target), nil
+}
+
+// isolatedOptions parses what follows isolated options such as (?i). In the flavors where the options continue
+// into the following alternatives, the options apply until the end of the enclosing group, and the expressions
+// that follow are attached to the options by the branch, and the next expression is at the head of the group
+// when the options are. Otherwise the options apply to the rest of the enclosing group, which is parsed here.
+func (p *parser) isolatedOptions(flags *Flags, term tokenKind, head bool) ([]Node, error) {
+	options := p.options&^flags.Off | flags.On
+	if p.flavor.Behavior&SYN_ISOLATED_OPTION_CONTINUE_BRANCH != 0 {
+		p.options = options
+		p.continued[flags] = true
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		p.groupHead = head
+		return []Node{flags}, nil
+	}
+	saved := p.options
+	p.options = options
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	sub, err := p.prsAlts(term)
+	if err != nil {
+		return nil, err
+	}
+	p.options = saved
+	flags.Sub = sub
+	if sub.Pos().End > flags.End {
+		flags.End = sub.Pos().End
+	}
+	return []Node{flags}, nil
+}
+
+// crudeBytes reads the bytes of a character given by escaped bytes such as \xe3\x81\x82.
+func (p *parser) crudeBytes() (*Literal, error) {
+	start := p.tok.start
+	buf := []byte{byte(p.tok.code)}
+	for len(buf) < utf8Len(buf[0]) {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokCrudeByte {
+			return nil, p.errorf(ERR_TOO_SHORT_MULTI_BYTE_STRING)
+		}
+		buf = append(buf, byte(p.tok.code))
+	}
+	end := p.tok.end
+	// Oniguruma checks the character before the error of the next token.
+	err := p.next()
+	if !validBytes(buf) {
+		return nil, &Error{Code: ERR_INVALID_CODE_POINT_VALUE, Offset: end}
+	}
+	if err != nil {
+		return nil, err
+	}
+	code := rune(buf[0])
+	if len(buf) > 1 {
+		code &= 1<<(7-len(buf)) - 1
+		for _, c := range buf[1:] {
+			code = code<<6 | rune(c)&0x3f
+		}
+	}
+	return &Literal{Span: Span{start, end}, Char: code, Byte: true}, nil
+}
+
+// validBytes reports whether the bytes are a valid character, as checked by Oniguruma.
+func validBytes(buf []byte) bool {
+	for i, c := range buf {
+		if (c&0xc0 == 0x80) != (i > 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// quote returns the characters of a \Q...\E quote, with the position after the \Q.
+func (p *parser) quote(tok *token, ignoreCase bool) []Node {
+	var nodes []Node
+	for !p.end() {
+		if strings.HasPrefix(p.pattern[p.pos:], `\E`) {
+			p.pos += 2
+			return nodes
+		}
+		start := p.pos
+		c := p.fetch()
+		nodes = append(nodes, &Literal{Span: Span{start, p.pos}, Char: c, IgnoreCase: ignoreCase})
+	}
+	return nodes
+}
+
+// invalidQuantifierTarget reports whether a node can't be repeated: anchors, look-arounds, \K and callouts.
+// A non-capturing group is checked by its content.
+func (p *parser) invalidQuantifierTarget(node Node) bool {
+	switch n := node.(type) {
+	case *Anchor, *Keep, *Callout:
+		return true
+	case *Group:
+		switch n.Kind {
+		case GroupLookahead, GroupNegativeLookahead, GroupLookbehind, GroupNegativeLookbehind:
+			return true
+		case GroupNonCapture:
+			return p.invalidQuantifierTarget(n.Sub)
+		}
+	case *Flags:
+		// Isolated options that continue into the following alternatives leave no node.
+		return p.continued[n] && p.invalidQuantifierTarget(n.Sub)
+	case *Alternation:
+		for _, alternative := range n.Alternatives {
+			if p.invalidQuantifierTarget(alternative) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unwrapGroup returns the content of a non-capturing group, which Oniguruma doesn't keep.
+func unwrapGroup(node Node) Node {
+	for {
+		g, ok := node.(*Group)
+		if !ok || g.Kind != GroupNonCapture {
+			return node
+		}
+		node = g.Sub
+	}
+}
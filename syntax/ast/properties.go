@@ -0,0 +1,132 @@
+package ast
+
+import "strings"
+
+// propertyNames are the normalized names of the character properties of Oniguruma, with the Unicode data of
+// Oniguruma 6.9.10. Names are normalized by propertyKey.
+var propertyNames = map[string]bool{}
+
+func init() {
+	for _, name := range strings.Fields(propertyNameList) {
+		propertyNames[name] = true
+	}
+}
+
+// propertyKey returns the normalized form of a property name: lower case, without spaces, hyphens and underscores.
+// It returns false for names with characters that are not ASCII.
+func propertyKey(name string) (string, bool) {
+	var key strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 0x80 {
+			return "", false
+		}
+		if c == ' ' || c == '-' || c == '_' {
+			continue
+		}
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		key.WriteByte(c)
+	}
+	return key.String(), true
+}
+
+const propertyNameList = `
+adlam adlm aghb ahex ahom alnum alpha alphabetic anatolianhieroglyphs any arab arabic armenian armi armn
+ascii asciihexdigit assigned avestan avst bali balinese bamu bamum bass bassavah batak batk beng bengali
+bhaiksuki bhks bidic bidicontrol blank bopo bopomofo brah brahmi brai braille bugi buginese buhd buhid c
+cakm canadianaboriginal cans cari carian cased casedletter caseignorable caucasianalbanian cc cf chakma cham
+changeswhencasefolded changeswhencasemapped changeswhenlowercased changeswhentitlecased changeswhenuppercased
+cher cherokee chorasmian chrs ci closepunctuation cn cntrl co combiningmark common connectorpunctuation
+control copt coptic cpmn cprt cs cuneiform currencysymbol cwcf cwcm cwl cwt cwu cypriot cyprominoan cyrillic
+cyrl dash dashpunctuation decimalnumber defaultignorablecodepoint dep deprecated deseret deva devanagari
+di dia diacritic diak digit divesakuru dogr dogra dsrt dupl duployan ebase ecomp egyp egyptianhieroglyphs
+elba elbasan elym elymaic emod emoji emojicomponent emojimodifier emojimodifierbase emojipresentation
+enclosingmark epres ethi ethiopic ext extendedpictographic extender extpict finalpunctuation format
+geor georgian glag glagolitic gong gonm goth gothic gran grantha graph graphemebase graphemeextend
+graphemelink grbase greek grek grext grlink gujarati gujr gunjalagondi gurmukhi guru han hang hangul hani
+hanifirohingya hano hanunoo hatr hatran hebr hebrew hex hexdigit hira hiragana hluw hmng hmnp hung hyphen
+idc idcontinue ideo ideographic ids idsb idsbinaryoperator idst idstart idstrinaryoperator imperialaramaic
+inadlam inaegeannumbers inahom inalchemicalsymbols inalphabeticpresentationforms inanatolianhieroglyphs
+inancientgreekmusicalnotation inancientgreeknumbers inancientsymbols inarabic inarabicextendeda
+inarabicextendedb inarabicmathematicalalphabeticsymbols inarabicpresentationformsa inarabicpresentationformsb
+inarabicsupplement inarmenian inarrows inavestan inbalinese inbamum inbamumsupplement inbasiclatin
+inbassavah inbatak inbengali inbhaiksuki inblockelements inbopomofo inbopomofoextended inboxdrawing
+inbrahmi inbraillepatterns inbuginese inbuhid inbyzantinemusicalsymbols incarian incaucasianalbanian
+inchakma incham incherokee incherokeesupplement inchesssymbols inchorasmian incjkcompatibility
+incjkcompatibilityforms incjkcompatibilityideographs incjkcompatibilityideographssupplement
+incjkradicalssupplement incjkstrokes incjksymbolsandpunctuation incjkunifiedideographs
+incjkunifiedideographsextensiona incjkunifiedideographsextensionb incjkunifiedideographsextensionc
+incjkunifiedideographsextensiond incjkunifiedideographsextensione incjkunifiedideographsextensionf
+incjkunifiedideographsextensiong incombiningdiacriticalmarks incombiningdiacriticalmarksextended
+incombiningdiacriticalmarksforsymbols incombiningdiacriticalmarkssupplement incombininghalfmarks
+incommonindicnumberforms incontrolpictures incoptic incopticepactnumbers incountingrodnumerals
+incuneiform incuneiformnumbersandpunctuation incurrencysymbols incypriotsyllabary incyprominoan
+incyrillic incyrillicextendeda incyrillicextendedb incyrillicextendedc incyrillicsupplement
+indeseret indevanagari indevanagariextended indingbats indivesakuru indogra indominotiles induployan
+inearlydynasticcuneiform inegyptianhieroglyphformatcontrols inegyptianhieroglyphs inelbasan inelymaic
+inemoticons inenclosedalphanumerics inenclosedalphanumericsupplement inenclosedcjklettersandmonths
+inenclosedideographicsupplement inethiopic inethiopicextended inethiopicextendeda inethiopicextendedb
+inethiopicsupplement ingeneralpunctuation ingeometricshapes ingeometricshapesextended ingeorgian
+ingeorgianextended ingeorgiansupplement inglagolitic inglagoliticsupplement ingothic ingrantha
+ingreekandcoptic ingreekextended ingujarati ingunjalagondi ingurmukhi inhalfwidthandfullwidthforms
+inhangulcompatibilityjamo inhanguljamo inhanguljamoextendeda inhanguljamoextendedb inhangulsyllables
+inhanifirohingya inhanunoo inhatran inhebrew inherited inhighprivateusesurrogates inhighsurrogates
+inhiragana inideographicdescriptioncharacters inideographicsymbolsandpunctuation inimperialaramaic
+inindicsiyaqnumbers ininscriptionalpahlavi ininscriptionalparthian inipaextensions initialpunctuation
+injavanese inkaithi inkanaextendeda inkanaextendedb inkanasupplement inkanbun inkangxiradicals
+inkannada inkatakana inkatakanaphoneticextensions inkayahli inkharoshthi inkhitansmallscript inkhmer
+inkhmersymbols inkhojki inkhudawadi inlao inlatin1supplement inlatinextendeda inlatinextendedadditional
+inlatinextendedb inlatinextendedc inlatinextendedd inlatinextendede inlatinextendedf inlatinextendedg
+inlepcha inletterlikesymbols inlimbu inlineara inlinearbideograms inlinearbsyllabary inlisu inlisusupplement
+inlowsurrogates inlycian inlydian inmahajani inmahjongtiles inmakasar inmalayalam inmandaic inmanichaean
+inmarchen inmasaramgondi inmathematicalalphanumericsymbols inmathematicaloperators inmayannumerals
+inmedefaidrin inmeeteimayek inmeeteimayekextensions inmendekikakui inmeroiticcursive inmeroitichieroglyphs
+inmiao inmiscellaneousmathematicalsymbolsa inmiscellaneousmathematicalsymbolsb inmiscellaneoussymbols
+inmiscellaneoussymbolsandarrows inmiscellaneoussymbolsandpictographs inmiscellaneoustechnical inmodi
+inmodifiertoneletters inmongolian inmongoliansupplement inmro inmultani inmusicalsymbols inmyanmar
+inmyanmarextendeda inmyanmarextendedb innabataean innandinagari innewa innewtailue innko innoblock
+innumberforms innushu innyiakengpuachuehmong inogham inolchiki inoldhungarian inolditalic inoldnortharabian
+inoldpermic inoldpersian inoldsogdian inoldsoutharabian inoldturkic inolduyghur inopticalcharacterrecognition
+inoriya inornamentaldingbats inosage inosmanya inottomansiyaqnumbers inpahawhhmong inpalmyrene inpaucinhau
+inphagspa inphaistosdisc inphoenician inphoneticextensions inphoneticextensionssupplement inplayingcards
+inprivateusearea inpsalterpahlavi inrejang inruminumeralsymbols inrunic insamaritan insaurashtra
+inscriptionalpahlavi inscriptionalparthian insharada inshavian inshorthandformatcontrols insiddham
+insinhala insinhalaarchaicnumbers insmallformvariants insmallkanaextension insogdian insorasompeng
+insoyombo inspacingmodifierletters inspecials insundanese insundanesesupplement insuperscriptsandsubscripts
+insupplementalarrowsa insupplementalarrowsb insupplementalarrowsc insupplementalmathematicaloperators
+insupplementalpunctuation insupplementalsymbolsandpictographs insupplementaryprivateuseareaa
+insupplementaryprivateuseareab insuttonsignwriting insylotinagri insymbolsandpictographsextendeda
+insymbolsforlegacycomputing insyriac insyriacsupplement intagalog intagbanwa intags intaile intaitham
+intaiviet intaixuanjingsymbols intakri intamil intamilsupplement intangsa intangut intangutcomponents
+intangutsupplement intelugu inthaana inthai intibetan intifinagh intirhuta intoto intransportandmapsymbols
+inugaritic inunifiedcanadianaboriginalsyllabics inunifiedcanadianaboriginalsyllabicsextended
+inunifiedcanadianaboriginalsyllabicsextendeda invai invariationselectors invariationselectorssupplement
+invedicextensions inverticalforms invithkuqi inwancho inwarangciti inyezidi inyijinghexagramsymbols
+inyiradicals inyisyllables inzanabazarsquare inznamennymusicalnotation ital java javanese joinc joincontrol
+kaithi kali kana kannada katakana kayahli khar kharoshthi khitansmallscript khmer khmr khoj khojki
+khudawadi kits knda kthi l lana lao laoo latin latn lc lepc lepcha letter letternumber limb limbu lina
+linb lineara linearb lineseparator lisu ll lm lo loe logicalorderexception lower lowercase lowercaseletter
+lt lu lyci lycian lydi lydian m mahajani mahj maka makasar malayalam mand mandaic mani manichaean marc
+marchen mark masaramgondi math mathsymbol mc me medefaidrin medf meeteimayek mend mendekikakui merc mero
+meroiticcursive meroitichieroglyphs miao mlym mn modi modifierletter modifiersymbol mong mongolian mro
+mroo mtei mult multani myanmar mymr n nabataean nand nandinagari narb nbat nchar nd newa newline newtailue
+nko nkoo nl no noncharactercodepoint nonspacingmark nshu number nushu nyiakengpuachuehmong oalpha odi ogam
+ogham ogrext oidc oids olchiki olck oldhungarian olditalic oldnortharabian oldpermic oldpersian oldsogdian
+oldsoutharabian oldturkic olduyghur olower omath openpunctuation oriya orkh orya osage osge osma osmanya
+other otheralphabetic otherdefaultignorablecodepoint othergraphemeextend otheridcontinue otheridstart
+otherletter otherlowercase othermath othernumber otherpunctuation othersymbol otheruppercase ougr oupper p
+pahawhhmong palm palmyrene paragraphseparator patsyn patternsyntax patternwhitespace patws pauc paucinhau
+pc pcm pd pe perm pf phag phagspa phli phlp phnx phoenician pi plrd po prependedconcatenationmark print
+privateuse prti ps psalterpahlavi punct punctuation qaac qaai qmark quotationmark radical regionalindicator
+rejang ri rjng rohg runic runr s samaritan samr sarb saur saurashtra sc sd sentenceterminal separator sgnw
+sharada shavian shaw shrd sidd siddham signwriting sind sinh sinhala sk sm so softdotted sogd sogdian
+sogo sora sorasompeng soyo soyombo space spaceseparator spacingmark sterm sund sundanese surrogate sylo
+sylotinagri symbol syrc syriac tagalog tagb tagbanwa taile taitham taiviet takr takri tale talu tamil
+taml tang tangsa tangut tavt telu telugu term terminalpunctuation tfng tglg thaa thaana thai tibetan tibt
+tifinagh tirh tirhuta titlecaseletter tnsa toto ugar ugaritic uideo unassigned unifiedideograph unknown
+upper uppercase uppercaseletter vai vaii variationselector vith vithkuqi vs wancho wara warangciti
+wcho whitespace word wspace xdigit xidc xidcontinue xids xidstart xpeo xsux yezi yezidi yi yiii z
+zanabazarsquare zanb zinh zl zp zs zyyy zzzz
+`
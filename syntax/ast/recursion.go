@@ -0,0 +1,313 @@
+package ast
+
+// The results of infiniteRecursiveCallCheck: whether a node may call the group being checked, whether it always
+// does, and whether it does before matching anything.
+const (
+	recursionExist = 1 << iota
+	recursionMust
+	recursionInfinite
+)
+
+// recursiveCallCheck reports whether a node calls the group marked with mark1, as recursive_call_check does.
+// Calls that do are marked as recursive.
+func recursiveCallCheck(n *inode) bool {
+	switch n.typ {
+	case ndList, ndAlt, ndAbsent:
+		found := false
+		for _, kid := range n.kids {
+			found = recursiveCallCheck(kid) || found
+		}
+		return found
+	case ndAnchor:
+		if n.look == lookNone {
+			return false
+		}
+		return recursiveCallCheck(n.body)
+	case ndQuant:
+		return recursiveCallCheck(n.body)
+	case ndCall:
+		found := recursiveCallCheck(n.target)
+		if found && n.target.mark1 {
+			n.recursion = true
+		}
+		return found
+	case ndBag:
+		switch n.bag {
+		case bagMemory:
+			switch {
+			case n.mark2:
+				return false
+			case n.mark1:
+				return true
+			}
+			n.mark2 = true
+			found := recursiveCallCheck(n.body)
+			n.mark2 = false
+			return found
+		case bagIfElse:
+			found := false
+			for _, branch := range []*inode{n.then, n.els} {
+				if branch != nil {
+					found = recursiveCallCheck(branch) || found
+				}
+			}
+			return recursiveCallCheck(n.body) || found
+		}
+		return recursiveCallCheck(n.body)
+	}
+	return false
+}
+
+// recursiveCallCheckTrav marks the called groups that call themselves, as recursive_call_check_trav does.
+// inRecursion is set inside a group that does.
+func (c *checker) recursiveCallCheckTrav(n *inode, inRecursion bool) {
+	switch n.typ {
+	case ndList, ndAlt, ndAbsent:
+		for _, kid := range n.kids {
+			c.recursiveCallCheckTrav(kid, inRecursion)
+		}
+	case ndQuant:
+		c.recursiveCallCheckTrav(n.body, inRecursion)
+	case ndAnchor:
+		if n.look != lookNone {
+			c.recursiveCallCheckTrav(n.body, inRecursion)
+		}
+	case ndBag:
+		if n.bag == bagMemory && (n.called || inRecursion) && !n.recursion {
+			n.mark1 = true
+			n.recursion = recursiveCallCheck(n.body)
+			n.mark1 = false
+		}
+		inRecursion = inRecursion || n.recursion
+		c.recursiveCallCheckTrav(n.body, inRecursion)
+		for _, branch := range []*inode{n.then, n.els} {
+			if branch != nil {
+				c.recursiveCallCheckTrav(branch, inRecursion)
+			}
+		}
+	}
+}
+
+// infiniteRecursiveCallCheck returns whether a node calls the group marked with mark1, as
+// infinite_recursive_call_check does. head is set when nothing is matched before the node in the group.
+func (c *checker) infiniteRecursiveCallCheck(n *inode, head bool) int {
+	r := 0
+	switch n.typ {
+	case ndList:
+		for _, kid := range n.kids {
+			ret := c.infiniteRecursiveCallCheck(kid, head)
+			if ret&recursionInfinite != 0 {
+				return ret
+			}
+			r |= ret
+			if head && c.minByteLen(kid) != 0 {
+				head = false
+			}
+		}
+	case ndAlt:
+		must := recursionMust
+		for _, kid := range n.kids {
+			ret := c.infiniteRecursiveCallCheck(kid, head)
+			if ret&recursionInfinite != 0 {
+				return ret
+			}
+			r |= ret & recursionExist
+			must &= ret
+		}
+		r |= must
+	case ndQuant:
+		if n.upper == 0 {
+			break
+		}
+		r = c.infiniteRecursiveCallCheck(n.body, head)
+		if n.lower == 0 {
+			r &^= recursionMust
+		}
+	case ndAbsent:
+		// The absent expression is repeated any number of times, unlike the expression of (?~|absent|exp).
+		for i, kid := range n.kids {
+			if i == 0 && n.skipsAbsent() {
+				continue
+			}
+			ret := c.infiniteRecursiveCallCheck(kid, head)
+			if ret&recursionInfinite != 0 {
+				return ret
+			}
+			if i == 0 {
+				ret &^= recursionMust
+			}
+			r |= ret
+		}
+	case ndAnchor:
+		if n.look != lookNone {
+			r = c.infiniteRecursiveCallCheck(n.body, head)
+		}
+	case ndCall:
+		r = c.infiniteRecursiveCallCheck(n.target, head)
+	case ndBag:
+		switch n.bag {
+		case bagMemory:
+			switch {
+			case n.mark2:
+				return 0
+			case n.mark1:
+				if head {
+					return recursionExist | recursionMust | recursionInfinite
+				}
+				return recursionExist | recursionMust
+			}
+			n.mark2 = true
+			r = c.infiniteRecursiveCallCheck(n.body, head)
+			n.mark2 = false
+		case bagIfElse:
+			ret := c.infiniteRecursiveCallCheck(n.body, head)
+			if ret&recursionInfinite != 0 {
+				return ret
+			}
+			r |= ret
+			if n.then != nil {
+				ret := c.infiniteRecursiveCallCheck(n.then, head && c.minByteLen(n.body) == 0)
+				if ret&recursionInfinite != 0 {
+					return ret
+				}
+				r |= ret
+			}
+			if n.els == nil {
+				r &^= recursionMust
+				break
+			}
+			ret = c.infiniteRecursiveCallCheck(n.els, head)
+			if ret&recursionInfinite != 0 {
+				return ret
+			}
+			r |= ret & recursionExist
+			if ret&recursionMust == 0 {
+				r &^= recursionMust
+			}
+		default:
+			r = c.infiniteRecursiveCallCheck(n.body, head)
+		}
+	}
+	return r
+}
+
+// infiniteRecursiveCallCheckTrav checks that the recursive groups that are called match something before calling
+// themselves again, and that they can end, as infinite_recursive_call_check_trav does.
+func (c *checker) infiniteRecursiveCallCheckTrav(n *inode) error {
+	switch n.typ {
+	case ndList, ndAlt, ndAbsent:
+		for _, kid := range n.kids {
+			if err := c.infiniteRecursiveCallCheckTrav(kid); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ndAnchor:
+		if n.look == lookNone {
+			return nil
+		}
+	case ndQuant:
+	case ndBag:
+		if n.bag == bagMemory && n.recursion && n.called {
+			n.mark1 = true
+			r := c.infiniteRecursiveCallCheck(n.body, true)
+			n.mark1 = false
+			if r&(recursionMust|recursionInfinite) != 0 {
+				return &Error{Code: ERR_NEVER_ENDING_RECURSION, Offset: n.span.Start}
+			}
+		}
+		for _, branch := range []*inode{n.then, n.els} {
+			if branch != nil {
+				if err := c.infiniteRecursiveCallCheckTrav(branch); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return nil
+	}
+	return c.infiniteRecursiveCallCheckTrav(n.body)
+}
+
+// minByteLen returns the length in bytes of the shortest text a node matches, as node_min_byte_len does.
+func (c *checker) minByteLen(n *inode) uint32 {
+	switch n.typ {
+	case ndBackref:
+		if n.checker || n.recursion {
+			return 0
+		}
+		var min uint32
+		for i, num := range n.refs {
+			if l := c.minByteLen(c.mems[num]); i == 0 || l < min {
+				min = l
+			}
+		}
+		return min
+	case ndCall:
+		if !n.recursion {
+			return c.minByteLen(n.target)
+		}
+		if n.target.fixedMin {
+			return n.target.minByteLen
+		}
+		return 0
+	case ndList:
+		var sum uint32
+		for _, kid := range n.kids {
+			sum = distanceAdd(sum, c.minByteLen(kid))
+		}
+		return sum
+	case ndAlt:
+		var min uint32
+		for i, kid := range n.kids {
+			if l := c.minByteLen(kid); i == 0 || l < min {
+				min = l
+			}
+		}
+		return min
+	case ndString:
+		if n.crude {
+			return uint32(len(n.chars))
+		}
+		return uint32(byteLen(n.chars))
+	case ndClass, ndCType, ndAnyChar:
+		return 1
+	case ndQuant:
+		if n.lower > 0 {
+			return distanceMultiply(c.minByteLen(n.body), n.lower)
+		}
+	case ndBag:
+		switch n.bag {
+		case bagMemory:
+			if !n.fixedMin {
+				if n.mark1 {
+					return 0
+				}
+				n.mark1 = true
+				n.minByteLen = c.minByteLen(n.body)
+				n.mark1 = false
+				n.fixedMin = true
+			}
+			return n.minByteLen
+		case bagIfElse:
+			min := c.minByteLen(n.body)
+			if n.then != nil {
+				min = distanceAdd(min, c.minByteLen(n.then))
+			}
+			var els uint32
+			if n.els != nil {
+				els = c.minByteLen(n.els)
+			}
+			if els < min {
+				min = els
+			}
+			return min
+		}
+		return c.minByteLen(n.body)
+	case ndAbsent:
+		if n.absent == AbsentExpression && len(n.kids) > 1 {
+			return c.minByteLen(n.kids[1])
+		}
+	}
+	return 0
+}
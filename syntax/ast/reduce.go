@@ -0,0 +1,156 @@
+package ast
+
+// Oniguruma reduces a quantifier of a quantifier while parsing, such as (?:a*)? into a*. The reductions change the
+// lengths the checks of look-behinds see, and multiplying fixed counts can overflow, so they are done the same way
+// here on the quantifiers of the tree the checks are done on.
+
+// Reductions of nested quantifiers, by the type of the inner and of the outer quantifier.
+const (
+	reduceAsIs = iota
+	reduceDel
+	reduceA
+	reduceAQ
+	reduceQQ
+	reducePQQ
+	reducePQQ2
+)
+
+// reduceTable is ReduceTypeTable of Oniguruma. The types are ?, *, + and their lazy versions.
+var reduceTable = [6][6]int{
+	{reduceDel, reduceA, reduceA, reduceQQ, reduceAQ, reduceAsIs},
+	{reduceDel, reduceDel, reduceDel, reducePQQ, reducePQQ, reduceDel},
+	{reduceA, reduceA, reduceDel, reduceAsIs, reducePQQ, reduceDel},
+	{reduceDel, reduceAQ, reduceAQ, reduceDel, reduceAQ, reduceAQ},
+	{reduceDel, reduceDel, reduceDel, reduceDel, reduceDel, reduceDel},
+	{reduceAsIs, reducePQQ2, reduceDel, reduceAQ, reduceAQ, reduceDel},
+}
+
+// quantifierType returns the type of a quantifier in reduceTable, or -1 when it has other counts.
+func quantifierType(q *inode) int {
+	t := -1
+	switch {
+	case q.lower == 0 && q.upper == 1:
+		t = 0
+	case q.lower == 0 && q.upper == repeatInfinite:
+		t = 1
+	case q.lower == 1 && q.upper == repeatInfinite:
+		t = 2
+	default:
+		return -1
+	}
+	if !q.greedy {
+		t += 3
+	}
+	return t
+}
+
+// assignQuantifierBody records a quantifier, reducing it with the quantifier it repeats.
+func (p *parser) assignQuantifierBody(q *Quantifier) error {
+	qn := &inode{typ: ndQuant, span: q.Span, lower: q.Min, upper: q.Max, greedy: q.Kind != Lazy,
+		possessive: q.Kind == Possessive}
+	p.qnodes[q] = qn
+	if q.Min == 1 && q.Max == 1 {
+		// x{1} is x.
+		qn.dropped = true
+		return nil
+	}
+	qn.body = &inode{typ: ndPending, span: q.Sub.Pos(), src: q.Sub}
+	target, ok := p.transparent(q.Sub, nil).(*Quantifier)
+	if !ok || p.qnodes[target].possessive {
+		return nil
+	}
+	inner := p.qnodes[target]
+	outerType, innerType := quantifierType(qn), quantifierType(inner)
+	if innerType >= 0 && outerType < 0 {
+		// (?:a*){n,m} and (?:a+){n,m} are (?:a*){n} and (?:a+){n}.
+		if (innerType == 1 || innerType == 2) && qn.upper != repeatInfinite && qn.upper > 1 && qn.greedy {
+			if qn.lower == 0 {
+				qn.upper = 1
+			} else {
+				qn.upper = qn.lower
+			}
+		}
+		return nil
+	}
+	qn.body = inner
+	if !reduceNestedQuantifier(qn, inner) {
+		return p.errorf(ERR_TOO_BIG_NUMBER_FOR_REPEAT_RANGE)
+	}
+	return nil
+}
+
+// transparent returns the node Oniguruma has in place of a node: the content of a non-capturing group, the
+// expressions after isolated options that continue into the following alternatives, or the body of a
+// quantifier that was dropped. Groups in uncaptured are capturing groups that don't capture anymore.
+func (p *parser) transparent(node Node, uncaptured map[*Group]bool) Node {
+	for {
+		switch n := node.(type) {
+		case *Group:
+			if n.Kind != GroupNonCapture || uncaptured[n] {
+				return node
+			}
+			node = n.Sub
+		case *Flags:
+			if !p.continued[n] {
+				return node
+			}
+			node = n.Sub
+		case *Quantifier:
+			if !p.qnodes[n].dropped {
+				return node
+			}
+			node = n.Sub
+		default:
+			return node
+		}
+	}
+}
+
+// reduceNestedQuantifier reduces the quantifier outer of the quantifier inner, as onig_reduce_nested_quantifier
+// does. It reports false when the multiplied counts are too big.
+func reduceNestedQuantifier(outer, inner *inode) bool {
+	outerType, innerType := quantifierType(outer), quantifierType(inner)
+	if outerType < 0 || innerType < 0 {
+		if outer.lower == outer.upper && inner.lower == inner.upper {
+			n, ok := multiplyRepeat(outer.lower, inner.lower)
+			if !ok {
+				return false
+			}
+			outer.lower, outer.upper = n, n
+			outer.body = inner.body
+		}
+		return true
+	}
+	switch reduceTable[innerType][outerType] {
+	case reduceDel:
+		outer.lower, outer.upper, outer.greedy = inner.lower, inner.upper, inner.greedy
+		outer.body = inner.body
+	case reduceA:
+		outer.lower, outer.upper, outer.greedy = 0, repeatInfinite, true
+		outer.body = inner.body
+	case reduceAQ:
+		outer.lower, outer.upper, outer.greedy = 0, repeatInfinite, false
+		outer.body = inner.body
+	case reduceQQ:
+		outer.lower, outer.upper, outer.greedy = 0, 1, false
+		outer.body = inner.body
+	case reducePQQ:
+		outer.lower, outer.upper, outer.greedy = 0, 1, false
+		inner.lower, inner.upper, inner.greedy = 1, repeatInfinite, true
+	case reducePQQ2:
+		outer.lower, outer.upper, outer.greedy = 0, 1, true
+		inner.lower, inner.upper, inner.greedy = 1, repeatInfinite, false
+	}
+	return true
+}
+
+// multiplyRepeat multiplies two counts of repetitions, as onig_positive_int_multiply does.
+func multiplyRepeat(x, y int) (int, bool) {
+	if x == 0 || y == 0 {
+		return 0, true
+	}
+	if x < (1<<31-1)/y {
+		return x * y, true
+	}
+	return 0, false
+}
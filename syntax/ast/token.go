@@ -0,0 +1,432 @@
+package ast
+
+// fetchToken reads the next token outside of a character class.
+func (p *parser) fetchToken() (*token, error) {
+	tok := &token{}
+	if p.pendingCodes != nil {
+		// The rest of a \x{...} sequence.
+		tok.kind = tokCodePoint
+		tok.start = p.pendingStart
+		tok.code = p.pendingCodes[0]
+		tok.base = 16
+		p.pendingCodes = p.pendingCodes[1:]
+		if len(p.pendingCodes) == 0 {
+			p.pendingCodes = nil
+		}
+		return tok, nil
+	}
+	op, op2, behavior := p.flavor.Op, p.flavor.Op2, p.flavor.Behavior
+start:
+	tok.start = p.pos
+	if p.end() {
+		tok.kind = tokEOT
+		return tok, nil
+	}
+	tok.kind = tokString
+	tok.backp = p.pos
+	c := p.fetch()
+	if c == '\\' {
+		if p.end() {
+			return nil, p.errorf(ERR_END_PATTERN_AT_ESCAPE)
+		}
+		tok.backp = p.pos
+		c = p.fetch()
+		tok.code = c
+		tok.escaped = true
+		switch c {
+		case 'w', 'W':
+			if op&SYN_OP_ESC_W_WORD != 0 {
+				tok.kind, tok.ctype, tok.negated = tokCharType, CharTypeWord, c == 'W'
+			}
+		case 's', 'S':
+			if op&SYN_OP_ESC_S_WHITE_SPACE != 0 {
+				tok.kind, tok.ctype, tok.negated = tokCharType, CharTypeSpace, c == 'S'
+			}
+		case 'd', 'D':
+			if op&SYN_OP_ESC_D_DIGIT != 0 {
+				tok.kind, tok.ctype, tok.negated = tokCharType, CharTypeDigit, c == 'D'
+			}
+		case 'h', 'H':
+			if op2&SYN_OP2_ESC_H_XDIGIT != 0 {
+				tok.kind, tok.ctype, tok.negated = tokCharType, CharTypeHexDigit, c == 'H'
+			}
+		case 'b', 'B':
+			if op&SYN_OP_ESC_B_WORD_BOUND != 0 {
+				tok.kind, tok.anchor = tokAnchor, AnchorWordBoundary
+				if c == 'B' {
+					tok.anchor = AnchorNotWordBoundary
+				}
+			}
+		case 'y', 'Y':
+			// Oniguruma has \y and \Y in every flavor, unlike \X.
+			tok.kind, tok.anchor = tokAnchor, AnchorTextSegmentBoundary
+			if c == 'Y' {
+				tok.anchor = AnchorNotTextSegmentBoundary
+			}
+		case '<', '>':
+			if op&SYN_OP_ESC_LTGT_WORD_BEGIN_END != 0 {
+				tok.kind, tok.anchor = tokAnchor, AnchorWordBegin
+				if c == '>' {
+					tok.anchor = AnchorWordEnd
+				}
+			}
+		case 'A':
+			if op&SYN_OP_ESC_AZ_BUF_ANCHOR != 0 {
+				tok.kind, tok.anchor = tokAnchor, AnchorBeginBuffer
+			}
+		case 'Z':
+			if op&SYN_OP_ESC_AZ_BUF_ANCHOR != 0 {
+				tok.kind, tok.anchor = tokAnchor, AnchorSemiEndBuffer
+				if behavior&SYN_PYTHON != 0 {
+					tok.anchor = AnchorEndBuffer
+				}
+			}
+		case 'z':
+			if op&SYN_OP_ESC_AZ_BUF_ANCHOR != 0 {
+				if behavior&SYN_PYTHON != 0 {
+					return nil, p.errorf(ERR_UNDEFINED_OPERATOR)
+				}
+				tok.kind, tok.anchor = tokAnchor, AnchorEndBuffer
+			}
+		case 'G':
+			if op&SYN_OP_ESC_CAPITAL_G_BEGIN_ANCHOR != 0 {
+				tok.kind, tok.anchor = tokAnchor, AnchorBeginPosition
+			}
+		case '`', '\'':
+			if op2&SYN_OP2_ESC_GNU_BUF_ANCHOR != 0 {
+				tok.kind, tok.anchor = tokAnchor, AnchorBeginBuffer
+				if c == '\'' {
+					tok.anchor = AnchorEndBuffer
+				}
+			}
+		case 'x':
+			if p.end() {
+				break
+			}
+			if p.peekIs('{') && op&SYN_OP_ESC_X_BRACE_HEX8 != 0 {
+				p.pos++
+				ok, err := p.fetchBraceCodePoint(tok, 16, false)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					p.queueCodes(tok)
+					break
+				}
+				// A brace that isn't a code point leaves the escaped letter.
+				p.pos--
+				break
+			}
+			if op&SYN_OP_ESC_X_HEX2 != 0 {
+				code, _, _ := p.scanBase(16, 2)
+				tok.kind = tokCrudeByte
+				tok.base = 16
+				tok.code = code
+			}
+		case 'o':
+			if p.end() {
+				break
+			}
+			if p.peekIs('{') && op&SYN_OP_ESC_O_BRACE_OCTAL != 0 {
+				p.pos++
+				ok, err := p.fetchBraceCodePoint(tok, 8, false)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					p.queueCodes(tok)
+					break
+				}
+				p.pos--
+			}
+		case 'u':
+			if p.end() {
+				break
+			}
+			if op2&SYN_OP2_ESC_U_HEX4 != 0 {
+				code, digits, _ := p.scanBase(16, 4)
+				if digits < 4 {
+					return nil, p.errorf(ERR_INVALID_CODE_POINT_VALUE)
+				}
+				tok.kind = tokCodePoint
+				tok.base = 16
+				tok.code = code
+			}
+		case 'U':
+			if p.end() {
+				break
+			}
+			if behavior&SYN_PYTHON != 0 {
+				code, digits, err := p.scanBase(16, 8)
+				if err != nil {
+					return nil, err
+				}
+				if digits < 8 {
+					return nil, p.errorf(ERR_INVALID_CODE_POINT_VALUE)
+				}
+				tok.kind = tokCodePoint
+				tok.base = 16
+				tok.code = code
+			}
+		case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			p.pos--
+			prev := p.pos
+			num := p.scanNumber()
+			if num >= 0 && num <= maxBackrefNum && op&SYN_OP_DECIMAL_BACKREF != 0 && (num <= p.captures || num <= 9) {
+				tok.kind = tokBackref
+				tok.refs = []int{num}
+				break
+			}
+			if c == '8' || c == '9' {
+				p.pos = prev + 1
+				break
+			}
+			p.pos = prev
+			p.fetchOctal(tok, c)
+		case '0':
+			p.fetchOctal(tok, c)
+		case 'p', 'P':
+			if p.peekIs('{') && op2&SYN_OP2_ESC_P_BRACE_CHAR_PROPERTY != 0 {
+				p.pos++
+				tok.kind = tokCharProperty
+				tok.negated = c == 'P'
+				if !p.end() && op2&SYN_OP2_ESC_P_BRACE_CIRCUMFLEX_NOT != 0 && p.peekIs('^') {
+					p.pos++
+					tok.negated = !tok.negated
+				}
+			}
+		case 'k':
+			if !p.end() && op2&SYN_OP2_ESC_K_NAMED_BACKREF != 0 {
+				if open, size := p.peek(); open == '<' || open == '\'' {
+					p.pos += size
+					if err := p.fetchNamedBackref(open, tok); err != nil {
+						return nil, err
+					}
+				}
+			}
+		case 'g':
+			if !p.end() && op2&SYN_OP2_ESC_G_SUBEXP_CALL != 0 {
+				if open, size := p.peek(); open == '<' || open == '\'' {
+					p.pos += size
+					name, kind, num, err := p.fetchName(open, true)
+					if err != nil {
+						return nil, err
+					}
+					if kind != refName {
+						if kind == refRelative {
+							num = p.relativeToAbsolute(num)
+							if num < 0 || num > maxCaptureNum {
+								return nil, p.errorf(ERR_UNDEFINED_GROUP_REFERENCE)
+							}
+						}
+						tok.byNumber = true
+						tok.group = num
+					}
+					tok.kind = tokCall
+					tok.name = name
+				}
+			}
+		case 'Q':
+			if op2&SYN_OP2_ESC_CAPITAL_Q_QUOTE != 0 {
+				tok.kind = tokQuoteOpen
+			}
+		case 'K':
+			if op2&SYN_OP2_ESC_CAPITAL_K_KEEP != 0 {
+				tok.kind = tokKeep
+			}
+		case 'R':
+			if op2&SYN_OP2_ESC_CAPITAL_R_GENERAL_NEWLINE != 0 {
+				tok.kind = tokGeneralNewline
+			}
+		case 'N':
+			if op2&SYN_OP2_ESC_CAPITAL_N_O_SUPER_DOT != 0 {
+				tok.kind = tokNoNewline
+			}
+		case 'O':
+			if op2&SYN_OP2_ESC_CAPITAL_N_O_SUPER_DOT != 0 {
+				tok.kind = tokTrueAnyChar
+			}
+		case 'X':
+			if op2&SYN_OP2_ESC_X_Y_TEXT_SEGMENT != 0 {
+				tok.kind = tokTextSegment
+			}
+		default:
+			p.pos = tok.backp
+			value, err := p.fetchEscapedValue()
+			if err != nil {
+				return nil, err
+			}
+			if value != c {
+				tok.kind = tokCodePoint
+				tok.code = value
+			} else {
+				_, size := p.charAt(tok.backp)
+				p.pos = tok.backp + size
+			}
+		}
+		return tok, nil
+	}
+
+	tok.code = c
+	switch c {
+	case '.':
+		if op&SYN_OP_DOT_ANYCHAR != 0 {
+			tok.kind = tokAnyChar
+		}
+	case '*', '+', '?':
+		if (c == '*' && op&SYN_OP_ASTERISK_ZERO_INF != 0) || (c == '+' && op&SYN_OP_PLUS_ONE_INF != 0) ||
+			(c == '?' && op&SYN_OP_QMARK_ZERO_ONE != 0) {
+			tok.kind = tokRepeat
+			tok.lower, tok.upper = 0, -1
+			if c == '+' {
+				tok.lower = 1
+			} else if c == '?' {
+				tok.upper = 1
+			}
+			p.greedyCheck(tok)
+		}
+	case '{':
+		if op&SYN_OP_BRACE_INTERVAL != 0 {
+			ok, err := p.fetchInterval(tok)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				if tok.fixed && behavior&SYN_FIXED_INTERVAL_IS_GREEDY_ONLY != 0 {
+					p.possessiveCheck(tok)
+				} else {
+					p.greedyCheck(tok)
+				}
+			}
+		}
+	case '|':
+		if op&SYN_OP_VBAR_ALT != 0 {
+			tok.kind = tokAlt
+		}
+	case '(':
+		if p.peekIs('?') && op2&SYN_OP2_QMARK_GROUP_EFFECT != 0 {
+			question := p.pos
+			p.pos++
+			if next, _ := p.peek(); next == '#' {
+				p.pos++
+				for {
+					if p.end() {
+						return nil, p.errorf(ERR_END_PATTERN_IN_GROUP)
+					}
+					c = p.fetch()
+					if c == '\\' {
+						if !p.end() {
+							p.fetch()
+						}
+					} else if c == ')' {
+						break
+					}
+				}
+				goto start
+			} else if op2&SYN_OP2_QMARK_PERL_SUBEXP_CALL != 0 {
+				switch {
+				case next == '&':
+					// Oniguruma checks the name, and then parses the group as a capturing group
+					// starting with the ampersand.
+					p.pos++
+					amp := p.pos - 1
+					if _, _, _, err := p.fetchName('(', false); err != nil {
+						return nil, err
+					}
+					p.pos = amp
+					tok.perlCall = true
+				case next == 'R':
+					// Oniguruma parses (?R) as a capturing group starting with the R too.
+					p.pos++
+					if !p.peekIs(')') {
+						return nil, p.errorf(ERR_UNDEFINED_GROUP_OPTION)
+					}
+					p.pos--
+					tok.perlCall = true
+				case next == '-' || next == '+' || isDigit(next):
+					_, kind, num, err := p.fetchName('(', true)
+					if err != nil {
+						return nil, err
+					}
+					switch {
+					case kind == refName:
+						return nil, p.errorf(ERR_INVALID_GROUP_NAME)
+					case kind == refRelative && p.relativeToAbsolute(num) < 0:
+						return nil, p.errorf(ERR_UNDEFINED_GROUP_REFERENCE)
+					}
+					// Oniguruma checks the number, and then parses the group as a group of options.
+					p.pos = question
+				default:
+					p.pos = question
+				}
+			} else {
+				p.pos = question
+			}
+		}
+		if op&SYN_OP_LPAREN_SUBEXP != 0 {
+			tok.kind = tokOpen
+		}
+	case ')':
+		if op&SYN_OP_LPAREN_SUBEXP != 0 {
+			tok.kind = tokClose
+		}
+	case '^', '$':
+		if op&SYN_OP_LINE_ANCHOR != 0 {
+			tok.kind = tokAnchor
+			singleline := p.options&OPTION_SINGLELINE != 0
+			switch {
+			case c == '^' && singleline:
+				tok.anchor = AnchorBeginBuffer
+			case c == '^':
+				tok.anchor = AnchorBeginLine
+			case singleline:
+				tok.anchor = AnchorSemiEndBuffer
+			default:
+				tok.anchor = AnchorEndLine
+			}
+		}
+	case '[':
+		if op&SYN_OP_BRACKET_CC != 0 {
+			tok.kind = tokOpenClass
+		}
+	case '#':
+		if p.options&OPTION_EXTEND != 0 {
+			for !p.end() {
+				if p.fetch() == '\n' {
+					break
+				}
+			}
+			goto start
+		}
+	case ' ', '\t', '\n', '\r', '\f':
+		if p.options&OPTION_EXTEND != 0 {
+			goto start
+		}
+	}
+	return tok, nil
+}
+
+// fetchOctal reads an octal escape such as \012, with the position at the first digit or after a \0.
+func (p *parser) fetchOctal(tok *token, c rune) {
+	if p.flavor.Op&SYN_OP_ESC_OCTAL3 != 0 {
+		maxDigits := 3
+		if c == '0' {
+			maxDigits = 2
+		}
+		code, _, _ := p.scanBase(8, maxDigits)
+		tok.kind = tokCrudeByte
+		tok.base = 8
+		tok.code = code & 0xff
+	} else if c != '0' {
+		p.pos++
+	}
+}
+
+// queueCodes keeps the code points after the first one of a \x{...} sequence, to be returned as the next tokens.
+func (p *parser) queueCodes(tok *token) {
+	if len(tok.codes) > 0 {
+		p.pendingCodes = tok.codes
+		p.pendingStart = tok.start
+		tok.codes = nil
+	}
+}
@@ -0,0 +1,311 @@
+package ast
+
+import (
+	"math"
+	"unicode/utf8"
+)
+
+// Oniguruma tunes the tree before compiling it: it expands case insensitive strings, turns repetitions followed
+// by something they can't match into atomic groups, and checks look-behinds on the result. Only the parts of the
+// tuning that can make a pattern invalid are done here.
+
+// infiniteLen is a length without upper bound.
+const infiniteLen = math.MaxUint32
+
+// expandStringMaxLength is the longest string that a fixed repetition of a string is expanded to.
+const expandStringMaxLength = 100
+
+// inLookBehind is the state of the tuning inside a look-behind.
+const inLookBehind = 1
+
+// tune tunes a node, as tune_tree does.
+func (c *checker) tune(n *inode, state int) error {
+	switch n.typ {
+	case ndList:
+		for i, kid := range n.kids {
+			if err := c.tune(kid, state); err != nil {
+				return err
+			}
+			if i > 0 {
+				tuneNext(n.kids[i-1], kid)
+			}
+		}
+	case ndAlt:
+		for _, kid := range n.kids {
+			if err := c.tune(kid, state); err != nil {
+				return err
+			}
+		}
+	case ndString:
+		switch {
+		case !n.ignoreCase || n.tuned:
+		case n.crude:
+			// Raw bytes aren't folded.
+			n.tuned = true
+			n.minCharLen, n.maxCharLen = uint32(len(n.chars)), uint32(len(n.chars))
+		default:
+			c.unravelCaseFold(n, state)
+		}
+	case ndBag:
+		if err := c.tune(n.body, state); err != nil {
+			return err
+		}
+		for _, branch := range []*inode{n.then, n.els} {
+			if branch != nil {
+				if err := c.tune(branch, state); err != nil {
+					return err
+				}
+			}
+		}
+	case ndQuant:
+		return c.tuneQuant(n, state)
+	case ndAnchor:
+		switch n.look {
+		case lookAhead, lookAheadNot:
+			return c.tune(n.body, state)
+		case lookBehind, lookBehindNot:
+			return c.tuneLookBehind(n, state)
+		}
+	case ndAbsent:
+		for _, kid := range n.kids {
+			if err := c.tune(kid, state); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unravelCaseFold expands a case insensitive string. In a look-behind, each character with case fold equivalents
+// of one character becomes a class. Elsewhere the string is kept, and matches strings of different lengths when
+// some of its characters fold to several characters.
+func (c *checker) unravelCaseFold(n *inode, state int) {
+	if len(n.chars) == 0 {
+		return
+	}
+	if state&inLookBehind == 0 {
+		n.tuned = true
+		n.minCharLen, n.maxCharLen = c.caseFoldCharLen(n.chars)
+		return
+	}
+	var parts []*inode
+	var str *inode
+	for _, ch := range n.chars {
+		if !hasCaseFold(ch, c.icASCII) {
+			if str == nil {
+				str = &inode{typ: ndString, span: n.span}
+				parts = append(parts, str)
+			}
+			str.chars = append(str.chars, ch)
+			continue
+		}
+		set := &charSet{}
+		set.addCode(ch)
+		for r := caseFoldNext(ch, c.icASCII); r != ch; r = caseFoldNext(r, c.icASCII) {
+			set.addCode(r)
+		}
+		parts = append(parts, &inode{typ: ndClass, span: n.span, set: set})
+		str = nil
+	}
+	if len(parts) == 1 {
+		*n = *parts[0]
+		return
+	}
+	*n = inode{typ: ndList, span: n.span, kids: parts}
+}
+
+// caseFoldCharLen returns the lengths in characters of the strings a case insensitive string matches.
+func (c *checker) caseFoldCharLen(chars []rune) (uint32, uint32) {
+	if c.icASCII {
+		return uint32(len(chars)), uint32(len(chars))
+	}
+	var max uint32
+	for _, ch := range chars {
+		if fold, ok := multiCharFolds[ch]; ok {
+			max += uint32(utf8.RuneCountInString(fold))
+		} else {
+			max++
+		}
+	}
+	// Characters that are the case fold of a single character can match that character.
+	min := make([]uint32, len(chars)+1)
+	for i := range chars {
+		min[i+1] = min[i] + 1
+		for w := 2; w <= 3 && w <= i+1; w++ {
+			if isMultiCharFoldTarget(chars[i+1-w:i+1], false) && min[i+1-w]+1 < min[i+1] {
+				min[i+1] = min[i+1-w] + 1
+			}
+		}
+	}
+	return min[len(chars)], max
+}
+
+// tuneQuant tunes a quantifier, as tune_quant does. A fixed repetition of a short string becomes a string.
+func (c *checker) tuneQuant(n *inode, state int) error {
+	if err := c.tune(n.body, state); err != nil {
+		return err
+	}
+	body := n.body
+	if body.typ == ndString && n.lower == n.upper && n.lower > 1 && n.lower <= expandStringMaxLength &&
+		byteLen(body.chars)*n.lower <= expandStringMaxLength {
+		str := *body
+		str.span = n.span
+		str.chars = nil
+		for i := 0; i < n.lower; i++ {
+			str.chars = append(str.chars, body.chars...)
+		}
+		str.minCharLen *= uint32(n.lower)
+		str.maxCharLen *= uint32(n.lower)
+		*n = str
+	}
+	return nil
+}
+
+// tuneNext makes a greedy repetition atomic when what follows it can't start with what it repeats, as
+// tune_next does.
+func tuneNext(n, next *inode) {
+	for n.typ == ndBag && n.bag == bagMemory {
+		n = n.body
+	}
+	if n.typ != ndQuant || !n.greedy || n.upper != repeatInfinite || n.lower > 1 || !isStrictReal(n.body) {
+		return
+	}
+	x := headLiteral(n.body)
+	if x == nil {
+		return
+	}
+	if y := headLiteral(next); y != nil && isExclusive(x, y) {
+		quant := *n
+		*n = inode{typ: ndBag, bag: bagStopBacktrack, span: quant.span, body: &quant}
+	}
+}
+
+// isStrictReal reports whether a node matches exactly one character or a non-empty string.
+func isStrictReal(n *inode) bool {
+	switch n.typ {
+	case ndString:
+		return len(n.chars) > 0
+	case ndClass, ndCType, ndAnyChar:
+		return true
+	}
+	return false
+}
+
+// headLiteral returns the string, class or character type a node starts with, or nil, as
+// get_tree_head_literal does.
+func headLiteral(n *inode) *inode {
+	switch n.typ {
+	case ndClass, ndCType:
+		return n
+	case ndString:
+		if len(n.chars) > 0 {
+			return n
+		}
+	case ndList:
+		return headLiteral(n.kids[0])
+	case ndQuant:
+		if n.lower > 0 {
+			return headLiteral(n.body)
+		}
+	case ndBag:
+		return headLiteral(n.body)
+	case ndAnchor:
+		if n.look == lookAhead {
+			return headLiteral(n.body)
+		}
+	}
+	return nil
+}
+
+// isExclusive reports whether two head literals can't match the same character, as is_exclusive does.
+func isExclusive(x, y *inode) bool {
+	switch x.typ {
+	case ndCType:
+		switch y.typ {
+		case ndCType:
+			return x.not != y.not && x.ascii == y.ascii
+		case ndClass, ndString:
+			return isExclusive(y, x)
+		}
+	case ndClass:
+		switch y.typ {
+		case ndCType:
+			return classExcludesWord(x.set, y)
+		case ndClass:
+			return classesExclusive(x.set, y.set)
+		case ndString:
+			return isExclusive(y, x)
+		}
+	case ndString:
+		ch := x.chars[0]
+		switch y.typ {
+		case ndCType:
+			word := isWord(ch)
+			if y.ascii {
+				word = isASCIIWord(ch)
+			}
+			return word == y.not
+		case ndClass:
+			return !y.set.contains(ch)
+		case ndString:
+			xs, ys := string(x.chars), string(y.chars)
+			for i := 0; i < len(xs) && i < len(ys); i++ {
+				if xs[i] != ys[i] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// classExcludesWord reports whether a class is exclusive with \w or \W.
+func classExcludesWord(s *charSet, word *inode) bool {
+	limit := rune(256)
+	if word.ascii {
+		limit = 128
+	}
+	if !word.not {
+		if s.mb != nil || s.not {
+			return false
+		}
+		for c := rune(0); c < limit; c++ {
+			if s.bit(c) && isWord(c) {
+				return false
+			}
+		}
+		return true
+	}
+	if s.mb != nil || s.not {
+		return false
+	}
+	for c := rune(0); c < 256; c++ {
+		if s.bit(c) && (c >= limit || !isWord(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// classesExclusive reports whether two classes are exclusive, looking at their single byte characters only.
+func classesExclusive(x, y *charSet) bool {
+	for c := rune(0); c < 256; c++ {
+		if x.bit(c) != x.not && y.bit(c) != y.not {
+			return false
+		}
+	}
+	return x.mb == nil && !x.not || y.mb == nil && !y.not
+}
+
+// byteLen returns the length of characters in UTF-8.
+func byteLen(chars []rune) int {
+	n := 0
+	for _, c := range chars {
+		if l := utf8.RuneLen(c); l > 0 {
+			n += l
+		} else {
+			n++
+		}
+	}
+	return n
+}
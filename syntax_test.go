@@ -0,0 +1,135 @@
+package onig
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSyntax_Name(t *testing.T) {
+	assert.Equal(t, "ruby", SyntaxRuby.Name())
+	assert.Same(t, SyntaxRuby, SyntaxDefault)
+	assert.Equal(t, "posix_extended", SyntaxPosixExtended.Name())
+	assert.Equal(t, "perl_ng", SyntaxPerlNG.Name())
+	custom := *SyntaxJava
+	assert.Equal(t, "", custom.Name())
+}
+
+func TestSyntaxNames(t *testing.T) {
+	assert.Equal(t, []string{
+		"asis",
+		"emacs",
+		"gnu_regex",
+		"grep",
+		"java",
+		"oniguruma",
+		"perl",
+		"perl_ng",
+		"posix_basic",
+		"posix_extended",
+		"python",
+		"ruby",
+	}, SyntaxNames())
+}
+
+func TestSyntaxByName_RoundTrip(t *testing.T) {
+	for _, name := range SyntaxNames() {
+		syntax, ok := SyntaxByName(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, name, syntax.Name())
+
+		r, err := CompileWithOptionsAndSyntax(`a`, REGEX_OPTION_IGNORECASE, syntax)
+		assert.NoError(t, err, name)
+		data, err := json.Marshal(r)
+		assert.NoError(t, err, name)
+		var decoded Regex
+		assert.NoError(t, json.Unmarshal(data, &decoded), name)
+		assert.Same(t, syntax, decoded.Syntax(), name)
+	}
+}
+
+func TestSyntaxByName_Aliases(t *testing.T) {
+	syntax, ok := SyntaxByName("PCRE")
+	assert.True(t, ok)
+	assert.Same(t, SyntaxPerlNG, syntax)
+	syntax, ok = SyntaxByName("Default")
+	assert.True(t, ok)
+	assert.Same(t, SyntaxRuby, syntax)
+	syntax, ok = SyntaxByName("Perl_NG")
+	assert.True(t, ok)
+	assert.Same(t, SyntaxPerlNG, syntax)
+	_, ok = SyntaxByName("cobol")
+	assert.False(t, ok)
+}
+
+func TestRegisterSyntax(t *testing.T) {
+	t.Cleanup(func() {
+		syntaxRegistry.Lock()
+		defer syntaxRegistry.Unlock()
+		delete(syntaxRegistry.names, syntaxRegistry.syntaxes["test_java_go"])
+		delete(syntaxRegistry.syntaxes, "test_java_go")
+		delete(syntaxRegistry.aliases, "test_js")
+	})
+	custom := *SyntaxJava
+	custom.SplitOptions = SplitOptions{LimitStyle: SplitLimitGo}
+	assert.Equal(t, "", custom.Name())
+	_, err := json.Marshal(MustCompileWithSyntax(`,`, &custom))
+	assert.Error(t, err)
+	assert.NoError(t, RegisterSyntax("Test_Java_Go", &custom))
+	assert.Equal(t, "test_java_go", custom.Name())
+	assert.Equal(t, "java", SyntaxJava.Name())
+	assert.NoError(t, RegisterSyntaxAlias("test_js", "test_java_go"))
+
+	syntax, ok := SyntaxByName("TEST_JS")
+	assert.True(t, ok)
+	assert.Same(t, &custom, syntax)
+	assert.Contains(t, SyntaxNames(), "test_java_go")
+
+	assert.ErrorIs(t, RegisterSyntax("test_java_go", SyntaxPerl), ErrSyntaxRegistered)
+	assert.ErrorIs(t, RegisterSyntax("test_js", SyntaxPerl), ErrSyntaxRegistered)
+	assert.ErrorIs(t, RegisterSyntax("java", SyntaxPerl), ErrSyntaxRegistered)
+	assert.Error(t, RegisterSyntax("test_again", &custom))
+	assert.Error(t, RegisterSyntax("test_empty", &Syntax{}))
+	assert.ErrorIs(t, RegisterSyntaxAlias("ruby", "perl"), ErrSyntaxRegistered)
+	assert.Error(t, RegisterSyntaxAlias("test_alias", "cobol"))
+
+	r := MustCompileWithSyntax(`,`, &custom)
+	data, err := json.Marshal(r)
+	assert.NoError(t, err)
+	var decoded Regex
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Same(t, &custom, decoded.Syntax())
+	assert.Equal(t, []string{"a", "b,c"}, decoded.MustSplitN("a,b,c", 2))
+}
+
+func TestSetDefaultSyntax(t *testing.T) {
+	defer SetDefaultSyntax(DefaultSyntax())
+	assert.Same(t, SyntaxRuby, DefaultSyntax())
+	assert.Same(t, SyntaxRuby, MustCompile(`a`).Syntax())
+
+	assert.NoError(t, SetDefaultSyntax(SyntaxPython))
+	r := MustCompile(`(?P<x>a)`)
+	assert.Same(t, SyntaxPython, r.Syntax())
+	text, err := r.MarshalText()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"pattern":"(?P<x>a)","syntax":"python","encoding":"utf-8"}`, string(text))
+
+	// The text of a regex doesn't depend on the default syntax of the process that reads it.
+	assert.NoError(t, SetDefaultSyntax(SyntaxRuby))
+	var decoded Regex
+	assert.NoError(t, decoded.UnmarshalText(text))
+	assert.Same(t, SyntaxPython, decoded.Syntax())
+
+	text, err = MustCompile(`a+`).MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, `a+`, string(text))
+	assert.NoError(t, SetDefaultSyntax(SyntaxPython))
+	assert.NoError(t, decoded.UnmarshalText(text))
+	assert.Same(t, SyntaxRuby, decoded.Syntax())
+}
+
+func TestSetDefaultSyntax_Invalid(t *testing.T) {
+	assert.Error(t, SetDefaultSyntax(nil))
+	assert.Error(t, SetDefaultSyntax(&Syntax{}))
+	assert.Same(t, SyntaxRuby, DefaultSyntax())
+}
@@ -0,0 +1,97 @@
+package onig
+
+import (
+	"fmt"
+)
+
+// Template is a replacement pattern compiled for a specific Regex.
+//
+// The replacement pattern is parsed only once, using the grammar of the regex's syntax,
+// and every group it refers to is checked against the regex when the template is compiled.
+// A Template can be used to expand any number of matches of its regex.
+type Template struct {
+	regex *Regex
+	parts []replacementPart
+	// replacer is used instead of parts when the syntax's RegexReplacer can't be compiled ahead of time.
+	replacer RegexReplacer
+}
+
+// templateCompiler is implemented by the RegexReplacers that can pre-parse their replacement pattern
+// into parts validated against a regex.
+type templateCompiler interface {
+	compileTemplate(regex *Regex) ([]replacementPart, error)
+}
+
+// CompileTemplate parses the replacement string using the ReplacerFactory of the regex's syntax.
+// An error is returned if the replacement is malformed or refers to a group that doesn't exist in the regex.
+// If the syntax doesn't have a ReplacerFactory, the replacement is used literally.
+func (r *Regex) CompileTemplate(replacement string) (*Template, error) {
+	template := &Template{regex: r}
+	if r.syntax == nil || r.syntax.ReplacerFactory == nil {
+		if replacement != "" {
+			template.parts = []replacementPart{{kind: replacementLiteral, text: replacement}}
+		}
+		return template, nil
+	}
+	replacer := r.syntax.ReplacerFactory(replacement)
+	compiler, ok := replacer.(templateCompiler)
+	if !ok {
+		template.replacer = replacer
+		return template, nil
+	}
+	parts, err := compiler.compileTemplate(r)
+	if err != nil {
+		return nil, err
+	}
+	template.parts = parts
+	return template, nil
+}
+
+// MustCompileTemplate parses the replacement string using the ReplacerFactory of the regex's syntax.
+// Compared to CompileTemplate, this method panics on error.
+func (r *Regex) MustCompileTemplate(replacement string) *Template {
+	template, err := r.CompileTemplate(replacement)
+	if err != nil {
+		panic(err)
+	}
+	return template
+}
+
+// Expand appends the replacement for the match described by captures to dst and returns the result.
+func (t *Template) Expand(dst []byte, captures *Captures) ([]byte, error) {
+	if t.replacer != nil {
+		replaced, err := t.replacer.Replace(captures)
+		if err != nil {
+			return dst, err
+		}
+		return append(dst, replaced...), nil
+	}
+	return appendReplacementParts(dst, t.parts, captures), nil
+}
+
+// Replace returns the replacement for the match described by captures.
+// It allows a Template to be used as a RegexReplacer or a ReplacementFunc.
+func (t *Template) Replace(captures *Captures) (string, error) {
+	if t.replacer != nil {
+		return t.replacer.Replace(captures)
+	}
+	return string(appendReplacementParts(nil, t.parts, captures)), nil
+}
+
+// validateReplacementParts checks that every group referred to by parts exists in regex.
+func validateReplacementParts(parts []replacementPart, regex *Regex) error {
+	groupCount := regex.NumberOfCaptures()
+	for _, part := range parts {
+		switch part.kind {
+		case replacementGroup:
+			if part.group < 0 || part.group > groupCount {
+				return fmt.Errorf("invalid group reference: %d", part.group)
+			}
+		case replacementNamedGroup:
+			if regex.GetGroupNumbersForGroupName(part.text) == nil {
+				return fmt.Errorf("undefined group name reference: %s", part.text)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package onig
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRegex_CompileTemplate(t *testing.T) {
+	r := MustCompile(`(?<user>\w+)@(?<host>\w+)`)
+	template, err := r.CompileTemplate(`\k<host> at \1`)
+	assert.NoError(t, err)
+	captures := r.MustCaptures("mail user@example now")
+	expanded, err := template.Expand([]byte("> "), captures)
+	assert.NoError(t, err)
+	assert.Equal(t, "> example at user", string(expanded))
+	replaced, err := template.Replace(captures)
+	assert.NoError(t, err)
+	assert.Equal(t, "example at user", replaced)
+}
+
+func TestRegex_CompileTemplate_InvalidReferences(t *testing.T) {
+	r := MustCompile(`(?<name>\w+)`)
+	_, err := r.CompileTemplate(`\k<nmae>`)
+	assert.EqualError(t, err, "undefined group name reference: nmae")
+	_, err = r.CompileTemplate(`\2`)
+	assert.EqualError(t, err, "invalid group reference: 2")
+	_, err = r.CompileTemplate(`\k<-1>`)
+	assert.EqualError(t, err, "invalid group reference: -1")
+	_, err = r.ReplaceAll("hello", `\k<nmae>`)
+	assert.Error(t, err)
+
+	r = MustCompileWithSyntax(`(\w+)`, SyntaxJava)
+	_, err = r.CompileTemplate(`$2`)
+	assert.EqualError(t, err, "no group 2")
+
+	r = MustCompileWithSyntax(`(\w+)`, SyntaxPerl)
+	_, err = r.CompileTemplate(`${name}`)
+	assert.EqualError(t, err, "undefined group name reference: name")
+}
+
+func TestRegex_CompileTemplate_DuplicateNames(t *testing.T) {
+	r := MustCompile(`(?<n>a)|(?<n>b)`)
+	assert.Equal(t, map[string][]int{"n": {1, 2}}, r.CaptureNamesWithIndices())
+	template := r.MustCompileTemplate(`[\k<n>]`)
+	replaced, err := template.Replace(r.MustCaptures("b"))
+	assert.NoError(t, err)
+	assert.Equal(t, "[b]", replaced)
+}
+
+func TestRegex_CompileTemplate_Literal(t *testing.T) {
+	r := MustCompileWithSyntax(`a.c`, SyntaxAsis)
+	template, err := r.CompileTemplate(`\1 $1`)
+	assert.NoError(t, err)
+	replaced, err := template.Replace(r.MustCaptures("a.c"))
+	assert.NoError(t, err)
+	assert.Equal(t, `\1 $1`, replaced)
+}
+
+type upperReplacer struct{}
+
+func (upperReplacer) Replace(captures *Captures) (string, error) {
+	return strings.ToUpper(captures.At(0)), nil
+}
+
+func TestRegex_CompileTemplate_CustomReplacer(t *testing.T) {
+	syntax := &Syntax{
+		ReplacerFactory: func(string) RegexReplacer { return upperReplacer{} },
+		raw:             SyntaxRuby.raw,
+	}
+	r := MustCompileWithSyntax(`[a-z]+`, syntax)
+	template, err := r.CompileTemplate("ignored")
+	assert.NoError(t, err)
+	expanded, err := template.Expand(nil, r.MustCaptures("abc"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABC", string(expanded))
+	assert.Equal(t, "ABC DEF", r.MustReplaceAll("abc def", "ignored"))
+}